@@ -6,11 +6,15 @@ import (
 
 	"github.com/GGP1/adak/cmd/server"
 	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/internal/email"
 	"github.com/GGP1/adak/internal/logger"
 	"github.com/GGP1/adak/pkg/http/rest"
 	"github.com/GGP1/adak/pkg/memcached"
 	"github.com/GGP1/adak/pkg/postgres"
 	"github.com/GGP1/adak/pkg/redis"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/payment"
 
 	_ "github.com/lib/pq"
 	"github.com/spf13/viper"
@@ -47,7 +51,30 @@ func main() {
 	}
 	defer rdb.Close()
 
-	router := rest.NewRouter(conf, db, mc, rdb)
+	// Release the stock reserved by carts that were left idle
+	sweeper := inventory.NewSweeper(db, conf.Inventory)
+	go sweeper.Run(ctx)
+
+	// Delete the guest carts that weren't used for a long time
+	cartSweeper := cart.NewSweeper(db, conf.Cart)
+	go cartSweeper.Run(ctx)
+
+	// Remind the users about the carts they left without ordering
+	emailer := email.New()
+	cartReminder := cart.NewReminder(db, &emailer, conf.Cart)
+	go cartReminder.Run(ctx)
+
+	provider, err := payment.NewProvider(conf.Payment, conf.Stripe, conf.Development)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	services := rest.NewServices(conf, db, mc, provider)
+	router := rest.NewRouter(conf, services, db, mc, rdb, provider)
+
+	// Place the orders of the subscriptions that are due
+	go services.Scheduler.Run(ctx)
+
 	srv := server.New(conf, router)
 
 	if err := srv.Start(ctx); err != nil {
@@ -9,6 +9,7 @@ import (
 	"github.com/GGP1/adak/cmd/server"
 	"github.com/GGP1/adak/internal/config"
 	"github.com/GGP1/adak/pkg/http/rest"
+	"github.com/GGP1/adak/pkg/shopping/payment"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,7 +20,9 @@ func TestServer(t *testing.T) {
 			Port: "61111",
 		},
 	}
-	srv := server.New(c, rest.NewRouter(c, nil, nil, nil))
+	provider := payment.NewFake(0)
+	router := rest.NewRouter(c, rest.NewServices(c, nil, nil, provider), nil, nil, nil, provider)
+	srv := server.New(c, router)
 	ctx := context.Background()
 
 	go func() {
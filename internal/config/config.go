@@ -18,15 +18,42 @@ type Config struct {
 	Admins      []string
 	Development bool
 
-	Email       Email
-	Memcached   Memcached
-	Postgres    Postgres
-	RateLimiter RateLimiter
-	Redis       Redis
-	Server      Server
-	Session     Session
-	Static      Static
-	Stripe      Stripe
+	Cart         Cart
+	Currency     Currency
+	Email        Email
+	Idempotency  Idempotency
+	Inventory    Inventory
+	Memcached    Memcached
+	Payment      Payment
+	Postgres     Postgres
+	RateLimiter  RateLimiter
+	Redis        Redis
+	Server       Server
+	Session      Session
+	Static       Static
+	Stripe       Stripe
+	Subscription Subscription
+}
+
+// Cart holds the carts configuration.
+type Cart struct {
+	// GuestTTL is the time a guest cart is kept without activity
+	GuestTTL time.Duration
+	// MergePolicy decides the quantity of the products that are both in the guest
+	// and the user cart when logging in, "sum" adds them and "latest" keeps the last updated
+	MergePolicy string
+	// ReminderAfter is the time a user cart must be left unchanged to send a reminder
+	ReminderAfter time.Duration
+	// ReminderInterval is how often the abandoned carts are searched
+	ReminderInterval time.Duration
+	// SweepInterval is how often the idle guest carts are deleted
+	SweepInterval time.Duration
+}
+
+// Currency holds the currencies configuration.
+type Currency struct {
+	// Base is the ISO 4217 code of the currency the products are priced in
+	Base string
 }
 
 // Email holds email attributes.
@@ -37,11 +64,33 @@ type Email struct {
 	Password string
 }
 
+// Idempotency holds the idempotency keys configuration.
+type Idempotency struct {
+	// TTL is the time the response to a request with an idempotency key is kept to replay it
+	TTL time.Duration
+}
+
+// Inventory holds the stock reservation configuration.
+type Inventory struct {
+	// HoldTTL is the time a product is reserved after being added to a cart
+	HoldTTL time.Duration
+	// SweepInterval is how often the expired holds are released
+	SweepInterval time.Duration
+}
+
 // Memcached is the LRU-cache configuration.
 type Memcached struct {
 	Servers []string
 }
 
+// Payment holds the payments configuration.
+type Payment struct {
+	// Provider is "stripe" or "fake", which is only allowed in development. If empty the fake one is used in development
+	Provider string
+	// FakeDelay is the time the fake provider takes to process the payments
+	FakeDelay time.Duration
+}
+
 // Postgres hols the database attributes.
 type Postgres struct {
 	Username string
@@ -91,10 +140,22 @@ type Static struct {
 	FS embed.FS
 }
 
+// Subscription holds the recurring orders configuration.
+type Subscription struct {
+	// Interval is how often the subscriptions due are searched
+	Interval time.Duration
+	// MaxRetries is the number of failed attempts after which a subscription is paused
+	MaxRetries int
+	// RetryAfter is the time waited after the first failure, it grows with each one
+	RetryAfter time.Duration
+}
+
 // Stripe hold stripe attributes
 type Stripe struct {
 	SecretKey string
-	Logger    struct {
+	// WebhookSecret is the signing secret used to verify the webhook events
+	WebhookSecret string
+	Logger        struct {
 		Level stripe.Level
 	}
 }
@@ -170,6 +231,14 @@ var (
 	defaults = map[string]interface{}{
 		// Admins
 		"admins": []string{},
+		// Cart
+		"cart.guestttl":         "72h",
+		"cart.mergepolicy":      "sum",
+		"cart.reminderafter":    "24h",
+		"cart.reminderinterval": "1h",
+		"cart.sweepinterval":    "1h",
+		// Currency
+		"currency.base": "USD",
 		// Development
 		"development": true,
 		// Email
@@ -181,8 +250,16 @@ var (
 		// Google
 		"google.client.id":     "id",
 		"google.client.secret": "secret",
+		// Idempotency
+		"idempotency.ttl": "24h",
+		// Inventory
+		"inventory.holdttl":       "15m",
+		"inventory.sweepinterval": "1m",
 		// Memcached
 		"memcached.servers": []string{"memcached:11211"},
+		// Payment
+		"payment.provider":  "",
+		"payment.fakedelay": "2s",
 		// Postgres
 		"postgres.username": "adak",
 		"postgres.password": "adak",
@@ -209,8 +286,13 @@ var (
 		"session.delay":    0,
 		"session.length":   0,
 		// Stripe
-		"stripe.secretkey":    "sk_test_default",
-		"stripe.logger.level": "4",
+		"stripe.secretkey":     "sk_test_default",
+		"stripe.webhooksecret": "",
+		"stripe.logger.level":  "4",
+		// Subscription
+		"subscription.interval":   "10m",
+		"subscription.maxretries": 3,
+		"subscription.retryafter": "6h",
 		// Token
 		"token.secretkey": "secretkey",
 	}
@@ -218,6 +300,14 @@ var (
 	envVars = map[string]string{
 		// Admins
 		"admins": "ADAK_ADMINS",
+		// Cart
+		"cart.guestttl":         "CART_GUEST_TTL",
+		"cart.mergepolicy":      "CART_MERGE_POLICY",
+		"cart.reminderafter":    "CART_REMINDER_AFTER",
+		"cart.reminderinterval": "CART_REMINDER_INTERVAL",
+		"cart.sweepinterval":    "CART_SWEEP_INTERVAL",
+		// Currency
+		"currency.base": "CURRENCY_BASE",
 		// Development
 		"development": "DEVELOPMENT",
 		// Email
@@ -228,8 +318,16 @@ var (
 		// Google
 		"google.client.id":     "GOOGLE_CLIENT_ID",
 		"google.client.secret": "GOOGLE_CLIENT_SECRET",
+		// Idempotency
+		"idempotency.ttl": "IDEMPOTENCY_TTL",
+		// Inventory
+		"inventory.holdttl":       "INVENTORY_HOLD_TTL",
+		"inventory.sweepinterval": "INVENTORY_SWEEP_INTERVAL",
 		// Memcached
 		"memcached.servers": "MEMCACHED_SERVERS",
+		// Payment
+		"payment.provider":  "PAYMENT_PROVIDER",
+		"payment.fakedelay": "PAYMENT_FAKE_DELAY",
 		// Postgres
 		"postgres.username": "POSTGRES_USERNAME",
 		"postgres.password": "POSTGRES_PASSWORD",
@@ -256,8 +354,13 @@ var (
 		"session.delay":    "SESSION_DELAY",
 		"session.length":   "SESSION_LENGTH",
 		// Stripe
-		"stripe.secretkey":    "STRIPE_SECRET_KEY",
-		"stripe.logger.level": "STRIPE_LOGGER_LEVEL",
+		"stripe.secretkey":     "STRIPE_SECRET_KEY",
+		"stripe.webhooksecret": "STRIPE_WEBHOOK_SECRET",
+		"stripe.logger.level":  "STRIPE_LOGGER_LEVEL",
+		// Subscription
+		"subscription.interval":   "SUBSCRIPTION_INTERVAL",
+		"subscription.maxretries": "SUBSCRIPTION_MAX_RETRIES",
+		"subscription.retryafter": "SUBSCRIPTION_RETRY_AFTER",
 		// Token
 		"token.secretkey": "TOKEN_SECRET_KEY",
 	}
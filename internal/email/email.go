@@ -7,10 +7,14 @@ import (
 	"bytes"
 	"context"
 	"embed"
+	"encoding/base64"
 	"html/template"
+	"io"
+	"mime/multipart"
 	"net"
 	"net/mail"
 	"net/smtp"
+	"net/textproto"
 
 	"github.com/GGP1/adak/internal/bufferpool"
 	"github.com/GGP1/adak/internal/logger"
@@ -27,8 +31,12 @@ type Emailer struct {
 	senderAddr string
 	senderPwd  string
 
-	validation  *template.Template
-	changeEmail *template.Template
+	validation   *template.Template
+	changeEmail  *template.Template
+	cartReminder *template.Template
+	returnStatus *template.Template
+	confirmation *template.Template
+	subscription *template.Template
 }
 
 // Items is a struct that keeps the values passed to the templates.
@@ -38,6 +46,9 @@ type Items struct {
 	Email    string
 	Token    string
 	NewEmail string
+	Products []string
+	Status   string
+	Note     string
 }
 
 // New returns a new emailer.
@@ -63,6 +74,22 @@ func New() Emailer {
 		if err != nil {
 			logger.Fatalf("Failed parsing change email template")
 		}
+		emailer.cartReminder, err = template.ParseFS(fs, "static/templates/cartReminder.html")
+		if err != nil {
+			logger.Fatalf("Failed parsing cart reminder template")
+		}
+		emailer.returnStatus, err = template.ParseFS(fs, "static/templates/returnStatus.html")
+		if err != nil {
+			logger.Fatalf("Failed parsing return status template")
+		}
+		emailer.confirmation, err = template.ParseFS(fs, "static/templates/orderConfirmation.html")
+		if err != nil {
+			logger.Fatalf("Failed parsing order confirmation template")
+		}
+		emailer.subscription, err = template.ParseFS(fs, "static/templates/subscriptionStatus.html")
+		if err != nil {
+			logger.Fatalf("Failed parsing subscription status template")
+		}
 	}
 
 	return emailer
@@ -155,6 +182,204 @@ func (e *Emailer) SendChangeConfirmation(id, username, email, newEmail, token st
 	return nil
 }
 
+// SendCartReminder reminds the user about the products left in the cart.
+func (e *Emailer) SendCartReminder(username, email string, products []string) error {
+	// Email content
+	from := mail.Address{Name: e.name, Address: e.senderAddr}
+	to := mail.Address{Name: username, Address: email}
+	items := Items{
+		Name:     username,
+		Email:    email,
+		Products: products,
+	}
+
+	headers := make(map[string]string, 4)
+	headers["From"] = from.String()
+	headers["To"] = to.String()
+	headers["Subject"] = "You left something in your cart"
+	headers["Content-Type"] = `text/html; charset="UTF-8"`
+
+	message := bufferpool.Get()
+	defer bufferpool.Put(message)
+
+	for k, v := range headers {
+		fmtHeaders(message, k, v)
+	}
+
+	buf := bufferpool.Get()
+	if err := e.cartReminder.Execute(buf, items); err != nil {
+		return err
+	}
+	message.Write(buf.Bytes())
+	bufferpool.Put(buf)
+
+	// Connect to smtp
+	auth := smtp.PlainAuth("", e.senderAddr, e.senderPwd, e.host)
+
+	if err := smtp.SendMail(e.addr, auth, from.Address, []string{to.Address}, message.Bytes()); err != nil {
+		logger.Debugf("Couldn't send the cart reminder email: %v.\nAddr: %s\nEmail: %s", err, e.addr, to.Address)
+		return errors.Wrap(err, "couldn't send the email")
+	}
+
+	logger.Infof("Successfully sent email to: %s", to.Address)
+	return nil
+}
+
+// SendReturnStatus notifies the user about the new status of a return.
+func (e *Emailer) SendReturnStatus(username, email, returnID, status, note string) error {
+	// Email content
+	from := mail.Address{Name: e.name, Address: e.senderAddr}
+	to := mail.Address{Name: username, Address: email}
+	items := Items{
+		ID:     returnID,
+		Name:   username,
+		Email:  email,
+		Status: status,
+		Note:   note,
+	}
+
+	headers := make(map[string]string, 4)
+	headers["From"] = from.String()
+	headers["To"] = to.String()
+	headers["Subject"] = "Your return was " + status
+	headers["Content-Type"] = `text/html; charset="UTF-8"`
+
+	message := bufferpool.Get()
+	defer bufferpool.Put(message)
+
+	for k, v := range headers {
+		fmtHeaders(message, k, v)
+	}
+
+	buf := bufferpool.Get()
+	if err := e.returnStatus.Execute(buf, items); err != nil {
+		return err
+	}
+	message.Write(buf.Bytes())
+	bufferpool.Put(buf)
+
+	// Connect to smtp
+	auth := smtp.PlainAuth("", e.senderAddr, e.senderPwd, e.host)
+
+	if err := smtp.SendMail(e.addr, auth, from.Address, []string{to.Address}, message.Bytes()); err != nil {
+		logger.Debugf("Couldn't send the return status email: %v.\nAddr: %s\nEmail: %s", err, e.addr, to.Address)
+		return errors.Wrap(err, "couldn't send the email")
+	}
+
+	logger.Infof("Successfully sent email to: %s", to.Address)
+	return nil
+}
+
+// SendSubscriptionStatus notifies the user about a change in a subscription, like a failed payment.
+func (e *Emailer) SendSubscriptionStatus(username, email, subscriptionID, status, note string) error {
+	// Email content
+	from := mail.Address{Name: e.name, Address: e.senderAddr}
+	to := mail.Address{Name: username, Address: email}
+	items := Items{
+		ID:     subscriptionID,
+		Name:   username,
+		Email:  email,
+		Status: status,
+		Note:   note,
+	}
+
+	headers := make(map[string]string, 4)
+	headers["From"] = from.String()
+	headers["To"] = to.String()
+	headers["Subject"] = "Your subscription is " + status
+	headers["Content-Type"] = `text/html; charset="UTF-8"`
+
+	message := bufferpool.Get()
+	defer bufferpool.Put(message)
+
+	for k, v := range headers {
+		fmtHeaders(message, k, v)
+	}
+
+	buf := bufferpool.Get()
+	if err := e.subscription.Execute(buf, items); err != nil {
+		return err
+	}
+	message.Write(buf.Bytes())
+	bufferpool.Put(buf)
+
+	// Connect to smtp
+	auth := smtp.PlainAuth("", e.senderAddr, e.senderPwd, e.host)
+
+	if err := smtp.SendMail(e.addr, auth, from.Address, []string{to.Address}, message.Bytes()); err != nil {
+		logger.Debugf("Couldn't send the subscription status email: %v.\nAddr: %s\nEmail: %s", err, e.addr, to.Address)
+		return errors.Wrap(err, "couldn't send the email")
+	}
+
+	logger.Infof("Successfully sent email to: %s", to.Address)
+	return nil
+}
+
+// SendOrderConfirmation confirms the purchase to the user attaching the invoices of the order in PDF.
+func (e *Emailer) SendOrderConfirmation(username, email, orderID string, invoice []byte) error {
+	// Email content
+	from := mail.Address{Name: e.name, Address: e.senderAddr}
+	to := mail.Address{Name: username, Address: email}
+	items := Items{
+		ID:    orderID,
+		Name:  username,
+		Email: email,
+	}
+
+	message := bufferpool.Get()
+	defer bufferpool.Put(message)
+
+	// The body and the attachment are sent as parts of the message
+	parts := multipart.NewWriter(message)
+
+	headers := make(map[string]string, 5)
+	headers["From"] = from.String()
+	headers["To"] = to.String()
+	headers["Subject"] = "Order confirmation"
+	headers["MIME-Version"] = "1.0"
+	headers["Content-Type"] = `multipart/mixed; boundary="` + parts.Boundary() + `"`
+
+	for k, v := range headers {
+		fmtHeaders(message, k, v)
+	}
+	message.WriteString("\r\n")
+
+	body, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {`text/html; charset="UTF-8"`},
+	})
+	if err != nil {
+		return err
+	}
+	if err := e.confirmation.Execute(body, items); err != nil {
+		return err
+	}
+
+	attachment, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/pdf"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {`attachment; filename="invoice-` + orderID + `.pdf"`},
+	})
+	if err != nil {
+		return err
+	}
+	writeBase64(attachment, invoice)
+
+	if err := parts.Close(); err != nil {
+		return err
+	}
+
+	// Connect to smtp
+	auth := smtp.PlainAuth("", e.senderAddr, e.senderPwd, e.host)
+
+	if err := smtp.SendMail(e.addr, auth, from.Address, []string{to.Address}, message.Bytes()); err != nil {
+		logger.Debugf("Couldn't send the order confirmation email: %v.\nAddr: %s\nEmail: %s", err, e.addr, to.Address)
+		return errors.Wrap(err, "couldn't send the email")
+	}
+
+	logger.Infof("Successfully sent email to: %s", to.Address)
+	return nil
+}
+
 func fmtHeaders(buf *bytes.Buffer, k, v string) {
 	// "key: value\r\n"
 	buf.WriteString(k)
@@ -164,3 +389,13 @@ func fmtHeaders(buf *bytes.Buffer, k, v string) {
 	buf.WriteByte('\r')
 	buf.WriteByte('\n')
 }
+
+// writeBase64 encodes data in lines of 76 characters as required by RFC 2045.
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		io.WriteString(w, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	io.WriteString(w, encoded+"\r\n")
+}
@@ -13,7 +13,10 @@ import (
 	"github.com/pkg/errors"
 )
 
-const maxResults = 50
+const (
+	maxResults = 50
+	maxSearch  = 100
+)
 
 // Object types
 const (
@@ -22,6 +25,10 @@ const (
 	Product
 	Review
 	Order
+	Promotion
+	Tax
+	Shipping
+	Return
 )
 
 type obj uint8
@@ -34,12 +41,87 @@ type Cursor struct {
 	ID        string
 }
 
+// kind is the type of the values a filter accepts.
+type kind uint8
+
+const (
+	text kind = iota
+	// list values are separated by commas
+	list
+	integer
+	// since and until values are dates or RFC3339 timestamps, until includes the whole day of the dates
+	since
+	until
+)
+
+// filters contains the fields each object can be filtered by.
+var filters = map[obj]map[string]kind{
+	Order: {
+		"status":    list,
+		"from":      since,
+		"to":        until,
+		"user_id":   text,
+		"email":     text,
+		"shop_id":   text,
+		"min_total": integer,
+		"max_total": integer,
+		"country":   text,
+	},
+}
+
+// sorts contains the fields each object can be sorted by, all of them must be timestamps
+// so the cursor can point to a value.
+var sorts = map[obj][]string{
+	Order: {"created_at", "ordered_at", "delivery_date"},
+}
+
+// searchable contains the objects that can be looked for with full-text search.
+var searchable = map[obj]bool{
+	Order: true,
+}
+
 // Query contains the request parameters provided by the client.
-//
-// TODO: include order field to let clients change the objects' order (DESC/ASC)
 type Query struct {
-	Cursor Cursor
-	Limit  string
+	Cursor  Cursor
+	Limit   string
+	Filters Filters
+	Sort    Sort
+	// Search contains the words looked for with full-text search
+	Search string
+}
+
+// Sort is the field the objects are sorted by, the newest objects come first by default.
+type Sort struct {
+	// Field is empty to sort by the creation date
+	Field string
+	Asc   bool
+}
+
+// Filters contains the values of the fields the objects are filtered by.
+type Filters map[string]interface{}
+
+// Int returns the integer value of the field and whether it was provided.
+func (f Filters) Int(field string) (int64, bool) {
+	v, ok := f[field].(int64)
+	return v, ok
+}
+
+// String returns the value of the field, empty if it wasn't provided.
+func (f Filters) String(field string) string {
+	v, _ := f[field].(string)
+	return v
+}
+
+// Strings returns the values of a list field.
+func (f Filters) Strings(field string) []string {
+	v, _ := f[field].([]string)
+	return v
+}
+
+// Time returns the time value of the field, zero if it wasn't provided.
+func (f Filters) Time(field string) time.Time {
+	v, _ := f[field].(time.Time)
+	return v
 }
 
 // DecodeCursor decodes de cursor and returns both it and time
@@ -96,13 +178,50 @@ func ParseQuery(rawQuery string, obj obj) (Query, error) {
 		return Query{}, errors.Wrap(err, "limit")
 	}
 
+	filters, err := parseFilters(values, obj)
+	if err != nil {
+		return Query{}, err
+	}
+
+	sort, err := parseSort(values.Get("sort"), obj)
+	if err != nil {
+		return Query{}, err
+	}
+
+	var search string
+	if searchable[obj] {
+		search = strings.TrimSpace(values.Get("search"))
+		if len(search) > maxSearch {
+			return Query{}, errors.Errorf("search exceeded the maximum length (%d)", maxSearch)
+		}
+	}
+
 	params := Query{
-		Cursor: cursor,
-		Limit:  limit,
+		Cursor:  cursor,
+		Limit:   limit,
+		Filters: filters,
+		Sort:    sort,
+		Search:  search,
 	}
 	return params, nil
 }
 
+type cartIDKey struct{}
+
+// CartID returns the id of the cart used in the request.
+func CartID(ctx context.Context) (string, error) {
+	cartID, ok := ctx.Value(cartIDKey{}).(string)
+	if !ok || cartID == "" {
+		return "", errors.New("cart not found")
+	}
+	return cartID, nil
+}
+
+// WithCartID returns a copy of the context containing the id of the cart used in the request.
+func WithCartID(ctx context.Context, cartID string) context.Context {
+	return context.WithValue(ctx, cartIDKey{}, cartID)
+}
+
 // URLID returns the id parsed from the url.
 func URLID(ctx context.Context) (string, error) {
 	id := chi.URLParamFromCtx(ctx, "id")
@@ -112,6 +231,81 @@ func URLID(ctx context.Context) (string, error) {
 	return id, nil
 }
 
+// parseFilters returns the filters of the object provided in the url values, the rest of
+// the values are ignored.
+func parseFilters(values url.Values, obj obj) (Filters, error) {
+	var f Filters
+	for field, kind := range filters[obj] {
+		value := strings.TrimSpace(values.Get(field))
+		if value == "" {
+			continue
+		}
+
+		var v interface{}
+		switch kind {
+		case text:
+			v = value
+		case list:
+			items := split(value)
+			for i, item := range items {
+				items[i] = strings.TrimSpace(item)
+			}
+			v = items
+		case integer:
+			i, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, errors.Errorf("invalid %s: %q is not a number", field, value)
+			}
+			v = i
+		case since, until:
+			t, err := parseTime(value, kind == until)
+			if err != nil {
+				return nil, errors.Wrap(err, field)
+			}
+			v = t
+		}
+
+		if f == nil {
+			f = make(Filters)
+		}
+		f[field] = v
+	}
+
+	return f, nil
+}
+
+// parseSort returns the field and direction to sort the object by, fields prefixed
+// with "-" are sorted in descending order.
+func parseSort(value string, obj obj) (Sort, error) {
+	if value == "" {
+		return Sort{}, nil
+	}
+
+	sort := Sort{Field: strings.TrimPrefix(value, "-"), Asc: !strings.HasPrefix(value, "-")}
+	for _, field := range sorts[obj] {
+		if field == sort.Field {
+			return sort, nil
+		}
+	}
+	return Sort{}, errors.Errorf("invalid sort field %q", sort.Field)
+}
+
+// parseTime parses an RFC3339 timestamp or a date, if end is true a date includes the whole day.
+func parseTime(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid date %q, use YYYY-MM-DD or RFC3339", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
 // split is like strings.Split but returns nil if the slice is empty
 func split(s string) []string {
 	if s == "" {
@@ -1,13 +1,31 @@
 package params
 
 import (
+	"context"
 	"encoding/base64"
+	"net/url"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+func TestCartID(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		expected := "cart"
+		ctx := WithCartID(context.Background(), expected)
+
+		got, err := CartID(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, got)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := CartID(context.Background())
+		assert.Error(t, err)
+	})
+}
+
 func TestDecodeCursor(t *testing.T) {
 	expected := Cursor{
 		CreatedAt: time.Unix(51000, 0),
@@ -67,6 +85,75 @@ func TestParseQuery(t *testing.T) {
 	}
 }
 
+func TestParseFilters(t *testing.T) {
+	values := url.Values{
+		"status":    {"paid, shipped"},
+		"from":      {"2021-03-01"},
+		"to":        {"2021-03-31"},
+		"email":     {"user@adak.com"},
+		"min_total": {"1000"},
+		"unknown":   {"ignored"},
+	}
+
+	got, err := parseFilters(values, Order)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"paid", "shipped"}, got.Strings("status"))
+	assert.Equal(t, time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), got.Time("from"))
+	// The whole last day is included
+	assert.Equal(t, time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC), got.Time("to"))
+	assert.Equal(t, "user@adak.com", got.String("email"))
+	min, ok := got.Int("min_total")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1000), min)
+	_, ok = got.Int("max_total")
+	assert.False(t, ok)
+	assert.Equal(t, 5, len(got))
+
+	// Objects without filters ignore the values
+	got, err = parseFilters(values, User)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	invalid := []url.Values{
+		{"from": {"01/03/2021"}},
+		{"max_total": {"ten"}},
+	}
+	for _, values := range invalid {
+		_, err := parseFilters(values, Order)
+		assert.Error(t, err)
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	cases := []struct {
+		desc     string
+		value    string
+		expected Sort
+		fail     bool
+	}{
+		{desc: "Default", value: "", expected: Sort{}},
+		{desc: "Ascending", value: "ordered_at", expected: Sort{Field: "ordered_at", Asc: true}},
+		{desc: "Descending", value: "-delivery_date", expected: Sort{Field: "delivery_date"}},
+		{desc: "Invalid field", value: "total", fail: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := parseSort(tc.value, Order)
+			if tc.fail {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+
+	_, err := parseSort("ordered_at", User)
+	assert.Error(t, err)
+}
+
 func TestParseInt(t *testing.T) {
 	t.Run("Valid", func(t *testing.T) {
 		expected := "20"
@@ -0,0 +1,169 @@
+/*
+Package pdf writes simple text documents in the PDF format using the standard Helvetica fonts,
+which every reader provides so nothing has to be embedded.
+*/
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// A4 page size in points.
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Document is a PDF document made of pages containing text and lines.
+//
+// Coordinates are in points and measured from the top left corner of the page.
+type Document struct {
+	pages []*bytes.Buffer
+}
+
+// New returns an empty document.
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage adds a blank page to the document, the following calls draw on it.
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// Text writes s with its baseline at y, bold uses Helvetica-Bold.
+func (d *Document) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	page := d.page()
+	fmt.Fprintf(page, "BT /%s %s Tf %s %s Td (", font, num(size), num(x), num(PageHeight-y))
+	page.Write(escape(s))
+	page.WriteString(") Tj ET\n")
+}
+
+// TextRight is like Text but the text ends at x.
+func (d *Document) TextRight(x, y, size float64, bold bool, s string) {
+	d.Text(x-TextWidth(s, size), y, size, bold, s)
+}
+
+// Line draws a line from (x1, y1) to (x2, y2).
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %s %s m %s %s l S\n",
+		num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// WriteTo writes the document to w.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	buf := &bytes.Buffer{}
+	offsets := make([]int, 0, 4+2*len(d.pages))
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// Objects 1 to 4 are the catalog, the pages tree and the fonts, each page
+	// is followed by its content stream
+	kids := &bytes.Buffer{}
+	for i := range d.pages {
+		fmt.Fprintf(kids, "%d 0 R ", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [ %s] /Count %d >>", kids, len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			num(PageWidth), num(PageHeight), 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.WriteTo(w)
+}
+
+// TextWidth returns the width of s written in Helvetica with the size provided.
+func TextWidth(s string, size float64) float64 {
+	var width int
+	for _, c := range encode(s) {
+		if c >= 32 && c <= 126 {
+			width += helveticaWidths[c-32]
+			continue
+		}
+		width += 556
+	}
+	return float64(width) * size / 1000
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// escape encodes s and escapes the characters with a special meaning inside PDF strings.
+func escape(s string) []byte {
+	encoded := encode(s)
+	escaped := make([]byte, 0, len(encoded))
+	for _, c := range encoded {
+		switch c {
+		case '\\', '(', ')':
+			escaped = append(escaped, '\\', c)
+		case '\n', '\r', '\t':
+			escaped = append(escaped, ' ')
+		default:
+			escaped = append(escaped, c)
+		}
+	}
+	return escaped
+}
+
+// encode converts s to WinAnsiEncoding, the characters that it doesn't contain are replaced by '?'.
+func encode(s string) []byte {
+	encoded := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 128, r >= 160 && r <= 255:
+			// Latin-1 matches WinAnsiEncoding in these ranges
+			encoded = append(encoded, byte(r))
+		case r == '€':
+			encoded = append(encoded, 0x80)
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+	return encoded
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// helveticaWidths contains the widths of the printable ASCII characters, in thousandths of
+// the font size.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
@@ -0,0 +1,50 @@
+package pdf
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTo(t *testing.T) {
+	doc := New()
+	doc.Text(50, 50, 12, true, "Invoice (copy)")
+	doc.Line(50, 60, 545, 60)
+	doc.AddPage()
+	doc.TextRight(545, 50, 10, false, "12.50 €")
+
+	buf := &bytes.Buffer{}
+	_, err := doc.WriteTo(buf)
+	assert.NoError(t, err)
+
+	out := buf.Bytes()
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(out, []byte("%%EOF\n")))
+	assert.Contains(t, string(out), "/Count 2")
+	assert.Contains(t, string(out), `(Invoice \(copy\)) Tj`)
+	assert.Contains(t, string(out), "(12.50 \x80) Tj")
+
+	// Every xref entry must point to the start of its object
+	match := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(out)
+	assert.NotNil(t, match)
+	xref, err := strconv.Atoi(string(match[1]))
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(out[xref:], []byte("xref")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(out[xref:], -1)
+	assert.Equal(t, 8, len(entries))
+	for i, e := range entries {
+		offset, err := strconv.Atoi(string(e[1]))
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(out[offset:], []byte(strconv.Itoa(i+1)+" 0 obj")))
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	assert.InDelta(t, 16.68, TextWidth("100", 10), 0.001)
+	// Characters outside WinAnsiEncoding are written as '?'
+	assert.Equal(t, TextWidth("?", 10), TextWidth("ж", 10))
+}
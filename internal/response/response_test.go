@@ -144,3 +144,37 @@ func TestJSONText(t *testing.T) {
 
 	assert.Equal(t, expectedText, buf.String())
 }
+
+type testRow struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (r testRow) Record() []string {
+	return []string{r.ID, r.Name}
+}
+
+func TestCSVStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	stream, err := CSVStream(rec, "test.csv", []string{"id", "name"})
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Write(testRow{ID: "1", Name: "a,b"}))
+	assert.NoError(t, stream.Close())
+
+	res := rec.Result()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `attachment; filename="test.csv"`, res.Header.Get("Content-Disposition"))
+	assert.Equal(t, "id,name\n1,\"a,b\"\n", rec.Body.String())
+	assert.True(t, rec.Flushed)
+}
+
+func TestJSONLinesStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	stream := JSONLinesStream(rec, "test.jsonl")
+	assert.NoError(t, stream.Write(testRow{ID: "1", Name: "a"}))
+	assert.NoError(t, stream.Write(testRow{ID: "2", Name: "b"}))
+	assert.NoError(t, stream.Close())
+
+	assert.Equal(t, "application/x-ndjson; charset=UTF-8", rec.Result().Header.Get("Content-Type"))
+	assert.Equal(t, "{\"id\":\"1\",\"name\":\"a\"}\n{\"id\":\"2\",\"name\":\"b\"}\n", rec.Body.String())
+}
@@ -0,0 +1,109 @@
+package response
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+)
+
+// flushEvery is the number of rows written before sending them to the client.
+const flushEvery = 100
+
+// Row is a value written to a stream, CSV streams write its record and JSON Lines streams
+// its JSON encoding.
+type Row interface {
+	Record() []string
+}
+
+// Stream writes the rows of a response as they are produced, so they don't have to be
+// loaded into memory.
+//
+// The status is predefined as 200 (OK) and it's sent along with the headers when the
+// stream is created, errors found afterwards can't be reported to the client.
+type Stream interface {
+	Write(row Row) error
+	// Close sends the rows buffered, it must be called after writing the last one
+	Close() error
+}
+
+// CSVStream returns a stream that writes the rows in CSV format, with the header as the first line.
+//
+// The response is sent as an attachment with the file name provided.
+func CSVStream(w http.ResponseWriter, filename string, header []string) (Stream, error) {
+	writeAttachment(w, "text/csv; charset=UTF-8", filename)
+	s := &csvStream{w: w, csv: csv.NewWriter(w)}
+	if err := s.csv.Write(header); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// JSONLinesStream returns a stream that writes each row as a JSON object in its own line.
+//
+// The response is sent as an attachment with the file name provided.
+func JSONLinesStream(w http.ResponseWriter, filename string) Stream {
+	writeAttachment(w, "application/x-ndjson; charset=UTF-8", filename)
+	return &jsonLinesStream{w: w, enc: json.NewEncoder(w)}
+}
+
+type csvStream struct {
+	w    http.ResponseWriter
+	csv  *csv.Writer
+	rows int
+}
+
+func (s *csvStream) Write(row Row) error {
+	if err := s.csv.Write(row.Record()); err != nil {
+		return err
+	}
+	s.rows++
+	if s.rows%flushEvery == 0 {
+		return s.Close()
+	}
+	return nil
+}
+
+func (s *csvStream) Close() error {
+	s.csv.Flush()
+	if err := s.csv.Error(); err != nil {
+		return err
+	}
+	flush(s.w)
+	return nil
+}
+
+type jsonLinesStream struct {
+	w    http.ResponseWriter
+	enc  *json.Encoder
+	rows int
+}
+
+func (s *jsonLinesStream) Write(row Row) error {
+	// The encoder writes directly to the response and ends every value with a new line
+	if err := s.enc.Encode(row); err != nil {
+		return err
+	}
+	s.rows++
+	if s.rows%flushEvery == 0 {
+		flush(s.w)
+	}
+	return nil
+}
+
+func (s *jsonLinesStream) Close() error {
+	flush(s.w)
+	return nil
+}
+
+func writeAttachment(w http.ResponseWriter, contentType, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// flush sends the data buffered to the client, if the writer supports it.
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
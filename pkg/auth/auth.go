@@ -9,6 +9,7 @@ import (
 	"github.com/GGP1/adak/internal/config"
 	"github.com/GGP1/adak/internal/cookie"
 	"github.com/GGP1/adak/internal/logger"
+	"github.com/GGP1/adak/pkg/shopping/cart"
 	"github.com/GGP1/adak/pkg/tracking"
 
 	"github.com/go-redis/redis/v8"
@@ -26,6 +27,7 @@ type Session interface {
 }
 
 type session struct {
+	carts   cart.Service
 	conf    config.Session
 	db      *sqlx.DB
 	dev     bool
@@ -34,8 +36,9 @@ type session struct {
 }
 
 // NewSession creates a new session with the necessary dependencies.
-func NewSession(db *sqlx.DB, rdb *redis.Client, config config.Session, development bool) Session {
+func NewSession(db *sqlx.DB, rdb *redis.Client, carts cart.Service, config config.Session, development bool) Session {
 	return &session{
+		carts:   carts,
 		conf:    config,
 		db:      db,
 		dev:     development,
@@ -97,7 +100,12 @@ func (s *session) Login(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return errors.New("invalid email or password")
 	}
 
-	return s.storeSession(ctx, w, user.ID, user.CartID)
+	if err := s.storeSession(ctx, w, user.ID, user.CartID); err != nil {
+		return err
+	}
+
+	s.mergeGuestCart(ctx, w, r, user.CartID)
+	return nil
 }
 
 // LoginOAuth authenticates users using OAuth2.
@@ -117,7 +125,12 @@ func (s *session) LoginOAuth(ctx context.Context, w http.ResponseWriter, r *http
 		return errors.New("please verify your email before logging in")
 	}
 
-	return s.storeSession(ctx, w, user.ID, user.CartID)
+	if err := s.storeSession(ctx, w, user.ID, user.CartID); err != nil {
+		return err
+	}
+
+	s.mergeGuestCart(ctx, w, r, user.CartID)
+	return nil
 }
 
 // Logout removes the user session and its cookies.
@@ -147,6 +160,23 @@ func (s *session) addDelay(ctx context.Context, key string) error {
 	return nil
 }
 
+// mergeGuestCart moves the products of the guest cart, if there is one, into the user cart.
+//
+// Failing to merge doesn't prevent the user from logging in, the guest cart is kept until it expires.
+func (s *session) mergeGuestCart(ctx context.Context, w http.ResponseWriter, r *http.Request, cartID string) {
+	guestID, err := cookie.GetValue(r, "GCID")
+	if err != nil {
+		return
+	}
+
+	if err := s.carts.Merge(ctx, guestID, cartID); err != nil {
+		logger.Errorf("merging guest cart %q: %v", guestID, err)
+		return
+	}
+
+	cookie.Delete(w, "GCID")
+}
+
 // storeSession saves the user key and sets the cookies used to authentication.
 func (s *session) storeSession(ctx context.Context, w http.ResponseWriter, userID, cartID string) error {
 	// The salt that will be used to identify the user's session
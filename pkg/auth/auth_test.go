@@ -11,6 +11,9 @@ import (
 	"github.com/GGP1/adak/internal/logger"
 	"github.com/GGP1/adak/internal/test"
 	"github.com/GGP1/adak/pkg/auth"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
 	"github.com/go-redis/redis/v8"
 	"github.com/jmoiron/sqlx"
 	"golang.org/x/crypto/bcrypt"
@@ -26,7 +29,7 @@ var (
 )
 
 func TestMain(m *testing.M) {
-	config := config.Session{
+	conf := config.Session{
 		Attempts: 1,
 		Delay:    0,
 	}
@@ -42,7 +45,9 @@ func TestMain(m *testing.M) {
 	db = sqlxDB
 	rdb = redisDB
 
-	session = auth.NewSession(db, rdb, config, true)
+	carts := cart.NewService(db, nil, config.Cart{},
+		inventory.NewService(config.Inventory{}), promotion.NewService(db))
+	session = auth.NewSession(db, rdb, carts, conf, true)
 	if err := createUser(context.Background()); err != nil {
 		logger.Fatal(err)
 	}
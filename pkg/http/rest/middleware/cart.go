@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/GGP1/adak/internal/cookie"
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/pkg/auth"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Cart contains the elements needed to identify the cart of each request.
+type Cart struct {
+	CartService cart.Service
+	Session     auth.Session
+	GuestTTL    time.Duration
+}
+
+// Resolve adds the id of the cart to the request context.
+//
+// Logged in users use their own cart, the rest get a guest cart that is identified
+// by the "GCID" cookie and merged into the user cart when logging in.
+func (c *Cart) Resolve(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if c.Session.AlreadyLoggedIn(ctx, r) {
+			cartID, err := cookie.GetValue(r, "CID")
+			if err != nil {
+				response.Error(w, http.StatusForbidden, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(params.WithCartID(ctx, cartID)))
+			return
+		}
+
+		// Create a new guest cart if the client has none or the previous one expired
+		guestID, err := cookie.GetValue(r, "GCID")
+		if err == nil {
+			err = c.CartService.Touch(ctx, guestID)
+			if err != nil && !errors.Is(err, cart.ErrExpired) {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		if err != nil {
+			guestID = uuid.NewString()
+			if err := c.CartService.CreateGuest(ctx, guestID); err != nil {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+
+		// -GCID- guest cart id, renewed on every request so it expires along with the cart
+		if err := cookie.Set(w, "GCID", guestID, "/", int(c.GuestTTL.Seconds())); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(params.WithCartID(ctx, guestID)))
+	})
+}
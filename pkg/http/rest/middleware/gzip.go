@@ -15,7 +15,7 @@ func GZIPCompress(next http.Handler) http.Handler {
 
 			next.ServeHTTP(gw, r)
 
-			gw.Flush()
+			gw.Close()
 			return
 		}
 
@@ -51,8 +51,15 @@ func (g *GZIPReponseWriter) WriteHeader(statuscode int) {
 	g.w.WriteHeader(statuscode)
 }
 
-// Flush flushes any pending compressed data and closes the gzip writer.
+// Flush sends any pending compressed data to the client, it's used to stream responses.
 func (g *GZIPReponseWriter) Flush() {
 	g.gw.Flush()
+	if f, ok := g.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close flushes any pending compressed data and closes the gzip writer.
+func (g *GZIPReponseWriter) Close() {
 	g.gw.Close()
 }
@@ -0,0 +1,245 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/internal/cookie"
+	"github.com/GGP1/adak/internal/logger"
+	"github.com/GGP1/adak/internal/response"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	// idempotencyLockTTL is the time a key is reserved if the server stops while processing its
+	// first request, the lock is extended until the response is saved
+	idempotencyLockTTL = time.Minute
+	maxIdempotencyKey  = 255
+	maxIdempotentBody  = 1 << 20
+)
+
+// idempotentResponse is the response saved to be replayed, Status is zero while the first
+// request is being processed.
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// Idempotency makes the requests that modify resources safe to retry.
+//
+// The response to the first request with an Idempotency-Key header is saved and replayed
+// to the retries using the same key, so they are processed only once.
+type Idempotency struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+// NewIdempotency returns the idempotency middleware with the configuration values passed.
+func NewIdempotency(config config.Idempotency, rdb *redis.Client) *Idempotency {
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &Idempotency{rdb: rdb, ttl: ttl}
+}
+
+// Handle replays the response saved for the user and key of the request, if any.
+//
+// Keys are scoped to the user (or the guest cart), reusing one with a different request fails
+// with 422 (Unprocessable Entity) and a retry made while the first request is still being
+// processed with 409 (Conflict). Server errors aren't saved so the request can be retried.
+func (i *Idempotency) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			response.Error(w, http.StatusBadRequest, errors.New("the idempotency key is too long"))
+			return
+		}
+
+		scope, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			scope, err = cookie.GetValue(r, "CID")
+			if err != nil {
+				// There's nothing to scope the key to, process the request as usual
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		r.Body.Close()
+		if len(body) > maxIdempotentBody {
+			response.Error(w, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		redisKey := "idempotency:" + scope + ":" + key
+		fingerprint := requestFingerprint(r, body)
+
+		lock, err := json.Marshal(idempotentResponse{Fingerprint: fingerprint})
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		first, err := i.rdb.SetNX(ctx, redisKey, lock, idempotencyLockTTL).Result()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !first {
+			i.replay(w, r, redisKey, fingerprint)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		func() {
+			defer i.hold(redisKey)()
+			next.ServeHTTP(rec, r)
+		}()
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		// Use a context that isn't canceled if the client went away, the request was processed anyway
+		ctx = context.Background()
+		if rec.status >= http.StatusInternalServerError {
+			if err := i.rdb.Del(ctx, redisKey).Err(); err != nil {
+				logger.Error(errors.Wrap(err, "couldn't release the idempotency key"))
+			}
+			return
+		}
+
+		header := w.Header().Clone()
+		header.Del("Set-Cookie")
+		saved, err := json.Marshal(idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      rec.status,
+			Header:      header,
+			Body:        rec.body.Bytes(),
+		})
+		if err == nil {
+			err = i.rdb.Set(ctx, redisKey, saved, i.ttl).Err()
+		}
+		if err != nil {
+			logger.Error(errors.Wrap(err, "couldn't save the idempotent response"))
+		}
+	})
+}
+
+// hold extends the lock of the key until the function returned is called, so a retry isn't
+// processed while the first request takes longer than the lock TTL.
+func (i *Idempotency) hold(redisKey string) (release func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(idempotencyLockTTL / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := i.rdb.Expire(context.Background(), redisKey, idempotencyLockTTL).Err(); err != nil {
+					logger.Error(errors.Wrap(err, "couldn't extend the idempotency lock"))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		// Wait so the lock TTL isn't set again after saving the response
+		<-stopped
+	}
+}
+
+// replay writes the response saved for the key.
+func (i *Idempotency) replay(w http.ResponseWriter, r *http.Request, redisKey, fingerprint string) {
+	value, err := i.rdb.Get(r.Context(), redisKey).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			// The first request failed and released the key meanwhile
+			response.Error(w, http.StatusConflict, errors.New("the request with this idempotency key failed, retry it"))
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var saved idempotentResponse
+	if err := json.Unmarshal(value, &saved); err != nil {
+		response.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if saved.Fingerprint != fingerprint {
+		response.Error(w, http.StatusUnprocessableEntity,
+			errors.New("the idempotency key was already used with a different request"))
+		return
+	}
+	if saved.Status == 0 {
+		response.Error(w, http.StatusConflict,
+			errors.New("a request with this idempotency key is being processed"))
+		return
+	}
+
+	for k, v := range saved.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(saved.Status)
+	w.Write(saved.Body)
+}
+
+// requestFingerprint identifies a request by its method, path and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder writes the response while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	if rr.status == 0 {
+		rr.status = code
+	}
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
@@ -0,0 +1,94 @@
+package middleware_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/internal/test"
+	"github.com/GGP1/adak/pkg/http/rest/middleware"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	rdb := test.StartRedis(t)
+	idempotency := middleware.NewIdempotency(config.Idempotency{}, rdb)
+
+	t.Run("Replay", func(t *testing.T) {
+		var calls int
+		handler := idempotency.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, "order %d", calls)
+		}))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, idempotentRequest(t, "replay", `{"cart_id":"1"}`))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "order 1", rec.Body.String())
+
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, idempotentRequest(t, "replay", `{"cart_id":"1"}`))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "order 1", rec.Body.String())
+		assert.Equal(t, "true", rec.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("In flight", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		handler := idempotency.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest(t, "in-flight", `{"cart_id":"1"}`))
+		}()
+		<-started
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, idempotentRequest(t, "in-flight", `{"cart_id":"1"}`))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		close(release)
+		<-done
+
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, idempotentRequest(t, "in-flight", `{"cart_id":"1"}`))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("Different request", func(t *testing.T) {
+		var calls int
+		handler := idempotency.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, idempotentRequest(t, "mismatch", `{"cart_id":"1"}`))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, idempotentRequest(t, "mismatch", `{"cart_id":"2"}`))
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func idempotentRequest(t *testing.T, key, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/orders/new", strings.NewReader(body))
+	r.Header.Set("Idempotency-Key", key)
+	test.AddCookie(t, r, "UID", "user")
+	return r
+}
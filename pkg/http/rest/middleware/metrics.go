@@ -116,6 +116,13 @@ func (h *interceptor) Write(b []byte) (int, error) {
 	return n, nil
 }
 
+// Flush sends any buffered data to the client if the underlying response writer supports it.
+func (h *interceptor) Flush() {
+	if f, ok := h.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // https://github.com/prometheus/client_golang/blob/6007b2b5cae01203111de55f753e76d8dac1f529/prometheus/promhttp/instrument_server.go#L298
 func computeApproximateRequestSize(r *http.Request) int {
 	s := 0
@@ -11,8 +11,19 @@ import (
 	"github.com/GGP1/adak/pkg/review"
 	"github.com/GGP1/adak/pkg/shop"
 	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/currency"
+	"github.com/GGP1/adak/pkg/shopping/delivery"
+	"github.com/GGP1/adak/pkg/shopping/invoice"
 	"github.com/GGP1/adak/pkg/shopping/ordering"
+	"github.com/GGP1/adak/pkg/shopping/payment"
 	"github.com/GGP1/adak/pkg/shopping/payment/stripe"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
+	"github.com/GGP1/adak/pkg/shopping/returns"
+	"github.com/GGP1/adak/pkg/shopping/shipment"
+	"github.com/GGP1/adak/pkg/shopping/shipping"
+	"github.com/GGP1/adak/pkg/shopping/subscription"
+	"github.com/GGP1/adak/pkg/shopping/tax"
+	"github.com/GGP1/adak/pkg/shopping/wishlist"
 	"github.com/GGP1/adak/pkg/tracking"
 	"github.com/GGP1/adak/pkg/user"
 	"github.com/GGP1/adak/pkg/user/account"
@@ -25,30 +36,28 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// NewRouter initializes services, creates and returns a mux router
-func NewRouter(config config.Config, db *sqlx.DB, mc *memcache.Client, rdb *redis.Client) http.Handler {
+// NewRouter creates and returns a mux router with the handlers of the services provided.
+func NewRouter(config config.Config, services Services, db *sqlx.DB, mc *memcache.Client,
+	rdb *redis.Client, provider payment.Provider) http.Handler {
 	router := chi.NewRouter()
 
-	// Services
-	accountService := account.NewService(db)
-	cartService := cart.NewService(db, mc)
-	orderingService := ordering.NewService(db)
-	productService := product.NewService(db, mc)
-	reviewService := review.NewService(db, mc)
-	shopService := shop.NewService(db, mc)
-	userService := user.NewService(db, mc)
-	trackingService := tracking.NewService(db)
-	session := auth.NewSession(db, rdb, config.Session, config.Development)
+	session := auth.NewSession(db, rdb, services.Cart, config.Session, config.Development)
 	emailer := email.New()
 
 	// Authentication middleware
 	mAuth := middleware.Auth{
 		DB:          db,
-		UserService: userService,
+		UserService: services.User,
 		Session:     session,
 	}
 	adminsOnly := mAuth.AdminsOnly
 	requireLogin := mAuth.RequireLogin
+	// Cart middleware, lets guests use a cart
+	mCart := middleware.Cart{
+		CartService: services.Cart,
+		Session:     session,
+		GuestTTL:    config.Cart.GuestTTL,
+	}
 	// Metrics middleware
 	metrics := middleware.NewMetrics()
 
@@ -61,6 +70,8 @@ func NewRouter(config config.Config, db *sqlx.DB, mc *memcache.Client, rdb *redi
 		rateLimiter := middleware.NewRateLimiter(config.RateLimiter, rdb)
 		router.Use(rateLimiter.Limit)
 	}
+	// Replays the responses to the shopping requests retried with the same Idempotency-Key
+	idempotency := middleware.NewIdempotency(config.Idempotency, rdb)
 
 	// Auth
 	router.Post("/login", auth.Login(session))
@@ -70,22 +81,38 @@ func NewRouter(config config.Config, db *sqlx.DB, mc *memcache.Client, rdb *redi
 	router.Get("/login/oauth2/google", auth.OAuth2Google(session))
 
 	// Cart
-	cart := cart.NewHandler(cartService, db, mc)
+	cart := cart.NewHandler(services.Cart, services.Currency, services.Shipping, db, mc)
+	promotion := promotion.NewHandler(services.Promotion)
+	delivery := delivery.NewHandler(services.Delivery, services.Cart, services.Shop, services.User)
 	router.Route("/cart", func(r chi.Router) {
-		r.Use(requireLogin)
+		r.Use(mCart.Resolve, idempotency.Handle)
 
 		r.Get("/", cart.Get())
 		r.Post("/add", cart.Add())
 		r.Get("/filter/{field}/{args}", cart.FilterBy())
 		r.Get("/checkout", cart.Checkout())
 		r.Get("/products", cart.Products())
+		r.Get("/slots", delivery.Available())
+		r.Post("/promotions", promotion.Apply())
+		r.Delete("/promotions/{code}", promotion.Remove())
 		r.Delete("/remove/{id}/{quantity}", cart.Remove())
 		r.Post("/reset", cart.Reset())
 		r.Get("/size", cart.Size())
 	})
 
+	// Exchange rates
+	currency := currency.NewHandler(services.Currency)
+	router.Route("/exchange-rates", func(r chi.Router) {
+		r.Use(adminsOnly)
+
+		r.Get("/", currency.Get())
+		r.Post("/import", currency.Import())
+		r.Put("/{currency}", currency.Set())
+		r.Delete("/{currency}", currency.Delete())
+	})
+
 	// Home
-	router.Get("/", Home(trackingService))
+	router.Get("/", Home(services.Tracking))
 
 	// Metrics
 	router.Handle("/metrics", promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
@@ -96,17 +123,50 @@ func NewRouter(config config.Config, db *sqlx.DB, mc *memcache.Client, rdb *redi
 	}))
 
 	// Ordering
-	order := ordering.NewHandler(config.Development, orderingService, cartService, db, mc)
+	order := ordering.NewHandler(provider, services.Payment, services.Ordering, services.Cart, services.Shipment,
+		services.Invoice, services.Scheduler, db, mc)
+	// The fake provider notifies its events directly instead of calling the webhook
+	if fake, ok := provider.(*payment.Fake); ok {
+		fake.Notify(order.HandleEvent)
+	}
+	shipment := shipment.NewHandler(services.Shipment, services.User, db, mc)
+	returns := returns.NewHandler(provider, services.Returns)
+	invoice := invoice.NewHandler(services.Invoice, services.Ordering, services.User)
 	router.Route("/orders", func(r chi.Router) {
+		r.Use(idempotency.Handle)
+
 		r.With(adminsOnly).Get("/", order.Get())
+		r.With(adminsOnly).Get("/export", order.Export())
 		r.With(adminsOnly).Delete("/{id}", order.Delete())
 		r.With(adminsOnly).Get("/{id}", order.GetByID())
+		r.With(adminsOnly).Get("/{id}/history", order.History())
+		r.With(adminsOnly).Put("/{id}/status", order.UpdateStatus())
+		r.With(adminsOnly).Get("/{id}/refunds", order.Refunds())
+		r.With(adminsOnly).Post("/{id}/refunds", order.Refund())
+		r.With(adminsOnly).Get("/{id}/shipments", shipment.GetByOrderID())
+		r.With(requireLogin).Post("/{id}/shipments", shipment.Create())
+		r.With(requireLogin).Post("/{id}/returns", returns.Request())
+		r.With(requireLogin).Post("/{id}/cancel", order.Cancel())
+		r.With(requireLogin).Post("/{id}/confirm", order.Confirm())
+		r.With(requireLogin, mCart.Resolve).Post("/{id}/reorder", order.Reorder())
+		r.With(requireLogin).Get("/{id}/invoice", invoice.Get())
 		r.With(requireLogin).Get("/user/{id}", order.GetByUserID())
 		r.With(requireLogin).Post("/new", order.New())
 	})
 
+	// Payment methods
+	paymentMethods := payment.NewHandler(services.Payment)
+	router.Route("/payment-methods", func(r chi.Router) {
+		r.Use(requireLogin, idempotency.Handle)
+
+		r.Get("/", paymentMethods.GetMethods())
+		r.Post("/", paymentMethods.AddMethod())
+		r.Delete("/{methodID}", paymentMethods.RemoveMethod())
+		r.Put("/{methodID}/default", paymentMethods.SetDefault())
+	})
+
 	// Product
-	product := product.NewHandler(productService, mc)
+	product := product.NewHandler(services.Product, mc)
 	router.Route("/products", func(r chi.Router) {
 		r.Get("/", product.Get())
 		r.Get("/{id}", product.GetByID())
@@ -114,10 +174,34 @@ func NewRouter(config config.Config, db *sqlx.DB, mc *memcache.Client, rdb *redi
 		r.With(adminsOnly).Delete("/{id}", product.Delete())
 		r.With(adminsOnly).Post("/create", product.Create())
 		r.Get("/search/{query}", product.Search())
+		r.With(adminsOnly).Post("/{id}/variants", product.CreateVariant())
+		r.With(adminsOnly).Put("/{id}/variants/{variantID}", product.UpdateVariant())
+		r.With(adminsOnly).Delete("/{id}/variants/{variantID}", product.DeleteVariant())
+	})
+
+	// Promotion
+	router.Route("/promotions", func(r chi.Router) {
+		r.Use(adminsOnly)
+
+		r.Get("/", promotion.Get())
+		r.Get("/{id}", promotion.GetByID())
+		r.Delete("/{id}", promotion.Delete())
+		r.Post("/create", promotion.Create())
+	})
+
+	// Returns
+	router.Route("/returns", func(r chi.Router) {
+		r.Use(idempotency.Handle)
+
+		r.With(adminsOnly).Get("/", returns.Get())
+		r.With(adminsOnly).Get("/{id}", returns.GetByID())
+		r.With(adminsOnly).Put("/{id}/status", returns.UpdateStatus())
+		r.With(adminsOnly).Post("/{id}/refund", returns.Refund())
+		r.With(requireLogin).Get("/user/{id}", returns.GetByUserID())
 	})
 
 	// Review
-	review := review.NewHandler(reviewService, mc)
+	review := review.NewHandler(services.Review, mc)
 	router.Route("/reviews", func(r chi.Router) {
 		r.Get("/", review.Get())
 		r.Get("/{id}", review.GetByID())
@@ -125,31 +209,84 @@ func NewRouter(config config.Config, db *sqlx.DB, mc *memcache.Client, rdb *redi
 		r.With(requireLogin).Post("/create", review.Create())
 	})
 
+	// Shipments
+	router.Route("/shipments", func(r chi.Router) {
+		r.Use(requireLogin)
+
+		r.Put("/{id}", shipment.Update())
+		r.Post("/{id}/deliver", shipment.Deliver())
+	})
+
+	// Shipping
+	shipping := shipping.NewHandler(services.Shipping)
+	router.Route("/shipping-methods", func(r chi.Router) {
+		r.Use(adminsOnly)
+
+		r.Get("/", shipping.Get())
+		r.Get("/{id}", shipping.GetByID())
+		r.Put("/{id}", shipping.Update())
+		r.Delete("/{id}", shipping.Delete())
+		r.Post("/create", shipping.Create())
+	})
+
 	// Shop
-	shop := shop.NewHandler(shopService, mc)
+	shop := shop.NewHandler(services.Shop, services.Ordering, services.Shipment, services.User, mc)
 	router.Route("/shops", func(r chi.Router) {
 		r.Get("/", shop.Get())
 		r.Get("/{id}", shop.GetByID())
+		r.With(requireLogin).Get("/{id}/orders", shop.Orders())
+		r.Get("/{id}/slots", delivery.GetByShopID())
+		r.With(requireLogin).Post("/{id}/slots", delivery.Create())
+		r.With(requireLogin).Put("/{id}/slots/{slotID}", delivery.Update())
+		r.With(requireLogin).Delete("/{id}/slots/{slotID}", delivery.Delete())
 		r.With(adminsOnly).Delete("/{id}", shop.Delete())
 		r.With(adminsOnly).Put("/{id}", shop.Update())
 		r.With(adminsOnly).Post("/create", shop.Create())
+		r.With(adminsOnly).Put("/{id}/owners/{userID}", shop.AddOwner())
+		r.With(adminsOnly).Delete("/{id}/owners/{userID}", shop.RemoveOwner())
 		r.Get("/search/{query}", shop.Search())
 	})
 
 	// Stripe
 	stripe := stripe.NewHandler()
 	router.Route("/stripe", func(r chi.Router) {
+		r.With(adminsOnly).Get("/balance", stripe.GetBalance())
+		r.With(adminsOnly).Get("/event/{event}", stripe.GetEvent())
+		r.With(adminsOnly).Get("/transactions/{txID}", stripe.GetTxBalance())
+		r.With(adminsOnly).Get("/events", stripe.ListEvents())
+		r.With(adminsOnly).Get("/transactions", stripe.ListTxs())
+		// Authenticated by the signature of the events
+		r.Post("/webhook", order.Webhook())
+	})
+
+	// Subscriptions
+	subscription := subscription.NewHandler(services.Subscription, services.Cart, services.Payment)
+	router.Route("/subscriptions", func(r chi.Router) {
+		r.Use(requireLogin, idempotency.Handle)
+
+		r.Get("/", subscription.Get())
+		r.Post("/", subscription.Create())
+		r.Get("/{id}", subscription.GetByID())
+		r.Post("/{id}/pause", subscription.Pause())
+		r.Post("/{id}/resume", subscription.Resume())
+		r.Post("/{id}/skip", subscription.Skip())
+		r.Post("/{id}/cancel", subscription.Cancel())
+	})
+
+	// Taxes
+	tax := tax.NewHandler(services.Tax)
+	router.Route("/taxes", func(r chi.Router) {
 		r.Use(adminsOnly)
 
-		r.Get("/balance", stripe.GetBalance())
-		r.Get("/event/{event}", stripe.GetEvent())
-		r.Get("/transactions/{txID}", stripe.GetTxBalance())
-		r.Get("/events", stripe.ListEvents())
-		r.Get("/transactions", stripe.ListTxs())
+		r.Get("/", tax.Get())
+		r.Get("/{id}", tax.GetByID())
+		r.Put("/{id}", tax.Update())
+		r.Delete("/{id}", tax.Delete())
+		r.Post("/create", tax.Create())
 	})
 
 	// Tracking
-	tracker := tracking.NewHandler(trackingService)
+	tracker := tracking.NewHandler(services.Tracking)
 	router.Route("/tracker", func(r chi.Router) {
 		r.Use(adminsOnly)
 
@@ -160,7 +297,8 @@ func NewRouter(config config.Config, db *sqlx.DB, mc *memcache.Client, rdb *redi
 	})
 
 	// User
-	user := user.NewHandler(config.Development, userService, cartService, emailer, mc)
+	user := user.NewHandler(config.Development, services.User, services.Cart, emailer, mc)
+	wishlist := wishlist.NewHandler(services.Wishlist, mc)
 	router.Route("/users", func(r chi.Router) {
 		r.Get("/", user.Get())
 		r.Get("/{id}", user.GetByID())
@@ -170,13 +308,28 @@ func NewRouter(config config.Config, db *sqlx.DB, mc *memcache.Client, rdb *redi
 		r.Get("/username/{username}", user.GetByUsername())
 		r.Post("/create", user.Create())
 		r.Get("/search/{query}", user.Search())
+
+		r.With(requireLogin).Route("/{id}/wishlists", func(r chi.Router) {
+			r.Get("/", wishlist.Get())
+			r.Post("/", wishlist.Create())
+			r.Get("/{wishlistID}", wishlist.GetByID())
+			r.Put("/{wishlistID}", wishlist.Rename())
+			r.Delete("/{wishlistID}", wishlist.Delete())
+			r.Post("/{wishlistID}/products", wishlist.Add())
+			r.Delete("/{wishlistID}/products/{productID}", wishlist.Remove())
+			r.With(mCart.Resolve).Post("/{wishlistID}/move-to-cart/{productID}", wishlist.MoveToCart())
+			r.With(mCart.Resolve).Post("/{wishlistID}/move-from-cart/{productID}", wishlist.MoveFromCart())
+			r.Post("/{wishlistID}/share", wishlist.Share())
+			r.Delete("/{wishlistID}/share", wishlist.Unshare())
+		})
 	})
+	router.Get("/wishlists/shared/{token}", wishlist.GetShared())
 
 	// Account
-	account := account.NewHandler(accountService, userService, emailer)
+	account := account.NewHandler(services.Account, services.User, emailer)
 	router.With(requireLogin).Post("/settings/email", account.SendChangeConfirmation())
 	router.With(requireLogin).Post("/settings/password", account.ChangePassword())
-	router.Get("/verification/{email}/{token}", account.SendEmailValidation(userService))
+	router.Get("/verification/{email}/{token}", account.SendEmailValidation(services.User))
 	router.Get("/verification/{token}/{email}/{id}", account.ChangeEmail())
 
 	http.Handle("/", router)
@@ -8,12 +8,15 @@ import (
 
 	"github.com/GGP1/adak/internal/config"
 	"github.com/GGP1/adak/pkg/http/rest"
+	"github.com/GGP1/adak/pkg/shopping/payment"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestRouter(t *testing.T) {
-	mux := rest.NewRouter(config.Config{}, nil, nil, nil)
+	provider := payment.NewFake(0)
+	services := rest.NewServices(config.Config{}, nil, nil, provider)
+	mux := rest.NewRouter(config.Config{}, services, nil, nil, nil, provider)
 	ts := httptest.NewServer(mux)
 	defer ts.Close()
 
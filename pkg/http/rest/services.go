@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/internal/email"
+	"github.com/GGP1/adak/pkg/product"
+	"github.com/GGP1/adak/pkg/review"
+	"github.com/GGP1/adak/pkg/shop"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/currency"
+	"github.com/GGP1/adak/pkg/shopping/delivery"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/invoice"
+	"github.com/GGP1/adak/pkg/shopping/ordering"
+	"github.com/GGP1/adak/pkg/shopping/payment"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
+	"github.com/GGP1/adak/pkg/shopping/returns"
+	"github.com/GGP1/adak/pkg/shopping/shipment"
+	"github.com/GGP1/adak/pkg/shopping/shipping"
+	"github.com/GGP1/adak/pkg/shopping/subscription"
+	"github.com/GGP1/adak/pkg/shopping/tax"
+	"github.com/GGP1/adak/pkg/shopping/wishlist"
+	"github.com/GGP1/adak/pkg/tracking"
+	"github.com/GGP1/adak/pkg/user"
+	"github.com/GGP1/adak/pkg/user/account"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jmoiron/sqlx"
+)
+
+// Services contains the services used by the router, they are shared with the jobs that
+// run in the background.
+type Services struct {
+	Account      account.Service
+	Cart         cart.Service
+	Currency     currency.Service
+	Delivery     delivery.Service
+	Inventory    inventory.Service
+	Invoice      invoice.Service
+	Ordering     ordering.Service
+	Payment      payment.Service
+	Product      product.Service
+	Promotion    promotion.Service
+	Returns      returns.Service
+	Scheduler    *subscription.Scheduler
+	Review       review.Service
+	Shipment     shipment.Service
+	Shipping     shipping.Service
+	Shop         shop.Service
+	Subscription subscription.Service
+	Tax          tax.Service
+	Tracking     tracking.Tracker
+	User         user.Service
+	Wishlist     wishlist.Service
+}
+
+// NewServices initializes the services, each one must be created only once as they register
+// their metrics.
+func NewServices(config config.Config, db *sqlx.DB, mc *memcache.Client, provider payment.Provider) Services {
+	emailer := email.New()
+	inventoryService := inventory.NewService(config.Inventory)
+	promotionService := promotion.NewService(db)
+	cartService := cart.NewService(db, mc, config.Cart, inventoryService, promotionService)
+	currencyService := currency.NewService(db, config.Currency)
+	taxService := tax.NewService(db)
+	shippingService := shipping.NewService(db)
+	deliveryService := delivery.NewService(db)
+	orderingService := ordering.NewService(db, inventoryService, promotionService, currencyService,
+		taxService, shippingService, deliveryService)
+	scheduler := subscription.NewScheduler(db, orderingService, cartService, deliveryService,
+		emailer, provider, config.Subscription)
+
+	return Services{
+		Account:      account.NewService(db),
+		Cart:         cartService,
+		Currency:     currencyService,
+		Delivery:     deliveryService,
+		Inventory:    inventoryService,
+		Invoice:      invoice.NewService(db, emailer),
+		Ordering:     orderingService,
+		Payment:      payment.NewService(db, provider),
+		Product:      product.NewService(db, mc),
+		Promotion:    promotionService,
+		Returns:      returns.NewService(db, orderingService, emailer),
+		Scheduler:    scheduler,
+		Review:       review.NewService(db, mc),
+		Shipment:     shipment.NewService(db, orderingService),
+		Shipping:     shippingService,
+		Shop:         shop.NewService(db, mc),
+		Subscription: subscription.NewService(db),
+		Tax:          taxService,
+		Tracking:     tracking.NewService(db),
+		User:         user.NewService(db, mc),
+		Wishlist:     wishlist.NewService(db, mc, cartService),
+	}
+}
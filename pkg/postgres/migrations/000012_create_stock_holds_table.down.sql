@@ -0,0 +1 @@
+DROP TABLE IF EXISTS stock_holds;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS stock_holds
+(
+    product_id text NOT NULL,
+    cart_id text NOT NULL,
+    quantity integer NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    CONSTRAINT stock_holds_pkey PRIMARY KEY (product_id, cart_id),
+    FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS promotions
+(
+    id text NOT NULL,
+    code text NOT NULL,
+    type text NOT NULL,
+    value integer NOT NULL DEFAULT 0,
+    product_id text,
+    buy_quantity integer,
+    get_quantity integer,
+    min_total integer NOT NULL DEFAULT 0,
+    usage_limit integer NOT NULL DEFAULT 0,
+    user_usage_limit integer NOT NULL DEFAULT 0,
+    starts_at timestamp with time zone,
+    ends_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT promotions_pkey PRIMARY KEY (id),
+    CONSTRAINT promotions_code_key UNIQUE (code),
+    FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS cart_promotions;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS cart_promotions
+(
+    cart_id text NOT NULL,
+    promotion_id text NOT NULL,
+    CONSTRAINT cart_promotions_pkey PRIMARY KEY (cart_id, promotion_id),
+    FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE,
+    FOREIGN KEY (promotion_id) REFERENCES promotions (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS promotion_redemptions;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS promotion_redemptions
+(
+    promotion_id text NOT NULL,
+    order_id text NOT NULL,
+    user_id text NOT NULL,
+    code text NOT NULL,
+    discount integer NOT NULL,
+    redeemed_at timestamp with time zone DEFAULT NOW(),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
\ No newline at end of file
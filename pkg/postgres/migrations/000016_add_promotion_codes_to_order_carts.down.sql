@@ -0,0 +1 @@
+ALTER TABLE order_carts DROP COLUMN IF EXISTS promotion_codes;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE order_carts ADD COLUMN IF NOT EXISTS promotion_codes text[];
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS guest_carts
+(
+    cart_id text NOT NULL,
+    last_activity timestamp with time zone NOT NULL,
+    CONSTRAINT guest_carts_pkey PRIMARY KEY (cart_id),
+    FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE cart_products DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE cart_products DROP CONSTRAINT IF EXISTS cart_products_pkey;
+ALTER TABLE cart_products ADD CONSTRAINT cart_products_pkey PRIMARY KEY (id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE cart_products DROP CONSTRAINT IF EXISTS cart_products_pkey;
+ALTER TABLE cart_products ADD CONSTRAINT cart_products_pkey PRIMARY KEY (id, cart_id);
+ALTER TABLE cart_products ADD COLUMN IF NOT EXISTS updated_at timestamp with time zone NOT NULL DEFAULT NOW();
\ No newline at end of file
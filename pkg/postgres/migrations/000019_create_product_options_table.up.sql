@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS product_options
+(
+    product_id text NOT NULL,
+    position integer NOT NULL,
+    name text NOT NULL,
+    option_values text[] NOT NULL,
+    CONSTRAINT product_options_pkey PRIMARY KEY (product_id, position),
+    FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS product_variants
+(
+    id text NOT NULL,
+    product_id text NOT NULL,
+    sku text NOT NULL,
+    options text[] NOT NULL,
+    stock integer NOT NULL,
+    weight integer,
+    discount integer,
+    taxes integer,
+    subtotal integer,
+    total integer,
+    created_at timestamp with time zone DEFAULT NOW(),
+    updated_at timestamp with time zone,
+    CONSTRAINT product_variants_pkey PRIMARY KEY (id),
+    CONSTRAINT product_variants_sku_key UNIQUE (sku),
+    CONSTRAINT product_variants_options_key UNIQUE (product_id, options),
+    FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE cart_products ADD COLUMN IF NOT EXISTS variant_id text NOT NULL DEFAULT '';
+ALTER TABLE cart_products DROP CONSTRAINT IF EXISTS cart_products_pkey;
+ALTER TABLE cart_products ADD CONSTRAINT cart_products_pkey PRIMARY KEY (id, variant_id, cart_id);
\ No newline at end of file
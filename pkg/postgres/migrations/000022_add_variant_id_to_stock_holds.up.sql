@@ -0,0 +1,3 @@
+ALTER TABLE stock_holds ADD COLUMN IF NOT EXISTS variant_id text NOT NULL DEFAULT '';
+ALTER TABLE stock_holds DROP CONSTRAINT IF EXISTS stock_holds_pkey;
+ALTER TABLE stock_holds ADD CONSTRAINT stock_holds_pkey PRIMARY KEY (product_id, variant_id, cart_id);
\ No newline at end of file
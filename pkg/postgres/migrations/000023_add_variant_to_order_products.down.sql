@@ -0,0 +1,3 @@
+ALTER TABLE order_products DROP COLUMN IF EXISTS options;
+ALTER TABLE order_products DROP COLUMN IF EXISTS sku;
+ALTER TABLE order_products DROP COLUMN IF EXISTS variant_id;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE order_products ADD COLUMN IF NOT EXISTS variant_id text;
+ALTER TABLE order_products ADD COLUMN IF NOT EXISTS sku text;
+ALTER TABLE order_products ADD COLUMN IF NOT EXISTS options text[];
\ No newline at end of file
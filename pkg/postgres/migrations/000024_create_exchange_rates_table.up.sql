@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS exchange_rates
+(
+    currency text NOT NULL,
+    rate numeric NOT NULL,
+    updated_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT exchange_rates_pkey PRIMARY KEY (currency),
+    CONSTRAINT exchange_rates_rate_check CHECK (rate > 0)
+);
\ No newline at end of file
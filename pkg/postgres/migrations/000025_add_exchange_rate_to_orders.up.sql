@@ -0,0 +1,2 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS base_currency text;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS exchange_rate numeric;
\ No newline at end of file
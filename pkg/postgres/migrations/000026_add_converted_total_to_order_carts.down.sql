@@ -0,0 +1 @@
+ALTER TABLE order_carts DROP COLUMN IF EXISTS converted_total;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE order_carts ADD COLUMN IF NOT EXISTS converted_total integer;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tax_rules;
\ No newline at end of file
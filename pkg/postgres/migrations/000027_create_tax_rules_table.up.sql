@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS tax_rules
+(
+    id text NOT NULL,
+    name text NOT NULL,
+    country text NOT NULL,
+    state text,
+    zip_code text,
+    category text,
+    rate integer NOT NULL DEFAULT 0,
+    inclusive boolean NOT NULL DEFAULT false,
+    compound boolean NOT NULL DEFAULT false,
+    exempt boolean NOT NULL DEFAULT false,
+    priority integer NOT NULL DEFAULT 0,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT tax_rules_pkey PRIMARY KEY (id),
+    CONSTRAINT tax_rules_rate_check CHECK (rate >= 0)
+);
\ No newline at end of file
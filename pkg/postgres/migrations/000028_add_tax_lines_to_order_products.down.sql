@@ -0,0 +1 @@
+ALTER TABLE order_products DROP COLUMN IF EXISTS tax_lines;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE order_products ADD COLUMN IF NOT EXISTS tax_lines jsonb;
\ No newline at end of file
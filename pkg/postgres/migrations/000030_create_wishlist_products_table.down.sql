@@ -0,0 +1 @@
+DROP TABLE IF EXISTS wishlist_products;
\ No newline at end of file
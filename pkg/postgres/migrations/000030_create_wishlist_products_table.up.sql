@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS wishlist_products
+(
+    wishlist_id text NOT NULL,
+    id text NOT NULL,
+    variant_id text NOT NULL DEFAULT '',
+    quantity integer NOT NULL,
+    added_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT wishlist_products_pkey PRIMARY KEY (wishlist_id, id, variant_id),
+    FOREIGN KEY (wishlist_id) REFERENCES wishlists (id) ON DELETE CASCADE,
+    FOREIGN KEY (id) REFERENCES products (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE cart_products DROP COLUMN IF EXISTS total;
+ALTER TABLE cart_products DROP COLUMN IF EXISTS subtotal;
+ALTER TABLE cart_products DROP COLUMN IF EXISTS taxes;
+ALTER TABLE cart_products DROP COLUMN IF EXISTS discount;
+ALTER TABLE cart_products DROP COLUMN IF EXISTS weight;
\ No newline at end of file
@@ -0,0 +1,14 @@
+ALTER TABLE cart_products ADD COLUMN IF NOT EXISTS weight integer NOT NULL DEFAULT 0;
+ALTER TABLE cart_products ADD COLUMN IF NOT EXISTS discount integer NOT NULL DEFAULT 0;
+ALTER TABLE cart_products ADD COLUMN IF NOT EXISTS taxes integer NOT NULL DEFAULT 0;
+ALTER TABLE cart_products ADD COLUMN IF NOT EXISTS subtotal integer NOT NULL DEFAULT 0;
+ALTER TABLE cart_products ADD COLUMN IF NOT EXISTS total integer NOT NULL DEFAULT 0;
+UPDATE cart_products AS cp SET
+(weight, discount, taxes, subtotal, total) = (
+    SELECT COALESCE(v.weight, p.weight, 0), COALESCE(v.discount, p.discount, 0),
+    COALESCE(v.taxes, p.taxes, 0), COALESCE(v.subtotal, p.subtotal, 0), COALESCE(v.total, p.total, 0)
+    FROM products AS p
+    LEFT JOIN product_variants AS v ON v.id=cp.variant_id AND v.product_id=p.id
+    WHERE p.id=cp.id
+)
+WHERE EXISTS (SELECT 1 FROM products WHERE id=cp.id);
\ No newline at end of file
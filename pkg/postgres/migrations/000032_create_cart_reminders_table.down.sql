@@ -0,0 +1 @@
+DROP TABLE IF EXISTS cart_reminders;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS cart_reminders
+(
+    cart_id text NOT NULL,
+    user_id text NOT NULL,
+    cart_updated_at timestamp with time zone NOT NULL,
+    sent_at timestamp with time zone DEFAULT NOW(),
+    order_id text,
+    recovered_at timestamp with time zone,
+    CONSTRAINT cart_reminders_pkey PRIMARY KEY (cart_id, cart_updated_at),
+    FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
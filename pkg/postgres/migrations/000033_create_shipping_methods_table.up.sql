@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS shipping_methods
+(
+    id text NOT NULL,
+    name text NOT NULL,
+    type text NOT NULL,
+    countries text[],
+    rate integer NOT NULL DEFAULT 0,
+    brackets jsonb,
+    free_above integer NOT NULL DEFAULT 0,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT shipping_methods_pkey PRIMARY KEY (id),
+    CONSTRAINT shipping_methods_rate_check CHECK (rate >= 0)
+);
\ No newline at end of file
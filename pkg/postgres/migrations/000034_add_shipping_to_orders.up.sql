@@ -0,0 +1,3 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_method_id text;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_method text;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_cost integer NOT NULL DEFAULT 0;
\ No newline at end of file
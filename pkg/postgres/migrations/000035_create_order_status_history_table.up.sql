@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS order_status_history
+(
+    order_id text NOT NULL,
+    from_status text NOT NULL,
+    to_status text NOT NULL,
+    changed_by text NOT NULL,
+    changed_at timestamp with time zone DEFAULT NOW(),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE order_products DROP COLUMN IF EXISTS refunded;
+ALTER TABLE orders DROP COLUMN IF EXISTS refunded;
+ALTER TABLE orders DROP COLUMN IF EXISTS payment_intent_id;
\ No newline at end of file
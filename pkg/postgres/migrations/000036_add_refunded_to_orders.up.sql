@@ -0,0 +1,3 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_intent_id text;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded integer NOT NULL DEFAULT 0;
+ALTER TABLE order_products ADD COLUMN IF NOT EXISTS refunded integer NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS refunds
+(
+    id text NOT NULL,
+    order_id text NOT NULL,
+    provider_id text,
+    amount integer NOT NULL,
+    lines jsonb,
+    created_by text NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT refunds_pkey PRIMARY KEY (id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
\ No newline at end of file
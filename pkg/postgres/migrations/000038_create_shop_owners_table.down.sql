@@ -0,0 +1 @@
+DROP TABLE IF EXISTS shop_owners;
\ No newline at end of file
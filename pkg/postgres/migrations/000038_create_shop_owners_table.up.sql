@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS shop_owners
+(
+    shop_id text NOT NULL,
+    user_id text NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT shop_owners_pkey PRIMARY KEY (shop_id, user_id),
+    FOREIGN KEY (shop_id) REFERENCES shops (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
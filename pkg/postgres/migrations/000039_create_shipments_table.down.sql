@@ -0,0 +1 @@
+DROP TABLE IF EXISTS shipments;
\ No newline at end of file
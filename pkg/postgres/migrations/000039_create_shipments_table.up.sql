@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS shipments
+(
+    id text NOT NULL,
+    order_id text NOT NULL,
+    carrier text NOT NULL,
+    tracking_number text NOT NULL,
+    status text NOT NULL,
+    created_by text NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+    delivered_at timestamp with time zone,
+    CONSTRAINT shipments_pkey PRIMARY KEY (id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS shipment_lines;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS shipment_lines
+(
+    shipment_id text NOT NULL,
+    product_id text NOT NULL,
+    variant_id text NOT NULL DEFAULT '',
+    quantity integer NOT NULL,
+    CONSTRAINT shipment_lines_pkey PRIMARY KEY (shipment_id, product_id, variant_id),
+    CONSTRAINT shipment_lines_quantity_check CHECK (quantity > 0),
+    FOREIGN KEY (shipment_id) REFERENCES shipments (id) ON DELETE CASCADE
+);
\ No newline at end of file
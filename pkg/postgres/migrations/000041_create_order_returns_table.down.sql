@@ -0,0 +1 @@
+DROP TABLE IF EXISTS order_returns;
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS order_returns
+(
+    id text NOT NULL,
+    order_id text NOT NULL,
+    user_id text NOT NULL,
+    reason text NOT NULL,
+    lines jsonb NOT NULL,
+    status text NOT NULL,
+    note text,
+    refund_id text,
+    restocked boolean NOT NULL DEFAULT false,
+    created_at timestamp with time zone DEFAULT NOW(),
+    updated_at timestamp with time zone,
+    CONSTRAINT order_returns_pkey PRIMARY KEY (id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
\ No newline at end of file
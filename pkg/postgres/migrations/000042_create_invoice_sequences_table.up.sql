@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS invoice_sequences
+(
+    shop_id text NOT NULL,
+    last_number integer NOT NULL,
+    CONSTRAINT invoice_sequences_pkey PRIMARY KEY (shop_id)
+);
\ No newline at end of file
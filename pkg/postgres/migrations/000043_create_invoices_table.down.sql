@@ -0,0 +1 @@
+DROP TABLE IF EXISTS invoices;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS invoices
+(
+    id text NOT NULL,
+    order_id text NOT NULL,
+    shop_id text NOT NULL,
+    number integer NOT NULL,
+    product_ids text[] NOT NULL,
+    issued_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT invoices_pkey PRIMARY KEY (id),
+    CONSTRAINT invoices_shop_id_number_key UNIQUE (shop_id, number),
+    CONSTRAINT invoices_order_id_shop_id_key UNIQUE (order_id, shop_id)
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE order_products DROP COLUMN IF EXISTS shop_id;
\ No newline at end of file
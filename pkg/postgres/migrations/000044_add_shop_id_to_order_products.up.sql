@@ -0,0 +1 @@
+ALTER TABLE order_products ADD COLUMN IF NOT EXISTS shop_id text;
\ No newline at end of file
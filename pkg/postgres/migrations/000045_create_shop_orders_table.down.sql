@@ -0,0 +1 @@
+DROP TABLE IF EXISTS shop_orders;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS shop_orders
+(
+    id text NOT NULL,
+    order_id text NOT NULL,
+    shop_id text NOT NULL,
+    status integer NOT NULL,
+    counter integer NOT NULL DEFAULT 0,
+    subtotal integer NOT NULL DEFAULT 0,
+    discount integer NOT NULL DEFAULT 0,
+    taxes integer NOT NULL DEFAULT 0,
+    shipping_cost integer NOT NULL DEFAULT 0,
+    total integer NOT NULL DEFAULT 0,
+    created_at timestamp with time zone DEFAULT NOW(),
+    updated_at timestamp with time zone,
+    CONSTRAINT shop_orders_pkey PRIMARY KEY (id),
+    CONSTRAINT shop_orders_order_id_shop_id_key UNIQUE (order_id, shop_id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS delivery_slots;
\ No newline at end of file
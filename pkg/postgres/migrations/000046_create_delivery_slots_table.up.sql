@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS delivery_slots
+(
+    id text NOT NULL,
+    shop_id text NOT NULL,
+    type text NOT NULL,
+    weekday integer NOT NULL,
+    start_time text NOT NULL,
+    end_time text NOT NULL,
+    capacity integer NOT NULL,
+    countries text[],
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT delivery_slots_pkey PRIMARY KEY (id),
+    CONSTRAINT delivery_slots_weekday_check CHECK (weekday BETWEEN 0 AND 6),
+    CONSTRAINT delivery_slots_capacity_check CHECK (capacity > 0),
+    FOREIGN KEY (shop_id) REFERENCES shops (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS slot_bookings;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS slot_bookings
+(
+    order_id text NOT NULL,
+    shop_id text NOT NULL,
+    slot_id text NOT NULL,
+    date date NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT slot_bookings_pkey PRIMARY KEY (order_id, shop_id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    FOREIGN KEY (slot_id) REFERENCES delivery_slots (id) ON DELETE CASCADE
+);
\ No newline at end of file
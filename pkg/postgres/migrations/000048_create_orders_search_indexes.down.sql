@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS orders_ordered_at_idx;
+DROP INDEX IF EXISTS orders_address_search_idx;
\ No newline at end of file
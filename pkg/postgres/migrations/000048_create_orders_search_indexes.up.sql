@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS orders_ordered_at_idx ON orders (ordered_at);
+
+CREATE INDEX IF NOT EXISTS orders_address_search_idx ON orders USING GIN (to_tsvector('simple', COALESCE(address, '') || ' ' || COALESCE(city, '') || ' ' ||
+COALESCE(state, '') || ' ' || COALESCE(zip_code, '') || ' ' || COALESCE(country, '')));
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS subscriptions;
\ No newline at end of file
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS subscriptions
+(
+    id text NOT NULL,
+    user_id text NOT NULL,
+    cart_id text NOT NULL,
+    status text NOT NULL,
+    frequency text NOT NULL,
+    interval_days integer NOT NULL DEFAULT 0,
+    currency text NOT NULL,
+    address text NOT NULL,
+    city text NOT NULL,
+    country text NOT NULL,
+    state text NOT NULL,
+    zip_code text NOT NULL,
+    shipping_method text,
+    customer_id text,
+    payment_method_id text,
+    next_order_at timestamp with time zone NOT NULL,
+    failures integer NOT NULL DEFAULT 0,
+    last_order_id text,
+    last_error text,
+    created_at timestamp with time zone DEFAULT NOW(),
+    updated_at timestamp with time zone,
+    CONSTRAINT subscriptions_pkey PRIMARY KEY (id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS subscription_products;
\ No newline at end of file
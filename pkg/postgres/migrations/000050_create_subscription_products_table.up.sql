@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS subscription_products
+(
+    subscription_id text NOT NULL,
+    product_id text NOT NULL,
+    variant_id text NOT NULL DEFAULT '',
+    quantity integer NOT NULL,
+    CONSTRAINT subscription_products_pkey PRIMARY KEY (subscription_id, product_id, variant_id),
+    CONSTRAINT subscription_products_quantity_check CHECK (quantity > 0),
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions (id) ON DELETE CASCADE
+);
\ No newline at end of file
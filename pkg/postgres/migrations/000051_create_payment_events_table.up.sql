@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS payment_events
+(
+    id text NOT NULL,
+    type text NOT NULL,
+    order_id text NOT NULL,
+    received_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT payment_events_pkey PRIMARY KEY (id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
\ No newline at end of file
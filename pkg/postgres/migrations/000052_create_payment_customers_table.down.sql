@@ -0,0 +1 @@
+DROP TABLE IF EXISTS payment_customers;
\ No newline at end of file
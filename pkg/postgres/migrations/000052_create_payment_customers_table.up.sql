@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS payment_customers
+(
+    user_id text NOT NULL,
+    customer_id text NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT payment_customers_pkey PRIMARY KEY (user_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
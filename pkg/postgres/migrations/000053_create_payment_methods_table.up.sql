@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS payment_methods
+(
+    id text NOT NULL,
+    user_id text NOT NULL,
+    brand text,
+    last4 text,
+    exp_month integer,
+    exp_year integer,
+    is_default boolean NOT NULL DEFAULT false,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT payment_methods_pkey PRIMARY KEY (id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
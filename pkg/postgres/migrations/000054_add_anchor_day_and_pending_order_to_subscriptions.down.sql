@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS pending_order_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS anchor_day;
\ No newline at end of file
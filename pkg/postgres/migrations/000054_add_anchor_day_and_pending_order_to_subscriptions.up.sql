@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS anchor_day integer NOT NULL DEFAULT 0;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS pending_order_id text;
+UPDATE subscriptions SET anchor_day=EXTRACT(DAY FROM next_order_at) WHERE anchor_day=0;
\ No newline at end of file
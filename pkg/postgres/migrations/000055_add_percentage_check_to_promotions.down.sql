@@ -0,0 +1 @@
+ALTER TABLE promotions DROP CONSTRAINT IF EXISTS promotions_percentage_check;
@@ -0,0 +1,2 @@
+UPDATE promotions SET value=100 WHERE type='percentage_off' AND value>100;
+ALTER TABLE promotions ADD CONSTRAINT promotions_percentage_check CHECK (type <> 'percentage_off' OR value BETWEEN 0 AND 100);
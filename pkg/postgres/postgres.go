@@ -164,7 +164,14 @@ CREATE TABLE IF NOT EXISTS cart_products
     id text NOT NULL,
     cart_id text NOT NULL,
     quantity integer NOT NULL,
-    CONSTRAINT cart_products_pkey PRIMARY KEY (id),
+    updated_at timestamp with time zone NOT NULL DEFAULT NOW(),
+    variant_id text NOT NULL DEFAULT '',
+    weight integer NOT NULL DEFAULT 0,
+    discount integer NOT NULL DEFAULT 0,
+    taxes integer NOT NULL DEFAULT 0,
+    subtotal integer NOT NULL DEFAULT 0,
+    total integer NOT NULL DEFAULT 0,
+    CONSTRAINT cart_products_pkey PRIMARY KEY (id, variant_id, cart_id),
     FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE
 );
 
@@ -196,6 +203,13 @@ CREATE TABLE IF NOT EXISTS orders
     created_at timestamp with time zone DEFAULT NOW(),
     ordered_at timestamp with time zone,
     delivery_date timestamp with time zone,
+    base_currency text,
+    exchange_rate numeric,
+    shipping_method_id text,
+    shipping_method text,
+    shipping_cost integer NOT NULL DEFAULT 0,
+    payment_intent_id text,
+    refunded integer NOT NULL DEFAULT 0,
     CONSTRAINT orders_pkey PRIMARY KEY (id),
     FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
 );
@@ -209,6 +223,8 @@ CREATE TABLE IF NOT EXISTS order_carts
     taxes integer,
     subtotal integer,
     total integer,
+    promotion_codes text[],
+    converted_total integer,
     FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
 );
 
@@ -226,10 +242,404 @@ CREATE TABLE IF NOT EXISTS order_products
     taxes integer,
     subtotal integer,
     total integer,
+    variant_id text,
+    sku text,
+    options text[],
+    tax_lines jsonb,
+    refunded integer NOT NULL DEFAULT 0,
+    shop_id text,
     FOREIGN KEY (order_id) 
         REFERENCES orders (id)
         ON DELETE CASCADE
         DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS stock_holds
+(
+    product_id text NOT NULL,
+    cart_id text NOT NULL,
+    quantity integer NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    variant_id text NOT NULL DEFAULT '',
+    CONSTRAINT stock_holds_pkey PRIMARY KEY (product_id, variant_id, cart_id),
+    FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS promotions
+(
+    id text NOT NULL,
+    code text NOT NULL,
+    type text NOT NULL,
+    value integer NOT NULL DEFAULT 0,
+    product_id text,
+    buy_quantity integer,
+    get_quantity integer,
+    min_total integer NOT NULL DEFAULT 0,
+    usage_limit integer NOT NULL DEFAULT 0,
+    user_usage_limit integer NOT NULL DEFAULT 0,
+    starts_at timestamp with time zone,
+    ends_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT promotions_pkey PRIMARY KEY (id),
+    CONSTRAINT promotions_code_key UNIQUE (code),
+    CONSTRAINT promotions_percentage_check CHECK (type <> 'percentage_off' OR value BETWEEN 0 AND 100),
+    FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS cart_promotions
+(
+    cart_id text NOT NULL,
+    promotion_id text NOT NULL,
+    CONSTRAINT cart_promotions_pkey PRIMARY KEY (cart_id, promotion_id),
+    FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE,
+    FOREIGN KEY (promotion_id) REFERENCES promotions (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS promotion_redemptions
+(
+    promotion_id text NOT NULL,
+    order_id text NOT NULL,
+    user_id text NOT NULL,
+    code text NOT NULL,
+    discount integer NOT NULL,
+    redeemed_at timestamp with time zone DEFAULT NOW(),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS guest_carts
+(
+    cart_id text NOT NULL,
+    last_activity timestamp with time zone NOT NULL,
+    CONSTRAINT guest_carts_pkey PRIMARY KEY (cart_id),
+    FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS product_options
+(
+    product_id text NOT NULL,
+    position integer NOT NULL,
+    name text NOT NULL,
+    option_values text[] NOT NULL,
+    CONSTRAINT product_options_pkey PRIMARY KEY (product_id, position),
+    FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS product_variants
+(
+    id text NOT NULL,
+    product_id text NOT NULL,
+    sku text NOT NULL,
+    options text[] NOT NULL,
+    stock integer NOT NULL,
+    weight integer,
+    discount integer,
+    taxes integer,
+    subtotal integer,
+    total integer,
+    created_at timestamp with time zone DEFAULT NOW(),
+    updated_at timestamp with time zone,
+    CONSTRAINT product_variants_pkey PRIMARY KEY (id),
+    CONSTRAINT product_variants_sku_key UNIQUE (sku),
+    CONSTRAINT product_variants_options_key UNIQUE (product_id, options),
+    FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS exchange_rates
+(
+    currency text NOT NULL,
+    rate numeric NOT NULL,
+    updated_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT exchange_rates_pkey PRIMARY KEY (currency),
+    CONSTRAINT exchange_rates_rate_check CHECK (rate > 0)
+);
+
+CREATE TABLE IF NOT EXISTS tax_rules
+(
+    id text NOT NULL,
+    name text NOT NULL,
+    country text NOT NULL,
+    state text,
+    zip_code text,
+    category text,
+    rate integer NOT NULL DEFAULT 0,
+    inclusive boolean NOT NULL DEFAULT false,
+    compound boolean NOT NULL DEFAULT false,
+    exempt boolean NOT NULL DEFAULT false,
+    priority integer NOT NULL DEFAULT 0,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT tax_rules_pkey PRIMARY KEY (id),
+    CONSTRAINT tax_rules_rate_check CHECK (rate >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS wishlists
+(
+    id text NOT NULL,
+    user_id text NOT NULL,
+    name text NOT NULL,
+    share_token text,
+    created_at timestamp with time zone DEFAULT NOW(),
+    updated_at timestamp with time zone,
+    CONSTRAINT wishlists_pkey PRIMARY KEY (id),
+    CONSTRAINT wishlists_share_token_key UNIQUE (share_token),
+    CONSTRAINT wishlists_user_id_name_key UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS wishlist_products
+(
+    wishlist_id text NOT NULL,
+    id text NOT NULL,
+    variant_id text NOT NULL DEFAULT '',
+    quantity integer NOT NULL,
+    added_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT wishlist_products_pkey PRIMARY KEY (wishlist_id, id, variant_id),
+    FOREIGN KEY (wishlist_id) REFERENCES wishlists (id) ON DELETE CASCADE,
+    FOREIGN KEY (id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS cart_reminders
+(
+    cart_id text NOT NULL,
+    user_id text NOT NULL,
+    cart_updated_at timestamp with time zone NOT NULL,
+    sent_at timestamp with time zone DEFAULT NOW(),
+    order_id text,
+    recovered_at timestamp with time zone,
+    CONSTRAINT cart_reminders_pkey PRIMARY KEY (cart_id, cart_updated_at),
+    FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS shipping_methods
+(
+    id text NOT NULL,
+    name text NOT NULL,
+    type text NOT NULL,
+    countries text[],
+    rate integer NOT NULL DEFAULT 0,
+    brackets jsonb,
+    free_above integer NOT NULL DEFAULT 0,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT shipping_methods_pkey PRIMARY KEY (id),
+    CONSTRAINT shipping_methods_rate_check CHECK (rate >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS order_status_history
+(
+    order_id text NOT NULL,
+    from_status text NOT NULL,
+    to_status text NOT NULL,
+    changed_by text NOT NULL,
+    changed_at timestamp with time zone DEFAULT NOW(),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS refunds
+(
+    id text NOT NULL,
+    order_id text NOT NULL,
+    provider_id text,
+    amount integer NOT NULL,
+    lines jsonb,
+    created_by text NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT refunds_pkey PRIMARY KEY (id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS shop_owners
+(
+    shop_id text NOT NULL,
+    user_id text NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT shop_owners_pkey PRIMARY KEY (shop_id, user_id),
+    FOREIGN KEY (shop_id) REFERENCES shops (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS shipments
+(
+    id text NOT NULL,
+    order_id text NOT NULL,
+    carrier text NOT NULL,
+    tracking_number text NOT NULL,
+    status text NOT NULL,
+    created_by text NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+    delivered_at timestamp with time zone,
+    CONSTRAINT shipments_pkey PRIMARY KEY (id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS shipment_lines
+(
+    shipment_id text NOT NULL,
+    product_id text NOT NULL,
+    variant_id text NOT NULL DEFAULT '',
+    quantity integer NOT NULL,
+    CONSTRAINT shipment_lines_pkey PRIMARY KEY (shipment_id, product_id, variant_id),
+    CONSTRAINT shipment_lines_quantity_check CHECK (quantity > 0),
+    FOREIGN KEY (shipment_id) REFERENCES shipments (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS order_returns
+(
+    id text NOT NULL,
+    order_id text NOT NULL,
+    user_id text NOT NULL,
+    reason text NOT NULL,
+    lines jsonb NOT NULL,
+    status text NOT NULL,
+    note text,
+    refund_id text,
+    restocked boolean NOT NULL DEFAULT false,
+    created_at timestamp with time zone DEFAULT NOW(),
+    updated_at timestamp with time zone,
+    CONSTRAINT order_returns_pkey PRIMARY KEY (id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS invoice_sequences
+(
+    shop_id text NOT NULL,
+    last_number integer NOT NULL,
+    CONSTRAINT invoice_sequences_pkey PRIMARY KEY (shop_id)
+);
+
+CREATE TABLE IF NOT EXISTS invoices
+(
+    id text NOT NULL,
+    order_id text NOT NULL,
+    shop_id text NOT NULL,
+    number integer NOT NULL,
+    product_ids text[] NOT NULL,
+    issued_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT invoices_pkey PRIMARY KEY (id),
+    CONSTRAINT invoices_shop_id_number_key UNIQUE (shop_id, number),
+    CONSTRAINT invoices_order_id_shop_id_key UNIQUE (order_id, shop_id)
+);
+
+CREATE TABLE IF NOT EXISTS shop_orders
+(
+    id text NOT NULL,
+    order_id text NOT NULL,
+    shop_id text NOT NULL,
+    status integer NOT NULL,
+    counter integer NOT NULL DEFAULT 0,
+    subtotal integer NOT NULL DEFAULT 0,
+    discount integer NOT NULL DEFAULT 0,
+    taxes integer NOT NULL DEFAULT 0,
+    shipping_cost integer NOT NULL DEFAULT 0,
+    total integer NOT NULL DEFAULT 0,
+    created_at timestamp with time zone DEFAULT NOW(),
+    updated_at timestamp with time zone,
+    CONSTRAINT shop_orders_pkey PRIMARY KEY (id),
+    CONSTRAINT shop_orders_order_id_shop_id_key UNIQUE (order_id, shop_id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS delivery_slots
+(
+    id text NOT NULL,
+    shop_id text NOT NULL,
+    type text NOT NULL,
+    weekday integer NOT NULL,
+    start_time text NOT NULL,
+    end_time text NOT NULL,
+    capacity integer NOT NULL,
+    countries text[],
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT delivery_slots_pkey PRIMARY KEY (id),
+    CONSTRAINT delivery_slots_weekday_check CHECK (weekday BETWEEN 0 AND 6),
+    CONSTRAINT delivery_slots_capacity_check CHECK (capacity > 0),
+    FOREIGN KEY (shop_id) REFERENCES shops (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS slot_bookings
+(
+    order_id text NOT NULL,
+    shop_id text NOT NULL,
+    slot_id text NOT NULL,
+    date date NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT slot_bookings_pkey PRIMARY KEY (order_id, shop_id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE,
+    FOREIGN KEY (slot_id) REFERENCES delivery_slots (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS subscriptions
+(
+    id text NOT NULL,
+    user_id text NOT NULL,
+    cart_id text NOT NULL,
+    status text NOT NULL,
+    frequency text NOT NULL,
+    interval_days integer NOT NULL DEFAULT 0,
+    anchor_day integer NOT NULL DEFAULT 0,
+    currency text NOT NULL,
+    address text NOT NULL,
+    city text NOT NULL,
+    country text NOT NULL,
+    state text NOT NULL,
+    zip_code text NOT NULL,
+    shipping_method text,
+    customer_id text,
+    payment_method_id text,
+    next_order_at timestamp with time zone NOT NULL,
+    failures integer NOT NULL DEFAULT 0,
+    last_order_id text,
+    pending_order_id text,
+    last_error text,
+    created_at timestamp with time zone DEFAULT NOW(),
+    updated_at timestamp with time zone,
+    CONSTRAINT subscriptions_pkey PRIMARY KEY (id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (cart_id) REFERENCES carts (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS subscription_products
+(
+    subscription_id text NOT NULL,
+    product_id text NOT NULL,
+    variant_id text NOT NULL DEFAULT '',
+    quantity integer NOT NULL,
+    CONSTRAINT subscription_products_pkey PRIMARY KEY (subscription_id, product_id, variant_id),
+    CONSTRAINT subscription_products_quantity_check CHECK (quantity > 0),
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS payment_events
+(
+    id text NOT NULL,
+    type text NOT NULL,
+    order_id text NOT NULL,
+    received_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT payment_events_pkey PRIMARY KEY (id),
+    FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS payment_customers
+(
+    user_id text NOT NULL,
+    customer_id text NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT payment_customers_pkey PRIMARY KEY (user_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS payment_methods
+(
+    id text NOT NULL,
+    user_id text NOT NULL,
+    brand text,
+    last4 text,
+    exp_month integer,
+    exp_year integer,
+    is_default boolean NOT NULL DEFAULT false,
+    created_at timestamp with time zone DEFAULT NOW(),
+    CONSTRAINT payment_methods_pkey PRIMARY KEY (id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
 );`
 
 const indexes = `
@@ -241,7 +651,34 @@ CREATE INDEX ON users (created_at);
 CREATE INDEX ON shops (created_at);
 CREATE INDEX ON products (created_at);
 CREATE INDEX ON reviews (created_at);
-CREATE INDEX ON orders (created_at);`
+CREATE INDEX ON orders (created_at);
+CREATE INDEX ON promotions (created_at);
+CREATE INDEX ON stock_holds (expires_at);
+CREATE INDEX ON promotion_redemptions (promotion_id, user_id);
+CREATE INDEX ON guest_carts (last_activity);
+CREATE INDEX ON product_variants (product_id);
+CREATE INDEX ON tax_rules (created_at);
+CREATE INDEX ON tax_rules (LOWER(country));
+CREATE INDEX ON wishlists (user_id);
+CREATE INDEX ON orders (cart_id);
+CREATE INDEX ON shipping_methods (created_at);
+CREATE INDEX ON order_status_history (order_id);
+CREATE INDEX ON refunds (order_id);
+CREATE INDEX ON shop_owners (user_id);
+CREATE INDEX ON shipments (order_id);
+CREATE INDEX ON order_returns (created_at);
+CREATE INDEX ON order_returns (order_id);
+CREATE INDEX ON order_returns (user_id);
+CREATE INDEX ON shop_orders (shop_id, created_at);
+CREATE INDEX ON delivery_slots (shop_id);
+CREATE INDEX ON slot_bookings (slot_id, date);
+CREATE INDEX ON orders (ordered_at);
+CREATE INDEX ON orders USING GIN (to_tsvector('simple', COALESCE(address, '') || ' ' || COALESCE(city, '') || ' ' ||
+COALESCE(state, '') || ' ' || COALESCE(zip_code, '') || ' ' || COALESCE(country, '')));
+CREATE INDEX ON subscriptions (user_id);
+CREATE INDEX ON subscriptions (status, next_order_at);
+CREATE INDEX ON payment_events (order_id);
+CREATE INDEX ON payment_methods (user_id);`
 
 const triggers = `
 CREATE OR REPLACE FUNCTION users_tsvector_trigger() RETURNS trigger AS $$
@@ -2,6 +2,8 @@ package postgres
 
 import (
 	"bytes"
+	"strconv"
+	"strings"
 
 	"github.com/GGP1/adak/internal/params"
 )
@@ -34,3 +36,60 @@ func AddPagination(query string, params params.Query) (string, []interface{}) {
 
 	return buf.String(), args
 }
+
+// Conditions accumulates the conditions of a query and their arguments.
+type Conditions struct {
+	conditions []string
+	args       []interface{}
+}
+
+// Add appends a condition, each "?" placeholder in it is replaced by the position of the
+// corresponding argument.
+func (c *Conditions) Add(condition string, args ...interface{}) {
+	for _, arg := range args {
+		c.args = append(c.args, arg)
+		condition = strings.Replace(condition, "?", "$"+strconv.Itoa(len(c.args)), 1)
+	}
+	c.conditions = append(c.conditions, condition)
+}
+
+// Args returns the arguments of the conditions in order.
+func (c *Conditions) Args() []interface{} {
+	return c.args
+}
+
+// Where returns the WHERE clause joining the conditions, it's empty if there are none.
+func (c *Conditions) Where() string {
+	if len(c.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(c.conditions, " AND ")
+}
+
+// AddFilteredPagination is like AddPagination but the query is filtered by the conditions
+// and sorted as requested in the params.
+//
+// column is the timestamp the objects are sorted by, the cursor points to a value of it,
+// created_at is used if it's empty.
+func AddFilteredPagination(query string, conds Conditions, params params.Query, column string) (string, []interface{}) {
+	if column == "" {
+		column = "created_at"
+	}
+	op, direction := "<", "DESC"
+	if params.Sort.Asc {
+		op, direction = ">", "ASC"
+	}
+
+	if params.Cursor.Used {
+		conds.Add("("+column+" "+op+" ? OR ("+column+" = ? AND id "+op+" ?))",
+			params.Cursor.CreatedAt, params.Cursor.CreatedAt, params.Cursor.ID)
+	}
+	args := append(conds.Args(), params.Limit)
+
+	buf := bytes.NewBufferString(query)
+	buf.WriteString(conds.Where())
+	buf.WriteString(" ORDER BY " + column + " " + direction + ", id " + direction)
+	buf.WriteString(" LIMIT $" + strconv.Itoa(len(args)))
+
+	return buf.String(), args
+}
@@ -1 +1,42 @@
 package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GGP1/adak/internal/params"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditions(t *testing.T) {
+	var conds Conditions
+	assert.Equal(t, "", conds.Where())
+
+	conds.Add("deleted_at IS NULL")
+	conds.Add("status=?", 1)
+	conds.Add("total BETWEEN ? AND ?", 10, 20)
+
+	assert.Equal(t, " WHERE deleted_at IS NULL AND status=$1 AND total BETWEEN $2 AND $3", conds.Where())
+	assert.Equal(t, []interface{}{1, 10, 20}, conds.Args())
+}
+
+func TestAddFilteredPagination(t *testing.T) {
+	var conds Conditions
+	conds.Add("status=?", 1)
+	createdAt := time.Unix(15000, 0)
+	query := params.Query{
+		Limit:  "20",
+		Cursor: params.Cursor{Used: true, CreatedAt: createdAt, ID: "id"},
+		Sort:   params.Sort{Field: "ordered_at", Asc: true},
+	}
+
+	q, args := AddFilteredPagination("SELECT * FROM orders", conds, query, "ordered_at")
+	assert.Equal(t, "SELECT * FROM orders WHERE status=$1 AND (ordered_at > $2 OR (ordered_at = $3 AND id > $4))"+
+		" ORDER BY ordered_at ASC, id ASC LIMIT $5", q)
+	assert.Equal(t, []interface{}{1, createdAt, createdAt, "id", "20"}, args)
+
+	q, args = AddFilteredPagination("SELECT * FROM orders", Conditions{}, params.Query{Limit: "10"}, "")
+	assert.Equal(t, "SELECT * FROM orders ORDER BY created_at DESC, id DESC LIMIT $1", q)
+	assert.Equal(t, []interface{}{"10"}, args)
+}
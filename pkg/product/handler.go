@@ -54,6 +54,11 @@ func (h *Handler) Create() http.HandlerFunc {
 
 		p.ID = zero.StringFrom(uuid.NewString())
 		p.CreatedAt = zero.TimeFrom(time.Now())
+		for i := range p.Variants {
+			p.Variants[i].ID = zero.StringFrom(uuid.NewString())
+			p.Variants[i].ProductID = p.ID
+			p.Variants[i].CreatedAt = p.CreatedAt
+		}
 		if err := h.service.Create(ctx, p); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
@@ -63,6 +68,41 @@ func (h *Handler) Create() http.HandlerFunc {
 	}
 }
 
+// CreateVariant adds a new variant to the product.
+func (h *Handler) CreateVariant() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		productID, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var v Variant
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, v); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		v.ID = zero.StringFrom(uuid.NewString())
+		v.ProductID = zero.StringFrom(productID)
+		v.CreatedAt = zero.TimeFrom(time.Now())
+		if err := h.service.CreateVariant(ctx, v); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusCreated, v)
+	}
+}
+
 // Delete removes a product.
 func (h *Handler) Delete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -83,6 +123,32 @@ func (h *Handler) Delete() http.HandlerFunc {
 	}
 }
 
+// DeleteVariant removes a product variant.
+func (h *Handler) DeleteVariant() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		productID, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		variantID := chi.URLParam(r, "variantID")
+		if err := validate.UUID(variantID); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.service.DeleteVariant(ctx, productID, variantID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, variantID)
+	}
+}
+
 // Get lists all the products.
 func (h *Handler) Get() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -195,3 +261,41 @@ func (h *Handler) Update() http.HandlerFunc {
 		response.JSON(w, http.StatusOK, product)
 	}
 }
+
+// UpdateVariant updates the product variant with the given id.
+func (h *Handler) UpdateVariant() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		productID, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		variantID := chi.URLParam(r, "variantID")
+		if err := validate.UUID(variantID); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var variant UpdateVariant
+		if err := json.NewDecoder(r.Body).Decode(&variant); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, variant); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.service.UpdateVariant(ctx, productID, variantID, variant); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, variant)
+	}
+}
@@ -3,6 +3,8 @@ package product
 import (
 	"github.com/GGP1/adak/pkg/review"
 
+	"github.com/lib/pq"
+	"gopkg.in/guregu/null.v4"
 	"gopkg.in/guregu/null.v4/zero"
 )
 
@@ -25,10 +27,58 @@ type Product struct {
 	Subtotal  zero.Int        `json:"subtotal,omitempty" validate:"required"`
 	Total     zero.Int        `json:"total,omitempty" validate:"min=0"`
 	Reviews   []review.Review `json:"reviews,omitempty"`
+	Options   []Option        `json:"options,omitempty" validate:"max=3,dive"`
+	Variants  []Variant       `json:"variants,omitempty" validate:"dive"`
 	CreatedAt zero.Time       `json:"created_at,omitempty" db:"created_at"`
 	UpdatedAt zero.Time       `json:"updated_at,omitempty" db:"updated_at"`
 }
 
+// Option is an axis along which a product varies, like the size or the color.
+type Option struct {
+	Name   string         `json:"name" validate:"required,max=40"`
+	Values pq.StringArray `json:"values" db:"option_values" validate:"required,dive,required,max=40"`
+}
+
+// Variant is a combination of the product options values with its own stock.
+//
+// The weight and amounts override the product ones when they are set, even to zero.
+type Variant struct {
+	ID        zero.String `json:"id,omitempty"`
+	ProductID zero.String `json:"product_id,omitempty" db:"product_id"`
+	SKU       zero.String `json:"sku,omitempty" validate:"required,max=64"`
+	// Options contains a value for each one of the product options, in the same order
+	Options   pq.StringArray `json:"options,omitempty" validate:"required"`
+	Stock     int64          `json:"stock" validate:"min=0"`
+	Weight    null.Int       `json:"weight,omitempty" validate:"min=0"`
+	Discount  null.Int       `json:"discount,omitempty" validate:"min=0"`
+	Taxes     null.Int       `json:"taxes,omitempty" validate:"min=0"`
+	Subtotal  null.Int       `json:"subtotal,omitempty" validate:"min=0"`
+	Total     null.Int       `json:"total,omitempty" validate:"min=0"`
+	CreatedAt zero.Time      `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt zero.Time      `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// WithVariant returns the product with the stock, weight and amounts of the variant.
+func (p Product) WithVariant(v Variant) Product {
+	p.Stock = zero.NewInt(v.Stock, true)
+	if v.Weight.Valid {
+		p.Weight = zero.NewInt(v.Weight.Int64, true)
+	}
+	if v.Discount.Valid {
+		p.Discount = zero.NewInt(v.Discount.Int64, true)
+	}
+	if v.Taxes.Valid {
+		p.Taxes = zero.NewInt(v.Taxes.Int64, true)
+	}
+	if v.Subtotal.Valid {
+		p.Subtotal = zero.NewInt(v.Subtotal.Int64, true)
+	}
+	if v.Total.Valid {
+		p.Total = zero.NewInt(v.Total.Int64, true)
+	}
+	return p
+}
+
 // UpdateProduct is the structure used to update products.
 type UpdateProduct struct {
 	Stock       zero.Int    `json:"stock,omitempty"`
@@ -42,3 +92,14 @@ type UpdateProduct struct {
 	Subtotal    zero.Int    `json:"subtotal,omitempty" validate:"required"`
 	Total       zero.Int    `json:"total,omitempty" validate:"min=0"`
 }
+
+// UpdateVariant is the structure used to update variants.
+type UpdateVariant struct {
+	SKU      zero.String `json:"sku,omitempty" validate:"required,max=64"`
+	Stock    int64       `json:"stock" validate:"min=0"`
+	Weight   null.Int    `json:"weight,omitempty" validate:"min=0"`
+	Discount null.Int    `json:"discount,omitempty" validate:"min=0"`
+	Taxes    null.Int    `json:"taxes,omitempty" validate:"min=0"`
+	Subtotal null.Int    `json:"subtotal,omitempty" validate:"min=0"`
+	Total    null.Int    `json:"total,omitempty" validate:"min=0"`
+}
@@ -2,6 +2,8 @@ package product
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/GGP1/adak/internal/params"
 	"github.com/GGP1/adak/pkg/postgres"
@@ -14,11 +16,15 @@ import (
 // Service provides product operations.
 type Service interface {
 	Create(ctx context.Context, p Product) error
+	CreateVariant(ctx context.Context, v Variant) error
 	Delete(ctx context.Context, id string) error
+	DeleteVariant(ctx context.Context, productID, variantID string) error
 	Get(ctx context.Context, params params.Query) ([]Product, error)
 	GetByID(ctx context.Context, id string) (Product, error)
+	GetVariant(ctx context.Context, productID, variantID string) (Variant, error)
 	Search(ctx context.Context, query string) ([]Product, error)
 	Update(ctx context.Context, id string, p UpdateProduct) error
+	UpdateVariant(ctx context.Context, productID, variantID string, v UpdateVariant) error
 }
 
 type service struct {
@@ -32,25 +38,91 @@ func NewService(db *sqlx.DB, mc *memcache.Client) Service {
 	return &service{db, mc, initMetrics()}
 }
 
-// Create a product.
+// Create a product along with its options and variants.
 func (s *service) Create(ctx context.Context, p Product) error {
 	s.metrics.incMethodCalls("Create")
 
+	if err := validateVariants(p.Options, p.Variants); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
 	q := `INSERT INTO products 
 	(id, shop_id, stock, brand, category, type, description, 
 	weight, discount, taxes, subtotal, total, created_at)
 	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
-	_, err := s.db.ExecContext(ctx, q, p.ID, p.ShopID, p.Stock, p.Brand,
+	_, err = tx.ExecContext(ctx, q, p.ID, p.ShopID, p.Stock, p.Brand,
 		p.Category, p.Type, p.Description, p.Weight, p.Discount, p.Taxes,
 		p.Subtotal, p.Total, p.CreatedAt)
 	if err != nil {
 		return errors.Wrap(err, "couldn't create the product")
 	}
 
+	optionsQ := "INSERT INTO product_options (product_id, position, name, option_values) VALUES ($1, $2, $3, $4)"
+	for i, o := range p.Options {
+		if _, err := tx.ExecContext(ctx, optionsQ, p.ID, i, o.Name, o.Values); err != nil {
+			return errors.Wrap(err, "couldn't create the product options")
+		}
+	}
+
+	for _, v := range p.Variants {
+		if err := saveVariant(ctx, tx, v); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
 	s.metrics.totalProducts.Inc()
 	return nil
 }
 
+// CreateVariant adds a variant to an existing product.
+func (s *service) CreateVariant(ctx context.Context, v Variant) error {
+	s.metrics.incMethodCalls("CreateVariant")
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	options, err := getOptions(ctx, tx, v.ProductID.String)
+	if err != nil {
+		return err
+	}
+
+	variants, err := getVariants(ctx, tx, v.ProductID.String)
+	if err != nil {
+		return err
+	}
+
+	if err := validateVariants(options, append(variants, v)); err != nil {
+		return err
+	}
+
+	if err := saveVariant(ctx, tx, v); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	if err := s.mc.Delete(v.ProductID.String); err != nil && err != memcache.ErrCacheMiss {
+		return errors.Wrap(err, "couldn't delete product from cache")
+	}
+
+	return nil
+}
+
 // Delete permanently deletes a product from the database.
 func (s *service) Delete(ctx context.Context, id string) error {
 	s.metrics.incMethodCalls("Delete")
@@ -67,6 +139,41 @@ func (s *service) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// DeleteVariant permanently deletes a product variant from the database.
+//
+// The variant is also taken out from the carts that contain it.
+func (s *service) DeleteVariant(ctx context.Context, productID, variantID string) error {
+	s.metrics.incMethodCalls("DeleteVariant")
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	q := "DELETE FROM product_variants WHERE id=$1 AND product_id=$2"
+	if _, err := tx.ExecContext(ctx, q, variantID, productID); err != nil {
+		return errors.Wrap(err, "couldn't delete the variant")
+	}
+
+	for _, table := range []string{"cart_products", "stock_holds", "wishlist_products"} {
+		q := "DELETE FROM " + table + " WHERE variant_id=$1"
+		if _, err := tx.ExecContext(ctx, q, variantID); err != nil {
+			return errors.Wrap(err, "couldn't delete the variant from the carts and wishlists")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	if err := s.mc.Delete(productID); err != nil && err != memcache.ErrCacheMiss {
+		return errors.Wrap(err, "couldn't delete product from cache")
+	}
+
+	return nil
+}
+
 // Get returns a list with all the products stored in the database.
 func (s *service) Get(ctx context.Context, params params.Query) ([]Product, error) {
 	s.metrics.incMethodCalls("Get")
@@ -134,9 +241,36 @@ func (s *service) GetByID(ctx context.Context, id string) (Product, error) {
 		p.Reviews = append(p.Reviews, r)
 	}
 
+	if !p.ID.Valid {
+		return p, nil
+	}
+
+	p.Options, err = getOptions(ctx, s.db, id)
+	if err != nil {
+		return Product{}, err
+	}
+
+	p.Variants, err = getVariants(ctx, s.db, id)
+	if err != nil {
+		return Product{}, err
+	}
+
 	return p, nil
 }
 
+// GetVariant retrieves the product variant requested from the database.
+func (s *service) GetVariant(ctx context.Context, productID, variantID string) (Variant, error) {
+	s.metrics.incMethodCalls("GetVariant")
+
+	var v Variant
+	q := "SELECT * FROM product_variants WHERE id=$1 AND product_id=$2"
+	if err := s.db.GetContext(ctx, &v, q, variantID, productID); err != nil {
+		return Variant{}, errors.Wrap(err, "couldn't find the variant")
+	}
+
+	return v, nil
+}
+
 // Search looks for the products that contain the value specified. (Only text fields)
 func (s *service) Search(ctx context.Context, query string) ([]Product, error) {
 	s.metrics.incMethodCalls("Search")
@@ -169,3 +303,90 @@ func (s *service) Update(ctx context.Context, id string, p UpdateProduct) error
 
 	return nil
 }
+
+// UpdateVariant updates the variant fields.
+func (s *service) UpdateVariant(ctx context.Context, productID, variantID string, v UpdateVariant) error {
+	s.metrics.incMethodCalls("UpdateVariant")
+
+	q := `UPDATE product_variants SET sku=$3, stock=$4, weight=$5, discount=$6,
+	taxes=$7, subtotal=$8, total=$9, updated_at=$10
+	WHERE id=$1 AND product_id=$2`
+	_, err := s.db.ExecContext(ctx, q, variantID, productID, v.SKU, v.Stock, v.Weight,
+		v.Discount, v.Taxes, v.Subtotal, v.Total, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "couldn't update the variant")
+	}
+
+	if err := s.mc.Delete(productID); err != nil && err != memcache.ErrCacheMiss {
+		return errors.Wrap(err, "couldn't delete product from cache")
+	}
+
+	return nil
+}
+
+func getOptions(ctx context.Context, db sqlx.QueryerContext, productID string) ([]Option, error) {
+	var options []Option
+	q := "SELECT name, option_values FROM product_options WHERE product_id=$1 ORDER BY position"
+	if err := sqlx.SelectContext(ctx, db, &options, q, productID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the product options")
+	}
+
+	return options, nil
+}
+
+func getVariants(ctx context.Context, db sqlx.QueryerContext, productID string) ([]Variant, error) {
+	var variants []Variant
+	q := "SELECT * FROM product_variants WHERE product_id=$1 ORDER BY options"
+	if err := sqlx.SelectContext(ctx, db, &variants, q, productID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the product variants")
+	}
+
+	return variants, nil
+}
+
+func saveVariant(ctx context.Context, tx *sqlx.Tx, v Variant) error {
+	q := `INSERT INTO product_variants
+	(id, product_id, sku, options, stock, weight, discount, taxes, subtotal, total, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := tx.ExecContext(ctx, q, v.ID, v.ProductID, v.SKU, v.Options, v.Stock,
+		v.Weight, v.Discount, v.Taxes, v.Subtotal, v.Total, v.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create the variant")
+	}
+
+	return nil
+}
+
+// validateVariants makes sure every variant has a valid value for each one of the options
+// and that there are no repeated combinations.
+func validateVariants(options []Option, variants []Variant) error {
+	combinations := make(map[string]struct{}, len(variants))
+	for _, v := range variants {
+		if len(v.Options) != len(options) {
+			return errors.Errorf("variant %q must have %d option values", v.SKU.String, len(options))
+		}
+
+		for i, value := range v.Options {
+			if !contains(options[i].Values, value) {
+				return errors.Errorf("variant %q: %q is not a valid %s", v.SKU.String, value, options[i].Name)
+			}
+		}
+
+		key := strings.Join(v.Options, ",")
+		if _, ok := combinations[key]; ok {
+			return errors.Errorf("variant %q: combination %q is repeated", v.SKU.String, key)
+		}
+		combinations[key] = struct{}{}
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
 	"gopkg.in/guregu/null.v4/zero"
 )
 
@@ -28,6 +29,27 @@ var p = product.Product{
 	Total:    zero.IntFrom(1),
 }
 
+var variantProduct = product.Product{
+	ID:       zero.StringFrom("5"),
+	ShopID:   zero.StringFrom("6"),
+	Brand:    zero.StringFrom("brand"),
+	Category: zero.StringFrom("category"),
+	Type:     zero.StringFrom("shirt"),
+	Weight:   zero.IntFrom(1),
+	Subtotal: zero.IntFrom(10),
+	Total:    zero.IntFrom(10),
+	Options:  []product.Option{{Name: "size", Values: []string{"S", "M"}}},
+	Variants: []product.Variant{
+		{
+			ID:        zero.StringFrom("51"),
+			ProductID: zero.StringFrom("5"),
+			SKU:       zero.StringFrom("SHIRT-S"),
+			Options:   []string{"S"},
+			Stock:     2,
+		},
+	},
+}
+
 // TestMain failed when creating the product service.
 func NewProductService(t *testing.T) (context.Context, product.Service) {
 	t.Helper()
@@ -54,6 +76,7 @@ func TestProductService(t *testing.T) {
 	t.Run("Get by id", getByID(ctx, s))
 	t.Run("Update", update(ctx, s))
 	t.Run("Search", search(ctx, s))
+	t.Run("Variants", variants(ctx, s))
 	t.Run("Delete", delete(ctx, s))
 }
 
@@ -134,6 +157,57 @@ func search(ctx context.Context, s product.Service) func(t *testing.T) {
 	}
 }
 
+func variants(ctx context.Context, s product.Service) func(t *testing.T) {
+	return func(t *testing.T) {
+		assert.NoError(t, s.Create(ctx, variantProduct))
+
+		pr, err := s.GetByID(ctx, variantProduct.ID.String)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(pr.Options))
+		assert.Equal(t, 1, len(pr.Variants))
+
+		v := product.Variant{
+			ID:        zero.StringFrom("52"),
+			ProductID: variantProduct.ID,
+			SKU:       zero.StringFrom("SHIRT-L"),
+			Options:   []string{"L"},
+			Stock:     1,
+		}
+		// "L" is not a valid size
+		assert.Error(t, s.CreateVariant(ctx, v))
+
+		v.Options = []string{"S"}
+		// Repeated combination
+		assert.Error(t, s.CreateVariant(ctx, v))
+
+		v.SKU = zero.StringFrom("SHIRT-M")
+		v.Options = []string{"M"}
+		assert.NoError(t, s.CreateVariant(ctx, v))
+
+		// Sold out and free variants
+		update := product.UpdateVariant{
+			SKU:      v.SKU,
+			Stock:    0,
+			Discount: null.IntFrom(0),
+			Total:    null.IntFrom(0),
+		}
+		assert.NoError(t, s.UpdateVariant(ctx, v.ProductID.String, v.ID.String, update))
+
+		got, err := s.GetVariant(ctx, v.ProductID.String, v.ID.String)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), got.Stock)
+		assert.True(t, got.Total.Valid)
+		assert.False(t, got.Weight.Valid)
+		withVariant := pr.WithVariant(got)
+		assert.Equal(t, int64(0), withVariant.Total.Int64)
+		assert.Equal(t, pr.Weight, withVariant.Weight)
+
+		assert.NoError(t, s.DeleteVariant(ctx, v.ProductID.String, v.ID.String))
+		_, err = s.GetVariant(ctx, v.ProductID.String, v.ID.String)
+		assert.Error(t, err)
+	}
+}
+
 func createRelationship(ctx context.Context, t *testing.T, db *sqlx.DB, mc *memcache.Client) {
 	t.Helper()
 
@@ -1,35 +1,80 @@
 package shop
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
+	"github.com/GGP1/adak/internal/cookie"
 	"github.com/GGP1/adak/internal/params"
 	"github.com/GGP1/adak/internal/response"
 	"github.com/GGP1/adak/internal/sanitize"
 	"github.com/GGP1/adak/internal/validate"
+	"github.com/GGP1/adak/pkg/shopping/ordering"
+	"github.com/GGP1/adak/pkg/shopping/shipment"
 	"github.com/google/uuid"
 
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
 )
 
+var errNotAllowed = errors.New("not found")
+
 type cursorResponse struct {
 	NextCursor string `json:"next_cursor,omitempty"`
 	Shops      []Shop `json:"shops,omitempty"`
 }
 
+type ordersResponse struct {
+	NextCursor string               `json:"next_cursor,omitempty"`
+	Orders     []ordering.ShopOrder `json:"orders,omitempty"`
+}
+
+// Admins tells whether a user is an administrator.
+type Admins interface {
+	IsAdmin(ctx context.Context, id string) (bool, error)
+}
+
 // Handler handles shop endpoints.
 type Handler struct {
-	service Service
-	cache   *memcache.Client
+	service         Service
+	orderingService ordering.Service
+	shipmentService shipment.Service
+	admins          Admins
+	cache           *memcache.Client
 }
 
 // NewHandler returns a new shop handler.
-func NewHandler(service Service, cache *memcache.Client) Handler {
+func NewHandler(service Service, orderingS ordering.Service, shipmentS shipment.Service,
+	admins Admins, cache *memcache.Client) Handler {
 	return Handler{
-		service: service,
-		cache:   cache,
+		service:         service,
+		orderingService: orderingS,
+		shipmentService: shipmentS,
+		admins:          admins,
+		cache:           cache,
+	}
+}
+
+// AddOwner lets a user manage the orders of the shop.
+func (h *Handler) AddOwner() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		userID := chi.URLParam(r, "userID")
+
+		if err := h.service.AddOwner(ctx, id, userID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, userID)
 	}
 }
 
@@ -136,6 +181,87 @@ func (h *Handler) GetByID() http.HandlerFunc {
 	}
 }
 
+// Orders lists the orders of the shop, only its owners and the administrators can see them.
+func (h *Handler) Orders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.authorize(ctx, r, id); err != nil {
+			if errors.Is(err, errNotAllowed) {
+				response.Error(w, http.StatusNotFound, err)
+				return
+			}
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		urlParams, err := params.ParseQuery(r.URL.RawQuery, params.Order)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		orders, err := h.orderingService.GetByShopID(ctx, id, urlParams)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		orderIDs := make([]string, len(orders))
+		for i, o := range orders {
+			orderIDs[i] = o.OrderID.String
+		}
+		shipments, err := h.shipmentService.GetByOrderIDs(ctx, orderIDs)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		for i, o := range orders {
+			orders[i].AttachShipments(shipments[o.OrderID.String])
+		}
+
+		var nextCursor string
+		if len(orders) > 0 {
+			nextCursor = params.EncodeCursor(
+				orders[len(orders)-1].CreatedAt.Time,
+				orders[len(orders)-1].ID.String,
+			)
+		}
+
+		response.JSON(w, http.StatusOK, ordersResponse{
+			NextCursor: nextCursor,
+			Orders:     orders,
+		})
+	}
+}
+
+// RemoveOwner revokes a user permissions over the shop.
+func (h *Handler) RemoveOwner() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		userID := chi.URLParam(r, "userID")
+
+		if err := h.service.RemoveOwner(ctx, id, userID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, userID)
+	}
+}
+
 // Search looks for the products with the given value.
 func (h *Handler) Search() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -189,3 +315,29 @@ func (h *Handler) Update() http.HandlerFunc {
 		response.JSONText(w, http.StatusOK, id)
 	}
 }
+
+// authorize makes sure the user logged in owns the shop or is an administrator.
+func (h *Handler) authorize(ctx context.Context, r *http.Request, shopID string) error {
+	userID, err := cookie.GetValue(r, "UID")
+	if err != nil {
+		return errNotAllowed
+	}
+
+	owner, err := h.service.IsOwner(ctx, shopID, userID)
+	if err != nil {
+		return err
+	}
+	if owner {
+		return nil
+	}
+
+	admin, err := h.admins.IsAdmin(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return errNotAllowed
+	}
+
+	return nil
+}
@@ -17,10 +17,13 @@ import (
 
 // Service provides shop operations.
 type Service interface {
+	AddOwner(ctx context.Context, shopID, userID string) error
 	Create(ctx context.Context, shop Shop) error
 	Delete(ctx context.Context, id string) error
 	Get(ctx context.Context, params params.Query) ([]Shop, error)
 	GetByID(ctx context.Context, id string) (Shop, error)
+	IsOwner(ctx context.Context, shopID, userID string) (bool, error)
+	RemoveOwner(ctx context.Context, shopID, userID string) error
 	Search(ctx context.Context, query string) ([]Shop, error)
 	Update(ctx context.Context, id string, shop UpdateShop) error
 }
@@ -36,6 +39,18 @@ func NewService(db *sqlx.DB, mc *memcache.Client) Service {
 	return &service{db, mc, initMetrics()}
 }
 
+// AddOwner lets the user manage the orders of the shop.
+func (s *service) AddOwner(ctx context.Context, shopID, userID string) error {
+	s.metrics.incMethodCalls("AddOwner")
+
+	q := "INSERT INTO shop_owners (shop_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+	if _, err := s.db.ExecContext(ctx, q, shopID, userID); err != nil {
+		return errors.Wrap(err, "couldn't add the shop owner")
+	}
+
+	return nil
+}
+
 // Create a shop.
 func (s *service) Create(ctx context.Context, shop Shop) error {
 	s.metrics.incMethodCalls("Create")
@@ -140,6 +155,31 @@ func (s *service) GetByID(ctx context.Context, id string) (Shop, error) {
 	return shop, nil
 }
 
+// IsOwner returns whether the user can manage the orders of the shop.
+func (s *service) IsOwner(ctx context.Context, shopID, userID string) (bool, error) {
+	s.metrics.incMethodCalls("IsOwner")
+
+	var owner bool
+	q := "SELECT EXISTS(SELECT 1 FROM shop_owners WHERE shop_id=$1 AND user_id=$2)"
+	if err := s.db.GetContext(ctx, &owner, q, shopID, userID); err != nil {
+		return false, errors.Wrap(err, "couldn't check the shop owners")
+	}
+
+	return owner, nil
+}
+
+// RemoveOwner revokes the user permissions over the shop.
+func (s *service) RemoveOwner(ctx context.Context, shopID, userID string) error {
+	s.metrics.incMethodCalls("RemoveOwner")
+
+	q := "DELETE FROM shop_owners WHERE shop_id=$1 AND user_id=$2"
+	if _, err := s.db.ExecContext(ctx, q, shopID, userID); err != nil {
+		return errors.Wrap(err, "couldn't remove the shop owner")
+	}
+
+	return nil
+}
+
 // Search looks for the shops that contain the value specified. (Only text fields)
 func (s *service) Search(ctx context.Context, query string) ([]Shop, error) {
 	s.metrics.incMethodCalls("Search")
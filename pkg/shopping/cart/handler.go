@@ -6,31 +6,48 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/GGP1/adak/internal/cookie"
 	"github.com/GGP1/adak/internal/params"
 	"github.com/GGP1/adak/internal/response"
 	"github.com/GGP1/adak/internal/sanitize"
 	"github.com/GGP1/adak/internal/validate"
+	"github.com/GGP1/adak/pkg/shopping/currency"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/shipping"
 
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 	"gopkg.in/guregu/null.v4/zero"
 )
 
+type checkoutResponse struct {
+	Currency     string `json:"currency"`
+	Total        int64  `json:"total"`
+	ExchangeRate string `json:"exchange_rate"`
+	// Shipping contains the methods available for the destination, their cost is not
+	// included in the total
+	Shipping []shipping.Quote `json:"shipping,omitempty"`
+}
+
 // Handler manages cart endpoints.
 type Handler struct {
-	service Service
-	db      *sqlx.DB
-	cache   *memcache.Client
+	service    Service
+	currencies currency.Service
+	shipping   shipping.Service
+	db         *sqlx.DB
+	cache      *memcache.Client
 }
 
 // NewHandler returns a new cart handler.
-func NewHandler(service Service, db *sqlx.DB, cache *memcache.Client) Handler {
+func NewHandler(service Service, currencies currency.Service, shipping shipping.Service,
+	db *sqlx.DB, cache *memcache.Client) Handler {
 	return Handler{
-		service: service,
-		db:      db,
-		cache:   cache,
+		service:    service,
+		currencies: currencies,
+		shipping:   shipping,
+		db:         db,
+		cache:      cache,
 	}
 }
 
@@ -39,7 +56,7 @@ func (h *Handler) Add() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		cartID, err := cookie.GetValue(r, "CID")
+		cartID, err := params.CartID(ctx)
 		if err != nil {
 			response.Error(w, http.StatusForbidden, err)
 			return
@@ -59,6 +76,18 @@ func (h *Handler) Add() http.HandlerFunc {
 
 		product.CartID = zero.StringFrom(cartID)
 		if err := h.service.Add(ctx, product); err != nil {
+			if errors.Is(err, inventory.ErrOutOfStock) {
+				response.Error(w, http.StatusConflict, err)
+				return
+			}
+			if errors.Is(err, ErrVariantRequired) {
+				response.Error(w, http.StatusBadRequest, err)
+				return
+			}
+			if errors.Is(err, ErrProductNotFound) {
+				response.Error(w, http.StatusNotFound, err)
+				return
+			}
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -68,10 +97,14 @@ func (h *Handler) Add() http.HandlerFunc {
 }
 
 // Checkout returns the final purchase.
+//
+// The total is converted to the currency specified in the "currency" query parameter,
+// the base currency is used if it's omitted. The shipping methods available are quoted
+// when the destination "country" is provided.
 func (h *Handler) Checkout() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		cartID, err := cookie.GetValue(r, "CID")
+		cartID, err := params.CartID(ctx)
 		if err != nil {
 			response.Error(w, http.StatusForbidden, err)
 			return
@@ -83,14 +116,52 @@ func (h *Handler) Checkout() http.HandlerFunc {
 			return
 		}
 
-		response.JSON(w, http.StatusOK, checkout)
+		curr := sanitize.Normalize(r.URL.Query().Get("currency"))
+		if curr == "" {
+			curr = h.currencies.Base()
+		}
+
+		conversion, err := h.currencies.Convert(ctx, checkout.Total, curr)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var quotes []shipping.Quote
+		if country := sanitize.Normalize(r.URL.Query().Get("country")); country != "" {
+			quotes, err = h.shipping.Quote(ctx, country, checkout.Weight, checkout.Total)
+			if err != nil {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+
+			for i, q := range quotes {
+				if checkout.FreeShipping {
+					quotes[i].Cost = 0
+					continue
+				}
+				cost, err := h.currencies.Convert(ctx, q.Cost, curr)
+				if err != nil {
+					response.Error(w, http.StatusBadRequest, err)
+					return
+				}
+				quotes[i].Cost = cost.Amount
+			}
+		}
+
+		response.JSON(w, http.StatusOK, checkoutResponse{
+			Currency:     conversion.Currency,
+			Total:        conversion.Amount,
+			ExchangeRate: conversion.Rate,
+			Shipping:     quotes,
+		})
 	}
 }
 
 // FilterBy returns the products filtered by the field provided.
 func (h *Handler) FilterBy() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cartID, err := cookie.GetValue(r, "CID")
+		cartID, err := params.CartID(r.Context())
 		if err != nil {
 			response.Error(w, http.StatusForbidden, err)
 			return
@@ -114,7 +185,7 @@ func (h *Handler) FilterBy() http.HandlerFunc {
 func (h *Handler) Get() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		cartID, err := cookie.GetValue(r, "CID")
+		cartID, err := params.CartID(ctx)
 		if err != nil {
 			response.Error(w, http.StatusForbidden, err)
 			return
@@ -140,7 +211,7 @@ func (h *Handler) Get() http.HandlerFunc {
 func (h *Handler) Products() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		cartID, err := cookie.GetValue(r, "CID")
+		cartID, err := params.CartID(ctx)
 		if err != nil {
 			response.Error(w, http.StatusForbidden, err)
 			return
@@ -159,7 +230,7 @@ func (h *Handler) Products() http.HandlerFunc {
 // Remove takes out a product from the shopping cart.
 func (h *Handler) Remove() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cartID, err := cookie.GetValue(r, "CID")
+		cartID, err := params.CartID(r.Context())
 		if err != nil {
 			response.Error(w, http.StatusForbidden, err)
 			return
@@ -179,7 +250,8 @@ func (h *Handler) Remove() http.HandlerFunc {
 			return
 		}
 
-		if err := h.service.Remove(ctx, cartID, id, int64(quantity)); err != nil {
+		variantID := r.URL.Query().Get("variant_id")
+		if err := h.service.Remove(ctx, cartID, id, variantID, int64(quantity)); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
@@ -192,7 +264,7 @@ func (h *Handler) Remove() http.HandlerFunc {
 func (h *Handler) Reset() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		cartID, err := cookie.GetValue(r, "CID")
+		cartID, err := params.CartID(ctx)
 		if err != nil {
 			response.Error(w, http.StatusForbidden, err)
 			return
@@ -211,7 +283,7 @@ func (h *Handler) Reset() http.HandlerFunc {
 func (h *Handler) Size() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		cartID, err := cookie.GetValue(r, "CID")
+		cartID, err := params.CartID(ctx)
 		if err != nil {
 			response.Error(w, http.StatusForbidden, err)
 			return
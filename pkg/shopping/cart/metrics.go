@@ -24,3 +24,33 @@ func initMetrics() metrics {
 func (m metrics) incMethodCalls(method string) {
 	m.methodCalls.With(prometheus.Labels{"method": method}).Inc()
 }
+
+type reminderMetrics struct {
+	sent         prometheus.Counter
+	recovered    prometheus.Counter
+	recoveryRate prometheus.Gauge
+}
+
+func initReminderMetrics() reminderMetrics {
+	const ns, sub = "adak", "cart_reminders"
+	return reminderMetrics{
+		sent: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: sub,
+			Name:      "sent_total",
+			Help:      "Total number of abandoned cart reminders sent",
+		}),
+		recovered: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: sub,
+			Name:      "recovered_total",
+			Help:      "Total number of carts ordered after receiving a reminder",
+		}),
+		recoveryRate: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: sub,
+			Name:      "recovery_rate",
+			Help:      "Ratio of reminders sent that ended up in an order",
+		}),
+	}
+}
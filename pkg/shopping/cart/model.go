@@ -4,6 +4,14 @@ import (
 	"gopkg.in/guregu/null.v4/zero"
 )
 
+// Merge policies, used to combine the products that are in both the guest and the user cart
+const (
+	// MergeSum adds the quantities of both carts
+	MergeSum = "sum"
+	// MergeLatest keeps the quantity of the cart product updated last
+	MergeLatest = "latest"
+)
+
 // Cart represents a temporary record of items that the customer selected for purchase.
 //
 // Amounts to be provided in a currency’s smallest unit.
@@ -23,9 +31,29 @@ type Cart struct {
 	Products []Product `json:"products,omitempty"`
 }
 
+// Checkout is the summary of the cart used to quote the order.
+type Checkout struct {
+	// Total after applying the promotions
+	Total  int64
+	Weight int64
+	// FreeShipping is set when one of the promotions applied removes the shipping cost
+	FreeShipping bool
+}
+
 // Product represents a product that has been added to the cart.
+//
+// Weight, Discount, Taxes, Subtotal and Total are the unit values of the product (or its
+// variant) when it was added, they are used to calculate the cart totals so later changes
+// in the product don't alter them.
 type Product struct {
-	ID       zero.String `json:"id,omitempty" validate:"uuid4_rfc4122"`
-	CartID   zero.String `json:"cart_id,omitempty" db:"cart_id"`
-	Quantity zero.Int    `json:"quantity,omitempty" validate:"required,min=1"`
+	ID        zero.String `json:"id,omitempty" validate:"uuid4_rfc4122"`
+	VariantID zero.String `json:"variant_id,omitempty" db:"variant_id"`
+	CartID    zero.String `json:"cart_id,omitempty" db:"cart_id"`
+	Quantity  zero.Int    `json:"quantity,omitempty" validate:"required,min=1"`
+	UpdatedAt zero.Time   `json:"updated_at,omitempty" db:"updated_at"`
+	Weight    zero.Int    `json:"weight,omitempty"`
+	Discount  zero.Int    `json:"discount,omitempty"`
+	Taxes     zero.Int    `json:"taxes,omitempty"`
+	Subtotal  zero.Int    `json:"subtotal,omitempty"`
+	Total     zero.Int    `json:"total,omitempty"`
 }
@@ -0,0 +1,220 @@
+package cart
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/internal/logger"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultReminderAfter    = 24 * time.Hour
+	defaultReminderInterval = time.Hour
+	// reminderBatch is the maximum number of reminders sent on each run
+	reminderBatch = 100
+)
+
+// Notifier sends the reminders to the users.
+type Notifier interface {
+	SendCartReminder(username, email string, products []string) error
+}
+
+// Reminder emails the users that left products in their carts without ordering them.
+type Reminder struct {
+	db       *sqlx.DB
+	notifier Notifier
+	after    time.Duration
+	interval time.Duration
+	metrics  reminderMetrics
+}
+
+type abandonedCart struct {
+	CartID    string    `db:"cart_id"`
+	UpdatedAt time.Time `db:"updated_at"`
+	UserID    string    `db:"user_id"`
+	Username  string    `db:"username"`
+	Email     string    `db:"email"`
+}
+
+type reminderProduct struct {
+	Quantity int64  `db:"quantity"`
+	Brand    string `db:"brand"`
+	Type     string `db:"type"`
+	SKU      string `db:"sku"`
+}
+
+// NewReminder returns a new abandoned carts reminder.
+func NewReminder(db *sqlx.DB, notifier Notifier, config config.Cart) *Reminder {
+	after := config.ReminderAfter
+	if after <= 0 {
+		after = defaultReminderAfter
+	}
+	interval := config.ReminderInterval
+	if interval <= 0 {
+		interval = defaultReminderInterval
+	}
+	return &Reminder{
+		db:       db,
+		notifier: notifier,
+		after:    after,
+		interval: interval,
+		metrics:  initReminderMetrics(),
+	}
+}
+
+// Run sends the reminders periodically until the context is cancelled.
+func (r *Reminder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := r.Remind(ctx)
+			if err != nil {
+				logger.Error(err)
+				continue
+			}
+			if n > 0 {
+				logger.Debugf("Sent %d abandoned cart reminders", n)
+			}
+		}
+	}
+}
+
+// Remind emails the owners of the carts that weren't modified for a while and returns
+// how many reminders were sent.
+//
+// A cart receives one reminder until its products change, guest carts and users with
+// an unverified email are skipped.
+func (r *Reminder) Remind(ctx context.Context) (int64, error) {
+	if err := r.markRecovered(ctx); err != nil {
+		return 0, err
+	}
+
+	q := `WITH idle AS (
+		SELECT cart_id, MAX(updated_at) AS updated_at FROM cart_products
+		GROUP BY cart_id HAVING MAX(updated_at) <= $1
+	)
+	SELECT i.cart_id, i.updated_at, u.id AS user_id, u.username, u.email
+	FROM idle AS i
+	JOIN users AS u ON u.cart_id=i.cart_id
+	WHERE u.verified_email
+	AND NOT EXISTS (SELECT 1 FROM cart_reminders WHERE cart_id=i.cart_id AND cart_updated_at >= i.updated_at)
+	AND NOT EXISTS (SELECT 1 FROM orders WHERE cart_id=i.cart_id AND ordered_at >= i.updated_at)
+	LIMIT $2`
+	var carts []abandonedCart
+	if err := r.db.SelectContext(ctx, &carts, q, time.Now().Add(-r.after), reminderBatch); err != nil {
+		return 0, errors.Wrap(err, "couldn't find the abandoned carts")
+	}
+
+	var sent int64
+	for _, cart := range carts {
+		ok, err := r.send(ctx, cart)
+		if err != nil {
+			// Try with the next cart, this one will be retried on the next run
+			logger.Error(err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+
+	r.metrics.sent.Add(float64(sent))
+	return sent, r.updateRecoveryRate(ctx)
+}
+
+// send records the reminder and emails the user, it returns false if another instance
+// already sent it.
+func (r *Reminder) send(ctx context.Context, cart abandonedCart) (bool, error) {
+	q := `INSERT INTO cart_reminders (cart_id, user_id, cart_updated_at) VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING`
+	res, err := r.db.ExecContext(ctx, q, cart.CartID, cart.UserID, cart.UpdatedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "couldn't record the reminder")
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	products, err := r.products(ctx, cart.CartID)
+	if err == nil {
+		err = r.notifier.SendCartReminder(cart.Username, cart.Email, products)
+	}
+	if err != nil {
+		// Remove the record so the reminder is sent again
+		deleteQ := "DELETE FROM cart_reminders WHERE cart_id=$1 AND cart_updated_at=$2"
+		if _, dErr := r.db.ExecContext(ctx, deleteQ, cart.CartID, cart.UpdatedAt); dErr != nil {
+			logger.Error(dErr)
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// products returns a description of each product in the cart.
+func (r *Reminder) products(ctx context.Context, cartID string) ([]string, error) {
+	q := `SELECT cp.quantity, p.brand, p.type, COALESCE(v.sku, '') AS sku
+	FROM cart_products AS cp
+	JOIN products AS p ON p.id=cp.id
+	LEFT JOIN product_variants AS v ON v.id=cp.variant_id
+	WHERE cp.cart_id=$1`
+	var rows []reminderProduct
+	if err := r.db.SelectContext(ctx, &rows, q, cartID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the cart products")
+	}
+
+	products := make([]string, len(rows))
+	for i, p := range rows {
+		products[i] = fmt.Sprintf("%d x %s %s", p.Quantity, p.Brand, p.Type)
+		if p.SKU != "" {
+			products[i] += " (" + p.SKU + ")"
+		}
+	}
+
+	return products, nil
+}
+
+// markRecovered links the reminders to the orders placed after sending them.
+func (r *Reminder) markRecovered(ctx context.Context) error {
+	q := `UPDATE cart_reminders AS r SET order_id=o.id, recovered_at=o.ordered_at
+	FROM orders AS o
+	WHERE r.recovered_at IS NULL AND o.cart_id=r.cart_id AND o.ordered_at >= r.sent_at`
+	res, err := r.db.ExecContext(ctx, q)
+	if err != nil {
+		return errors.Wrap(err, "couldn't update the recovered carts")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	r.metrics.recovered.Add(float64(n))
+	return nil
+}
+
+// updateRecoveryRate sets the share of reminders that ended up in an order.
+func (r *Reminder) updateRecoveryRate(ctx context.Context) error {
+	var stats struct {
+		Sent      int64 `db:"sent"`
+		Recovered int64 `db:"recovered"`
+	}
+	q := "SELECT COUNT(*) AS sent, COUNT(recovered_at) AS recovered FROM cart_reminders"
+	if err := r.db.GetContext(ctx, &stats, q); err != nil {
+		return errors.Wrap(err, "couldn't calculate the recovery rate")
+	}
+
+	if stats.Sent > 0 {
+		r.metrics.recoveryRate.Set(float64(stats.Recovered) / float64(stats.Sent))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package cart_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notifier struct {
+	emails []string
+}
+
+func (n *notifier) SendCartReminder(username, email string, products []string) error {
+	n.emails = append(n.emails, email)
+	return nil
+}
+
+func TestReminder(t *testing.T) {
+	ctx := context.Background()
+	idle := time.Now().Add(-48 * time.Hour)
+
+	// Only the first cart is idle and belongs to a user with a verified email
+	carts := []struct {
+		id        string
+		email     string
+		verified  bool
+		updatedAt time.Time
+	}{
+		{id: "idle_cart", email: "idle@adak.com", verified: true, updatedAt: idle},
+		{id: "unverified_cart", email: "unverified@adak.com", verified: false, updatedAt: idle},
+		{id: "active_cart", email: "active@adak.com", verified: true, updatedAt: time.Now()},
+	}
+	for _, c := range carts {
+		require.NoError(t, service.Create(ctx, c.id))
+
+		userQ := `INSERT INTO users (id, cart_id, username, email, password, verified_email)
+		VALUES ($1, $1, $1, $2, 'password', $3)`
+		_, err := db.ExecContext(ctx, userQ, c.id, c.email, c.verified)
+		require.NoError(t, err)
+
+		productQ := "INSERT INTO cart_products (id, cart_id, quantity, updated_at) VALUES ('1', $1, 1, $2)"
+		_, err = db.ExecContext(ctx, productQ, c.id, c.updatedAt)
+		require.NoError(t, err)
+	}
+
+	n := &notifier{}
+	reminder := cart.NewReminder(db, n, config.Cart{ReminderAfter: 24 * time.Hour})
+
+	sent, err := reminder.Remind(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), sent)
+	assert.Equal(t, []string{"idle@adak.com"}, n.emails)
+
+	// The cart didn't change, the reminder isn't sent again
+	sent, err = reminder.Remind(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), sent)
+	assert.Equal(t, 1, len(n.emails))
+
+	// Modifying the cart makes it eligible for a new reminder once it's idle again
+	q := "UPDATE cart_products SET updated_at=$2 WHERE cart_id=$1"
+	_, err = db.ExecContext(ctx, q, "idle_cart", idle.Add(time.Hour))
+	require.NoError(t, err)
+
+	sent, err = reminder.Remind(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), sent)
+	assert.Equal(t, []string{"idle@adak.com", "idle@adak.com"}, n.emails)
+}
@@ -2,8 +2,13 @@ package cart
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
+	"github.com/GGP1/adak/internal/config"
 	"github.com/GGP1/adak/pkg/product"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -20,30 +25,74 @@ var fields = map[string]string{
 	"type":     "type=$2",
 }
 
+// ErrVariantRequired is returned when adding a product with variants without specifying one.
+var ErrVariantRequired = errors.New("a variant of the product must be chosen")
+
+// ErrProductNotFound is returned when the product, or the variant, added doesn't exist.
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrExpired is returned when the guest cart doesn't exist or has been idle for too long.
+var ErrExpired = errors.New("guest cart expired")
+
+// mergeUpdates contains the update applied to the products that are in both carts for each merge policy.
+var mergeUpdates = map[string]string{
+	MergeSum: "quantity=cart_products.quantity+EXCLUDED.quantity",
+	MergeLatest: `quantity=CASE WHEN EXCLUDED.updated_at > cart_products.updated_at
+	THEN EXCLUDED.quantity ELSE cart_products.quantity END`,
+}
+
+const defaultGuestTTL = 72 * time.Hour
+
 // Service contains order functionalities.
 type Service interface {
 	Add(ctx context.Context, cartProduct Product) error
-	Checkout(ctx context.Context, cartID string) (int64, error)
+	AddTx(ctx context.Context, tx *sqlx.Tx, cartProduct Product) error
+	Checkout(ctx context.Context, cartID string) (Checkout, error)
 	Create(ctx context.Context, cartID string) error
+	CreateGuest(ctx context.Context, cartID string) error
 	Delete(ctx context.Context, cartID string) error
 	FilterBy(ctx context.Context, cartID, field, args string) ([]product.Product, error)
 	Get(ctx context.Context, cartID string) (Cart, error)
-	CartProduct(ctx context.Context, cartID, productID string) (Product, error)
+	CartProduct(ctx context.Context, cartID, productID, variantID string) (Product, error)
 	CartProducts(ctx context.Context, cartID string) ([]Product, error)
-	Remove(ctx context.Context, cartID string, pID string, quantity int64) error
+	Merge(ctx context.Context, guestID, cartID string) error
+	Refresh(ctx context.Context, tx *sqlx.Tx, cartID string) error
+	Remove(ctx context.Context, cartID, pID, variantID string, quantity int64) error
 	Reset(ctx context.Context, cartID string) error
 	Size(ctx context.Context, cartID string) (int64, error)
+	Touch(ctx context.Context, cartID string) error
 }
 
 type service struct {
-	db      *sqlx.DB
-	mc      *memcache.Client
-	metrics metrics
+	db          *sqlx.DB
+	mc          *memcache.Client
+	inventory   inventory.Service
+	promotions  promotion.Service
+	guestTTL    time.Duration
+	mergePolicy string
+	metrics     metrics
 }
 
 // NewService returns a new cart service.
-func NewService(db *sqlx.DB, mc *memcache.Client) Service {
-	return &service{db, mc, initMetrics()}
+func NewService(db *sqlx.DB, mc *memcache.Client, config config.Cart,
+	inventory inventory.Service, promotions promotion.Service) Service {
+	guestTTL := config.GuestTTL
+	if guestTTL <= 0 {
+		guestTTL = defaultGuestTTL
+	}
+	mergePolicy := config.MergePolicy
+	if _, ok := mergeUpdates[mergePolicy]; !ok {
+		mergePolicy = MergeSum
+	}
+	return &service{
+		db:          db,
+		mc:          mc,
+		inventory:   inventory,
+		promotions:  promotions,
+		guestTTL:    guestTTL,
+		mergePolicy: mergePolicy,
+		metrics:     initMetrics(),
+	}
 }
 
 // New returns a cart with the default values.
@@ -70,28 +119,28 @@ func (s *service) Add(ctx context.Context, cartProduct Product) error {
 	}
 	defer tx.Rollback()
 
-	var p product.Product
-	if err := tx.GetContext(ctx, &p, "SELECT * FROM products WHERE id=$1", cartProduct.ID); err != nil {
-		return errors.Wrap(err, "couldn't find product")
+	if err := s.add(ctx, tx, cartProduct); err != nil {
+		return err
 	}
 
-	if err := s.createOrUpdateProduct(ctx, tx, cartProduct); err != nil {
-		return err
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
 	}
 
-	q := `UPDATE carts SET 
-	counter=counter+$2, weight=weight+$3, 
-	discount=discount+$4, taxes=taxes+$5, 
-	subtotal=subtotal+$6, total=total+$7 
-	WHERE id=$1`
-	_, err = tx.ExecContext(ctx, q, cartProduct.CartID, cartProduct.Quantity,
-		p.Weight, p.Discount, p.Taxes, p.Subtotal, p.Total)
-	if err != nil {
-		return errors.Wrap(err, "updating cart")
+	if err := s.mc.Delete(cartProduct.CartID.String); err != nil && err != memcache.ErrCacheMiss {
+		return errors.Wrap(err, "deleting cart from cache")
 	}
 
-	if err := tx.Commit(); err != nil {
-		return errors.Wrap(err, "committing transaction")
+	return nil
+}
+
+// AddTx is like Add but it's executed inside the transaction received, so the product is
+// only added if the rest of the operations performed by the caller succeed.
+func (s *service) AddTx(ctx context.Context, tx *sqlx.Tx, cartProduct Product) error {
+	s.metrics.incMethodCalls("AddTx")
+
+	if err := s.add(ctx, tx, cartProduct); err != nil {
+		return err
 	}
 
 	if err := s.mc.Delete(cartProduct.CartID.String); err != nil && err != memcache.ErrCacheMiss {
@@ -101,17 +150,28 @@ func (s *service) Add(ctx context.Context, cartProduct Product) error {
 	return nil
 }
 
-// Checkout returns the cart total.
-func (s *service) Checkout(ctx context.Context, cartID string) (int64, error) {
+// Checkout returns the cart total after applying the promotions and its weight.
+//
+// Taxes depend on the shipping address, they are calculated when ordering.
+func (s *service) Checkout(ctx context.Context, cartID string) (Checkout, error) {
 	s.metrics.incMethodCalls("Checkout")
 
 	var cart Cart
 	if err := s.db.GetContext(ctx, &cart, "SELECT * FROM carts WHERE id=$1", cartID); err != nil {
-		return 0, errors.Wrap(err, "couldn't find the cart")
+		return Checkout{}, errors.Wrap(err, "couldn't find the cart")
 	}
 
-	total := cart.Total.Int64 + cart.Taxes.Int64 - cart.Discount.Int64
-	return total, nil
+	promotions, err := s.promotions.Evaluate(ctx, cartID)
+	if err != nil {
+		return Checkout{}, err
+	}
+
+	checkout := Checkout{
+		Total:        promotions.Subtotal(cart.Total.Int64),
+		Weight:       cart.Weight.Int64,
+		FreeShipping: promotions.FreeShipping,
+	}
+	return checkout, nil
 }
 
 // Create a cart.
@@ -129,6 +189,35 @@ func (s *service) Create(ctx context.Context, cartID string) error {
 	return nil
 }
 
+// CreateGuest creates a cart for a user that is not logged in.
+func (s *service) CreateGuest(ctx context.Context, cartID string) error {
+	s.metrics.incMethodCalls("CreateGuest")
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	cartQuery := `INSERT INTO carts
+	(id, counter, weight, discount, taxes, subtotal, total)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := tx.ExecContext(ctx, cartQuery, cartID, 0, 0, 0, 0, 0, 0); err != nil {
+		return errors.Wrap(err, "couldn't create the cart")
+	}
+
+	guestQuery := "INSERT INTO guest_carts (cart_id, last_activity) VALUES ($1, $2)"
+	if _, err := tx.ExecContext(ctx, guestQuery, cartID, time.Now()); err != nil {
+		return errors.Wrap(err, "couldn't create the guest cart")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	return nil
+}
+
 // Delete permanently deletes a cart from the database.
 func (s *service) Delete(ctx context.Context, cartID string) error {
 	s.metrics.incMethodCalls("Delete")
@@ -187,7 +276,8 @@ func (s *service) Get(ctx context.Context, cartID string) (Cart, error) {
 		err := rows.Scan(
 			&cart.ID, &cart.Counter, &cart.Weight, &cart.Discount,
 			&cart.Taxes, &cart.Subtotal, &cart.Total,
-			&p.ID, &p.CartID, &p.Quantity,
+			&p.ID, &p.CartID, &p.Quantity, &p.UpdatedAt, &p.VariantID,
+			&p.Weight, &p.Discount, &p.Taxes, &p.Subtotal, &p.Total,
 		)
 		if err != nil {
 			return Cart{}, errors.Wrap(err, "couldn't scan cart")
@@ -200,12 +290,12 @@ func (s *service) Get(ctx context.Context, cartID string) (Cart, error) {
 }
 
 // CartProduct returns a cart product.
-func (s *service) CartProduct(ctx context.Context, cartID, productID string) (Product, error) {
+func (s *service) CartProduct(ctx context.Context, cartID, productID, variantID string) (Product, error) {
 	s.metrics.incMethodCalls("Product")
 
 	var product Product
-	q := "SELECT * FROM cart_products WHERE id=$1 AND cart_id=$2"
-	if err := s.db.GetContext(ctx, &product, q, productID, cartID); err != nil {
+	q := "SELECT * FROM cart_products WHERE id=$1 AND variant_id=$2 AND cart_id=$3"
+	if err := s.db.GetContext(ctx, &product, q, productID, variantID, cartID); err != nil {
 		return Product{}, errors.Wrap(err, "couldn't find cart product")
 	}
 
@@ -228,8 +318,114 @@ func (s *service) CartProducts(ctx context.Context, cartID string) ([]Product, e
 	return products, nil
 }
 
+// Merge moves the products and promotions of the guest cart into the user cart
+// and deletes the former.
+//
+// The quantity of the products that are in both carts is decided by the merge policy.
+func (s *service) Merge(ctx context.Context, guestID, cartID string) error {
+	s.metrics.incMethodCalls("Merge")
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	var guestProducts []Product
+	q := "SELECT * FROM cart_products WHERE cart_id=$1 FOR UPDATE"
+	if err := tx.SelectContext(ctx, &guestProducts, q, guestID); err != nil {
+		return errors.Wrap(err, "couldn't find the guest cart products")
+	}
+
+	// Release the guest cart holds first so they aren't counted against the user cart
+	if err := s.inventory.ReleaseAll(ctx, tx, guestID); err != nil {
+		return err
+	}
+
+	// The products that are in both carts keep the values of the user cart
+	mergeQ := `INSERT INTO cart_products
+	(id, variant_id, cart_id, quantity, updated_at, weight, discount, taxes, subtotal, total)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (id, variant_id, cart_id) DO UPDATE SET ` + mergeUpdates[s.mergePolicy] + `,
+	updated_at=GREATEST(cart_products.updated_at, EXCLUDED.updated_at)
+	RETURNING quantity`
+	for _, p := range guestProducts {
+		item := inventory.Item{ProductID: p.ID.String, VariantID: p.VariantID.String}
+		err := tx.GetContext(ctx, &item.Quantity, mergeQ, item.ProductID, item.VariantID,
+			cartID, p.Quantity, p.UpdatedAt, p.Weight, p.Discount, p.Taxes, p.Subtotal, p.Total)
+		if err != nil {
+			return errors.Wrap(err, "couldn't merge the product")
+		}
+
+		if err := s.inventory.Hold(ctx, tx, cartID, item); err != nil {
+			return err
+		}
+	}
+
+	promotionsQ := `INSERT INTO cart_promotions
+	(cart_id, promotion_id)
+	SELECT $2, promotion_id FROM cart_promotions WHERE cart_id=$1
+	ON CONFLICT DO NOTHING`
+	if _, err := tx.ExecContext(ctx, promotionsQ, guestID, cartID); err != nil {
+		return errors.Wrap(err, "couldn't merge the promotions")
+	}
+
+	if err := updateTotals(ctx, tx, cartID); err != nil {
+		return err
+	}
+
+	// Products, holds and promotions are deleted in cascade
+	if _, err := tx.ExecContext(ctx, "DELETE FROM carts WHERE id=$1", guestID); err != nil {
+		return errors.Wrap(err, "couldn't delete the guest cart")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	for _, id := range []string{guestID, cartID} {
+		if err := s.mc.Delete(id); err != nil && err != memcache.ErrCacheMiss {
+			return errors.Wrap(err, "deleting cart from cache")
+		}
+	}
+
+	return nil
+}
+
+// Refresh updates the values of the cart products to the current ones of the products
+// and recalculates the cart totals.
+//
+// It's executed inside the transaction received so the values used by an order are the
+// same that are saved.
+func (s *service) Refresh(ctx context.Context, tx *sqlx.Tx, cartID string) error {
+	s.metrics.incMethodCalls("Refresh")
+
+	q := `UPDATE cart_products AS cp SET
+	(weight, discount, taxes, subtotal, total) = (
+		SELECT COALESCE(v.weight, p.weight), COALESCE(v.discount, p.discount),
+		COALESCE(v.taxes, p.taxes), COALESCE(v.subtotal, p.subtotal), COALESCE(v.total, p.total)
+		FROM products AS p
+		LEFT JOIN product_variants AS v ON v.id=cp.variant_id AND v.product_id=p.id
+		WHERE p.id=cp.id
+	)
+	WHERE cp.cart_id=$1 AND EXISTS (SELECT 1 FROM products WHERE id=cp.id)`
+	if _, err := tx.ExecContext(ctx, q, cartID); err != nil {
+		return errors.Wrap(err, "couldn't refresh the cart products")
+	}
+
+	if err := updateTotals(ctx, tx, cartID); err != nil {
+		return err
+	}
+
+	if err := s.mc.Delete(cartID); err != nil && err != memcache.ErrCacheMiss {
+		return errors.Wrap(err, "deleting cart from cache")
+	}
+
+	return nil
+}
+
 // Remove takes away the specified quantity of products from the cart.
-func (s *service) Remove(ctx context.Context, cartID string, pID string, quantity int64) error {
+func (s *service) Remove(ctx context.Context, cartID, pID, variantID string, quantity int64) error {
 	s.metrics.incMethodCalls("Remove")
 
 	tx, err := s.db.Beginx()
@@ -239,8 +435,8 @@ func (s *service) Remove(ctx context.Context, cartID string, pID string, quantit
 	defer tx.Rollback()
 
 	var cartProduct Product
-	cpQ := "SELECT * FROM cart_products WHERE id=$1 AND cart_id=$2"
-	if err := tx.GetContext(ctx, &cartProduct, cpQ, pID, cartID); err != nil {
+	cpQ := "SELECT * FROM cart_products WHERE id=$1 AND variant_id=$2 AND cart_id=$3"
+	if err := tx.GetContext(ctx, &cartProduct, cpQ, pID, variantID, cartID); err != nil {
 		return errors.Wrap(err, "couldn't find cart product")
 	}
 
@@ -250,25 +446,25 @@ func (s *service) Remove(ctx context.Context, cartID string, pID string, quantit
 	}
 
 	if quantity == cartProduct.Quantity.Int64 {
-		_, err := s.db.ExecContext(ctx, "DELETE FROM cart_products WHERE id=$1 AND cart_id=$2", pID, cartID)
-		if err != nil {
+		q := "DELETE FROM cart_products WHERE id=$1 AND variant_id=$2 AND cart_id=$3"
+		if _, err := tx.ExecContext(ctx, q, pID, variantID, cartID); err != nil {
 			return errors.Wrap(err, "couldn't delete the product")
 		}
+	} else {
+		q := `UPDATE cart_products SET quantity=quantity-$4, updated_at=NOW()
+		WHERE id=$1 AND variant_id=$2 AND cart_id=$3`
+		if _, err := tx.ExecContext(ctx, q, pID, variantID, cartID, quantity); err != nil {
+			return errors.Wrap(err, "couldn't update the product quantity")
+		}
 	}
 
-	var product product.Product
-	if err := tx.GetContext(ctx, &product, "SELECT * FROM products WHERE id = $1", pID); err != nil {
-		return errors.New("couldn't find the product")
+	item := inventory.Item{ProductID: pID, VariantID: variantID, Quantity: quantity}
+	if err := s.inventory.Release(ctx, tx, cartID, item); err != nil {
+		return err
 	}
 
-	uptQ := `UPDATE carts SET 
-	counter=counter-$2, weight=weight-$3, discount=discount-$4, 
-	taxes=taxes-$5, subtotal=subtotal-$6, total=total-$7 
-	WHERE id=$1`
-	_, err = tx.ExecContext(ctx, uptQ, cartID, quantity, product.Weight,
-		product.Discount, product.Taxes, product.Subtotal, product.Total)
-	if err != nil {
-		return errors.Wrap(err, "updating cart")
+	if err := updateTotals(ctx, tx, cartID); err != nil {
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -286,12 +482,16 @@ func (s *service) Remove(ctx context.Context, cartID string, pID string, quantit
 func (s *service) Reset(ctx context.Context, cartID string) error {
 	s.metrics.incMethodCalls("Reset")
 
-	tx, err := s.db.Begin()
+	tx, err := s.db.Beginx()
 	if err != nil {
 		return errors.Wrap(err, "starting transaction")
 	}
 	defer tx.Rollback()
 
+	if err := s.inventory.ReleaseAll(ctx, tx, cartID); err != nil {
+		return err
+	}
+
 	del := "DELETE FROM cart_products WHERE cart_id=$1"
 	if _, err := tx.ExecContext(ctx, del, cartID); err != nil {
 		return errors.Wrap(err, "couldn't delete cart products")
@@ -327,16 +527,135 @@ func (s *service) Size(ctx context.Context, cartID string) (int64, error) {
 	return size, nil
 }
 
-func (s *service) createOrUpdateProduct(ctx context.Context, tx *sqlx.Tx, cartProduct Product) error {
+// Touch refreshes the last activity of a guest cart.
+//
+// It returns ErrExpired if the cart is not a guest one or if it has been idle for too long.
+func (s *service) Touch(ctx context.Context, cartID string) error {
+	s.metrics.incMethodCalls("Touch")
+
+	now := time.Now()
+	q := "UPDATE guest_carts SET last_activity=$2 WHERE cart_id=$1 AND last_activity > $3"
+	res, err := s.db.ExecContext(ctx, q, cartID, now, now.Add(-s.guestTTL))
+	if err != nil {
+		return errors.Wrap(err, "updating guest cart")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "updating guest cart")
+	}
+	if n == 0 {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// add places the product in the cart, reserving its stock, and updates the cart totals.
+func (s *service) add(ctx context.Context, tx *sqlx.Tx, cartProduct Product) error {
+	p, err := getProduct(ctx, tx, cartProduct.ID.String, cartProduct.VariantID.String)
+	if err != nil {
+		return err
+	}
+
+	quantity, err := s.createOrUpdateProduct(ctx, tx, cartProduct, p)
+	if err != nil {
+		return err
+	}
+
+	// Reserve the total quantity of the product placed in the cart
+	item := inventory.Item{
+		ProductID: cartProduct.ID.String,
+		VariantID: cartProduct.VariantID.String,
+		Quantity:  quantity,
+	}
+	if err := s.inventory.Hold(ctx, tx, cartProduct.CartID.String, item); err != nil {
+		return err
+	}
+
+	return updateTotals(ctx, tx, cartProduct.CartID.String)
+}
+
+// createOrUpdateProduct adds the product to the cart and returns its updated quantity.
+//
+// New lines take the current values of the product, which are the ones the user has seen
+// when adding it. Lines already in the cart keep their values, the differences with the
+// current ones are reported when ordering for the user to accept them.
+func (s *service) createOrUpdateProduct(ctx context.Context, tx *sqlx.Tx,
+	cartProduct Product, p product.Product) (int64, error) {
 	productsQ := `INSERT INTO cart_products
-	(id, cart_id, quantity)
-	VALUES ($1, $2, $3)
-	ON CONFLICT (id) DO UPDATE SET 
-	quantity=EXCLUDED.quantity+$3`
-	_, err := tx.ExecContext(ctx, productsQ, cartProduct.ID, cartProduct.CartID, cartProduct.Quantity)
+	(id, variant_id, cart_id, quantity, weight, discount, taxes, subtotal, total)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (id, variant_id, cart_id) DO UPDATE SET 
+	quantity=cart_products.quantity+$4, updated_at=NOW()
+	RETURNING quantity`
+	var quantity int64
+	err := tx.GetContext(ctx, &quantity, productsQ, cartProduct.ID, cartProduct.VariantID.String,
+		cartProduct.CartID, cartProduct.Quantity, p.Weight, p.Discount, p.Taxes, p.Subtotal, p.Total)
 	if err != nil {
-		return errors.Wrap(err, "couldn't create the product")
+		return 0, errors.Wrap(err, "couldn't create the product")
+	}
+
+	return quantity, nil
+}
+
+// updateTotals recalculates the cart values from the products in it.
+//
+// The values saved in each line are used, not the current ones of the products. Taxes
+// depend on the shipping address, they are calculated when ordering.
+func updateTotals(ctx context.Context, tx *sqlx.Tx, cartID string) error {
+	q := `UPDATE carts SET
+	counter=t.counter, weight=t.weight, discount=t.discount,
+	taxes=0, subtotal=t.subtotal, total=t.total
+	FROM (
+		SELECT COALESCE(SUM(quantity), 0) AS counter,
+		COALESCE(SUM(quantity * weight), 0) AS weight,
+		COALESCE(SUM(quantity * discount), 0) AS discount,
+		COALESCE(SUM(quantity * subtotal), 0) AS subtotal,
+		COALESCE(SUM(quantity * total), 0) AS total
+		FROM cart_products
+		WHERE cart_id=$1
+	) AS t
+	WHERE carts.id=$1`
+	if _, err := tx.ExecContext(ctx, q, cartID); err != nil {
+		return errors.Wrap(err, "updating cart totals")
 	}
 
 	return nil
 }
+
+// getProduct returns the product with the values of the variant requested.
+//
+// Products that have variants can only be added to the cart through one of them.
+func getProduct(ctx context.Context, tx *sqlx.Tx, productID, variantID string) (product.Product, error) {
+	var p product.Product
+	if err := tx.GetContext(ctx, &p, "SELECT * FROM products WHERE id=$1", productID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return product.Product{}, errors.Wrapf(ErrProductNotFound, "product %q", productID)
+		}
+		return product.Product{}, errors.Wrap(err, "couldn't find product")
+	}
+
+	if variantID == "" {
+		var hasVariants bool
+		q := "SELECT EXISTS(SELECT 1 FROM product_variants WHERE product_id=$1)"
+		if err := tx.GetContext(ctx, &hasVariants, q, productID); err != nil {
+			return product.Product{}, errors.Wrap(err, "couldn't find product variants")
+		}
+		if hasVariants {
+			return product.Product{}, errors.Wrapf(ErrVariantRequired, "product %q", productID)
+		}
+		return p, nil
+	}
+
+	var v product.Variant
+	q := "SELECT * FROM product_variants WHERE id=$1 AND product_id=$2"
+	if err := tx.GetContext(ctx, &v, q, variantID, productID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return product.Product{}, errors.Wrapf(ErrProductNotFound, "variant %q", variantID)
+		}
+		return product.Product{}, errors.Wrap(err, "couldn't find the variant")
+	}
+
+	return p.WithVariant(v), nil
+}
@@ -5,29 +5,37 @@ import (
 	"os"
 	"testing"
 
+	"github.com/GGP1/adak/internal/config"
 	"github.com/GGP1/adak/internal/logger"
 	"github.com/GGP1/adak/internal/test"
 	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/guregu/null.v4/zero"
 )
 
 const cartID = "1234"
 
-var service cart.Service
+var (
+	db      *sqlx.DB
+	service cart.Service
+)
 
 func TestMain(m *testing.M) {
 	poolMc, resourceMc, mc, err := test.RunMemcached()
 	if err != nil {
 		logger.Fatal(err)
 	}
-	poolPg, resourcePg, db, err := test.RunPostgres()
+	poolPg, resourcePg, pg, err := test.RunPostgres()
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	service = cart.NewService(db, mc)
+	db = pg
+	service = cart.NewService(db, mc, config.Cart{}, inventory.NewService(config.Inventory{}), promotion.NewService(db))
 	if err := service.Create(context.Background(), cartID); err != nil {
 		logger.Fatal(err)
 	}
@@ -56,17 +64,17 @@ func TestAdd(t *testing.T) {
 	err := service.Add(ctx, product)
 	assert.NoError(t, err)
 
-	p, err := service.CartProduct(ctx, cartID, pID)
+	p, err := service.CartProduct(ctx, cartID, pID, "")
 	assert.NoError(t, err)
 
 	assert.Equal(t, quantity, p.Quantity)
 }
 
 func TestCheckout(t *testing.T) {
-	total, err := service.Checkout(context.Background(), cartID)
+	checkout, err := service.Checkout(context.Background(), cartID)
 	assert.NoError(t, err)
 
-	assert.Equal(t, int64(0), total)
+	assert.Equal(t, int64(0), checkout.Total)
 }
 
 func TestDelete(t *testing.T) {
@@ -88,6 +96,28 @@ func TestGet(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestGuest(t *testing.T) {
+	ctx := context.Background()
+	guestID := "guest"
+
+	err := service.CreateGuest(ctx, guestID)
+	assert.NoError(t, err)
+
+	err = service.Touch(ctx, guestID)
+	assert.NoError(t, err)
+
+	// User carts aren't guest carts
+	err = service.Touch(ctx, cartID)
+	assert.ErrorIs(t, err, cart.ErrExpired)
+
+	err = service.Merge(ctx, guestID, cartID)
+	assert.NoError(t, err)
+
+	// The guest cart is deleted after merging
+	err = service.Touch(ctx, guestID)
+	assert.ErrorIs(t, err, cart.ErrExpired)
+}
+
 func TestRemove(t *testing.T) {
 	ctx := context.Background()
 	product := cart.Product{
@@ -98,7 +128,7 @@ func TestRemove(t *testing.T) {
 	err := service.Add(ctx, product)
 	assert.NoError(t, err)
 
-	err = service.Remove(ctx, cartID, "2", 1)
+	err = service.Remove(ctx, cartID, "2", "", 1)
 	assert.NoError(t, err)
 
 	c, _ := service.Get(ctx, cartID)
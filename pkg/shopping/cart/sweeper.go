@@ -0,0 +1,69 @@
+package cart
+
+import (
+	"context"
+	"time"
+
+	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/internal/logger"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const defaultSweepInterval = time.Hour
+
+// Sweeper deletes the guest carts that have been idle for too long.
+type Sweeper struct {
+	db       *sqlx.DB
+	guestTTL time.Duration
+	interval time.Duration
+}
+
+// NewSweeper returns a new guest carts sweeper.
+func NewSweeper(db *sqlx.DB, config config.Cart) *Sweeper {
+	guestTTL := config.GuestTTL
+	if guestTTL <= 0 {
+		guestTTL = defaultGuestTTL
+	}
+	interval := config.SweepInterval
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+	return &Sweeper{db: db, guestTTL: guestTTL, interval: interval}
+}
+
+// Run sweeps the idle guest carts periodically until the context is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.Sweep(ctx)
+			if err != nil {
+				logger.Error(err)
+				continue
+			}
+			if n > 0 {
+				logger.Debugf("Deleted %d idle guest carts", n)
+			}
+		}
+	}
+}
+
+// Sweep deletes the idle guest carts and returns how many were removed.
+//
+// Their products, stock holds and promotions are deleted in cascade.
+func (s *Sweeper) Sweep(ctx context.Context) (int64, error) {
+	q := "DELETE FROM carts WHERE id IN (SELECT cart_id FROM guest_carts WHERE last_activity <= $1)"
+	res, err := s.db.ExecContext(ctx, q, time.Now().Add(-s.guestTTL))
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting idle guest carts")
+	}
+
+	return res.RowsAffected()
+}
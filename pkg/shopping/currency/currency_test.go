@@ -0,0 +1,53 @@
+package currency
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		desc       string
+		amount     int64
+		rate       string
+		fromDigits int
+		toDigits   int
+		expected   int64
+	}{
+		{desc: "same digits", amount: 1000, rate: "0.9", fromDigits: 2, toDigits: 2, expected: 900},
+		{desc: "round half up", amount: 105, rate: "0.5", fromDigits: 2, toDigits: 2, expected: 53},
+		{desc: "round down", amount: 101, rate: "0.5", fromDigits: 2, toDigits: 2, expected: 51},
+		{desc: "zero decimals", amount: 1099, rate: "150.25", fromDigits: 2, toDigits: 0, expected: 1651},
+		{desc: "three decimals", amount: 1000, rate: "0.3075", fromDigits: 2, toDigits: 3, expected: 3075},
+		{desc: "from zero decimals", amount: 1500, rate: "0.0067", fromDigits: 0, toDigits: 2, expected: 1005},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := convert(tc.amount, tc.rate, tc.fromDigits, tc.toDigits)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestConvertInvalidRate(t *testing.T) {
+	for _, rate := range []string{"", "abc", "0", "-1.5"} {
+		_, err := convert(100, rate, 2, 2)
+		assert.Error(t, err, rate)
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	digits, err := MinorUnits("jpy")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, digits)
+
+	digits, err = MinorUnits("KWD")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, digits)
+
+	_, err = MinorUnits("XXX")
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
@@ -0,0 +1,98 @@
+package currency
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/internal/sanitize"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+)
+
+type setRequest struct {
+	Rate string `json:"rate"`
+}
+
+// Handler handles currency endpoints.
+type Handler struct {
+	service Service
+}
+
+// NewHandler returns a new currency handler.
+func NewHandler(service Service) Handler {
+	return Handler{
+		service: service,
+	}
+}
+
+// Delete removes the exchange rate of a currency.
+func (h *Handler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		currency := Normalize(sanitize.Normalize(chi.URLParam(r, "currency")))
+
+		if err := h.service.Delete(ctx, currency); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, currency)
+	}
+}
+
+// Get lists the exchange rates.
+func (h *Handler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rates, err := h.service.Get(r.Context())
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, rates)
+	}
+}
+
+// Import saves the exchange rates from the CSV file sent in the request body.
+func (h *Handler) Import() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		n, err := h.service.Import(r.Context(), r.Body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, strconv.FormatInt(n, 10)+" exchange rates imported")
+	}
+}
+
+// Set creates or updates the exchange rate of a currency.
+func (h *Handler) Set() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		currency := Normalize(sanitize.Normalize(chi.URLParam(r, "currency")))
+
+		var req setRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := h.service.Set(ctx, currency, req.Rate); err != nil {
+			if errors.Is(err, ErrUnsupported) {
+				response.Error(w, http.StatusBadRequest, err)
+				return
+			}
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, currency+" exchange rate updated")
+	}
+}
@@ -0,0 +1,38 @@
+package currency
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnsupported is returned when a currency is not a valid ISO 4217 code or it has no exchange rate.
+var ErrUnsupported = errors.New("unsupported currency")
+
+// minorUnits contains the number of digits after the decimal separator of each currency.
+//
+// Source: https://www.iso.org/iso-4217-currency-codes.html
+var minorUnits = map[string]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BGN": 2, "BHD": 3, "BOB": 2, "BRL": 2,
+	"CAD": 2, "CHF": 2, "CLP": 0, "CNY": 2, "COP": 2, "CZK": 2, "DKK": 2,
+	"EGP": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2,
+	"INR": 2, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3,
+	"LYD": 3, "MXN": 2, "MYR": 2, "NOK": 2, "NZD": 2, "OMR": 3, "PEN": 2,
+	"PHP": 2, "PLN": 2, "PYG": 0, "RON": 2, "RUB": 2, "SAR": 2, "SEK": 2,
+	"SGD": 2, "THB": 2, "TND": 3, "TRY": 2, "TWD": 2, "UAH": 2, "UGX": 0,
+	"USD": 2, "UYU": 2, "VND": 0, "XAF": 0, "XOF": 0, "ZAR": 2,
+}
+
+// Normalize returns the upper-cased currency code.
+func Normalize(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// MinorUnits returns the number of decimals used by the currency.
+func MinorUnits(currency string) (int, error) {
+	digits, ok := minorUnits[Normalize(currency)]
+	if !ok {
+		return 0, errors.Wrap(ErrUnsupported, currency)
+	}
+	return digits, nil
+}
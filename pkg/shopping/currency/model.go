@@ -0,0 +1,22 @@
+package currency
+
+import (
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Rate represents the units of a currency that are equivalent to one unit of the base currency.
+type Rate struct {
+	Currency zero.String `json:"currency,omitempty" validate:"required,len=3"`
+	// Rate is stored as a numeric to avoid floating point errors
+	Rate      zero.String `json:"rate,omitempty" validate:"required"`
+	UpdatedAt zero.Time   `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// Conversion is the result of converting an amount from the base currency.
+//
+// Amounts are expressed in the currency’s smallest unit.
+type Conversion struct {
+	Currency string `json:"currency"`
+	Amount   int64  `json:"amount"`
+	Rate     string `json:"exchange_rate"`
+}
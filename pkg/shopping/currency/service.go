@@ -0,0 +1,211 @@
+// Package currency converts the amounts from the base currency to the ones requested by the shoppers.
+package currency
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/GGP1/adak/internal/config"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const defaultBase = "USD"
+
+// Service contains currency functionalities.
+type Service interface {
+	Base() string
+	Convert(ctx context.Context, amount int64, currency string) (Conversion, error)
+	Delete(ctx context.Context, currency string) error
+	Get(ctx context.Context) ([]Rate, error)
+	Import(ctx context.Context, r io.Reader) (int64, error)
+	Set(ctx context.Context, currency, rate string) error
+}
+
+type service struct {
+	db      *sqlx.DB
+	base    string
+	metrics metrics
+}
+
+// NewService returns a new currency service.
+func NewService(db *sqlx.DB, config config.Currency) Service {
+	base := Normalize(config.Base)
+	if base == "" {
+		base = defaultBase
+	}
+	return &service{db, base, initMetrics()}
+}
+
+// Base returns the currency the products are priced in.
+func (s *service) Base() string {
+	return s.base
+}
+
+// Convert takes an amount in the base currency and returns its value in the currency provided.
+func (s *service) Convert(ctx context.Context, amount int64, currency string) (Conversion, error) {
+	s.metrics.incMethodCalls("Convert")
+
+	currency = Normalize(currency)
+	if currency == s.base {
+		return Conversion{Currency: currency, Amount: amount, Rate: "1"}, nil
+	}
+
+	fromDigits, err := MinorUnits(s.base)
+	if err != nil {
+		return Conversion{}, err
+	}
+	toDigits, err := MinorUnits(currency)
+	if err != nil {
+		return Conversion{}, err
+	}
+
+	var rate string
+	if err := s.db.GetContext(ctx, &rate, "SELECT rate FROM exchange_rates WHERE currency=$1", currency); err != nil {
+		return Conversion{}, errors.Wrapf(ErrUnsupported, "no exchange rate for %s", currency)
+	}
+
+	converted, err := convert(amount, rate, fromDigits, toDigits)
+	if err != nil {
+		return Conversion{}, err
+	}
+
+	return Conversion{Currency: currency, Amount: converted, Rate: rate}, nil
+}
+
+// Delete removes the exchange rate of a currency.
+func (s *service) Delete(ctx context.Context, currency string) error {
+	s.metrics.incMethodCalls("Delete")
+
+	q := "DELETE FROM exchange_rates WHERE currency=$1"
+	if _, err := s.db.ExecContext(ctx, q, Normalize(currency)); err != nil {
+		return errors.Wrap(err, "couldn't delete the exchange rate")
+	}
+
+	return nil
+}
+
+// Get returns all the exchange rates.
+func (s *service) Get(ctx context.Context) ([]Rate, error) {
+	s.metrics.incMethodCalls("Get")
+
+	var rates []Rate
+	if err := s.db.SelectContext(ctx, &rates, "SELECT * FROM exchange_rates ORDER BY currency"); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the exchange rates")
+	}
+
+	return rates, nil
+}
+
+// Import saves the exchange rates from a CSV file with the format "currency,rate",
+// the header is optional. It returns the number of rates saved.
+//
+// All the rates are saved or none is.
+func (s *service) Import(ctx context.Context, r io.Reader) (int64, error) {
+	s.metrics.incMethodCalls("Import")
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, errors.Wrap(err, "reading csv")
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	var n int64
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(record[0], "currency") {
+			continue
+		}
+		if err := setRate(ctx, tx, s.base, record[0], record[1]); err != nil {
+			return 0, errors.Wrapf(err, "line %d", i+1)
+		}
+		n++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "committing transaction")
+	}
+
+	return n, nil
+}
+
+// Set creates or updates the exchange rate of a currency.
+func (s *service) Set(ctx context.Context, currency, rate string) error {
+	s.metrics.incMethodCalls("Set")
+
+	return setRate(ctx, s.db, s.base, currency, rate)
+}
+
+// convert multiplies the amount by the rate and adjusts it to the minor units of the
+// target currency, rounding half away from zero.
+func convert(amount int64, rate string, fromDigits, toDigits int) (int64, error) {
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 {
+		return 0, errors.Errorf("invalid exchange rate %q", rate)
+	}
+
+	v := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), r)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(toDigits-fromDigits))), nil))
+	if toDigits > fromDigits {
+		v.Mul(v, scale)
+	} else {
+		v.Quo(v, scale)
+	}
+
+	// Round half away from zero
+	num, denom := v.Num(), v.Denom()
+	q, m := new(big.Int).QuoRem(num, denom, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(denom) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+
+	if !q.IsInt64() {
+		return 0, errors.New("converted amount overflows")
+	}
+	return q.Int64(), nil
+}
+
+// setRate validates and upserts an exchange rate.
+func setRate(ctx context.Context, db sqlx.ExecerContext, base, currency, rate string) error {
+	currency = Normalize(currency)
+	if currency == base {
+		return errors.New("the base currency rate is always 1")
+	}
+	if _, err := MinorUnits(currency); err != nil {
+		return err
+	}
+
+	rate = strings.TrimSpace(rate)
+	if r, ok := new(big.Rat).SetString(rate); !ok || r.Sign() <= 0 {
+		return errors.Errorf("invalid exchange rate %q", rate)
+	}
+
+	q := `INSERT INTO exchange_rates
+	(currency, rate, updated_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (currency) DO UPDATE SET rate=$2, updated_at=$3`
+	if _, err := db.ExecContext(ctx, q, currency, rate, time.Now()); err != nil {
+		return errors.Wrap(err, "couldn't save the exchange rate")
+	}
+
+	return nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
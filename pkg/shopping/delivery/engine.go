@@ -0,0 +1,107 @@
+package delivery
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	clockLayout = "15:04"
+	dateLayout  = "2006-01-02"
+)
+
+// bookingKey identifies the bookings of a slot on a date.
+func bookingKey(slotID, date string) string {
+	return slotID + ":" + date
+}
+
+// covers returns whether the slot can be used to hand over an order to the country, users
+// can come from anywhere to pick up their orders.
+func (s Slot) covers(country string) bool {
+	if s.Type.String == Pickup || len(s.Countries) == 0 {
+		return true
+	}
+	for _, c := range s.Countries {
+		if strings.EqualFold(c, strings.TrimSpace(country)) {
+			return true
+		}
+	}
+	return false
+}
+
+// window returns when the slot starts and ends on the date provided.
+func (s Slot) window(date time.Time) (time.Time, time.Time, error) {
+	start, err := time.Parse(clockLayout, s.Start.String)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrap(err, "invalid slot start")
+	}
+	end, err := time.Parse(clockLayout, s.End.String)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrap(err, "invalid slot end")
+	}
+
+	y, m, d := date.Date()
+	loc := date.Location()
+	return time.Date(y, m, d, start.Hour(), start.Minute(), 0, 0, loc),
+		time.Date(y, m, d, end.Hour(), end.Minute(), 0, 0, loc), nil
+}
+
+// schedule returns the slots that can be booked in the days following now, sorted by
+// their start.
+//
+// booked contains the number of orders booked in each slot by date, slots that already
+// started or have no room left are excluded.
+func schedule(slots []Slot, booked map[string]int64, country string, now time.Time, days int) []Availability {
+	var available []Availability
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+
+	for i := 0; i < days; i++ {
+		date := today.AddDate(0, 0, i)
+		for _, s := range slots {
+			if time.Weekday(s.Weekday) != date.Weekday() || !s.covers(country) {
+				continue
+			}
+			start, end, err := s.window(date)
+			if err != nil || !start.After(now) {
+				continue
+			}
+
+			day := date.Format(dateLayout)
+			remaining := s.Capacity.Int64 - booked[bookingKey(s.ID.String, day)]
+			if remaining <= 0 {
+				continue
+			}
+
+			available = append(available, Availability{
+				SlotID:    s.ID.String,
+				ShopID:    s.ShopID.String,
+				Type:      s.Type.String,
+				Date:      day,
+				Start:     start,
+				End:       end,
+				Remaining: remaining,
+			})
+		}
+	}
+
+	sort.SliceStable(available, func(i, j int) bool {
+		return available[i].Start.Before(available[j].Start)
+	})
+	return available
+}
+
+// validateSlot checks the fields that can't be validated with tags.
+func validateSlot(s Slot) error {
+	start, end, err := s.window(time.Time{})
+	if err != nil {
+		return err
+	}
+	if !start.Before(end) {
+		return errors.New("the slot must start before it ends")
+	}
+	return nil
+}
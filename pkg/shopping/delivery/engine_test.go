@@ -0,0 +1,79 @@
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestCovers(t *testing.T) {
+	zone := Slot{Type: zero.StringFrom(Delivery), Countries: pq.StringArray{"AR", "UY"}}
+	pickup := Slot{Type: zero.StringFrom(Pickup), Countries: pq.StringArray{"AR"}}
+	anywhere := Slot{Type: zero.StringFrom(Delivery)}
+
+	assert.True(t, zone.covers("ar"))
+	assert.False(t, zone.covers("BR"))
+	assert.True(t, pickup.covers("BR"))
+	assert.True(t, anywhere.covers("BR"))
+}
+
+func TestSchedule(t *testing.T) {
+	// Monday
+	now := time.Date(2022, time.March, 7, 12, 0, 0, 0, time.UTC)
+	slots := []Slot{
+		{ID: zero.StringFrom("morning"), Type: zero.StringFrom(Delivery), Weekday: 1,
+			Start: zero.StringFrom("09:00"), End: zero.StringFrom("11:00"), Capacity: zero.IntFrom(5)},
+		{ID: zero.StringFrom("evening"), Type: zero.StringFrom(Delivery), Weekday: 1,
+			Start: zero.StringFrom("18:00"), End: zero.StringFrom("20:00"), Capacity: zero.IntFrom(2)},
+		{ID: zero.StringFrom("tuesday"), Type: zero.StringFrom(Pickup), Weekday: 2,
+			Start: zero.StringFrom("10:00"), End: zero.StringFrom("12:00"), Capacity: zero.IntFrom(3)},
+		{ID: zero.StringFrom("abroad"), Type: zero.StringFrom(Delivery), Weekday: 2,
+			Start: zero.StringFrom("10:00"), End: zero.StringFrom("12:00"), Capacity: zero.IntFrom(3),
+			Countries: pq.StringArray{"UY"}},
+	}
+	booked := map[string]int64{
+		bookingKey("evening", "2022-03-07"): 2,
+		bookingKey("tuesday", "2022-03-08"): 1,
+	}
+
+	available := schedule(slots, booked, "AR", now, 8)
+
+	var got []string
+	for _, a := range available {
+		got = append(got, a.SlotID+" "+a.Date)
+	}
+	expected := []string{
+		// The morning slot already started today and the evening one is full
+		"tuesday 2022-03-08",
+		"morning 2022-03-14",
+		"evening 2022-03-14",
+	}
+	assert.Equal(t, expected, got)
+	assert.Equal(t, int64(2), available[0].Remaining)
+	assert.Equal(t, time.Date(2022, time.March, 8, 10, 0, 0, 0, time.UTC), available[0].Start)
+	assert.Equal(t, time.Date(2022, time.March, 8, 12, 0, 0, 0, time.UTC), available[0].End)
+}
+
+func TestValidateSlot(t *testing.T) {
+	cases := []struct {
+		desc  string
+		start string
+		end   string
+		valid bool
+	}{
+		{desc: "Valid", start: "09:00", end: "11:30", valid: true},
+		{desc: "Ends before starting", start: "11:00", end: "09:00", valid: false},
+		{desc: "Empty window", start: "11:00", end: "11:00", valid: false},
+		{desc: "Invalid format", start: "9am", end: "11:00", valid: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := validateSlot(Slot{Start: zero.StringFrom(tc.start), End: zero.StringFrom(tc.end)})
+			assert.Equal(t, tc.valid, err == nil)
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GGP1/adak/internal/cookie"
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/internal/sanitize"
+	"github.com/GGP1/adak/internal/validate"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+var errNotAllowed = errors.New("not found")
+
+// Admins tells whether a user is an administrator, it's satisfied by the user service.
+type Admins interface {
+	IsAdmin(ctx context.Context, id string) (bool, error)
+}
+
+// Owners tells whether a user manages a shop, it's satisfied by the shop service.
+type Owners interface {
+	IsOwner(ctx context.Context, shopID, userID string) (bool, error)
+}
+
+// Handler handles delivery slots endpoints.
+type Handler struct {
+	service     Service
+	cartService cart.Service
+	owners      Owners
+	admins      Admins
+}
+
+// NewHandler returns a new delivery handler.
+func NewHandler(service Service, cartS cart.Service, owners Owners, admins Admins) Handler {
+	return Handler{
+		service:     service,
+		cartService: cartS,
+		owners:      owners,
+		admins:      admins,
+	}
+}
+
+// Available lists the slots that can be booked for the products in the cart.
+//
+// Delivery slots are filtered by the destination "country", the number of days ahead
+// listed can be changed with the "days" query parameter.
+func (h *Handler) Available() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		cartID, err := params.CartID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		var days int
+		if d := r.URL.Query().Get("days"); d != "" {
+			days, err = strconv.Atoi(d)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, errors.New("invalid number of days"))
+				return
+			}
+		}
+
+		c, err := h.cartService.Get(ctx, cartID)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+		productIDs := make([]string, len(c.Products))
+		for i, p := range c.Products {
+			productIDs[i] = p.ID.String
+		}
+
+		country := sanitize.Normalize(r.URL.Query().Get("country"))
+		slots, err := h.service.Available(ctx, productIDs, country, days)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, slots)
+	}
+}
+
+// Create creates a delivery slot for the shop.
+func (h *Handler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		shopID, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.authorize(ctx, r, shopID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		var slot Slot
+		if err := json.NewDecoder(r.Body).Decode(&slot); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		slot.ShopID = zero.StringFrom(shopID)
+		if err := validate.Struct(ctx, slot); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := validateSlot(slot); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		slot.ID = zero.StringFrom(uuid.NewString())
+		slot.CreatedAt = zero.TimeFrom(time.Now())
+		if err := h.service.Create(ctx, slot); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusCreated, slot)
+	}
+}
+
+// Delete removes a delivery slot of the shop.
+func (h *Handler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		shopID, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.authorize(ctx, r, shopID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		slotID := chi.URLParam(r, "slotID")
+		if err := h.service.Delete(ctx, shopID, slotID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, slotID)
+	}
+}
+
+// GetByShopID lists the delivery slots of the shop.
+func (h *Handler) GetByShopID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		shopID, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		slots, err := h.service.GetByShopID(ctx, shopID)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, slots)
+	}
+}
+
+// Update updates a delivery slot of the shop.
+func (h *Handler) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		shopID, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.authorize(ctx, r, shopID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		var slot Slot
+		if err := json.NewDecoder(r.Body).Decode(&slot); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		slot.ShopID = zero.StringFrom(shopID)
+		if err := validate.Struct(ctx, slot); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := validateSlot(slot); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		slotID := chi.URLParam(r, "slotID")
+		if err := h.service.Update(ctx, shopID, slotID, slot); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, slotID)
+	}
+}
+
+// authorize makes sure the user logged in owns the shop or is an administrator.
+func (h *Handler) authorize(ctx context.Context, r *http.Request, shopID string) error {
+	userID, err := cookie.GetValue(r, "UID")
+	if err != nil {
+		return errNotAllowed
+	}
+
+	owner, err := h.owners.IsOwner(ctx, shopID, userID)
+	if err != nil {
+		return err
+	}
+	if owner {
+		return nil
+	}
+
+	admin, err := h.admins.IsAdmin(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return errNotAllowed
+	}
+
+	return nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrSlotNotFound), errors.Is(err, errNotAllowed):
+		// Return 404 instead of 401 to not give additional information
+		response.Error(w, http.StatusNotFound, err)
+	default:
+		response.Error(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,52 @@
+package delivery
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Slot types
+const (
+	// Delivery slots are the windows in which the shop delivers the orders to their address
+	Delivery = "delivery"
+	// Pickup slots are the windows in which the users can pick up the orders at the shop
+	Pickup = "pickup"
+)
+
+// Slot is a time window that repeats every week in which a shop hands over a limited
+// number of orders.
+type Slot struct {
+	ID     zero.String `json:"id,omitempty"`
+	ShopID zero.String `json:"shop_id,omitempty" db:"shop_id" validate:"required"`
+	Type   zero.String `json:"type,omitempty" validate:"required,oneof=delivery pickup"`
+	// Weekday is the day of the week of the slot, 0 is Sunday
+	Weekday int64 `json:"weekday" validate:"min=0,max=6"`
+	// Start and End are times of the day in the 15:04 format
+	Start zero.String `json:"start,omitempty" db:"start_time" validate:"required"`
+	End   zero.String `json:"end,omitempty" db:"end_time" validate:"required"`
+	// Capacity is the maximum number of orders booked in the slot each day
+	Capacity zero.Int `json:"capacity,omitempty" validate:"required,min=1"`
+	// Countries is the zone covered by delivery slots, an empty list covers every country
+	Countries pq.StringArray `json:"countries,omitempty"`
+	CreatedAt zero.Time      `json:"created_at,omitempty" db:"created_at"`
+}
+
+// Availability is a slot on a specific date and the number of orders it can still take.
+type Availability struct {
+	SlotID    string    `json:"slot_id"`
+	ShopID    string    `json:"shop_id"`
+	Type      string    `json:"type"`
+	Date      string    `json:"date"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Remaining int64     `json:"remaining"`
+}
+
+// Booking is the slot chosen to hand over the products of a shop.
+type Booking struct {
+	SlotID string `json:"slot_id" validate:"required"`
+	// Date is the day of the slot in the 2006-01-02 format
+	Date string `json:"date" validate:"required"`
+}
@@ -0,0 +1,299 @@
+// Package delivery schedules the hand over of the orders in the time windows defined by the shops.
+package delivery
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultDays = 7
+	maxDays     = 30
+)
+
+var (
+	// ErrSlotFull is returned when the slot chosen has no room left on the date.
+	ErrSlotFull = errors.New("the delivery slot is full")
+	// ErrSlotNotFound is returned when the slot doesn't exist or doesn't belong to the shops of the cart.
+	ErrSlotNotFound = errors.New("delivery slot not found")
+	// ErrSlotRequired is returned when ordering products of a shop that defines slots without booking one.
+	ErrSlotRequired = errors.New("a delivery slot is required for each shop that defines them")
+	// ErrSlotUnavailable is returned when the slot can't be booked on the date or for the destination.
+	ErrSlotUnavailable = errors.New("the delivery slot is not available for this date and destination")
+)
+
+// Service contains delivery slots functionalities.
+type Service interface {
+	Available(ctx context.Context, productIDs []string, country string, days int) ([]Availability, error)
+	Book(ctx context.Context, tx *sqlx.Tx, orderID string, productIDs []string,
+		country string, bookings []Booking) (time.Time, error)
+	Create(ctx context.Context, slot Slot) error
+	Delete(ctx context.Context, shopID, id string) error
+	GetByShopID(ctx context.Context, shopID string) ([]Slot, error)
+	Release(ctx context.Context, tx *sqlx.Tx, orderID string) error
+	Update(ctx context.Context, shopID, id string, slot Slot) error
+}
+
+type service struct {
+	db      *sqlx.DB
+	metrics metrics
+}
+
+// NewService returns a new delivery service.
+func NewService(db *sqlx.DB) Service {
+	return &service{db, initMetrics()}
+}
+
+// Available returns the slots of the shops selling the products that can be booked in the
+// following days, sorted by their start.
+func (s *service) Available(ctx context.Context, productIDs []string, country string, days int) ([]Availability, error) {
+	s.metrics.incMethodCalls("Available")
+
+	if days <= 0 {
+		days = defaultDays
+	}
+	if days > maxDays {
+		days = maxDays
+	}
+
+	shopIDs, err := getShops(ctx, s.db, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var slots []Slot
+	if err := s.db.SelectContext(ctx, &slots, "SELECT * FROM delivery_slots WHERE shop_id=ANY($1)", pq.Array(shopIDs)); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the delivery slots")
+	}
+	if len(slots) == 0 {
+		return nil, nil
+	}
+
+	slotIDs := make([]string, len(slots))
+	for i, slot := range slots {
+		slotIDs[i] = slot.ID.String
+	}
+	q := `SELECT slot_id, to_char(date, 'YYYY-MM-DD'), COUNT(*) FROM slot_bookings
+	WHERE slot_id=ANY($1) AND date>=CURRENT_DATE GROUP BY slot_id, date`
+	rows, err := s.db.QueryContext(ctx, q, pq.Array(slotIDs))
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't count the slot bookings")
+	}
+	defer rows.Close()
+
+	booked := make(map[string]int64)
+	for rows.Next() {
+		var (
+			slotID, date string
+			count        int64
+		)
+		if err := rows.Scan(&slotID, &date, &count); err != nil {
+			return nil, errors.Wrap(err, "couldn't scan the slot bookings")
+		}
+		booked[bookingKey(slotID, date)] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "couldn't count the slot bookings")
+	}
+
+	return schedule(slots, booked, country, time.Now(), days), nil
+}
+
+// Book reserves the slots chosen for the order inside the transaction provided and returns
+// when the last one starts, zero if nothing was booked.
+//
+// Every shop selling the products that defines slots must have exactly one booked.
+func (s *service) Book(ctx context.Context, tx *sqlx.Tx, orderID string, productIDs []string,
+	country string, bookings []Booking) (time.Time, error) {
+	s.metrics.incMethodCalls("Book")
+
+	shopIDs, err := getShops(ctx, tx, productIDs)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var scheduled []string
+	q := "SELECT DISTINCT shop_id FROM delivery_slots WHERE shop_id=ANY($1)"
+	if err := tx.SelectContext(ctx, &scheduled, q, pq.Array(shopIDs)); err != nil {
+		return time.Time{}, errors.Wrap(err, "couldn't find the delivery slots")
+	}
+	required := make(map[string]bool, len(scheduled))
+	for _, shopID := range scheduled {
+		required[shopID] = true
+	}
+
+	// Lock the slots always in the same order so concurrent orders don't deadlock
+	bookings = append([]Booking(nil), bookings...)
+	sort.Slice(bookings, func(i, j int) bool {
+		return bookings[i].SlotID < bookings[j].SlotID
+	})
+
+	var last time.Time
+	booked := make(map[string]bool, len(bookings))
+	for _, b := range bookings {
+		start, shopID, err := book(ctx, tx, orderID, country, b)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !required[shopID] {
+			return time.Time{}, ErrSlotNotFound
+		}
+		if booked[shopID] {
+			return time.Time{}, errors.Wrap(ErrSlotUnavailable, "only one slot can be booked per shop")
+		}
+		booked[shopID] = true
+
+		if start.After(last) {
+			last = start
+		}
+	}
+
+	for shopID := range required {
+		if !booked[shopID] {
+			return time.Time{}, ErrSlotRequired
+		}
+	}
+
+	return last, nil
+}
+
+// Create a delivery slot.
+func (s *service) Create(ctx context.Context, slot Slot) error {
+	s.metrics.incMethodCalls("Create")
+
+	q := `INSERT INTO delivery_slots
+	(id, shop_id, type, weekday, start_time, end_time, capacity, countries, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := s.db.ExecContext(ctx, q, slot.ID, slot.ShopID, slot.Type, slot.Weekday,
+		slot.Start, slot.End, slot.Capacity, slot.Countries, slot.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create the delivery slot")
+	}
+
+	return nil
+}
+
+// Delete permanently deletes a delivery slot of a shop along with its bookings.
+func (s *service) Delete(ctx context.Context, shopID, id string) error {
+	s.metrics.incMethodCalls("Delete")
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM delivery_slots WHERE id=$1 AND shop_id=$2", id, shopID)
+	if err != nil {
+		return errors.Wrap(err, "couldn't delete the delivery slot")
+	}
+
+	return checkAffected(res)
+}
+
+// GetByShopID returns the delivery slots of a shop sorted by weekday and start.
+func (s *service) GetByShopID(ctx context.Context, shopID string) ([]Slot, error) {
+	s.metrics.incMethodCalls("GetByShopID")
+
+	var slots []Slot
+	q := "SELECT * FROM delivery_slots WHERE shop_id=$1 ORDER BY weekday, start_time"
+	if err := s.db.SelectContext(ctx, &slots, q, shopID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the delivery slots")
+	}
+
+	return slots, nil
+}
+
+// Release frees the slots booked by an order.
+func (s *service) Release(ctx context.Context, tx *sqlx.Tx, orderID string) error {
+	s.metrics.incMethodCalls("Release")
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM slot_bookings WHERE order_id=$1", orderID); err != nil {
+		return errors.Wrap(err, "couldn't release the delivery slots")
+	}
+
+	return nil
+}
+
+// Update updates a delivery slot of a shop.
+//
+// The orders already booked are kept even if the capacity is reduced below their number.
+func (s *service) Update(ctx context.Context, shopID, id string, slot Slot) error {
+	s.metrics.incMethodCalls("Update")
+
+	q := `UPDATE delivery_slots SET
+	type=$3, weekday=$4, start_time=$5, end_time=$6, capacity=$7, countries=$8
+	WHERE id=$1 AND shop_id=$2`
+	res, err := s.db.ExecContext(ctx, q, id, shopID, slot.Type, slot.Weekday,
+		slot.Start, slot.End, slot.Capacity, slot.Countries)
+	if err != nil {
+		return errors.Wrap(err, "couldn't update the delivery slot")
+	}
+
+	return checkAffected(res)
+}
+
+// book reserves a slot for the order and returns when it starts and the shop it belongs to.
+func book(ctx context.Context, tx *sqlx.Tx, orderID, country string, b Booking) (time.Time, string, error) {
+	var slot Slot
+	// Lock the slot so concurrent orders can't exceed its capacity
+	if err := tx.GetContext(ctx, &slot, "SELECT * FROM delivery_slots WHERE id=$1 FOR UPDATE", b.SlotID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, "", ErrSlotNotFound
+		}
+		return time.Time{}, "", errors.Wrap(err, "couldn't find the delivery slot")
+	}
+
+	date, err := time.ParseInLocation(dateLayout, strings.TrimSpace(b.Date), time.Local)
+	if err != nil {
+		return time.Time{}, "", errors.Wrap(ErrSlotUnavailable, "invalid date")
+	}
+	if time.Weekday(slot.Weekday) != date.Weekday() || !slot.covers(country) {
+		return time.Time{}, "", ErrSlotUnavailable
+	}
+	start, _, err := slot.window(date)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	if !start.After(time.Now()) {
+		return time.Time{}, "", ErrSlotUnavailable
+	}
+
+	var booked int64
+	q := "SELECT COUNT(*) FROM slot_bookings WHERE slot_id=$1 AND date=$2"
+	if err := tx.GetContext(ctx, &booked, q, slot.ID, date.Format(dateLayout)); err != nil {
+		return time.Time{}, "", errors.Wrap(err, "couldn't count the slot bookings")
+	}
+	if booked >= slot.Capacity.Int64 {
+		return time.Time{}, "", ErrSlotFull
+	}
+
+	q = "INSERT INTO slot_bookings (order_id, shop_id, slot_id, date, created_at) VALUES ($1, $2, $3, $4, $5)"
+	if _, err := tx.ExecContext(ctx, q, orderID, slot.ShopID, slot.ID, date.Format(dateLayout), time.Now()); err != nil {
+		return time.Time{}, "", errors.Wrap(err, "couldn't book the delivery slot")
+	}
+
+	return start, slot.ShopID.String, nil
+}
+
+// getShops returns the shops selling the products.
+func getShops(ctx context.Context, db sqlx.QueryerContext, productIDs []string) ([]string, error) {
+	var shopIDs []string
+	q := "SELECT DISTINCT shop_id FROM products WHERE id=ANY($1) AND shop_id IS NOT NULL"
+	if err := sqlx.SelectContext(ctx, db, &shopIDs, q, pq.Array(productIDs)); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the products shops")
+	}
+	return shopIDs, nil
+}
+
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "couldn't check the rows affected")
+	}
+	if n == 0 {
+		return ErrSlotNotFound
+	}
+	return nil
+}
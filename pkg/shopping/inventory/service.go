@@ -0,0 +1,199 @@
+// Package inventory keeps track of the products' stock reserved by the carts.
+package inventory
+
+import (
+	"context"
+	"time"
+
+	"github.com/GGP1/adak/internal/config"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const defaultHoldTTL = 15 * time.Minute
+
+// ErrOutOfStock is returned when there aren't enough units of a product to satisfy a request.
+var ErrOutOfStock = errors.New("not enough stock")
+
+// Item represents a quantity of a product to be reserved or committed.
+//
+// VariantID is empty for products without variants.
+type Item struct {
+	ProductID string
+	VariantID string
+	Quantity  int64
+}
+
+// Service contains inventory functionalities.
+//
+// All the methods take the transaction that is modifying the cart or the order
+// so the reservations are consistent with them.
+type Service interface {
+	Available(ctx context.Context, tx *sqlx.Tx, cartID string, item Item) (int64, error)
+	Commit(ctx context.Context, tx *sqlx.Tx, cartID string, items []Item) error
+	Hold(ctx context.Context, tx *sqlx.Tx, cartID string, item Item) error
+	Release(ctx context.Context, tx *sqlx.Tx, cartID string, item Item) error
+	ReleaseAll(ctx context.Context, tx *sqlx.Tx, cartID string) error
+	Restock(ctx context.Context, tx *sqlx.Tx, items []Item) error
+}
+
+type service struct {
+	holdTTL time.Duration
+	metrics metrics
+}
+
+// NewService returns a new inventory service.
+func NewService(config config.Inventory) Service {
+	holdTTL := config.HoldTTL
+	if holdTTL <= 0 {
+		holdTTL = defaultHoldTTL
+	}
+	return &service{holdTTL, initMetrics()}
+}
+
+// Available returns the units of the item that the cart can take, those are the ones
+// in stock that are not held by other carts.
+func (s *service) Available(ctx context.Context, tx *sqlx.Tx, cartID string, item Item) (int64, error) {
+	s.metrics.incMethodCalls("Available")
+	return available(ctx, tx, cartID, item)
+}
+
+// Commit decrements the stock of the items ordered and removes the cart holds.
+//
+// Holds that have expired are not an issue as long as there are enough units available.
+func (s *service) Commit(ctx context.Context, tx *sqlx.Tx, cartID string, items []Item) error {
+	s.metrics.incMethodCalls("Commit")
+
+	for _, item := range items {
+		if err := s.checkAvailability(ctx, tx, cartID, item); err != nil {
+			return err
+		}
+
+		q := "UPDATE products SET stock=stock-$2 WHERE id=$1"
+		args := []interface{}{item.ProductID, item.Quantity}
+		if item.VariantID != "" {
+			q = "UPDATE product_variants SET stock=stock-$2 WHERE id=$1"
+			args[0] = item.VariantID
+		}
+		if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+			return errors.Wrap(err, "decrementing stock")
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM stock_holds WHERE cart_id=$1", cartID); err != nil {
+		return errors.Wrap(err, "deleting holds")
+	}
+
+	return nil
+}
+
+// Hold reserves the quantity of the item specified for the cart, replacing any previous hold.
+func (s *service) Hold(ctx context.Context, tx *sqlx.Tx, cartID string, item Item) error {
+	s.metrics.incMethodCalls("Hold")
+
+	if err := s.checkAvailability(ctx, tx, cartID, item); err != nil {
+		return err
+	}
+
+	q := `INSERT INTO stock_holds
+	(product_id, variant_id, cart_id, quantity, expires_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (product_id, variant_id, cart_id) DO UPDATE SET
+	quantity=EXCLUDED.quantity, expires_at=EXCLUDED.expires_at`
+	_, err := tx.ExecContext(ctx, q, item.ProductID, item.VariantID, cartID,
+		item.Quantity, time.Now().Add(s.holdTTL))
+	if err != nil {
+		return errors.Wrap(err, "couldn't hold the product")
+	}
+
+	return nil
+}
+
+// Release takes away the quantity of the item specified from the cart hold.
+func (s *service) Release(ctx context.Context, tx *sqlx.Tx, cartID string, item Item) error {
+	s.metrics.incMethodCalls("Release")
+
+	q := "UPDATE stock_holds SET quantity=quantity-$4 WHERE product_id=$1 AND variant_id=$2 AND cart_id=$3"
+	if _, err := tx.ExecContext(ctx, q, item.ProductID, item.VariantID, cartID, item.Quantity); err != nil {
+		return errors.Wrap(err, "updating hold")
+	}
+
+	del := "DELETE FROM stock_holds WHERE product_id=$1 AND variant_id=$2 AND cart_id=$3 AND quantity <= 0"
+	if _, err := tx.ExecContext(ctx, del, item.ProductID, item.VariantID, cartID); err != nil {
+		return errors.Wrap(err, "deleting hold")
+	}
+
+	return nil
+}
+
+// ReleaseAll removes all the holds of a cart.
+func (s *service) ReleaseAll(ctx context.Context, tx *sqlx.Tx, cartID string) error {
+	s.metrics.incMethodCalls("ReleaseAll")
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM stock_holds WHERE cart_id=$1", cartID); err != nil {
+		return errors.Wrap(err, "deleting holds")
+	}
+
+	return nil
+}
+
+// Restock gives back the units of the items to the stock, used when orders are cancelled or refunded.
+//
+// Products and variants that no longer exist are skipped.
+func (s *service) Restock(ctx context.Context, tx *sqlx.Tx, items []Item) error {
+	s.metrics.incMethodCalls("Restock")
+
+	for _, item := range items {
+		q := "UPDATE products SET stock=stock+$2 WHERE id=$1"
+		args := []interface{}{item.ProductID, item.Quantity}
+		if item.VariantID != "" {
+			q = "UPDATE product_variants SET stock=stock+$2 WHERE id=$1"
+			args[0] = item.VariantID
+		}
+		if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+			return errors.Wrap(err, "incrementing stock")
+		}
+	}
+
+	return nil
+}
+
+// checkAvailability verifies that the units available cover the quantity requested.
+func (s *service) checkAvailability(ctx context.Context, tx *sqlx.Tx, cartID string, item Item) error {
+	units, err := available(ctx, tx, cartID, item)
+	if err != nil {
+		return err
+	}
+
+	if units < item.Quantity {
+		s.metrics.outOfStock.Inc()
+		return errors.Wrapf(ErrOutOfStock, "product %q has %d units available", item.ProductID, units)
+	}
+
+	return nil
+}
+
+// available locks the product (or variant) row and returns the stock minus the units
+// held by other carts.
+func available(ctx context.Context, tx *sqlx.Tx, cartID string, item Item) (int64, error) {
+	var stock int64
+	q := "SELECT stock FROM products WHERE id=$1 FOR UPDATE"
+	args := []interface{}{item.ProductID}
+	if item.VariantID != "" {
+		q = "SELECT stock FROM product_variants WHERE id=$1 AND product_id=$2 FOR UPDATE"
+		args = []interface{}{item.VariantID, item.ProductID}
+	}
+	if err := tx.GetContext(ctx, &stock, q, args...); err != nil {
+		return 0, errors.Wrap(err, "couldn't find product")
+	}
+
+	var held int64
+	heldQ := `SELECT COALESCE(SUM(quantity), 0) FROM stock_holds
+	WHERE product_id=$1 AND variant_id=$2 AND cart_id<>$3 AND expires_at > NOW()`
+	if err := tx.GetContext(ctx, &held, heldQ, item.ProductID, item.VariantID, cartID); err != nil {
+		return 0, errors.Wrap(err, "couldn't get held stock")
+	}
+
+	return stock - held, nil
+}
@@ -0,0 +1,152 @@
+package inventory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/internal/logger"
+	"github.com/GGP1/adak/internal/test"
+	"github.com/GGP1/adak/pkg/product"
+	"github.com/GGP1/adak/pkg/shop"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+const (
+	productID = "stock"
+	cartA     = "cart_a"
+	cartB     = "cart_b"
+)
+
+func NewInventoryService(t *testing.T) (context.Context, *sqlx.DB, inventory.Service) {
+	t.Helper()
+	logger.Disable()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	db := test.StartPostgres(t)
+	mc := test.StartMemcached(t)
+	service := inventory.NewService(config.Inventory{})
+	createRelationship(ctx, t, db, mc, service)
+
+	t.Cleanup(func() {
+		cancel()
+	})
+
+	return ctx, db, service
+}
+
+func TestInventoryService(t *testing.T) {
+	ctx, db, s := NewInventoryService(t)
+
+	t.Run("Hold", hold(ctx, db, s))
+	t.Run("Release", release(ctx, db, s))
+	t.Run("Commit", commit(ctx, db, s))
+	t.Run("Sweep", sweep(ctx, db, s))
+}
+
+func hold(ctx context.Context, db *sqlx.DB, s inventory.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		inTx(t, db, func(tx *sqlx.Tx) error {
+			return s.Hold(ctx, tx, cartA, inventory.Item{ProductID: productID, Quantity: 2})
+		})
+
+		tx, err := db.Beginx()
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		err = s.Hold(ctx, tx, cartB, inventory.Item{ProductID: productID, Quantity: 2})
+		assert.True(t, errors.Is(err, inventory.ErrOutOfStock))
+	}
+}
+
+func release(ctx context.Context, db *sqlx.DB, s inventory.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		inTx(t, db, func(tx *sqlx.Tx) error {
+			return s.Release(ctx, tx, cartA, inventory.Item{ProductID: productID, Quantity: 1})
+		})
+
+		inTx(t, db, func(tx *sqlx.Tx) error {
+			return s.Hold(ctx, tx, cartB, inventory.Item{ProductID: productID, Quantity: 1})
+		})
+	}
+}
+
+func commit(ctx context.Context, db *sqlx.DB, s inventory.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		items := []inventory.Item{{ProductID: productID, Quantity: 1}}
+		inTx(t, db, func(tx *sqlx.Tx) error {
+			return s.Commit(ctx, tx, cartA, items)
+		})
+
+		var stock int64
+		err := db.GetContext(ctx, &stock, "SELECT stock FROM products WHERE id=$1", productID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), stock)
+
+		// One of the two units left is held by cart B
+		tx, err := db.Beginx()
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		items[0].Quantity = 2
+		err = s.Commit(ctx, tx, cartA, items)
+		assert.True(t, errors.Is(err, inventory.ErrOutOfStock))
+	}
+}
+
+func sweep(ctx context.Context, db *sqlx.DB, s inventory.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		_, err := db.ExecContext(ctx, "UPDATE stock_holds SET expires_at=NOW() - interval '1 minute'")
+		assert.NoError(t, err)
+
+		sweeper := inventory.NewSweeper(db, config.Inventory{})
+		n, err := sweeper.Sweep(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	}
+}
+
+func inTx(t *testing.T, db *sqlx.DB, f func(tx *sqlx.Tx) error) {
+	t.Helper()
+
+	tx, err := db.Beginx()
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	assert.NoError(t, f(tx))
+	assert.NoError(t, tx.Commit())
+}
+
+func createRelationship(ctx context.Context, t *testing.T, db *sqlx.DB, mc *memcache.Client, s inventory.Service) {
+	t.Helper()
+
+	shopService := shop.NewService(db, mc)
+	err := shopService.Create(ctx, shop.Shop{ID: "shop", Name: "test"})
+	assert.NoError(t, err)
+
+	productService := product.NewService(db, mc)
+	err = productService.Create(ctx, product.Product{
+		ID:       zero.StringFrom(productID),
+		ShopID:   zero.StringFrom("shop"),
+		Stock:    zero.IntFrom(3),
+		Brand:    zero.StringFrom("brand"),
+		Category: zero.StringFrom("category"),
+		Type:     zero.StringFrom("type"),
+		Weight:   zero.IntFrom(1),
+		Subtotal: zero.IntFrom(1),
+		Total:    zero.IntFrom(1),
+	})
+	assert.NoError(t, err)
+
+	cartService := cart.NewService(db, mc, config.Cart{}, s, promotion.NewService(db))
+	assert.NoError(t, cartService.Create(ctx, cartA))
+	assert.NoError(t, cartService.Create(ctx, cartB))
+}
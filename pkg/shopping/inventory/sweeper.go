@@ -0,0 +1,61 @@
+package inventory
+
+import (
+	"context"
+	"time"
+
+	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/internal/logger"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const defaultSweepInterval = time.Minute
+
+// Sweeper releases the holds that have expired.
+type Sweeper struct {
+	db       *sqlx.DB
+	interval time.Duration
+}
+
+// NewSweeper returns a new holds sweeper.
+func NewSweeper(db *sqlx.DB, config config.Inventory) *Sweeper {
+	interval := config.SweepInterval
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+	return &Sweeper{db: db, interval: interval}
+}
+
+// Run sweeps the expired holds periodically until the context is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.Sweep(ctx)
+			if err != nil {
+				logger.Error(err)
+				continue
+			}
+			if n > 0 {
+				logger.Debugf("Released %d expired stock holds", n)
+			}
+		}
+	}
+}
+
+// Sweep deletes the expired holds and returns how many were removed.
+func (s *Sweeper) Sweep(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM stock_holds WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting expired holds")
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,118 @@
+package invoice
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/GGP1/adak/pkg/shopping/ordering"
+)
+
+// buildDocuments returns the documents of the invoices of an order.
+//
+// The shipping cost and promotions discount apply to the whole order, they are split
+// among the sellers in proportion to the price of their products.
+func buildDocuments(order ordering.Order, invoices []Invoice, sellers map[string]Seller) []Document {
+	shops := make(map[string]string, len(order.Products))
+	for _, inv := range invoices {
+		for _, id := range inv.ProductIDs {
+			shops[id] = inv.ShopID.String
+		}
+	}
+	products := make(map[string][]ordering.OrderProduct, len(invoices))
+	for _, p := range order.Products {
+		shopID := shops[p.ProductID.String]
+		products[shopID] = append(products[shopID], p)
+	}
+
+	var productsTotal, exclusive int64
+	weights := make([]int64, len(invoices))
+	for i, inv := range invoices {
+		for _, p := range products[inv.ShopID.String] {
+			weights[i] += p.Total.Int64 * p.Quantity.Int64
+			exclusive += p.TaxLines.Exclusive()
+		}
+		productsTotal += weights[i]
+	}
+
+	// The cart total already has the promotions discount subtracted
+	discount := productsTotal + exclusive + order.ShippingCost.Int64 - order.Cart.Total.Int64
+	if discount < 0 {
+		discount = 0
+	}
+	shipping := ordering.Allocate(order.ShippingCost.Int64, weights)
+	discounts := ordering.Allocate(discount, weights)
+
+	docs := make([]Document, len(invoices))
+	for i, inv := range invoices {
+		lines := products[inv.ShopID.String]
+		taxes := summarizeTaxes(lines)
+		var exclusive int64
+		for _, t := range taxes {
+			if !t.Inclusive {
+				exclusive += t.Amount
+			}
+		}
+
+		docs[i] = Document{
+			Invoice:  inv,
+			Seller:   sellers[inv.ShopID.String],
+			Order:    order,
+			Products: lines,
+			Taxes:    taxes,
+			Subtotal: weights[i],
+			Shipping: shipping[i],
+			Discount: discounts[i],
+			Total:    weights[i] + exclusive + shipping[i] - discounts[i],
+			Currency: order.BaseCurrency.String,
+		}
+	}
+
+	return docs
+}
+
+// summarizeTaxes adds up the amounts charged by each tax on the products.
+func summarizeTaxes(products []ordering.OrderProduct) []TaxSummary {
+	var summaries []TaxSummary
+	for _, p := range products {
+	taxes:
+		for _, t := range p.TaxLines {
+			for i, s := range summaries {
+				if s.Name == t.Name && s.Rate == t.Rate && s.Inclusive == t.Inclusive {
+					summaries[i].Amount += t.Amount
+					continue taxes
+				}
+			}
+			summaries = append(summaries, TaxSummary{
+				Name:      t.Name,
+				Rate:      t.Rate,
+				Inclusive: t.Inclusive,
+				Amount:    t.Amount,
+			})
+		}
+	}
+	return summaries
+}
+
+// formatAmount formats an amount provided in the currency's smallest unit, 1050 = 10.50.
+func formatAmount(amount int64, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, amount/100, amount%100, currency)
+}
+
+// formatRate formats a rate in basis points as a percentage.
+func formatRate(rate int64) string {
+	return strconv.FormatFloat(float64(rate)/100, 'f', -1, 64) + "%"
+}
+
+// describe returns the name printed for a product.
+func describe(p ordering.OrderProduct) string {
+	name := p.Brand.String + " " + p.Type.String
+	if p.SKU.String != "" {
+		name += " (" + p.SKU.String + ")"
+	}
+	return name
+}
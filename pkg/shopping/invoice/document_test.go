@@ -0,0 +1,56 @@
+package invoice
+
+import (
+	"testing"
+
+	"github.com/GGP1/adak/pkg/shopping/ordering"
+	"github.com/GGP1/adak/pkg/shopping/tax"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestBuildDocuments(t *testing.T) {
+	vat := tax.Tax{Name: "VAT", Rate: 2100, Amount: 420}
+	order := ordering.Order{
+		BaseCurrency: zero.StringFrom("USD"),
+		ShippingCost: zero.IntFrom(500),
+		Products: []ordering.OrderProduct{
+			{ProductID: zero.StringFrom("a"), Quantity: zero.IntFrom(2), Total: zero.IntFrom(1000), TaxLines: tax.Breakdown{vat}},
+			{ProductID: zero.StringFrom("b"), Quantity: zero.IntFrom(1), Total: zero.IntFrom(1000), TaxLines: tax.Breakdown{vat}},
+			{ProductID: zero.StringFrom("c"), Quantity: zero.IntFrom(1), Total: zero.IntFrom(1000)},
+		},
+	}
+	// 4000 + 840 of taxes + 500 of shipping - 300 of promotions
+	order.Cart.Total = zero.IntFrom(5040)
+
+	invoices := []Invoice{
+		{ShopID: zero.StringFrom("shop1"), ProductIDs: pq.StringArray{"a", "b"}},
+		{ShopID: zero.StringFrom("shop2"), ProductIDs: pq.StringArray{"c"}},
+	}
+	docs := buildDocuments(order, invoices, map[string]Seller{"shop1": {Name: "One"}, "shop2": {Name: "Two"}})
+
+	assert.Equal(t, 2, len(docs))
+	assert.Equal(t, "One", docs[0].Seller.Name)
+	assert.Equal(t, 2, len(docs[0].Products))
+	assert.Equal(t, []TaxSummary{{Name: "VAT", Rate: 2100, Amount: 840}}, docs[0].Taxes)
+	assert.Equal(t, int64(3000), docs[0].Subtotal)
+	assert.Equal(t, int64(375), docs[0].Shipping)
+	assert.Equal(t, int64(225), docs[0].Discount)
+	assert.Equal(t, int64(3990), docs[0].Total)
+
+	assert.Equal(t, int64(1000), docs[1].Subtotal)
+	assert.Equal(t, int64(125), docs[1].Shipping)
+	assert.Equal(t, int64(75), docs[1].Discount)
+	assert.Equal(t, int64(1050), docs[1].Total)
+
+	// The invoices add up to what the user paid
+	assert.Equal(t, order.Cart.Total.Int64, docs[0].Total+docs[1].Total)
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "12.05 USD", formatAmount(1205, "USD"))
+	assert.Equal(t, "-0.50 EUR", formatAmount(-50, "EUR"))
+	assert.Equal(t, "10.5%", formatRate(1050))
+}
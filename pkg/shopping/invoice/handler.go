@@ -0,0 +1,140 @@
+package invoice
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/http"
+
+	"github.com/GGP1/adak/internal/cookie"
+	"github.com/GGP1/adak/internal/logger"
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/pkg/shopping/ordering"
+
+	"github.com/pkg/errors"
+)
+
+var errNotAllowed = errors.New("not found")
+
+// Admins tells whether a user is an administrator, it's satisfied by the user service.
+type Admins interface {
+	IsAdmin(ctx context.Context, id string) (bool, error)
+}
+
+// Handler handles invoice endpoints.
+type Handler struct {
+	service         Service
+	orderingService ordering.Service
+	admins          Admins
+	template        *template.Template
+}
+
+// NewHandler returns a new invoice handler.
+func NewHandler(service Service, orderingS ordering.Service, admins Admins) Handler {
+	tmpl, err := loadTemplate()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	return Handler{
+		service:         service,
+		orderingService: orderingS,
+		admins:          admins,
+		template:        tmpl,
+	}
+}
+
+// Get returns the invoices of an order in PDF or, if the format requested is "html", in HTML.
+//
+// Only the user that placed the order and administrators are allowed.
+func (h *Handler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		orderID, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "pdf" && format != "html" {
+			response.Error(w, http.StatusBadRequest, errors.Errorf("invalid format %q", format))
+			return
+		}
+
+		order, err := h.orderingService.GetByID(ctx, orderID)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if order.ID.String == "" {
+			writeError(w, ErrNotFound)
+			return
+		}
+
+		if err := h.authorize(ctx, r, order.UserID.String); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		docs, err := h.service.Issue(ctx, order)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		buf := &bytes.Buffer{}
+		if format == "html" {
+			if err := WriteHTML(buf, h.template, docs); err != nil {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		} else {
+			if err := WritePDF(buf, docs); err != nil {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Header().Set("Content-Disposition", `inline; filename="invoice-`+orderID+`.pdf"`)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		buf.WriteTo(w)
+	}
+}
+
+// authorize returns an error if the user isn't the owner of the order nor an administrator.
+func (h *Handler) authorize(ctx context.Context, r *http.Request, ownerID string) error {
+	userID, err := cookie.GetValue(r, "UID")
+	if err != nil {
+		return errNotAllowed
+	}
+	if userID == ownerID {
+		return nil
+	}
+
+	isAdmin, err := h.admins.IsAdmin(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return errNotAllowed
+	}
+
+	return nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, errNotAllowed):
+		// Return 404 instead of 401 to not give additional information
+		response.Error(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrNotInvoiceable):
+		response.Error(w, http.StatusConflict, err)
+	default:
+		response.Error(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,65 @@
+package invoice
+
+import (
+	"fmt"
+
+	"github.com/GGP1/adak/pkg/shop"
+	"github.com/GGP1/adak/pkg/shopping/ordering"
+
+	"github.com/lib/pq"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Invoice is the record of the products of an order sold by a shop.
+//
+// Each shop numbers its invoices sequentially starting from one, without gaps.
+type Invoice struct {
+	ID      zero.String `json:"id,omitempty"`
+	OrderID zero.String `json:"order_id,omitempty" db:"order_id"`
+	// ShopID is empty for the products whose shop no longer exists, they are invoiced by the platform
+	ShopID zero.String `json:"shop_id,omitempty" db:"shop_id"`
+	Number zero.Int    `json:"number,omitempty"`
+	// ProductIDs are the products of the order sold by the shop, they are saved so the
+	// invoice doesn't change if the products are deleted
+	ProductIDs pq.StringArray `json:"product_ids,omitempty" db:"product_ids"`
+	IssuedAt   zero.Time      `json:"issued_at,omitempty" db:"issued_at"`
+}
+
+// Code returns the number of the invoice padded with zeros.
+func (i Invoice) Code() string {
+	return fmt.Sprintf("%06d", i.Number.Int64)
+}
+
+// Seller is the shop that issues the invoice.
+type Seller struct {
+	Name     string
+	Location shop.Location
+}
+
+// Document contains everything printed on an invoice.
+//
+// Amounts are in the base currency and provided in its smallest unit.
+type Document struct {
+	Invoice  Invoice
+	Seller   Seller
+	Order    ordering.Order
+	Products []ordering.OrderProduct
+	Taxes    []TaxSummary
+	// Subtotal is the sum of the products price, inclusive taxes are part of it
+	Subtotal int64
+	// Shipping and Discount are the shares of the order shipping cost and promotions
+	// discount that correspond to the seller
+	Shipping int64
+	Discount int64
+	Total    int64
+	Currency string
+}
+
+// TaxSummary is the sum of the amounts charged by a tax on the products of an invoice.
+type TaxSummary struct {
+	Name string
+	// Rate in basis points, 2100 = 21%
+	Rate      int64
+	Inclusive bool
+	Amount    int64
+}
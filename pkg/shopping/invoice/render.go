@@ -0,0 +1,153 @@
+package invoice
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/GGP1/adak/internal/pdf"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	margin     = 50
+	lineHeight = 16
+	// rowsBottom is the lowest position a product row can take before continuing on a new page
+	rowsBottom = pdf.PageHeight - 140
+)
+
+var funcs = template.FuncMap{
+	"amount":   formatAmount,
+	"rate":     formatRate,
+	"describe": describe,
+	"multiply": func(a, b int64) int64 { return a * b },
+}
+
+// loadTemplate parses the template used to render the invoices in HTML, it returns nil
+// if the static files are not available.
+func loadTemplate() (*template.Template, error) {
+	staticFS := viper.Get("static.fs")
+	if staticFS == nil {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("invoice.html").Funcs(funcs).
+		ParseFS(staticFS.(embed.FS), "static/templates/invoice.html")
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't parse the invoice template")
+	}
+	return tmpl, nil
+}
+
+// WriteHTML renders the invoices in HTML using the template provided.
+func WriteHTML(w io.Writer, tmpl *template.Template, docs []Document) error {
+	if tmpl == nil {
+		return errors.New("the invoice template is not available")
+	}
+	return tmpl.Execute(w, docs)
+}
+
+// WritePDF renders the invoices in a PDF document, each of them starts on a new page.
+func WritePDF(w io.Writer, docs []Document) error {
+	d := pdf.New()
+	for _, doc := range docs {
+		writeInvoice(d, doc)
+	}
+
+	_, err := d.WriteTo(w)
+	return err
+}
+
+func writeInvoice(d *pdf.Document, doc Document) {
+	const right = pdf.PageWidth - margin
+	amount := func(a int64) string {
+		return formatAmount(a, doc.Currency)
+	}
+
+	d.AddPage()
+	d.Text(margin, 70, 20, true, "INVOICE")
+	d.TextRight(right, 62, 12, true, "No. "+doc.Invoice.Code())
+	d.TextRight(right, 78, 9, false, "Issued: "+doc.Invoice.IssuedAt.Time.Format("2006-01-02"))
+	d.TextRight(right, 90, 9, false, "Order: "+doc.Order.ID.String)
+
+	// Seller and buyer addresses
+	l := doc.Seller.Location
+	writeAddress(d, margin, 130, "Seller", doc.Seller.Name, l.Address, l.City, l.State, l.ZipCode, l.Country)
+	o := doc.Order
+	writeAddress(d, pdf.PageWidth/2, 130, "Bill to", "", o.Address.String, o.City.String,
+		o.State.String, o.ZipCode.String, o.Country.String)
+
+	y := writeRowsHeader(d, 230)
+	for _, p := range doc.Products {
+		if y > rowsBottom {
+			d.AddPage()
+			d.Text(margin, 70, 12, true, "INVOICE No. "+doc.Invoice.Code()+" (continued)")
+			y = writeRowsHeader(d, 110)
+		}
+		d.Text(margin, y, 9, false, describe(p))
+		d.TextRight(360, y, 9, false, strconv.FormatInt(p.Quantity.Int64, 10))
+		d.TextRight(450, y, 9, false, amount(p.Total.Int64))
+		d.TextRight(right, y, 9, false, amount(p.Total.Int64*p.Quantity.Int64))
+		y += lineHeight
+	}
+	d.Line(margin, y-lineHeight/2, right, y-lineHeight/2)
+	y += lineHeight / 2
+
+	total := func(bold bool, label, value string) {
+		if y > pdf.PageHeight-margin {
+			d.AddPage()
+			y = 70
+		}
+		d.Text(330, y, 9, bold, label)
+		d.TextRight(right, y, 9, bold, value)
+		y += lineHeight
+	}
+	total(false, "Subtotal", amount(doc.Subtotal))
+	for _, t := range doc.Taxes {
+		label := t.Name + " " + formatRate(t.Rate)
+		if t.Inclusive {
+			label += " (included)"
+		}
+		total(false, label, amount(t.Amount))
+	}
+	if doc.Shipping > 0 {
+		total(false, "Shipping", amount(doc.Shipping))
+	}
+	if doc.Discount > 0 {
+		total(false, "Discount", amount(-doc.Discount))
+	}
+	total(true, "Total", amount(doc.Total))
+
+	if !strings.EqualFold(o.Currency.String, doc.Currency) {
+		y += lineHeight
+		d.Text(margin, y, 8, false, "The order was charged in "+o.Currency.String+
+			" at the exchange rate "+o.ExchangeRate.String+".")
+	}
+}
+
+// writeAddress writes a block with the title followed by the non-empty lines provided.
+func writeAddress(d *pdf.Document, x, y float64, title string, lines ...string) {
+	d.Text(x, y, 9, true, title)
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		y += 13
+		d.Text(x, y, 9, false, line)
+	}
+}
+
+// writeRowsHeader writes the header of the products table and returns the position of the first row.
+func writeRowsHeader(d *pdf.Document, y float64) float64 {
+	const right = pdf.PageWidth - margin
+	d.Text(margin, y, 9, true, "Description")
+	d.TextRight(360, y, 9, true, "Qty")
+	d.TextRight(450, y, 9, true, "Unit price")
+	d.TextRight(right, y, 9, true, "Amount")
+	d.Line(margin, y+6, right, y+6)
+	return y + 6 + lineHeight
+}
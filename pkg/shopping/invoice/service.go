@@ -0,0 +1,242 @@
+package invoice
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/GGP1/adak/internal/email"
+	"github.com/GGP1/adak/pkg/shopping/ordering"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+var (
+	// ErrNotFound is returned when the order doesn't exist.
+	ErrNotFound = errors.New("order not found")
+	// ErrNotInvoiceable is returned when the order wasn't paid.
+	ErrNotInvoiceable = errors.New("the order can't be invoiced")
+)
+
+// Service provides invoice operations.
+type Service interface {
+	Confirm(ctx context.Context, order ordering.Order) error
+	Issue(ctx context.Context, order ordering.Order) ([]Document, error)
+}
+
+type service struct {
+	db      *sqlx.DB
+	emailer email.Emailer
+	metrics metrics
+}
+
+// NewService returns a new invoice service.
+func NewService(db *sqlx.DB, emailer email.Emailer) Service {
+	return &service{db, emailer, initMetrics()}
+}
+
+// Confirm sends the user an email confirming the purchase with the invoices attached.
+func (s *service) Confirm(ctx context.Context, order ordering.Order) error {
+	s.metrics.incMethodCalls("Confirm")
+
+	docs, err := s.Issue(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WritePDF(buf, docs); err != nil {
+		return err
+	}
+
+	var user struct {
+		Username string `db:"username"`
+		Email    string `db:"email"`
+	}
+	q := "SELECT username, email FROM users WHERE id=$1"
+	if err := s.db.GetContext(ctx, &user, q, order.UserID); err != nil {
+		return errors.Wrap(err, "couldn't find the user to confirm the order")
+	}
+
+	return s.emailer.SendOrderConfirmation(user.Username, user.Email, order.ID.String, buf.Bytes())
+}
+
+// Issue returns the invoices of an order, one per seller.
+//
+// The invoices are numbered the first time they are requested, the numbers are taken
+// inside the transaction that saves them so they never have gaps.
+func (s *service) Issue(ctx context.Context, order ordering.Order) ([]Document, error) {
+	s.metrics.incMethodCalls("Issue")
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	// Lock the order so concurrent requests don't issue its invoices twice
+	var status int64
+	q := "SELECT COALESCE(status, 0) FROM orders WHERE id=$1 FOR UPDATE"
+	if err := tx.GetContext(ctx, &status, q, order.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Wrap(err, "couldn't find the order")
+	}
+
+	var invoices []Invoice
+	q = "SELECT * FROM invoices WHERE order_id=$1 ORDER BY shop_id"
+	if err := tx.SelectContext(ctx, &invoices, q, order.ID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the invoices")
+	}
+
+	if len(invoices) == 0 {
+		switch status {
+		case int64(ordering.Pending), int64(ordering.Failed), int64(ordering.Cancelled):
+			return nil, ErrNotInvoiceable
+		}
+
+		invoices, err = s.create(ctx, tx, order.ID.String, order.Products)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sellers, err := getSellers(ctx, tx, invoices)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "committing transaction")
+	}
+
+	return buildDocuments(order, invoices, sellers), nil
+}
+
+// create numbers and saves an invoice for each of the shops of the products.
+func (s *service) create(ctx context.Context, tx *sqlx.Tx, orderID string, products []ordering.OrderProduct) ([]Invoice, error) {
+	shops, err := getShops(ctx, tx, products)
+	if err != nil {
+		return nil, err
+	}
+
+	productIDs := make(map[string][]string, len(shops))
+	shopIDs := make([]string, 0, len(shops))
+	for _, p := range products {
+		shopID := shops[p.ProductID.String]
+		if _, ok := productIDs[shopID]; !ok {
+			shopIDs = append(shopIDs, shopID)
+		}
+		productIDs[shopID] = append(productIDs[shopID], p.ProductID.String)
+	}
+	sort.Strings(shopIDs)
+
+	sequenceQ := `INSERT INTO invoice_sequences (shop_id, last_number) VALUES ($1, 1)
+	ON CONFLICT (shop_id) DO UPDATE SET last_number=invoice_sequences.last_number+1
+	RETURNING last_number`
+	invoiceQ := `INSERT INTO invoices (id, order_id, shop_id, number, product_ids, issued_at)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+	invoices := make([]Invoice, len(shopIDs))
+	for i, shopID := range shopIDs {
+		var number int64
+		if err := tx.GetContext(ctx, &number, sequenceQ, shopID); err != nil {
+			return nil, errors.Wrap(err, "couldn't number the invoice")
+		}
+
+		inv := Invoice{
+			ID:         zero.StringFrom(uuid.NewString()),
+			OrderID:    zero.StringFrom(orderID),
+			ShopID:     zero.StringFrom(shopID),
+			Number:     zero.IntFrom(number),
+			ProductIDs: productIDs[shopID],
+			IssuedAt:   zero.TimeFrom(time.Now()),
+		}
+		_, err := tx.ExecContext(ctx, invoiceQ, inv.ID, inv.OrderID, inv.ShopID, inv.Number,
+			inv.ProductIDs, inv.IssuedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't create the invoice")
+		}
+		invoices[i] = inv
+	}
+
+	s.metrics.issued.Add(float64(len(invoices)))
+	return invoices, nil
+}
+
+// getShops returns the id of the shop selling each product, the products of shops that
+// were deleted are mapped to an empty id.
+func getShops(ctx context.Context, tx *sqlx.Tx, products []ordering.OrderProduct) (map[string]string, error) {
+	productIDs := make([]string, len(products))
+	for i, p := range products {
+		productIDs[i] = p.ProductID.String
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, shop_id FROM products WHERE id=ANY($1)", pq.Array(productIDs))
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't find the shops of the products")
+	}
+	defer rows.Close()
+
+	shops := make(map[string]string, len(productIDs))
+	for _, id := range productIDs {
+		shops[id] = ""
+	}
+	for rows.Next() {
+		var productID, shopID string
+		if err := rows.Scan(&productID, &shopID); err != nil {
+			return nil, errors.Wrap(err, "couldn't scan the shop")
+		}
+		shops[productID] = shopID
+	}
+
+	return shops, rows.Err()
+}
+
+// getSellers returns the name and location of the shops issuing the invoices.
+func getSellers(ctx context.Context, tx *sqlx.Tx, invoices []Invoice) (map[string]Seller, error) {
+	shopIDs := make([]string, len(invoices))
+	for i, inv := range invoices {
+		shopIDs[i] = inv.ShopID.String
+	}
+
+	q := `SELECT s.id, s.name, COALESCE(l.country, ''), COALESCE(l.state, ''),
+	COALESCE(l.zip_code, ''), COALESCE(l.city, ''), COALESCE(l.address, '')
+	FROM shops AS s LEFT JOIN locations AS l ON l.shop_id=s.id
+	WHERE s.id=ANY($1)`
+	rows, err := tx.QueryContext(ctx, q, pq.Array(shopIDs))
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't find the sellers")
+	}
+	defer rows.Close()
+
+	sellers := make(map[string]Seller, len(shopIDs))
+	for rows.Next() {
+		var (
+			id     string
+			seller Seller
+		)
+		l := &seller.Location
+		if err := rows.Scan(&id, &seller.Name, &l.Country, &l.State, &l.ZipCode, &l.City, &l.Address); err != nil {
+			return nil, errors.Wrap(err, "couldn't scan the seller")
+		}
+		l.ShopID = id
+		sellers[id] = seller
+	}
+
+	// The products of shops that no longer exist are invoiced by the platform
+	for _, id := range shopIDs {
+		if _, ok := sellers[id]; !ok {
+			sellers[id] = Seller{Name: "Adak"}
+		}
+	}
+
+	return sellers, rows.Err()
+}
@@ -0,0 +1,160 @@
+package ordering
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Fields that can change between adding a product to the cart and ordering it, taxes
+// aren't included as they are calculated when ordering
+const (
+	DiscountChange = "discount"
+	SubtotalChange = "subtotal"
+	TotalChange    = "total"
+	// QuantityChange is reported when the units available don't cover the ones in the cart,
+	// Current is zero if the product no longer exists
+	QuantityChange = "quantity"
+)
+
+// Change is a difference between a cart product and its current state.
+type Change struct {
+	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
+	Field     string `json:"field"`
+	Previous  int64  `json:"previous"`
+	Current   int64  `json:"current"`
+}
+
+// ChangesError is returned when the cart products changed since they were added.
+//
+// Price changes are accepted by ordering again with the token in OrderParams.AcceptChanges,
+// the token is empty when there are quantity changes as the cart must be updated first.
+type ChangesError struct {
+	Token   string   `json:"token,omitempty"`
+	Changes []Change `json:"changes"`
+}
+
+func (e *ChangesError) Error() string {
+	return "the cart products changed since they were added"
+}
+
+// cartLine contains the values saved in a cart product and the current ones.
+type cartLine struct {
+	ProductID       string   `db:"id"`
+	VariantID       string   `db:"variant_id"`
+	Quantity        int64    `db:"quantity"`
+	Discount        int64    `db:"discount"`
+	Subtotal        int64    `db:"subtotal"`
+	Total           int64    `db:"total"`
+	CurrentDiscount zero.Int `db:"current_discount"`
+	CurrentSubtotal zero.Int `db:"current_subtotal"`
+	CurrentTotal    zero.Int `db:"current_total"`
+	Found           bool     `db:"found"`
+}
+
+// detectChanges compares the cart products with their current values and availability.
+//
+// It returns nil if nothing changed or the changes were accepted, in which case the cart
+// products are updated.
+func (s *service) detectChanges(ctx context.Context, tx *sqlx.Tx, cartID, acceptToken string) error {
+	q := `SELECT cp.id, cp.variant_id, cp.quantity, cp.discount, cp.subtotal, cp.total,
+	COALESCE(v.discount, p.discount) AS current_discount,
+	COALESCE(v.subtotal, p.subtotal) AS current_subtotal,
+	COALESCE(v.total, p.total) AS current_total,
+	(p.id IS NOT NULL AND (cp.variant_id='' OR v.id IS NOT NULL)) AS found
+	FROM cart_products AS cp
+	LEFT JOIN products AS p ON p.id=cp.id
+	LEFT JOIN product_variants AS v ON v.id=cp.variant_id AND v.product_id=cp.id
+	WHERE cp.cart_id=$1
+	ORDER BY cp.id, cp.variant_id`
+	var lines []cartLine
+	if err := tx.SelectContext(ctx, &lines, q, cartID); err != nil {
+		return errors.Wrap(err, "couldn't find the cart products")
+	}
+
+	var changes []Change
+	unavailable := false
+	for _, l := range lines {
+		if !l.Found {
+			changes = append(changes, l.change(QuantityChange, l.Quantity, 0))
+			unavailable = true
+			continue
+		}
+
+		item := inventory.Item{ProductID: l.ProductID, VariantID: l.VariantID, Quantity: l.Quantity}
+		units, err := s.inventory.Available(ctx, tx, cartID, item)
+		if err != nil {
+			return err
+		}
+		if units < l.Quantity {
+			if units < 0 {
+				units = 0
+			}
+			changes = append(changes, l.change(QuantityChange, l.Quantity, units))
+			unavailable = true
+		}
+
+		changes = append(changes, l.priceChanges()...)
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if unavailable {
+		return &ChangesError{Changes: changes}
+	}
+
+	token := changesToken(changes)
+	if acceptToken != token {
+		return &ChangesError{Token: token, Changes: changes}
+	}
+
+	return nil
+}
+
+func (l cartLine) change(field string, previous, current int64) Change {
+	return Change{
+		ProductID: l.ProductID,
+		VariantID: l.VariantID,
+		Field:     field,
+		Previous:  previous,
+		Current:   current,
+	}
+}
+
+func (l cartLine) priceChanges() []Change {
+	var changes []Change
+	fields := []struct {
+		name     string
+		previous int64
+		current  zero.Int
+	}{
+		{DiscountChange, l.Discount, l.CurrentDiscount},
+		{SubtotalChange, l.Subtotal, l.CurrentSubtotal},
+		{TotalChange, l.Total, l.CurrentTotal},
+	}
+	for _, f := range fields {
+		if f.previous != f.current.Int64 {
+			changes = append(changes, l.change(f.name, f.previous, f.current.Int64))
+		}
+	}
+	return changes
+}
+
+// changesToken returns a hash of the changes, it's used by the clients to accept them.
+func changesToken(changes []Change) string {
+	h := sha256.New()
+	for _, c := range changes {
+		fmt.Fprintf(h, "%s:%s:%s:%d:%d;", c.ProductID, c.VariantID, c.Field, c.Previous, c.Current)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
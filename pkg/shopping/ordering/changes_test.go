@@ -0,0 +1,41 @@
+package ordering
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestPriceChanges(t *testing.T) {
+	line := cartLine{
+		ProductID:       "product",
+		Quantity:        2,
+		Discount:        0,
+		Subtotal:        1000,
+		Total:           1000,
+		CurrentDiscount: zero.IntFrom(0),
+		CurrentSubtotal: zero.IntFrom(1200),
+		CurrentTotal:    zero.IntFrom(1200),
+		Found:           true,
+	}
+
+	expected := []Change{
+		{ProductID: "product", Field: SubtotalChange, Previous: 1000, Current: 1200},
+		{ProductID: "product", Field: TotalChange, Previous: 1000, Current: 1200},
+	}
+	assert.Equal(t, expected, line.priceChanges())
+
+	line.CurrentSubtotal = zero.IntFrom(1000)
+	line.CurrentTotal = zero.IntFrom(1000)
+	assert.Empty(t, line.priceChanges())
+}
+
+func TestChangesToken(t *testing.T) {
+	changes := []Change{{ProductID: "product", Field: TotalChange, Previous: 1000, Current: 1200}}
+	token := changesToken(changes)
+	assert.Equal(t, token, changesToken(changes))
+
+	changes[0].Current = 1300
+	assert.NotEqual(t, token, changesToken(changes))
+}
@@ -0,0 +1,160 @@
+package ordering
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GGP1/adak/pkg/postgres"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// ExportHeader contains the names of the columns of the export in CSV format.
+var ExportHeader = []string{
+	"order_id", "ordered_at", "status", "user_id", "currency", "base_currency", "exchange_rate",
+	"product_id", "variant_id", "sku", "brand", "category", "type",
+	"quantity", "refunded", "unit_total", "line_total", "taxes",
+}
+
+// ExportFilter selects the orders exported, zero values don't filter.
+type ExportFilter struct {
+	// From is inclusive and To exclusive
+	From     time.Time
+	To       time.Time
+	Statuses []status
+	ShopID   string
+}
+
+// ExportLine is a line of an order as exported for accounting.
+//
+// Amounts are in the base currency and provided in its smallest unit.
+type ExportLine struct {
+	OrderID      string    `json:"order_id"`
+	OrderedAt    time.Time `json:"ordered_at"`
+	Status       string    `json:"status"`
+	UserID       string    `json:"user_id"`
+	Currency     string    `json:"currency"`
+	BaseCurrency string    `json:"base_currency"`
+	ExchangeRate string    `json:"exchange_rate"`
+	ProductID    string    `json:"product_id"`
+	VariantID    string    `json:"variant_id"`
+	SKU          string    `json:"sku"`
+	Brand        string    `json:"brand"`
+	Category     string    `json:"category"`
+	Type         string    `json:"type"`
+	Quantity     int64     `json:"quantity"`
+	// Refunded is the number of units refunded
+	Refunded  int64 `json:"refunded"`
+	UnitTotal int64 `json:"unit_total"`
+	LineTotal int64 `json:"line_total"`
+	Taxes     int64 `json:"taxes"`
+}
+
+// Record returns the values of the line in the order of ExportHeader.
+func (l ExportLine) Record() []string {
+	return []string{
+		l.OrderID, l.OrderedAt.Format(time.RFC3339), l.Status, l.UserID, l.Currency, l.BaseCurrency,
+		l.ExchangeRate, l.ProductID, l.VariantID, l.SKU, l.Brand, l.Category, l.Type,
+		strconv.FormatInt(l.Quantity, 10), strconv.FormatInt(l.Refunded, 10),
+		strconv.FormatInt(l.UnitTotal, 10), strconv.FormatInt(l.LineTotal, 10),
+		strconv.FormatInt(l.Taxes, 10),
+	}
+}
+
+// parseStatuses returns the statuses with the names provided.
+func parseStatuses(names []string) ([]status, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	statuses := make([]status, len(names))
+	for i, name := range names {
+		s, err := parseStatus(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		statuses[i] = s
+	}
+	return statuses, nil
+}
+
+// Export calls fn with each line of the orders that match the filter, sorted by the order date.
+//
+// The lines are read from the database as they are consumed so the orders are never
+// loaded into memory all together, fn should not block for long.
+func (s *service) Export(ctx context.Context, filter ExportFilter, fn func(ExportLine) error) error {
+	s.metrics.incMethodCalls("Export")
+
+	q, args := exportQuery(filter)
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return errors.Wrap(err, "couldn't export the orders")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			l  ExportLine
+			st status
+		)
+		err := rows.Scan(&l.OrderID, &l.OrderedAt, &st, &l.UserID, &l.Currency, &l.BaseCurrency,
+			&l.ExchangeRate, &l.ProductID, &l.VariantID, &l.SKU, &l.Brand, &l.Category, &l.Type,
+			&l.Quantity, &l.Refunded, &l.UnitTotal, &l.Taxes)
+		if err != nil {
+			return errors.Wrap(err, "couldn't scan the order line")
+		}
+		l.Status = st.String()
+		l.LineTotal = l.UnitTotal * l.Quantity
+
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// exportQuery returns the query and arguments used to export the orders matching the filter.
+func exportQuery(filter ExportFilter) (string, []interface{}) {
+	// The date is set when the order is created, rows without it can't be placed in a range
+	var conds postgres.Conditions
+	conds.Add("o.ordered_at IS NOT NULL")
+
+	if !filter.From.IsZero() {
+		conds.Add("o.ordered_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		conds.Add("o.ordered_at < ?", filter.To)
+	}
+	if len(filter.Statuses) > 0 {
+		conds.Add("COALESCE(o.status, 0)=ANY(?)", statusArray(filter.Statuses))
+	}
+	if filter.ShopID != "" {
+		// Orders placed before splitting them by shop don't have the shop saved in their products
+		conds.Add("COALESCE(p.shop_id, pr.shop_id)=?", filter.ShopID)
+	}
+
+	q := `SELECT o.id, o.ordered_at, COALESCE(o.status, 0), COALESCE(o.user_id, ''),
+	COALESCE(o.currency, ''), COALESCE(o.base_currency, ''), COALESCE(o.exchange_rate::text, ''),
+	p.product_id, COALESCE(p.variant_id, ''), COALESCE(p.sku, ''),
+	COALESCE(p.brand, ''), COALESCE(p.category, ''), COALESCE(p.type, ''),
+	COALESCE(p.quantity, 0), p.refunded, COALESCE(p.total, 0), COALESCE(p.taxes, 0)
+	FROM orders AS o
+	JOIN order_products AS p ON p.order_id=o.id
+	LEFT JOIN products AS pr ON pr.id=p.product_id` + conds.Where() + `
+	ORDER BY o.ordered_at, o.id`
+
+	return q, conds.Args()
+}
+
+// statusArray returns the statuses as an array that can be used in a query.
+func statusArray(statuses []status) interface{} {
+	values := make([]int64, len(statuses))
+	for i, s := range statuses {
+		values[i] = int64(s)
+	}
+	return pq.Array(values)
+}
@@ -0,0 +1,89 @@
+package ordering
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/GGP1/adak/internal/params"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportQuery(t *testing.T) {
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	q, args := exportQuery(ExportFilter{From: from, Statuses: []status{Paid, Shipped}, ShopID: "shop"})
+
+	assert.Contains(t, q, "WHERE o.ordered_at IS NOT NULL AND o.ordered_at >= $1 AND COALESCE(o.status, 0)=ANY($2) AND "+
+		"COALESCE(p.shop_id, pr.shop_id)=$3")
+	assert.Equal(t, 3, len(args))
+	assert.Equal(t, from, args[0])
+	assert.Equal(t, "shop", args[2])
+
+	q, args = exportQuery(ExportFilter{})
+	assert.Contains(t, q, "WHERE o.ordered_at IS NOT NULL\n")
+	assert.Empty(t, args)
+}
+
+func TestParseExportFilter(t *testing.T) {
+	query := url.Values{
+		"from":   {"2021-03-01"},
+		"to":     {"2021-03-31"},
+		"status": {"paid, delivered"},
+	}
+	filter, err := parseExportFilter(query)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), filter.From)
+	// The whole last day is included
+	assert.Equal(t, time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC), filter.To)
+	assert.Equal(t, []status{Paid, Delivered}, filter.Statuses)
+
+	invalid := []url.Values{
+		{"from": {"01/03/2021"}},
+		{"from": {"2021-04-01"}, "to": {"2021-03-01"}},
+		{"status": {"paid,lost"}},
+	}
+	for _, query := range invalid {
+		_, err := parseExportFilter(query)
+		assert.Error(t, err)
+	}
+}
+
+func TestExportLineRecord(t *testing.T) {
+	l := ExportLine{
+		OrderID:   "order",
+		OrderedAt: time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC),
+		Status:    "paid",
+		Quantity:  2,
+		UnitTotal: 150,
+		LineTotal: 300,
+	}
+	record := l.Record()
+	assert.Equal(t, len(ExportHeader), len(record))
+	assert.Equal(t, "2021-03-01T10:00:00Z", record[1])
+	assert.Equal(t, "300", record[16])
+}
+
+func TestGetQuery(t *testing.T) {
+	query := params.Query{
+		Limit: "20",
+		Filters: params.Filters{
+			"status":    []string{"paid"},
+			"email":     "user@adak.com",
+			"max_total": int64(5000),
+		},
+		Search: "main street",
+		Sort:   params.Sort{Field: "ordered_at"},
+	}
+
+	q, args, err := getQuery(query)
+	assert.NoError(t, err)
+	assert.Contains(t, q, "WHERE COALESCE(status, 0)=ANY($1) AND user_id IN (SELECT id FROM users WHERE LOWER(email)=LOWER($2))"+
+		" AND id IN (SELECT order_id FROM order_carts WHERE total <= $3) AND "+addressSearch+" @@ plainto_tsquery('simple', $4)")
+	assert.Contains(t, q, "ORDER BY COALESCE(ordered_at, created_at) DESC, id DESC LIMIT $5")
+	assert.Equal(t, 5, len(args))
+
+	query.Filters = params.Filters{"status": []string{"lost"}}
+	_, _, err = getQuery(query)
+	assert.Error(t, err)
+}
@@ -3,23 +3,38 @@ package ordering
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/GGP1/adak/internal/cookie"
+	"github.com/GGP1/adak/internal/logger"
 	"github.com/GGP1/adak/internal/params"
 	"github.com/GGP1/adak/internal/response"
 	"github.com/GGP1/adak/internal/sanitize"
 	"github.com/GGP1/adak/internal/token"
 	"github.com/GGP1/adak/internal/validate"
 	"github.com/GGP1/adak/pkg/shopping/cart"
-	"github.com/GGP1/adak/pkg/shopping/payment/stripe"
+	"github.com/GGP1/adak/pkg/shopping/currency"
+	"github.com/GGP1/adak/pkg/shopping/delivery"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/payment"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
+	"github.com/GGP1/adak/pkg/shopping/shipment"
+	"github.com/GGP1/adak/pkg/shopping/shipping"
 	"github.com/google/uuid"
 
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 	"gopkg.in/guregu/null.v4/zero"
 )
 
+// maxWebhookPayload is the maximum size of the events received by the webhook.
+const maxWebhookPayload = 65536
+
 type cursorResponse struct {
 	NextCursor string  `json:"next_cursor,omitempty"`
 	Orders     []Order `json:"orders,omitempty"`
@@ -27,14 +42,33 @@ type cursorResponse struct {
 
 // OrderParams holds the parameters for creating a order.
 type OrderParams struct {
-	Currency string      `json:"currency" validate:"required"`
-	Address  string      `json:"address" validate:"required"`
-	City     string      `json:"city" validate:"required"`
-	Country  string      `json:"country" validate:"required"`
-	State    string      `json:"state" validate:"required"`
-	ZipCode  string      `json:"zip_code" validate:"required"`
-	Date     Date        `json:"date" validate:"required"`
-	Card     stripe.Card `json:"card" validate:"required"`
+	Currency string `json:"currency" validate:"required,len=3"`
+	Address  string `json:"address" validate:"required"`
+	City     string `json:"city" validate:"required"`
+	Country  string `json:"country" validate:"required"`
+	State    string `json:"state" validate:"required"`
+	ZipCode  string `json:"zip_code" validate:"required"`
+	// PaymentMethodID is the id of the method saved by the user that is charged
+	PaymentMethodID string `json:"payment_method_id" validate:"required"`
+	// Date is only used when none of the shops of the cart define delivery slots
+	Date *Date `json:"date,omitempty"`
+	// Slots contains the delivery slot chosen for each shop that defines them
+	Slots []delivery.Booking `json:"slots" validate:"dive"`
+	// ShippingMethod is the id of one of the methods quoted in the cart checkout,
+	// it can be omitted only if none is available
+	ShippingMethod string `json:"shipping_method"`
+	// AcceptChanges is the token returned when the cart products changed since they were added,
+	// it must be provided to order with their current values
+	AcceptChanges string `json:"accept_changes"`
+}
+
+type refundRequest struct {
+	// Lines is empty to refund everything that's left
+	Lines []RefundLine `json:"lines" validate:"dive"`
+}
+
+type statusRequest struct {
+	Status string `json:"status" validate:"required"`
 }
 
 // Date of the order.
@@ -46,26 +80,149 @@ type Date struct {
 	Minutes int `json:"minutes" validate:"required,min=0,max=60"`
 }
 
+// Confirmer confirms the purchase to the user once the order is paid.
+type Confirmer interface {
+	Confirm(ctx context.Context, order Order) error
+}
+
+// PaymentObserver is notified when the payment of an order fails after it was placed.
+type PaymentObserver interface {
+	PaymentFailed(ctx context.Context, order Order) error
+}
+
 // Handler handles ordering endpoints.
 type Handler struct {
 	orderingService Service
-	development     bool
+	provider        payment.Provider
+	paymentService  payment.Service
 	db              *sqlx.DB
 	cache           *memcache.Client
 	cartService     cart.Service
+	shipmentService shipment.Service
+	confirmer       Confirmer
+	observer        PaymentObserver
 }
 
 // NewHandler returns a new ordering handler.
-func NewHandler(dev bool, orderingS Service, cartS cart.Service, db *sqlx.DB, cache *memcache.Client) Handler {
+func NewHandler(provider payment.Provider, paymentS payment.Service, orderingS Service, cartS cart.Service,
+	shipmentS shipment.Service, confirmer Confirmer, observer PaymentObserver, db *sqlx.DB, cache *memcache.Client) Handler {
 	return Handler{
-		development:     dev,
+		provider:        provider,
+		paymentService:  paymentS,
 		orderingService: orderingS,
 		cartService:     cartS,
+		shipmentService: shipmentS,
+		confirmer:       confirmer,
+		observer:        observer,
 		db:              db,
 		cache:           cache,
 	}
 }
 
+// Cancel cancels an order of the user that wasn't shipped yet.
+func (h *Handler) Cancel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		userID, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		order, err := h.orderingService.GetByID(ctx, id)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if order.UserID.String != userID {
+			response.Error(w, http.StatusNotFound, ErrNotFound)
+			return
+		}
+
+		// Cancel the payment first so the user isn't charged for the cancelled order
+		if status(order.Status.Int64) == Pending && order.PaymentIntentID.String != "" {
+			if err := h.provider.CancelIntent(ctx, order.PaymentIntentID.String); err != nil {
+				response.Error(w, http.StatusConflict, errors.Wrap(err, "couldn't cancel the payment"))
+				return
+			}
+		}
+
+		if err := h.orderingService.Cancel(ctx, id, userID, ProviderRefund(ctx, h.provider)); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Orders are cached by user id
+		if err := h.cache.Delete(userID); err != nil && err != memcache.ErrCacheMiss {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "order "+id+" cancelled")
+	}
+}
+
+// Confirm confirms the payment of an order of the user whose intent is waiting for it.
+//
+// Intents that were only authorized are captured right away.
+func (h *Handler) Confirm() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		userID, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		order, err := h.orderingService.GetByID(ctx, id)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if order.UserID.String != userID {
+			response.Error(w, http.StatusNotFound, ErrNotFound)
+			return
+		}
+		if status(order.Status.Int64) != Pending || order.PaymentIntentID.String == "" {
+			response.Error(w, http.StatusConflict, errors.New("the order has no payment waiting for confirmation"))
+			return
+		}
+
+		intent, err := h.provider.ConfirmIntent(ctx, order.PaymentIntentID.String)
+		if err != nil {
+			if errors.Is(err, payment.ErrDeclined) {
+				h.cancelOrder(ctx, id, userID, order.PaymentIntentID.String)
+				response.Error(w, http.StatusPaymentRequired, err)
+				return
+			}
+			response.Error(w, http.StatusConflict, err)
+			return
+		}
+		if intent.Status == payment.IntentRequiresCapture {
+			intent, err = h.provider.CaptureIntent(ctx, intent.ID)
+			if err != nil {
+				response.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+
+		// The order is marked as paid once the provider notifies the payment succeeded
+		response.JSONText(w, http.StatusOK, "order "+id+" payment is "+intent.Status)
+	}
+}
+
 // Delete deletes an order.
 func (h *Handler) Delete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -86,7 +243,58 @@ func (h *Handler) Delete() http.HandlerFunc {
 	}
 }
 
-// Get finds all the stored orders.
+// Export streams the lines of the orders in CSV or, if the format requested is "jsonl", in JSON Lines.
+//
+// The orders can be filtered by date range ("from" inclusive and "to" exclusive, dates or RFC3339
+// timestamps), "status" names separated by commas and the "shop_id" of the products.
+func (h *Handler) Export() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+
+		format := query.Get("format")
+		if format != "" && format != "csv" && format != "jsonl" {
+			response.Error(w, http.StatusBadRequest, errors.Errorf("invalid format %q", format))
+			return
+		}
+
+		filter, err := parseExportFilter(query)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var stream response.Stream
+		filename := "orders-" + time.Now().Format("20060102150405")
+		if format == "jsonl" {
+			stream = response.JSONLinesStream(w, filename+".jsonl")
+		} else {
+			stream, err = response.CSVStream(w, filename+".csv", ExportHeader)
+			if err != nil {
+				logger.Error(errors.Wrap(err, "couldn't export the orders"))
+				return
+			}
+		}
+
+		// The status was already sent, errors can only be logged
+		err = h.orderingService.Export(ctx, filter, func(l ExportLine) error {
+			return stream.Write(l)
+		})
+		if err != nil {
+			logger.Error(errors.Wrap(err, "couldn't export the orders"))
+		}
+		if err := stream.Close(); err != nil {
+			logger.Error(errors.Wrap(err, "couldn't export the orders"))
+		}
+	}
+}
+
+// Get finds the stored orders.
+//
+// They can be filtered by "status" names separated by commas, ordered date range ("from" inclusive
+// and "to" exclusive), "user_id", user "email", "shop_id", "min_total" and "max_total" in the base
+// currency and "country", looked for by their address with "search" and sorted with "sort"
+// (created_at, ordered_at or delivery_date, prefixed with "-" for descending order).
 func (h *Handler) Get() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -96,6 +304,10 @@ func (h *Handler) Get() http.HandlerFunc {
 			response.Error(w, http.StatusBadRequest, err)
 			return
 		}
+		if _, err := parseStatuses(urlParams.Filters.Strings("status")); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
 
 		orders, err := h.orderingService.Get(ctx, urlParams)
 		if err != nil {
@@ -106,7 +318,7 @@ func (h *Handler) Get() http.HandlerFunc {
 		var nextCursor string
 		if len(orders) > 0 {
 			nextCursor = params.EncodeCursor(
-				orders[len(orders)-1].CreatedAt.Time,
+				cursorTime(orders[len(orders)-1], urlParams.Sort.Field),
 				orders[len(orders)-1].ID.String,
 			)
 		}
@@ -135,6 +347,21 @@ func (h *Handler) GetByID() http.HandlerFunc {
 			return
 		}
 
+		order.Shipments, err = h.shipmentService.GetByOrderID(ctx, id)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		order.ShopOrders, err = h.orderingService.GetShopOrders(ctx, id)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		for i := range order.ShopOrders {
+			order.ShopOrders[i].AttachShipments(order.Shipments)
+		}
+
 		response.JSON(w, http.StatusOK, order)
 	}
 }
@@ -168,11 +395,46 @@ func (h *Handler) GetByUserID() http.HandlerFunc {
 			return
 		}
 
+		orderIDs := make([]string, len(orders))
+		for i, o := range orders {
+			orderIDs[i] = o.ID.String
+		}
+		shipments, err := h.shipmentService.GetByOrderIDs(ctx, orderIDs)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		for i, o := range orders {
+			orders[i].Shipments = shipments[o.ID.String]
+		}
+
 		response.JSONAndCache(h.cache, w, id, orders)
 	}
 }
 
-// New creates a new order and the payment intent.
+// History lists the status changes of an order.
+func (h *Handler) History() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		history, err := h.orderingService.History(ctx, id)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, history)
+	}
+}
+
+// New creates a new order and the payment intent, the order is pending until the
+// payment is notified by the provider.
 func (h *Handler) New() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -198,35 +460,305 @@ func (h *Handler) New() http.HandlerFunc {
 			return
 		}
 
+		method, err := h.paymentService.GetMethod(ctx, userID, orderParams.PaymentMethodID)
+		if err != nil {
+			if errors.Is(err, payment.ErrNotFound) {
+				response.Error(w, http.StatusBadRequest, err)
+				return
+			}
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
 		id := uuid.NewString()
 		order, err := h.orderingService.New(ctx, id, userID, cartID, orderParams, h.cartService)
 		if err != nil {
+			var changesErr *ChangesError
+			if errors.As(err, &changesErr) {
+				response.JSON(w, http.StatusConflict, changesErr)
+				return
+			}
+			if errors.Is(err, inventory.ErrOutOfStock) || errors.Is(err, promotion.ErrNotApplicable) ||
+				errors.Is(err, delivery.ErrSlotFull) {
+				response.Error(w, http.StatusConflict, err)
+				return
+			}
+			if errors.Is(err, currency.ErrUnsupported) ||
+				errors.Is(err, shipping.ErrMethodRequired) || errors.Is(err, shipping.ErrUnavailable) ||
+				errors.Is(err, ErrDateRequired) || errors.Is(err, delivery.ErrSlotNotFound) ||
+				errors.Is(err, delivery.ErrSlotRequired) || errors.Is(err, delivery.ErrSlotUnavailable) {
+				response.Error(w, http.StatusBadRequest, err)
+				return
+			}
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
 
-		if !h.development {
-			// Create payment intent and update the order status
-			_, err = stripe.CreateIntent(order.ID.String, order.CartID.String,
-				order.Currency.String, order.Cart.Total.Int64, orderParams.Card)
-			if err != nil {
-				response.Error(w, http.StatusInternalServerError, err)
+		intent, err := h.provider.CreateIntent(ctx, payment.IntentParams{
+			OrderID:    order.ID.String,
+			CartID:     order.CartID.String,
+			Currency:   order.Currency.String,
+			Amount:     order.Cart.ConvertedTotal.Int64,
+			CustomerID: method.CustomerID.String,
+			MethodID:   method.ID.String,
+		})
+		if err != nil {
+			// The intent may have been created anyway
+			h.cancelOrder(ctx, order.ID.String, userID, intent.ID)
+			if errors.Is(err, payment.ErrDeclined) {
+				response.Error(w, http.StatusPaymentRequired, err)
 				return
 			}
+			response.Error(w, http.StatusInternalServerError, err)
+			return
 		}
 
-		if err := h.orderingService.UpdateStatus(ctx, order.ID.String, Paid); err != nil {
+		if err := h.orderingService.SetPaymentIntent(ctx, order.ID.String, intent.ID); err != nil {
+			h.cancelOrder(ctx, order.ID.String, userID, intent.ID)
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
-		order.Status = zero.IntFrom(int64(Paid))
+		order.PaymentIntentID = zero.StringFrom(intent.ID)
 
+		// The order is placed already, the products left in the cart don't invalidate it
 		if err := h.cartService.Reset(ctx, cartID); err != nil {
+			logger.Error(err)
+		}
+
+		// The order is marked as paid and confirmed once the provider notifies the payment succeeded
+		response.JSON(w, http.StatusCreated, order)
+	}
+}
+
+// Refund gives back the money paid for the lines of an order, or all of it.
+func (h *Handler) Refund() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		adminID, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		var req refundRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		refund, err := h.orderingService.Refund(ctx, id, adminID, req.Lines, ProviderRefund(ctx, h.provider))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSON(w, http.StatusCreated, refund)
+	}
+}
+
+// Refunds lists the refunds of an order.
+func (h *Handler) Refunds() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		refunds, err := h.orderingService.Refunds(ctx, id)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, refunds)
+	}
+}
+
+// Reorder places the products of a past order of the user in the cart again.
+//
+// Products deleted, out of stock or that require choosing a variant are skipped, the ones
+// whose price changed are added and reported.
+func (h *Handler) Reorder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		cartID, err := params.CartID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+		userID, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		summary, err := h.orderingService.Reorder(ctx, id, userID, cartID, h.cartService)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, summary)
+	}
+}
+
+// UpdateStatus moves an order to the status requested.
+func (h *Handler) UpdateStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		adminID, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		var req statusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		status, err := parseStatus(strings.ToLower(sanitize.Normalize(req.Status)))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.orderingService.UpdateStatus(ctx, id, status, adminID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "order "+id+" is "+status.String())
+	}
+}
+
+// Webhook receives the payment events sent by the provider and updates the orders they concern.
+//
+// Events that can't be applied are acknowledged anyway so they aren't sent again.
+func (h *Handler) Webhook() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookPayload))
+		if err != nil {
+			response.Error(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		defer r.Body.Close()
+
+		event, ok, err := h.provider.ParseEvent(payload, r.Header)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		if !ok {
+			response.JSONText(w, http.StatusOK, "event ignored")
+			return
+		}
+
+		if err := h.HandleEvent(r.Context(), event); err != nil {
 			response.Error(w, http.StatusInternalServerError, err)
 			return
 		}
 
-		response.JSON(w, http.StatusCreated, order)
+		response.JSONText(w, http.StatusOK, "event processed")
+	}
+}
+
+// HandleEvent applies a payment event to the order it concerns and confirms the order
+// once it's paid.
+func (h *Handler) HandleEvent(ctx context.Context, event payment.Event) error {
+	orderID, applied, err := h.orderingService.ApplyPaymentEvent(ctx, event)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrInvalidRefund) {
+			logger.Error(errors.Wrapf(err, "couldn't apply the payment event %s", event.ID))
+			return nil
+		}
+		return err
+	}
+	if !applied {
+		return nil
+	}
+
+	order, err := h.orderingService.GetByID(ctx, orderID)
+	if err != nil {
+		logger.Error(err)
+		return nil
+	}
+	if err := h.cache.Delete(order.UserID.String); err != nil && err != memcache.ErrCacheMiss {
+		logger.Error(err)
+	}
+
+	// The event was applied already, the errors that follow must not fail it
+	switch event.Type {
+	case payment.PaymentSucceeded:
+		if err := h.confirmer.Confirm(ctx, order); err != nil {
+			logger.Error(errors.Wrapf(err, "couldn't confirm the order %s", orderID))
+		}
+	case payment.PaymentFailed:
+		if err := h.observer.PaymentFailed(ctx, order); err != nil {
+			logger.Error(errors.Wrapf(err, "couldn't handle the failed payment of the order %s", orderID))
+		}
+	}
+
+	return nil
+}
+
+// cancelOrder cancels the intent, if there's one, and the order whose payment failed to give
+// back its stock, delivery slots and promotions. The errors are only logged as the payment
+// error is returned.
+func (h *Handler) cancelOrder(ctx context.Context, orderID, userID, intentID string) {
+	if intentID != "" {
+		if err := h.provider.CancelIntent(ctx, intentID); err != nil {
+			// The payment may have gone through, the order is settled by its event
+			logger.Error(errors.Wrapf(err, "couldn't cancel the payment of the order %s", orderID))
+			return
+		}
+	}
+	if err := h.orderingService.UpdateStatus(ctx, orderID, Failed, userID); err != nil {
+		logger.Error(err)
+	}
+	if err := h.orderingService.Cancel(ctx, orderID, userID, nil); err != nil {
+		logger.Error(err)
+	}
+}
+
+// ProviderRefund returns a RefundFunc that refunds the payment intents through the provider.
+func ProviderRefund(ctx context.Context, provider payment.Provider) RefundFunc {
+	return func(intentID, key string, amount int64) (string, error) {
+		return provider.Refund(ctx, intentID, key, amount)
 	}
 }
 
@@ -238,8 +770,47 @@ func validateOrderParams(ctx context.Context, oParams *OrderParams) error {
 	oParams.City = sanitize.Normalize(oParams.City)
 	oParams.Country = sanitize.Normalize(oParams.Country)
 	oParams.Currency = sanitize.Normalize(oParams.Currency)
+	oParams.ShippingMethod = sanitize.Normalize(oParams.ShippingMethod)
 	oParams.State = sanitize.Normalize(oParams.State)
 	oParams.ZipCode = sanitize.Normalize(oParams.ZipCode)
 
 	return nil
 }
+
+// writeError responds with the status code that corresponds to the lifecycle error provided.
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		response.Error(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrInvalidTransition):
+		response.Error(w, http.StatusConflict, err)
+	case errors.Is(err, ErrInvalidRefund):
+		response.Error(w, http.StatusUnprocessableEntity, err)
+	default:
+		response.Error(w, http.StatusInternalServerError, err)
+	}
+}
+
+// parseExportFilter returns the export filter from the url query values.
+func parseExportFilter(query url.Values) (ExportFilter, error) {
+	q, err := params.ParseQuery(query.Encode(), params.Order)
+	if err != nil {
+		return ExportFilter{}, err
+	}
+
+	filter := ExportFilter{
+		From:   q.Filters.Time("from"),
+		To:     q.Filters.Time("to"),
+		ShopID: q.Filters.String("shop_id"),
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return ExportFilter{}, errors.New("\"from\" must be before \"to\"")
+	}
+
+	filter.Statuses, err = parseStatuses(q.Filters.Strings("status"))
+	if err != nil {
+		return ExportFilter{}, err
+	}
+
+	return filter, nil
+}
@@ -1,18 +1,15 @@
 package ordering
 
 import (
-	"gopkg.in/guregu/null.v4/zero"
-)
+	"database/sql/driver"
+	"encoding/json"
 
-type status int64
+	"github.com/GGP1/adak/pkg/shopping/shipment"
+	"github.com/GGP1/adak/pkg/shopping/tax"
 
-// Order statuses
-const (
-	Pending status = iota
-	Paid
-	Shipping
-	Shipped
-	Failed
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
 )
 
 // Order represents a user purchase request.
@@ -32,6 +29,20 @@ type Order struct {
 	Cart         OrderCart      `json:"cart,omitempty"`
 	Products     []OrderProduct `json:"products,omitempty"`
 	CreatedAt    zero.Time      `json:"created_at,omitempty" db:"created_at"`
+	// BaseCurrency is the currency the products are priced in and ExchangeRate the
+	// rate used to convert the cart total to Currency when ordering
+	BaseCurrency zero.String `json:"base_currency,omitempty" db:"base_currency"`
+	ExchangeRate zero.String `json:"exchange_rate,omitempty" db:"exchange_rate"`
+	// ShippingCost is in the base currency and already included in the cart total
+	ShippingMethodID zero.String `json:"shipping_method_id,omitempty" db:"shipping_method_id"`
+	ShippingMethod   zero.String `json:"shipping_method,omitempty" db:"shipping_method"`
+	ShippingCost     zero.Int    `json:"shipping_cost,omitempty" db:"shipping_cost"`
+	PaymentIntentID  zero.String `json:"payment_intent_id,omitempty" db:"payment_intent_id"`
+	// Refunded is the amount given back to the user, in the currency of the order
+	Refunded  zero.Int            `json:"refunded,omitempty"`
+	Shipments []shipment.Shipment `json:"shipments,omitempty"`
+	// ShopOrders are the parts of the order sold by each shop
+	ShopOrders []ShopOrder `json:"shop_orders,omitempty"`
 }
 
 // OrderCart represents the cart ordered by the user.
@@ -46,6 +57,10 @@ type OrderCart struct {
 	Taxes    zero.Int    `json:"taxes,omitempty"`
 	Subtotal zero.Int    `json:"subtotal,omitempty"`
 	Total    zero.Int    `json:"total,omitempty"`
+	// PromotionCodes contains the codes redeemed, their discount is included in Discount
+	PromotionCodes pq.StringArray `json:"promotion_codes,omitempty" db:"promotion_codes"`
+	// ConvertedTotal is the Total in the currency of the order
+	ConvertedTotal zero.Int `json:"converted_total,omitempty" db:"converted_total"`
 }
 
 // OrderProduct represents a product placed into the cart ordered by the user.
@@ -65,4 +80,116 @@ type OrderProduct struct {
 	Taxes       zero.Int    `json:"taxes,omitempty"`
 	Subtotal    zero.Int    `json:"subtotal,omitempty"`
 	Total       zero.Int    `json:"total,omitempty"`
+	// VariantID, SKU and Options are empty for products without variants
+	VariantID zero.String    `json:"variant_id,omitempty" db:"variant_id"`
+	SKU       zero.String    `json:"sku,omitempty"`
+	Options   pq.StringArray `json:"options,omitempty"`
+	// TaxLines details the taxes charged on the whole line (all the units), Taxes is their sum
+	TaxLines tax.Breakdown `json:"tax_lines,omitempty" db:"tax_lines"`
+	// Refunded is the number of units refunded
+	Refunded zero.Int `json:"refunded,omitempty"`
+	// ShopID is the shop that sold the product, empty in the orders placed before splitting them by shop
+	ShopID zero.String `json:"shop_id,omitempty" db:"shop_id"`
+}
+
+// ShopOrder is the part of an order sold by a shop, it has its own totals and status.
+//
+// Amounts are in the base currency and provided in its smallest unit, the shipping cost
+// and promotions discount of the order are split among its shops in proportion to the
+// price of their products.
+type ShopOrder struct {
+	ID           zero.String         `json:"id,omitempty"`
+	OrderID      zero.String         `json:"order_id,omitempty" db:"order_id"`
+	ShopID       zero.String         `json:"shop_id,omitempty" db:"shop_id"`
+	Status       int64               `json:"status"`
+	Counter      int64               `json:"counter,omitempty"`
+	Subtotal     int64               `json:"subtotal,omitempty"`
+	Discount     int64               `json:"discount,omitempty"`
+	Taxes        int64               `json:"taxes,omitempty"`
+	ShippingCost int64               `json:"shipping_cost,omitempty" db:"shipping_cost"`
+	Total        int64               `json:"total,omitempty"`
+	CreatedAt    zero.Time           `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt    zero.Time           `json:"updated_at,omitempty" db:"updated_at"`
+	Products     []OrderProduct      `json:"products,omitempty"`
+	Shipments    []shipment.Shipment `json:"shipments,omitempty"`
+}
+
+// AttachShipments sets the shipments of the order that contain products of the shop, only
+// with the lines of these products.
+func (so *ShopOrder) AttachShipments(shipments []shipment.Shipment) {
+	products := make(map[string]struct{}, len(so.Products))
+	for _, p := range so.Products {
+		products[p.ProductID.String] = struct{}{}
+	}
+
+	so.Shipments = nil
+	for _, sh := range shipments {
+		var lines []shipment.Line
+		for _, l := range sh.Lines {
+			if _, ok := products[l.ProductID]; ok {
+				lines = append(lines, l)
+			}
+		}
+		if len(lines) > 0 {
+			sh.Lines = lines
+			so.Shipments = append(so.Shipments, sh)
+		}
+	}
+}
+
+// Refund is the money given back to the user for an order.
+type Refund struct {
+	ID      zero.String `json:"id,omitempty"`
+	OrderID zero.String `json:"order_id,omitempty" db:"order_id"`
+	// ProviderID is the id of the refund in the payment provider
+	ProviderID zero.String `json:"provider_id,omitempty" db:"provider_id"`
+	// Amount is in the currency of the order
+	Amount    zero.Int    `json:"amount,omitempty"`
+	Lines     RefundLines `json:"lines,omitempty"`
+	CreatedBy zero.String `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt zero.Time   `json:"created_at,omitempty" db:"created_at"`
+}
+
+// RefundLine is a quantity of an ordered product being refunded.
+type RefundLine struct {
+	ProductID string `json:"product_id" validate:"required"`
+	VariantID string `json:"variant_id,omitempty"`
+	Quantity  int64  `json:"quantity" validate:"min=1"`
+	// Amount is the price of the units plus their exclusive taxes, in the base currency
+	Amount int64 `json:"amount"`
+}
+
+// RefundLines contains the products refunded, they are restocked unless a return skipped it.
+type RefundLines []RefundLine
+
+// Scan implements the sql.Scanner interface.
+func (l *RefundLines) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	}
+	return errors.Errorf("cannot scan %T into refund lines", src)
+}
+
+// Value implements the driver.Valuer interface.
+func (l RefundLines) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return json.Marshal(l)
+}
+
+// StatusChange is a record of a transition in the order lifecycle.
+type StatusChange struct {
+	OrderID zero.String `json:"order_id,omitempty" db:"order_id"`
+	// From is empty when the order is created
+	From      zero.String `json:"from,omitempty" db:"from_status"`
+	To        zero.String `json:"to,omitempty" db:"to_status"`
+	ChangedBy zero.String `json:"changed_by,omitempty" db:"changed_by"`
+	ChangedAt zero.Time   `json:"changed_at,omitempty" db:"changed_at"`
 }
@@ -0,0 +1,152 @@
+package ordering
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/GGP1/adak/pkg/shopping/payment"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// paymentProvider is recorded as the author of the status changes made by payment events.
+const paymentProvider = "payment provider"
+
+// errIntentNotSaved is returned when an event arrives before the intent is saved in its
+// order, the provider delivers it again later.
+var errIntentNotSaved = errors.New("the payment intent of the order wasn't saved yet")
+
+// ApplyPaymentEvent moves the order to the status that corresponds to a payment event and
+// returns its id.
+//
+// Each event is applied once, false is returned if it was already processed.
+func (s *service) ApplyPaymentEvent(ctx context.Context, event payment.Event) (string, bool, error) {
+	s.metrics.incMethodCalls("ApplyPaymentEvent")
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", false, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	orderID, err := findEventOrder(ctx, tx, event)
+	if err != nil {
+		return "", false, err
+	}
+
+	q := "INSERT INTO payment_events (id, type, order_id, received_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING"
+	res, err := tx.ExecContext(ctx, q, event.ID, event.Type, orderID, time.Now())
+	if err != nil {
+		return "", false, errors.Wrap(err, "couldn't record the payment event")
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return orderID, false, err
+	}
+
+	switch event.Type {
+	case payment.PaymentSucceeded:
+		err = s.transition(ctx, tx, orderID, Paid, paymentProvider)
+	case payment.PaymentFailed:
+		err = s.transition(ctx, tx, orderID, Failed, paymentProvider)
+	case payment.PaymentRefunded:
+		// The money was already given back by the provider, only record it
+		refundFn := func(string, string, int64) (string, error) { return event.RefundID, nil }
+		_, err = s.refund(ctx, tx, orderID, paymentProvider, nil, false, Refunded, refundFn)
+	case payment.DisputeCreated:
+		err = s.transition(ctx, tx, orderID, Disputed, paymentProvider)
+	case payment.DisputeWon:
+		err = s.restore(ctx, tx, orderID, paymentProvider)
+	case payment.DisputeLost:
+		err = s.transition(ctx, tx, orderID, Refunded, paymentProvider)
+	default:
+		err = errors.Errorf("unknown payment event type %q", event.Type)
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", false, errors.Wrap(err, "committing transaction")
+	}
+
+	return orderID, true, nil
+}
+
+// findEventOrder returns the id of the order a payment event concerns.
+//
+// Orders are found by the intent id, the order id is only used by events that don't
+// carry one.
+func findEventOrder(ctx context.Context, tx *sqlx.Tx, event payment.Event) (string, error) {
+	var orderID string
+	switch {
+	case event.IntentID != "":
+		q := "SELECT id FROM orders WHERE payment_intent_id=$1"
+		err := tx.GetContext(ctx, &orderID, q, event.IntentID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return "", errors.Wrap(err, "couldn't find the order")
+		}
+		if err == nil || event.OrderID == "" {
+			break
+		}
+		// The intent is saved right after it's created, the event may arrive in between
+		q = "SELECT EXISTS(SELECT 1 FROM orders WHERE id=$1 AND payment_intent_id IS NULL)"
+		var pending bool
+		if err := tx.GetContext(ctx, &pending, q, event.OrderID); err != nil {
+			return "", errors.Wrap(err, "couldn't find the order")
+		}
+		if pending {
+			return "", errIntentNotSaved
+		}
+	case event.OrderID != "":
+		q := "SELECT id FROM orders WHERE id=$1"
+		err := tx.GetContext(ctx, &orderID, q, event.OrderID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return "", errors.Wrap(err, "couldn't find the order")
+		}
+	}
+
+	if orderID == "" {
+		return "", ErrNotFound
+	}
+	return orderID, nil
+}
+
+// restore moves a disputed order back to the status it had before the dispute.
+func (s *service) restore(ctx context.Context, tx *sqlx.Tx, orderID, changedBy string) error {
+	var from status
+	if err := tx.GetContext(ctx, &from, "SELECT status FROM orders WHERE id=$1 FOR UPDATE", orderID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return errors.Wrap(err, "couldn't find the order")
+	}
+	if from != Disputed {
+		return errors.Wrapf(ErrInvalidTransition, "%s orders aren't disputed", from)
+	}
+
+	var previous string
+	q := `SELECT from_status FROM order_status_history WHERE order_id=$1 AND to_status=$2
+	ORDER BY changed_at DESC LIMIT 1`
+	if err := tx.GetContext(ctx, &previous, q, orderID, Disputed.String()); err != nil {
+		return errors.Wrap(err, "couldn't find the status before the dispute")
+	}
+	to, err := parseStatus(previous)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE orders SET status=$2 WHERE id=$1", orderID, to); err != nil {
+		return errors.Wrap(err, "couldn't update the order status")
+	}
+
+	if err := saveStatusChange(ctx, tx, orderID, from.String(), to, changedBy); err != nil {
+		return err
+	}
+
+	s.metrics.totalOrders.With(prometheus.Labels{"status": strconv.FormatInt(int64(to), 10)}).Inc()
+	return nil
+}
@@ -0,0 +1,343 @@
+package ordering
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// ErrInvalidRefund is returned when the refund requested exceeds what's left to refund.
+var ErrInvalidRefund = errors.New("invalid refund")
+
+// RefundFunc gives back the amount (in the currency of the order) charged with the payment
+// intent provided and returns the id of the refund in the payment provider.
+//
+// It's called before committing the refund, if it fails nothing is saved. Calls with the
+// same key must return the refund made by the first one, so a refund whose transaction
+// failed can be retried without giving back the money twice.
+type RefundFunc func(intentID, key string, amount int64) (string, error)
+
+// refundableOrder contains the order values needed to calculate a refund.
+type refundableOrder struct {
+	Status          status `db:"status"`
+	UserID          string `db:"user_id"`
+	PaymentIntentID string `db:"payment_intent_id"`
+	Refunded        int64  `db:"refunded"`
+	// Total is in the base currency and ConvertedTotal in the order one
+	Total          int64 `db:"total"`
+	ConvertedTotal int64 `db:"converted_total"`
+}
+
+// convert turns an amount in the base currency into the currency of the order using
+// the same ratio the order was charged with.
+func (o refundableOrder) convert(amount int64) int64 {
+	if o.Total == 0 {
+		return 0
+	}
+	return (amount*o.ConvertedTotal + o.Total/2) / o.Total
+}
+
+// remaining returns the amount that hasn't been refunded yet.
+func (o refundableOrder) remaining() int64 {
+	return o.ConvertedTotal - o.Refunded
+}
+
+// Cancel cancels an order that wasn't shipped yet on behalf of the user that placed it.
+//
+// The products are restocked and, if the order was paid, the whole amount is refunded.
+func (s *service) Cancel(ctx context.Context, orderID, userID string, refund RefundFunc) error {
+	s.metrics.incMethodCalls("Cancel")
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	order, err := getRefundableOrder(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+	if order.UserID != userID {
+		return ErrNotFound
+	}
+
+	switch order.Status {
+	case Pending, Failed:
+		// Nothing was charged, just give back the stock
+		if err := s.transition(ctx, tx, orderID, Cancelled, userID); err != nil {
+			return err
+		}
+		products, err := getOrderProducts(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		lines, err := refundLines(products, nil)
+		if err != nil {
+			return err
+		}
+		if err := s.restock(ctx, tx, orderID, lines, true); err != nil {
+			return err
+		}
+	case Paid:
+		// The whole amount is refunded, which moves the order to cancelled
+		if _, err := s.refund(ctx, tx, orderID, userID, nil, true, Cancelled, refund); err != nil {
+			return err
+		}
+	default:
+		return errors.Wrapf(ErrInvalidTransition, "%s -> %s", order.Status, Cancelled)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	return nil
+}
+
+// Refund gives back the money paid for the lines provided and restocks them.
+//
+// If no lines are provided, the amount that wasn't refunded yet (including shipping)
+// is given back and the order status is updated to refunded.
+func (s *service) Refund(ctx context.Context, orderID, createdBy string, lines []RefundLine, refund RefundFunc) (Refund, error) {
+	s.metrics.incMethodCalls("Refund")
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Refund{}, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	r, err := s.refund(ctx, tx, orderID, createdBy, lines, true, Refunded, refund)
+	if err != nil {
+		return Refund{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Refund{}, errors.Wrap(err, "committing transaction")
+	}
+
+	return r, nil
+}
+
+// RefundReturn gives back the money paid for the lines of a return inside the transaction provided,
+// the units are given back to the stock only if restock is true.
+func (s *service) RefundReturn(ctx context.Context, tx *sqlx.Tx, orderID, createdBy string,
+	lines []RefundLine, restock bool, refund RefundFunc) (Refund, error) {
+	s.metrics.incMethodCalls("RefundReturn")
+
+	if len(lines) == 0 {
+		return Refund{}, errors.Wrap(ErrInvalidRefund, "a return must have lines")
+	}
+
+	return s.refund(ctx, tx, orderID, createdBy, lines, restock, Refunded, refund)
+}
+
+// Refunds returns the refunds of an order.
+func (s *service) Refunds(ctx context.Context, orderID string) ([]Refund, error) {
+	s.metrics.incMethodCalls("Refunds")
+
+	var refunds []Refund
+	q := "SELECT * FROM refunds WHERE order_id=$1 ORDER BY created_at"
+	if err := s.db.SelectContext(ctx, &refunds, q, orderID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the refunds")
+	}
+
+	return refunds, nil
+}
+
+// refund calculates, records and executes a refund inside the transaction provided.
+//
+// The order moves to the status "to" once its whole amount is refunded.
+func (s *service) refund(ctx context.Context, tx *sqlx.Tx, orderID, createdBy string,
+	requested []RefundLine, restock bool, to status, refundFn RefundFunc) (Refund, error) {
+	order, err := getRefundableOrder(ctx, tx, orderID)
+	if err != nil {
+		return Refund{}, err
+	}
+
+	switch order.Status {
+	case Paid, Shipping, PartiallyShipped, Shipped, Delivered:
+	default:
+		return Refund{}, errors.Wrapf(ErrInvalidTransition, "%s orders can't be refunded", order.Status)
+	}
+
+	products, err := getOrderProducts(ctx, tx, orderID)
+	if err != nil {
+		return Refund{}, err
+	}
+
+	lines, err := refundLines(products, requested)
+	if err != nil {
+		return Refund{}, err
+	}
+
+	var amount int64
+	if len(requested) == 0 {
+		amount = order.remaining()
+	} else {
+		var base int64
+		for _, l := range lines {
+			base += l.Amount
+		}
+		amount = order.convert(base)
+		if amount > order.remaining() {
+			amount = order.remaining()
+		}
+	}
+	if amount <= 0 && len(lines) == 0 {
+		return Refund{}, errors.Wrap(ErrInvalidRefund, "the order was already refunded")
+	}
+
+	if err := s.restock(ctx, tx, orderID, lines, restock); err != nil {
+		return Refund{}, err
+	}
+
+	q := "UPDATE orders SET refunded=refunded+$2 WHERE id=$1"
+	if _, err := tx.ExecContext(ctx, q, orderID, amount); err != nil {
+		return Refund{}, errors.Wrap(err, "couldn't update the order refunded amount")
+	}
+
+	if order.Refunded+amount >= order.ConvertedTotal {
+		if err := s.transition(ctx, tx, orderID, to, createdBy); err != nil {
+			return Refund{}, err
+		}
+	}
+
+	r := Refund{
+		ID:        zero.StringFrom(uuid.NewString()),
+		OrderID:   zero.StringFrom(orderID),
+		Amount:    zero.IntFrom(amount),
+		Lines:     lines,
+		CreatedBy: zero.StringFrom(createdBy),
+		CreatedAt: zero.TimeFrom(time.Now()),
+	}
+	if amount > 0 {
+		// The key only changes once the refund is saved
+		key := fmt.Sprintf("refund-%s-%d-%d", orderID, order.Refunded, amount)
+		providerID, err := refundFn(order.PaymentIntentID, key, amount)
+		if err != nil {
+			return Refund{}, err
+		}
+		r.ProviderID = zero.StringFrom(providerID)
+	}
+
+	insertQ := `INSERT INTO refunds (id, order_id, provider_id, amount, lines, created_by, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = tx.ExecContext(ctx, insertQ, r.ID, r.OrderID, r.ProviderID, r.Amount, r.Lines, r.CreatedBy, r.CreatedAt)
+	if err != nil {
+		return Refund{}, errors.Wrap(err, "couldn't save the refund")
+	}
+
+	return r, nil
+}
+
+// restock marks the lines as refunded and, if toStock is true, gives back their units to the stock.
+func (s *service) restock(ctx context.Context, tx *sqlx.Tx, orderID string, lines []RefundLine, toStock bool) error {
+	q := `UPDATE order_products SET refunded=refunded+$4
+	WHERE order_id=$1 AND product_id=$2 AND COALESCE(variant_id, '')=$3`
+	items := make([]inventory.Item, len(lines))
+	for i, l := range lines {
+		if _, err := tx.ExecContext(ctx, q, orderID, l.ProductID, l.VariantID, l.Quantity); err != nil {
+			return errors.Wrap(err, "couldn't update the refunded units")
+		}
+		items[i] = inventory.Item{ProductID: l.ProductID, VariantID: l.VariantID, Quantity: l.Quantity}
+	}
+
+	if !toStock {
+		return nil
+	}
+	return s.inventory.Restock(ctx, tx, items)
+}
+
+// refundLines returns the lines to refund with their amount, all the units that weren't
+// refunded yet are included when none is requested.
+func refundLines(products []OrderProduct, requested []RefundLine) ([]RefundLine, error) {
+	if len(requested) == 0 {
+		lines := make([]RefundLine, 0, len(products))
+		for _, p := range products {
+			if left := p.Quantity.Int64 - p.Refunded.Int64; left > 0 {
+				lines = append(lines, RefundLine{
+					ProductID: p.ProductID.String,
+					VariantID: p.VariantID.String,
+					Quantity:  left,
+					Amount:    lineAmount(p, left),
+				})
+			}
+		}
+		return lines, nil
+	}
+
+	lines := make([]RefundLine, len(requested))
+	refunding := make(map[string]int64, len(requested))
+	for i, r := range requested {
+		key := r.ProductID + "/" + r.VariantID
+		refunding[key] += r.Quantity
+
+		var found bool
+		for _, p := range products {
+			if p.ProductID.String != r.ProductID || p.VariantID.String != r.VariantID {
+				continue
+			}
+			found = true
+			if left := p.Quantity.Int64 - p.Refunded.Int64; refunding[key] > left {
+				return nil, errors.Wrapf(ErrInvalidRefund, "product %q has %d units left to refund", r.ProductID, left)
+			}
+			lines[i] = RefundLine{
+				ProductID: r.ProductID,
+				VariantID: r.VariantID,
+				Quantity:  r.Quantity,
+				Amount:    lineAmount(p, r.Quantity),
+			}
+		}
+		if !found {
+			return nil, errors.Wrapf(ErrInvalidRefund, "product %q is not part of the order", r.ProductID)
+		}
+	}
+
+	return lines, nil
+}
+
+// lineAmount returns the price of the units of a product plus their share of the
+// exclusive taxes, in the base currency.
+func lineAmount(p OrderProduct, quantity int64) int64 {
+	amount := p.Total.Int64 * quantity
+	if p.Quantity.Int64 > 0 {
+		amount += p.TaxLines.Exclusive() * quantity / p.Quantity.Int64
+	}
+	return amount
+}
+
+func getRefundableOrder(ctx context.Context, tx *sqlx.Tx, orderID string) (refundableOrder, error) {
+	var order refundableOrder
+	q := `SELECT COALESCE(o.status, 0) AS status, o.user_id, COALESCE(o.payment_intent_id, '') AS payment_intent_id, o.refunded,
+	COALESCE(c.total, 0) AS total, COALESCE(c.converted_total, 0) AS converted_total
+	FROM orders AS o
+	LEFT JOIN order_carts AS c ON c.order_id=o.id
+	WHERE o.id=$1
+	FOR UPDATE OF o`
+	if err := tx.GetContext(ctx, &order, q, orderID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return refundableOrder{}, ErrNotFound
+		}
+		return refundableOrder{}, errors.Wrap(err, "couldn't find the order")
+	}
+
+	return order, nil
+}
+
+func getOrderProducts(ctx context.Context, tx *sqlx.Tx, orderID string) ([]OrderProduct, error) {
+	var products []OrderProduct
+	if err := tx.SelectContext(ctx, &products, "SELECT * FROM order_products WHERE order_id=$1", orderID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the order products")
+	}
+	return products, nil
+}
@@ -0,0 +1,63 @@
+package ordering
+
+import (
+	"testing"
+
+	"github.com/GGP1/adak/pkg/shopping/tax"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestRefundLines(t *testing.T) {
+	products := []OrderProduct{
+		{
+			ProductID: zero.StringFrom("a"),
+			Quantity:  zero.IntFrom(2),
+			Total:     zero.IntFrom(1000),
+			TaxLines:  tax.Breakdown{{Amount: 420}, {Amount: 100, Inclusive: true}},
+		},
+		{
+			ProductID: zero.StringFrom("b"),
+			VariantID: zero.StringFrom("b-red"),
+			Quantity:  zero.IntFrom(3),
+			Total:     zero.IntFrom(500),
+			Refunded:  zero.IntFrom(1),
+		},
+	}
+
+	t.Run("All", func(t *testing.T) {
+		lines, err := refundLines(products, nil)
+		assert.NoError(t, err)
+
+		expected := []RefundLine{
+			{ProductID: "a", Quantity: 2, Amount: 2420},
+			{ProductID: "b", VariantID: "b-red", Quantity: 2, Amount: 1000},
+		}
+		assert.Equal(t, expected, lines)
+	})
+
+	t.Run("Partial", func(t *testing.T) {
+		lines, err := refundLines(products, []RefundLine{{ProductID: "a", Quantity: 1}})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1210), lines[0].Amount)
+	})
+
+	t.Run("Exceeded", func(t *testing.T) {
+		requested := []RefundLine{{ProductID: "b", VariantID: "b-red", Quantity: 3}}
+		_, err := refundLines(products, requested)
+		assert.True(t, errors.Is(err, ErrInvalidRefund))
+	})
+
+	t.Run("Not ordered", func(t *testing.T) {
+		_, err := refundLines(products, []RefundLine{{ProductID: "c", Quantity: 1}})
+		assert.True(t, errors.Is(err, ErrInvalidRefund))
+	})
+}
+
+func TestRefundConvert(t *testing.T) {
+	order := refundableOrder{Total: 3000, ConvertedTotal: 2700}
+	assert.Equal(t, int64(900), order.convert(1000))
+	assert.Equal(t, int64(1), order.convert(1))
+}
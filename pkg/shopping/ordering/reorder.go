@@ -0,0 +1,156 @@
+package ordering
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Reasons reported for the products of a past order that weren't added as they were bought
+const (
+	// ReorderDeleted is reported when the product or its variant no longer exists
+	ReorderDeleted = "deleted"
+	// ReorderOutOfStock is reported when the units bought can't be reserved
+	ReorderOutOfStock = "out_of_stock"
+	// ReorderVariantRequired is reported when the product started offering variants after it was bought
+	ReorderVariantRequired = "variant_required"
+	// ReorderPriceChanged is reported when the product was added with a total different from the one paid
+	ReorderPriceChanged = "price_changed"
+)
+
+// ReorderLine is a product of a past order placed again in the cart.
+//
+// Previous and Current are the unit totals paid and charged now, only set when the price changed.
+type ReorderLine struct {
+	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
+	Quantity  int64  `json:"quantity"`
+	Reason    string `json:"reason,omitempty"`
+	Previous  int64  `json:"previous,omitempty"`
+	Current   int64  `json:"current,omitempty"`
+}
+
+// Reorder is the summary of repopulating the cart with the products of a past order.
+type Reorder struct {
+	CartID string `json:"cart_id"`
+	// Added contains the products placed in the cart, the ones with a different price
+	// are reported with the ReorderPriceChanged reason
+	Added []ReorderLine `json:"added"`
+	// Skipped contains the products that couldn't be placed in the cart and why
+	Skipped []ReorderLine `json:"skipped"`
+}
+
+// orderLine contains the values of a product when it was ordered and the current ones.
+type orderLine struct {
+	ProductID    string   `db:"product_id"`
+	VariantID    string   `db:"variant_id"`
+	Quantity     int64    `db:"quantity"`
+	Total        int64    `db:"total"`
+	CurrentTotal zero.Int `db:"current_total"`
+	Found        bool     `db:"found"`
+}
+
+// Reorder adds the products of a past order of the user to the cart.
+//
+// Products that can't be bought anymore are skipped and reported along with the reason,
+// the rest are added with their current price.
+func (s *service) Reorder(ctx context.Context, orderID, userID, cartID string, cartService cart.Service) (Reorder, error) {
+	s.metrics.incMethodCalls("Reorder")
+
+	var owner string
+	if err := s.db.GetContext(ctx, &owner, "SELECT user_id FROM orders WHERE id=$1", orderID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Reorder{}, ErrNotFound
+		}
+		return Reorder{}, errors.Wrap(err, "couldn't find the order")
+	}
+	if owner != userID {
+		return Reorder{}, ErrNotFound
+	}
+
+	q := `SELECT op.product_id, COALESCE(op.variant_id, '') AS variant_id,
+	COALESCE(op.quantity, 0) AS quantity, COALESCE(op.total, 0) AS total,
+	COALESCE(v.total, p.total) AS current_total,
+	(p.id IS NOT NULL AND (COALESCE(op.variant_id, '')='' OR v.id IS NOT NULL)) AS found
+	FROM order_products AS op
+	LEFT JOIN products AS p ON p.id=op.product_id
+	LEFT JOIN product_variants AS v ON v.id=op.variant_id AND v.product_id=op.product_id
+	WHERE op.order_id=$1
+	ORDER BY op.product_id, op.variant_id`
+	var lines []orderLine
+	if err := s.db.SelectContext(ctx, &lines, q, orderID); err != nil {
+		return Reorder{}, errors.Wrap(err, "couldn't find the order products")
+	}
+
+	summary := Reorder{CartID: cartID, Added: []ReorderLine{}, Skipped: []ReorderLine{}}
+	for _, l := range lines {
+		if l.Quantity <= 0 {
+			continue
+		}
+		if !l.Found {
+			summary.Skipped = append(summary.Skipped, l.reorderLine(ReorderDeleted))
+			continue
+		}
+
+		product := cart.Product{
+			ID:        zero.StringFrom(l.ProductID),
+			VariantID: zero.StringFrom(l.VariantID),
+			CartID:    zero.StringFrom(cartID),
+			Quantity:  zero.IntFrom(l.Quantity),
+		}
+		if err := cartService.Add(ctx, product); err != nil {
+			reason, ok := reorderReason(err)
+			if !ok {
+				return Reorder{}, err
+			}
+			summary.Skipped = append(summary.Skipped, l.reorderLine(reason))
+			continue
+		}
+
+		summary.Added = append(summary.Added, l.added())
+	}
+
+	return summary, nil
+}
+
+// added returns the line placed in the cart, reporting the price change if there was one.
+func (l orderLine) added() ReorderLine {
+	if !l.CurrentTotal.Valid || l.CurrentTotal.Int64 == l.Total {
+		return l.reorderLine("")
+	}
+
+	line := l.reorderLine(ReorderPriceChanged)
+	line.Previous = l.Total
+	line.Current = l.CurrentTotal.Int64
+	return line
+}
+
+func (l orderLine) reorderLine(reason string) ReorderLine {
+	return ReorderLine{
+		ProductID: l.ProductID,
+		VariantID: l.VariantID,
+		Quantity:  l.Quantity,
+		Reason:    reason,
+	}
+}
+
+// reorderReason returns the reason why a product couldn't be added to the cart, false
+// if the error isn't caused by the product availability.
+func reorderReason(err error) (string, bool) {
+	switch {
+	case errors.Is(err, inventory.ErrOutOfStock):
+		return ReorderOutOfStock, true
+	case errors.Is(err, cart.ErrVariantRequired):
+		return ReorderVariantRequired, true
+	case errors.Is(err, sql.ErrNoRows):
+		// Deleted after looking for the order products
+		return ReorderDeleted, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,46 @@
+package ordering
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestReorderAdded(t *testing.T) {
+	line := orderLine{ProductID: "product", VariantID: "variant", Quantity: 2, Total: 1000,
+		CurrentTotal: zero.IntFrom(1000), Found: true}
+	assert.Equal(t, ReorderLine{ProductID: "product", VariantID: "variant", Quantity: 2}, line.added())
+
+	line.CurrentTotal = zero.IntFrom(1200)
+	expected := ReorderLine{ProductID: "product", VariantID: "variant", Quantity: 2,
+		Reason: ReorderPriceChanged, Previous: 1000, Current: 1200}
+	assert.Equal(t, expected, line.added())
+}
+
+func TestReorderReason(t *testing.T) {
+	cases := []struct {
+		desc     string
+		err      error
+		expected string
+		ok       bool
+	}{
+		{desc: "Out of stock", err: errors.Wrap(inventory.ErrOutOfStock, "product"), expected: ReorderOutOfStock, ok: true},
+		{desc: "Variant required", err: errors.Wrap(cart.ErrVariantRequired, "product"), expected: ReorderVariantRequired, ok: true},
+		{desc: "Deleted", err: errors.Wrap(sql.ErrNoRows, "couldn't find product"), expected: ReorderDeleted, ok: true},
+		{desc: "Unexpected", err: errors.New("connection refused"), ok: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			reason, ok := reorderReason(tc.err)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, reason)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package ordering
+
+import (
+	"time"
+
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/pkg/postgres"
+)
+
+// addressSearch is the document the orders address is searched in, it must match
+// the expression of the index created on the orders table.
+const addressSearch = `to_tsvector('simple', COALESCE(address, '') || ' ' || COALESCE(city, '') || ' ' ||
+COALESCE(state, '') || ' ' || COALESCE(zip_code, '') || ' ' || COALESCE(country, ''))`
+
+// sortColumns contains the expressions of the fields the orders can be sorted by, orders
+// without the date are placed by their creation date.
+var sortColumns = map[string]string{
+	"created_at":    "created_at",
+	"ordered_at":    "COALESCE(ordered_at, created_at)",
+	"delivery_date": "COALESCE(delivery_date, created_at)",
+}
+
+// getQuery returns the query and arguments used to list the orders matching the filters
+// and search of the params.
+func getQuery(params params.Query) (string, []interface{}, error) {
+	var conds postgres.Conditions
+	f := params.Filters
+
+	statuses, err := parseStatuses(f.Strings("status"))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(statuses) > 0 {
+		conds.Add("COALESCE(status, 0)=ANY(?)", statusArray(statuses))
+	}
+	if from := f.Time("from"); !from.IsZero() {
+		conds.Add("ordered_at >= ?", from)
+	}
+	if to := f.Time("to"); !to.IsZero() {
+		conds.Add("ordered_at < ?", to)
+	}
+	if userID := f.String("user_id"); userID != "" {
+		conds.Add("user_id=?", userID)
+	}
+	if email := f.String("email"); email != "" {
+		conds.Add("user_id IN (SELECT id FROM users WHERE LOWER(email)=LOWER(?))", email)
+	}
+	if shopID := f.String("shop_id"); shopID != "" {
+		// Orders placed before splitting them by shop don't have the shop saved in their products
+		conds.Add(`id IN (SELECT op.order_id FROM order_products AS op
+		LEFT JOIN products AS pr ON pr.id=op.product_id WHERE COALESCE(op.shop_id, pr.shop_id)=?)`, shopID)
+	}
+	if min, ok := f.Int("min_total"); ok {
+		conds.Add("id IN (SELECT order_id FROM order_carts WHERE total >= ?)", min)
+	}
+	if max, ok := f.Int("max_total"); ok {
+		conds.Add("id IN (SELECT order_id FROM order_carts WHERE total <= ?)", max)
+	}
+	if country := f.String("country"); country != "" {
+		conds.Add("LOWER(country)=LOWER(?)", country)
+	}
+	if params.Search != "" {
+		conds.Add(addressSearch+" @@ plainto_tsquery('simple', ?)", params.Search)
+	}
+
+	q, args := postgres.AddFilteredPagination("SELECT * FROM orders", conds, params, sortColumns[params.Sort.Field])
+	return q, args, nil
+}
+
+// cursorTime returns the value of the field the orders are sorted by used as the cursor.
+func cursorTime(order Order, field string) time.Time {
+	switch field {
+	case "ordered_at":
+		if order.OrderedAt.Valid {
+			return order.OrderedAt.Time
+		}
+	case "delivery_date":
+		if order.DeliveryDate.Valid {
+			return order.DeliveryDate.Time
+		}
+	}
+	return order.CreatedAt.Time
+}
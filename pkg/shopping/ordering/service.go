@@ -2,13 +2,21 @@ package ordering
 
 import (
 	"context"
+	"database/sql"
 	"strconv"
 	"time"
 
 	"github.com/GGP1/adak/internal/params"
-	"github.com/GGP1/adak/pkg/postgres"
 	"github.com/GGP1/adak/pkg/product"
 	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/currency"
+	"github.com/GGP1/adak/pkg/shopping/delivery"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/payment"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
+	"github.com/GGP1/adak/pkg/shopping/shipment"
+	"github.com/GGP1/adak/pkg/shopping/shipping"
+	"github.com/GGP1/adak/pkg/shopping/tax"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/jmoiron/sqlx"
@@ -18,24 +26,45 @@ import (
 
 // Service contains order functionalities.
 type Service interface {
+	ApplyPaymentEvent(ctx context.Context, event payment.Event) (string, bool, error)
+	Cancel(ctx context.Context, orderID, userID string, refund RefundFunc) error
+	Fulfill(ctx context.Context, tx *sqlx.Tx, orderID string, f shipment.Fulfillment,
+		shops map[string]shipment.Fulfillment, changedBy string) error
 	New(ctx context.Context, id, userID string, cartID string, oParams OrderParams, cartService cart.Service) (Order, error)
 	Delete(ctx context.Context, orderID string) error
+	Export(ctx context.Context, filter ExportFilter, fn func(ExportLine) error) error
 	Get(ctx context.Context, params params.Query) ([]Order, error)
 	GetByID(ctx context.Context, orderID string) (Order, error)
 	GetByUserID(ctx context.Context, userID string) ([]Order, error)
 	GetCartByID(ctx context.Context, orderID string) (OrderCart, error)
 	GetProductsByID(ctx context.Context, orderID string) ([]OrderProduct, error)
-	UpdateStatus(ctx context.Context, orderID string, status status) error
+	GetByShopID(ctx context.Context, shopID string, params params.Query) ([]ShopOrder, error)
+	GetShopOrders(ctx context.Context, orderID string) ([]ShopOrder, error)
+	History(ctx context.Context, orderID string) ([]StatusChange, error)
+	Refund(ctx context.Context, orderID, createdBy string, lines []RefundLine, refund RefundFunc) (Refund, error)
+	RefundReturn(ctx context.Context, tx *sqlx.Tx, orderID, createdBy string,
+		lines []RefundLine, restock bool, refund RefundFunc) (Refund, error)
+	Refunds(ctx context.Context, orderID string) ([]Refund, error)
+	Reorder(ctx context.Context, orderID, userID, cartID string, cartService cart.Service) (Reorder, error)
+	SetPaymentIntent(ctx context.Context, orderID, intentID string) error
+	UpdateStatus(ctx context.Context, orderID string, to status, changedBy string) error
 }
 
 type service struct {
-	db      *sqlx.DB
-	metrics metrics
+	db         *sqlx.DB
+	inventory  inventory.Service
+	promotions promotion.Service
+	currencies currency.Service
+	taxes      tax.Service
+	shipping   shipping.Service
+	delivery   delivery.Service
+	metrics    metrics
 }
 
 // NewService returns a new ordering service.
-func NewService(db *sqlx.DB) Service {
-	return &service{db, initMetrics()}
+func NewService(db *sqlx.DB, inventory inventory.Service, promotions promotion.Service,
+	currencies currency.Service, taxes tax.Service, shipping shipping.Service, delivery delivery.Service) Service {
+	return &service{db, inventory, promotions, currencies, taxes, shipping, delivery, initMetrics()}
 }
 
 // New creates an order.
@@ -52,11 +81,19 @@ func (s *service) New(ctx context.Context, id, userID, cartID string,
 		return Order{}, errors.New("ordering zero products is not permitted")
 	}
 
-	// Format delivery date
-	deliveryDate := time.Date(oParams.Date.Year, time.Month(oParams.Date.Month), oParams.Date.Day,
-		oParams.Date.Hour, oParams.Date.Minutes, 0, 0, time.Local)
-	if deliveryDate.Before(time.Now()) {
-		return Order{}, errors.New("past dates are not valid")
+	// Format delivery date, the slots booked replace it
+	var deliveryDate time.Time
+	if oParams.Date != nil {
+		deliveryDate = time.Date(oParams.Date.Year, time.Month(oParams.Date.Month), oParams.Date.Day,
+			oParams.Date.Hour, oParams.Date.Minutes, 0, 0, time.Local)
+		if deliveryDate.Before(time.Now()) {
+			return Order{}, errors.New("past dates are not valid")
+		}
+	}
+
+	// Fail before saving anything if the currency requested is not supported
+	if _, err := s.currencies.Convert(ctx, 0, oParams.Currency); err != nil {
+		return Order{}, err
 	}
 
 	tx, err := s.db.Beginx()
@@ -65,11 +102,20 @@ func (s *service) New(ctx context.Context, id, userID, cartID string,
 	}
 	defer tx.Rollback()
 
+	if err := s.detectChanges(ctx, tx, cartID, oParams.AcceptChanges); err != nil {
+		return Order{}, err
+	}
+
+	// Apply the accepted changes to the cart so the promotions use the current values
+	if err := cartService.Refresh(ctx, tx, cartID); err != nil {
+		return Order{}, err
+	}
+
 	orderQ := `INSERT INTO orders
 	(id, user_id, currency, address, city, country, state, zip_code, 
 	status, ordered_at, delivery_date, cart_id)
 	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
-	_, err = tx.ExecContext(ctx, orderQ, id, userID, oParams.Currency,
+	_, err = tx.ExecContext(ctx, orderQ, id, userID, currency.Normalize(oParams.Currency),
 		oParams.Address, oParams.City, oParams.Country, oParams.State, oParams.ZipCode,
 		zero.IntFrom(int64(Pending)), zero.TimeFrom(time.Now()),
 		zero.TimeFrom(deliveryDate), cart.ID)
@@ -77,11 +123,97 @@ func (s *service) New(ctx context.Context, id, userID, cartID string,
 		return Order{}, errors.Wrap(err, "couldn't create the order")
 	}
 
-	if err := s.saveOrderCart(ctx, tx, id, cart); err != nil {
+	if err := saveStatusChange(ctx, tx, id, "", Pending, userID); err != nil {
+		return Order{}, err
+	}
+
+	productIDs := make([]string, len(cart.Products))
+	for i, p := range cart.Products {
+		productIDs[i] = p.ID.String
+	}
+	slotDate, err := s.delivery.Book(ctx, tx, id, productIDs, oParams.Country, oParams.Slots)
+	if err != nil {
+		return Order{}, err
+	}
+	if !slotDate.IsZero() {
+		deliveryDate = slotDate
+	}
+	if deliveryDate.IsZero() {
+		return Order{}, ErrDateRequired
+	}
+
+	promotions, err := s.promotions.Redeem(ctx, tx, cartID, userID, id)
+	if err != nil {
 		return Order{}, err
 	}
 
-	if err := s.saveOrderProducts(ctx, tx, id, cart.Products); err != nil {
+	products, err := orderProducts(ctx, tx, id, cart.Products)
+	if err != nil {
+		return Order{}, err
+	}
+
+	orderCart := sumProducts(products)
+	subtotal := promotions.Subtotal(orderCart.Total.Int64)
+	// Taxes are paid on the amount charged, after the promotions
+	taxes, exclusiveTaxes, err := s.applyTaxes(ctx, oParams, products, orderCart.Total.Int64-subtotal)
+	if err != nil {
+		return Order{}, err
+	}
+
+	quote, err := s.shipping.Choose(ctx, oParams.ShippingMethod, oParams.Country,
+		orderCart.Weight.Int64, subtotal)
+	if err != nil {
+		return Order{}, err
+	}
+	if promotions.FreeShipping {
+		quote.Cost = 0
+	}
+
+	// Inclusive taxes are already part of the products total
+	total := subtotal + exclusiveTaxes + quote.Cost
+	conversion, err := s.currencies.Convert(ctx, total, oParams.Currency)
+	if err != nil {
+		return Order{}, err
+	}
+
+	// Freeze the rate and shipping cost so later updates don't change the order amounts
+	amountsQ := `UPDATE orders SET base_currency=$2, exchange_rate=$3,
+	shipping_method_id=$4, shipping_method=$5, shipping_cost=$6, delivery_date=$7
+	WHERE id=$1`
+	_, err = tx.ExecContext(ctx, amountsQ, id, s.currencies.Base(), conversion.Rate,
+		quote.MethodID, quote.Name, quote.Cost, zero.TimeFrom(deliveryDate))
+	if err != nil {
+		return Order{}, errors.Wrap(err, "couldn't save the order amounts")
+	}
+
+	orderCart.OrderID = zero.StringFrom(id)
+	orderCart.Discount = zero.IntFrom(orderCart.Discount.Int64 + promotions.Discount)
+	orderCart.Taxes = zero.IntFrom(taxes)
+	orderCart.Total = zero.IntFrom(total)
+	orderCart.PromotionCodes = promotions.Codes
+	orderCart.ConvertedTotal = zero.IntFrom(conversion.Amount)
+	if err := s.saveOrderCart(ctx, tx, orderCart); err != nil {
+		return Order{}, err
+	}
+
+	if err := s.saveOrderProducts(ctx, tx, products); err != nil {
+		return Order{}, err
+	}
+
+	shopOrders := splitShops(id, products, quote.Cost, promotions.Discount)
+	if err := saveShopOrders(ctx, tx, shopOrders); err != nil {
+		return Order{}, err
+	}
+
+	items := make([]inventory.Item, len(cart.Products))
+	for i, p := range cart.Products {
+		items[i] = inventory.Item{
+			ProductID: p.ID.String,
+			VariantID: p.VariantID.String,
+			Quantity:  p.Quantity.Int64,
+		}
+	}
+	if err := s.inventory.Commit(ctx, tx, cartID, items); err != nil {
 		return Order{}, err
 	}
 
@@ -90,26 +222,26 @@ func (s *service) New(ctx context.Context, id, userID, cartID string,
 	}
 
 	order := Order{
-		ID:           zero.StringFrom(id),
-		UserID:       zero.StringFrom(userID),
-		Currency:     zero.StringFrom(oParams.Currency),
-		Address:      zero.StringFrom(oParams.Address),
-		City:         zero.StringFrom(oParams.City),
-		State:        zero.StringFrom(oParams.State),
-		ZipCode:      zero.StringFrom(oParams.ZipCode),
-		Status:       zero.IntFrom(int64(Pending)),
-		OrderedAt:    zero.TimeFrom(time.Now()),
-		DeliveryDate: zero.TimeFrom(deliveryDate),
-		CartID:       zero.StringFrom(cart.ID),
-		Cart: OrderCart{
-			OrderID:  zero.StringFrom(id),
-			Counter:  cart.Counter,
-			Weight:   cart.Weight,
-			Discount: cart.Discount,
-			Taxes:    cart.Taxes,
-			Subtotal: cart.Subtotal,
-			Total:    cart.Total,
-		},
+		ID:               zero.StringFrom(id),
+		UserID:           zero.StringFrom(userID),
+		Currency:         zero.StringFrom(conversion.Currency),
+		Address:          zero.StringFrom(oParams.Address),
+		City:             zero.StringFrom(oParams.City),
+		Country:          zero.StringFrom(oParams.Country),
+		State:            zero.StringFrom(oParams.State),
+		ZipCode:          zero.StringFrom(oParams.ZipCode),
+		Status:           zero.IntFrom(int64(Pending)),
+		OrderedAt:        zero.TimeFrom(time.Now()),
+		DeliveryDate:     zero.TimeFrom(deliveryDate),
+		CartID:           zero.StringFrom(cart.ID),
+		Cart:             orderCart,
+		Products:         products,
+		BaseCurrency:     zero.StringFrom(s.currencies.Base()),
+		ExchangeRate:     zero.StringFrom(conversion.Rate),
+		ShippingMethodID: zero.StringFrom(quote.MethodID),
+		ShippingMethod:   zero.StringFrom(quote.Name),
+		ShippingCost:     zero.IntFrom(quote.Cost),
+		ShopOrders:       shopOrders,
 	}
 
 	s.metrics.totalOrders.With(prometheus.Labels{"status": strconv.FormatInt(int64(Pending), 10)}).Inc()
@@ -130,8 +262,12 @@ func (s *service) Delete(ctx context.Context, orderID string) error {
 func (s *service) Get(ctx context.Context, params params.Query) ([]Order, error) {
 	s.metrics.incMethodCalls("Get")
 
+	q, args, err := getQuery(params)
+	if err != nil {
+		return nil, err
+	}
+
 	var orders []Order
-	q, args := postgres.AddPagination("SELECT * FROM orders", params)
 	if err := s.db.SelectContext(ctx, &orders, q, args...); err != nil {
 		return nil, errors.Wrap(err, "couldn't find the orders")
 	}
@@ -160,11 +296,15 @@ func (s *service) GetByID(ctx context.Context, orderID string) (Order, error) {
 		p := OrderProduct{}
 		err := rows.Scan(
 			&order.ID, &order.UserID, &order.Currency, &order.Address, &order.City,
-			&order.State, &order.ZipCode, &order.Country, &order.Status, &order.OrderedAt,
-			&order.DeliveryDate, &order.CartID,
+			&order.State, &order.ZipCode, &order.Country, &order.Status, &order.CartID,
+			&order.CreatedAt, &order.OrderedAt, &order.DeliveryDate, &order.BaseCurrency, &order.ExchangeRate,
+			&order.ShippingMethodID, &order.ShippingMethod, &order.ShippingCost,
+			&order.PaymentIntentID, &order.Refunded,
 			&c.OrderID, &c.Counter, &c.Weight, &c.Discount, &c.Taxes, &c.Subtotal, &c.Total,
+			&c.PromotionCodes, &c.ConvertedTotal,
 			&p.ProductID, &p.OrderID, &p.Quantity, &p.Brand, &p.Category, &p.Type, &p.Description,
 			&p.Weight, &p.Discount, &p.Taxes, &p.Subtotal, &p.Total,
+			&p.VariantID, &p.SKU, &p.Options, &p.TaxLines, &p.Refunded, &p.ShopID,
 		)
 		if err != nil {
 			return Order{}, errors.Wrap(err, "couldn't scan order")
@@ -199,11 +339,15 @@ func (s *service) GetByUserID(ctx context.Context, userID string) ([]Order, erro
 		p := OrderProduct{}
 		err := rows.Scan(
 			&o.ID, &o.UserID, &o.Currency, &o.Address, &o.City,
-			&o.State, &o.ZipCode, &o.Country, &o.Status, &o.OrderedAt,
-			&o.DeliveryDate, &o.CartID,
+			&o.State, &o.ZipCode, &o.Country, &o.Status, &o.CartID,
+			&o.CreatedAt, &o.OrderedAt, &o.DeliveryDate, &o.BaseCurrency, &o.ExchangeRate,
+			&o.ShippingMethodID, &o.ShippingMethod, &o.ShippingCost,
+			&o.PaymentIntentID, &o.Refunded,
 			&c.OrderID, &c.Counter, &c.Weight, &c.Discount, &c.Taxes, &c.Subtotal, &c.Total,
+			&c.PromotionCodes, &c.ConvertedTotal,
 			&p.ProductID, &p.OrderID, &p.Quantity, &p.Brand, &p.Category, &p.Type,
 			&p.Description, &p.Weight, &p.Discount, &p.Taxes, &p.Subtotal, &p.Total,
+			&p.VariantID, &p.SKU, &p.Options, &p.TaxLines, &p.Refunded, &p.ShopID,
 		)
 		if err != nil {
 			return nil, errors.Wrap(err, "couldn't scan order")
@@ -241,25 +385,146 @@ func (s *service) GetProductsByID(ctx context.Context, orderID string) ([]OrderP
 	return products, nil
 }
 
-// UpdateStatus updates the order status.
-func (s *service) UpdateStatus(ctx context.Context, orderID string, status status) error {
+// Fulfill moves the order and its shop orders to the statuses that correspond to the progress
+// of their shipments.
+func (s *service) Fulfill(ctx context.Context, tx *sqlx.Tx, orderID string, f shipment.Fulfillment,
+	shops map[string]shipment.Fulfillment, changedBy string) error {
+	s.metrics.incMethodCalls("Fulfill")
+
+	to, ok := fulfillmentStatus(f)
+	if !ok {
+		return nil
+	}
+
+	var from status
+	if err := tx.GetContext(ctx, &from, "SELECT COALESCE(status, 0) FROM orders WHERE id=$1", orderID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return errors.Wrap(err, "couldn't find the order")
+	}
+	if from != to {
+		if err := s.transition(ctx, tx, orderID, to, changedBy); err != nil {
+			if errors.Is(err, ErrInvalidTransition) {
+				return errors.Wrapf(shipment.ErrNotShippable, "%s -> %s", from, to)
+			}
+			return err
+		}
+	}
+
+	return s.fulfillShops(ctx, tx, orderID, shops)
+}
+
+// History returns the status changes of an order, from the oldest to the newest.
+func (s *service) History(ctx context.Context, orderID string) ([]StatusChange, error) {
+	s.metrics.incMethodCalls("History")
+
+	var history []StatusChange
+	q := "SELECT * FROM order_status_history WHERE order_id=$1 ORDER BY changed_at"
+	if err := s.db.SelectContext(ctx, &history, q, orderID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the order status history")
+	}
+
+	return history, nil
+}
+
+// SetPaymentIntent saves the id of the payment intent that charged the order.
+func (s *service) SetPaymentIntent(ctx context.Context, orderID, intentID string) error {
+	s.metrics.incMethodCalls("SetPaymentIntent")
+
+	q := "UPDATE orders SET payment_intent_id=$2 WHERE id=$1"
+	if _, err := s.db.ExecContext(ctx, q, orderID, intentID); err != nil {
+		return errors.Wrap(err, "couldn't save the payment intent")
+	}
+
+	return nil
+}
+
+// UpdateStatus moves the order to a new status and records who changed it.
+//
+// ErrInvalidTransition is returned if the order lifecycle doesn't allow the change.
+func (s *service) UpdateStatus(ctx context.Context, orderID string, to status, changedBy string) error {
 	s.metrics.incMethodCalls("UpdateStatus")
-	s.metrics.totalOrders.With(prometheus.Labels{"status": strconv.FormatInt(int64(status), 10)}).Inc()
-	_, err := s.db.ExecContext(ctx, "UPDATE orders SET status=$2 WHERE id=$1", orderID, status)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if err := s.transition(ctx, tx, orderID, to, changedBy); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	return nil
+}
+
+// transition updates the order status inside the transaction provided.
+func (s *service) transition(ctx context.Context, tx *sqlx.Tx, orderID string, to status, changedBy string) error {
+	var from status
+	// The status of pending orders may be NULL
+	q := "SELECT COALESCE(status, 0) FROM orders WHERE id=$1 FOR UPDATE"
+	if err := tx.GetContext(ctx, &from, q, orderID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return errors.Wrap(err, "couldn't find the order")
+	}
+
+	if !canTransition(from, to) {
+		return errors.Wrapf(ErrInvalidTransition, "%s -> %s", from, to)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE orders SET status=$2 WHERE id=$1", orderID, to); err != nil {
 		return errors.Wrap(err, "couldn't update the order status")
 	}
 
+	if err := saveStatusChange(ctx, tx, orderID, from.String(), to, changedBy); err != nil {
+		return err
+	}
+
+	if err := propagateStatus(ctx, tx, orderID, to); err != nil {
+		return err
+	}
+
+	// Cancelled and failed orders won't be handed over, let others book their slots
+	if to == Cancelled || to == Failed {
+		if err := s.delivery.Release(ctx, tx, orderID); err != nil {
+			return err
+		}
+	}
+	// Failed orders keep their promotions as the payment can be retried
+	if to == Cancelled {
+		if err := s.promotions.Release(ctx, tx, orderID); err != nil {
+			return err
+		}
+	}
+
+	s.metrics.totalOrders.With(prometheus.Labels{"status": strconv.FormatInt(int64(to), 10)}).Inc()
+	return nil
+}
+
+// saveStatusChange adds a record to the order status history.
+func saveStatusChange(ctx context.Context, tx *sqlx.Tx, orderID, from string, to status, changedBy string) error {
+	q := `INSERT INTO order_status_history (order_id, from_status, to_status, changed_by, changed_at)
+	VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.ExecContext(ctx, q, orderID, from, to.String(), changedBy, time.Now()); err != nil {
+		return errors.Wrap(err, "couldn't save the status change")
+	}
 	return nil
 }
 
 // saveOrderCart saves the current user cart to the database.
-func (s *service) saveOrderCart(ctx context.Context, tx *sqlx.Tx, id string, cart cart.Cart) error {
+func (s *service) saveOrderCart(ctx context.Context, tx *sqlx.Tx, cart OrderCart) error {
 	q := `INSERT INTO order_carts
-	(order_id, counter, weight, discount, taxes, subtotal, total)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := tx.ExecContext(ctx, q, id, cart.Counter, cart.Weight,
-		cart.Discount, cart.Taxes, cart.Subtotal, cart.Total)
+	(order_id, counter, weight, discount, taxes, subtotal, total, promotion_codes, converted_total)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := tx.ExecContext(ctx, q, cart.OrderID, cart.Counter, cart.Weight,
+		cart.Discount, cart.Taxes, cart.Subtotal, cart.Total, cart.PromotionCodes, cart.ConvertedTotal)
 	if err != nil {
 		return errors.Wrap(err, "couldn't save the order cart")
 	}
@@ -267,19 +532,80 @@ func (s *service) saveOrderCart(ctx context.Context, tx *sqlx.Tx, id string, car
 	return nil
 }
 
-// saveOrderProducts saves cart products to the database using batch insert.
-func (s *service) saveOrderProducts(ctx context.Context, tx *sqlx.Tx, id string, cartProducts []cart.Product) error {
+// applyTaxes calculates the taxes of each product depending on the shipping address and
+// returns the sum of all of them and of the ones not included in the products price.
+func (s *service) applyTaxes(ctx context.Context, oParams OrderParams, products []OrderProduct, discount int64) (int64, int64, error) {
+	address := tax.Address{
+		Country: oParams.Country,
+		State:   oParams.State,
+		ZipCode: oParams.ZipCode,
+	}
+	lines := taxLines(products, discount)
+
+	breakdowns, err := s.taxes.Calculate(ctx, address, lines)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var total, exclusive int64
+	for i, b := range breakdowns {
+		products[i].Taxes = zero.IntFrom(b.Total())
+		products[i].TaxLines = b
+		total += b.Total()
+		exclusive += b.Exclusive()
+	}
+
+	return total, exclusive, nil
+}
+
+// taxLines returns the amount taxed of each product, the promotions discount is split
+// among them in proportion to their totals.
+func taxLines(products []OrderProduct, discount int64) []tax.Line {
+	amounts := make([]int64, len(products))
+	for i, p := range products {
+		amounts[i] = p.Total.Int64 * p.Quantity.Int64
+	}
+
+	shares := Allocate(discount, amounts)
+	lines := make([]tax.Line, len(products))
+	for i, p := range products {
+		amount := amounts[i] - shares[i]
+		// The rounding remainder may exceed the last amount
+		if amount < 0 {
+			amount = 0
+		}
+		lines[i] = tax.Line{Category: p.Category.String, Amount: amount}
+	}
+	return lines
+}
+
+// orderProducts returns the cart products with the values they have at the moment of ordering.
+func orderProducts(ctx context.Context, tx *sqlx.Tx, id string, cartProducts []cart.Product) ([]OrderProduct, error) {
 	stmt, err := tx.PreparexContext(ctx, "SELECT * FROM products WHERE id=$1")
 	if err != nil {
-		return errors.Wrap(err, "preparing statement")
+		return nil, errors.Wrap(err, "preparing statement")
 	}
 	defer stmt.Close()
 
+	variantStmt, err := tx.PreparexContext(ctx, "SELECT * FROM product_variants WHERE id=$1 AND product_id=$2")
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing statement")
+	}
+	defer variantStmt.Close()
+
 	orderProducts := make([]OrderProduct, len(cartProducts))
 	for i, cp := range cartProducts {
 		var p product.Product
 		if err := stmt.GetContext(ctx, &p, cp.ID); err != nil {
-			return errors.Wrap(err, "couldn't find product")
+			return nil, errors.Wrap(err, "couldn't find product")
+		}
+
+		var v product.Variant
+		if cp.VariantID.String != "" {
+			if err := variantStmt.GetContext(ctx, &v, cp.VariantID, cp.ID); err != nil {
+				return nil, errors.Wrap(err, "couldn't find the variant")
+			}
+			p = p.WithVariant(v)
 		}
 
 		orderProducts[i] = OrderProduct{
@@ -289,20 +615,50 @@ func (s *service) saveOrderProducts(ctx context.Context, tx *sqlx.Tx, id string,
 			Brand:       p.Brand,
 			Category:    p.Category,
 			Description: p.Description,
+			Weight:      p.Weight,
 			Discount:    p.Discount,
-			Taxes:       p.Taxes,
 			Type:        p.Type,
 			Subtotal:    p.Subtotal,
 			Total:       p.Total,
+			VariantID:   v.ID,
+			SKU:         v.SKU,
+			Options:     v.Options,
+			ShopID:      p.ShopID,
 		}
 	}
 
+	return orderProducts, nil
+}
+
+// sumProducts returns a cart with the totals of the products provided.
+func sumProducts(products []OrderProduct) OrderCart {
+	var counter, weight, discount, subtotal, total int64
+	for _, p := range products {
+		quantity := p.Quantity.Int64
+		counter += quantity
+		weight += quantity * p.Weight.Int64
+		discount += quantity * p.Discount.Int64
+		subtotal += quantity * p.Subtotal.Int64
+		total += quantity * p.Total.Int64
+	}
+
+	return OrderCart{
+		Counter:  zero.IntFrom(counter),
+		Weight:   zero.IntFrom(weight),
+		Discount: zero.IntFrom(discount),
+		Subtotal: zero.IntFrom(subtotal),
+		Total:    zero.IntFrom(total),
+	}
+}
+
+// saveOrderProducts saves the order products to the database using batch insert.
+func (s *service) saveOrderProducts(ctx context.Context, tx *sqlx.Tx, orderProducts []OrderProduct) error {
 	q := `INSERT INTO order_products
 	(order_id, product_id, quantity, brand, category, type, description, weight, 
-	discount, taxes, subtotal, total)
+	discount, taxes, subtotal, total, variant_id, sku, options, tax_lines, shop_id)
 	VALUES 
 	(:order_id, :product_id, :quantity, :brand, :category, :type, :description, 
-	:weight, :discount, :taxes, :subtotal, :total)`
+	:weight, :discount, :taxes, :subtotal, :total, :variant_id, :sku, :options, :tax_lines, :shop_id)`
 	if _, err := tx.NamedExecContext(ctx, q, orderProducts); err != nil {
 		return errors.Wrap(err, "couldn't save order products")
 	}
@@ -4,12 +4,21 @@ import (
 	"context"
 	"testing"
 
+	"github.com/GGP1/adak/internal/config"
 	"github.com/GGP1/adak/internal/logger"
 	"github.com/GGP1/adak/internal/params"
 	"github.com/GGP1/adak/internal/test"
 	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/currency"
+	"github.com/GGP1/adak/pkg/shopping/delivery"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
 	"github.com/GGP1/adak/pkg/shopping/ordering"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
+	"github.com/GGP1/adak/pkg/shopping/shipping"
+	"github.com/GGP1/adak/pkg/shopping/tax"
 	"github.com/GGP1/adak/pkg/user"
+
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/guregu/null.v4/zero"
 )
@@ -26,10 +35,14 @@ func NewOrderingService(t *testing.T) (context.Context, ordering.Service, cart.S
 	ctx, cancel := context.WithCancel(context.Background())
 
 	db := test.StartPostgres(t)
-	service := ordering.NewService(db)
+	inventoryService := inventory.NewService(config.Inventory{})
+	promotionService := promotion.NewService(db)
+	currencyService := currency.NewService(db, config.Currency{})
+	service := ordering.NewService(db, inventoryService, promotionService, currencyService,
+		tax.NewService(db), shipping.NewService(db), delivery.NewService(db))
 
 	mc := test.StartMemcached(t)
-	cartService := cart.NewService(db, mc)
+	cartService := cart.NewService(db, mc, config.Cart{}, inventoryService, promotionService)
 	err := cartService.Create(ctx, cartID)
 	assert.NoError(t, err)
 	userService := user.NewService(db, mc)
@@ -53,6 +66,7 @@ func TestOrderingService(t *testing.T) {
 	t.Run("Get cart by ID", getCartByID(ctx, s))
 	t.Run("Get products by ID", getProductsByID(ctx, s))
 	t.Run("Update status", updateStatus(ctx, s))
+	t.Run("Refund", refund(ctx, s))
 	t.Run("Delete", delete(ctx, s))
 }
 
@@ -63,7 +77,8 @@ func new(ctx context.Context, s ordering.Service, cartService cart.Service) func
 		assert.NoError(t, err)
 
 		params := ordering.OrderParams{
-			Date: ordering.Date{
+			Currency: "USD",
+			Date: &ordering.Date{
 				Year:    2150,
 				Month:   8,
 				Day:     14,
@@ -71,8 +86,17 @@ func new(ctx context.Context, s ordering.Service, cartService cart.Service) func
 				Minutes: 0,
 			},
 		}
-		_, err = s.New(ctx, orderID, userID, cartID, params, cartService)
+		order, err := s.New(ctx, orderID, userID, cartID, params, cartService)
+		assert.NoError(t, err)
+
+		// Pending orders without promotions are saved with zeros, not NULLs
+		shopOrders, err := s.GetShopOrders(ctx, orderID)
 		assert.NoError(t, err)
+		assert.Equal(t, len(order.ShopOrders), len(shopOrders))
+		for _, so := range shopOrders {
+			assert.Equal(t, int64(ordering.Pending), so.Status)
+			assert.Equal(t, int64(0), so.Discount)
+		}
 	}
 }
 
@@ -127,15 +151,38 @@ func getProductsByID(ctx context.Context, s ordering.Service) func(*testing.T) {
 	}
 }
 
+func refund(ctx context.Context, s ordering.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		noop := func(string, string, int64) (string, error) { return "", nil }
+		_, err := s.Refund(ctx, orderID, userID, nil, noop)
+		assert.NoError(t, err)
+
+		order, err := s.GetByID(ctx, orderID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(ordering.Refunded), order.Status.Int64)
+
+		_, err = s.Refund(ctx, orderID, userID, nil, noop)
+		assert.Error(t, err)
+	}
+}
+
 func updateStatus(ctx context.Context, s ordering.Service) func(*testing.T) {
 	return func(t *testing.T) {
-		status := ordering.Shipped
-		err := s.UpdateStatus(ctx, orderID, status)
+		status := ordering.Paid
+		err := s.UpdateStatus(ctx, orderID, status, userID)
 		assert.NoError(t, err)
 
 		order, err := s.GetByID(ctx, orderID)
 		assert.NoError(t, err)
 
 		assert.Equal(t, int64(status), order.Status.Int64)
+
+		err = s.UpdateStatus(ctx, orderID, ordering.Pending, userID)
+		assert.True(t, errors.Is(err, ordering.ErrInvalidTransition))
+
+		history, err := s.History(ctx, orderID)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(history))
+		assert.Equal(t, "paid", history[1].To.String)
 	}
 }
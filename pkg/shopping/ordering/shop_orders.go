@@ -0,0 +1,267 @@
+package ordering
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/pkg/postgres"
+	"github.com/GGP1/adak/pkg/shopping/shipment"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// fulfillmentStatuses are the statuses that depend on the progress of the shipments, they
+// are set on each shop order separately.
+var fulfillmentStatuses = map[status]struct{}{
+	Shipping:         {},
+	PartiallyShipped: {},
+	Shipped:          {},
+	Delivered:        {},
+}
+
+// Allocate splits the amount in proportion to the weights, the remainder of the division
+// goes to the last one so the shares add up to the amount.
+func Allocate(amount int64, weights []int64) []int64 {
+	shares := make([]int64, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+
+	var sum int64
+	for _, w := range weights {
+		sum += w
+	}
+	last := len(weights) - 1
+	if sum == 0 {
+		shares[last] = amount
+		return shares
+	}
+
+	var given int64
+	for i, w := range weights[:last] {
+		shares[i] = amount * w / sum
+		given += shares[i]
+	}
+	shares[last] = amount - given
+
+	return shares
+}
+
+// GetByShopID returns the shop orders of a shop with their products.
+func (s *service) GetByShopID(ctx context.Context, shopID string, params params.Query) ([]ShopOrder, error) {
+	s.metrics.incMethodCalls("GetByShopID")
+
+	q, args := postgres.AddPagination("SELECT * FROM (SELECT * FROM shop_orders WHERE shop_id=?) AS so", params)
+	args = append(args, shopID)
+	q = strings.Replace(q, "?", "$"+strconv.Itoa(len(args)), 1)
+
+	var shopOrders []ShopOrder
+	if err := s.db.SelectContext(ctx, &shopOrders, q, args...); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the shop orders")
+	}
+
+	if err := s.attachShopProducts(ctx, shopOrders); err != nil {
+		return nil, err
+	}
+
+	return shopOrders, nil
+}
+
+// GetShopOrders returns the shop orders an order was split into with their products.
+func (s *service) GetShopOrders(ctx context.Context, orderID string) ([]ShopOrder, error) {
+	s.metrics.incMethodCalls("GetShopOrders")
+
+	var shopOrders []ShopOrder
+	q := "SELECT * FROM shop_orders WHERE order_id=$1 ORDER BY shop_id"
+	if err := s.db.SelectContext(ctx, &shopOrders, q, orderID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the shop orders")
+	}
+
+	if err := s.attachShopProducts(ctx, shopOrders); err != nil {
+		return nil, err
+	}
+
+	return shopOrders, nil
+}
+
+// attachShopProducts sets the products sold by the shop in each shop order.
+func (s *service) attachShopProducts(ctx context.Context, shopOrders []ShopOrder) error {
+	if len(shopOrders) == 0 {
+		return nil
+	}
+
+	orderIDs := make([]string, len(shopOrders))
+	for i, so := range shopOrders {
+		orderIDs[i] = so.OrderID.String
+	}
+
+	var products []OrderProduct
+	q := "SELECT * FROM order_products WHERE order_id=ANY($1) AND shop_id IS NOT NULL"
+	if err := s.db.SelectContext(ctx, &products, q, pq.Array(orderIDs)); err != nil {
+		return errors.Wrap(err, "couldn't find the order products")
+	}
+
+	byShopOrder := make(map[string][]OrderProduct, len(shopOrders))
+	for _, p := range products {
+		key := p.OrderID.String + ":" + p.ShopID.String
+		byShopOrder[key] = append(byShopOrder[key], p)
+	}
+	for i, so := range shopOrders {
+		shopOrders[i].Products = byShopOrder[so.OrderID.String+":"+so.ShopID.String]
+	}
+
+	return nil
+}
+
+// fulfillShops moves each shop order to the status that corresponds to the progress of its shipments.
+//
+// Shop orders whose status doesn't allow the change are left as they are, the parent order
+// status was already validated.
+func (s *service) fulfillShops(ctx context.Context, tx *sqlx.Tx, orderID string, shops map[string]shipment.Fulfillment) error {
+	for shopID, f := range shops {
+		to, ok := fulfillmentStatus(f)
+		if !ok {
+			continue
+		}
+
+		var from status
+		q := "SELECT status FROM shop_orders WHERE order_id=$1 AND shop_id=$2 FOR UPDATE"
+		if err := tx.GetContext(ctx, &from, q, orderID, shopID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return errors.Wrap(err, "couldn't find the shop order")
+		}
+		if from == to || !canTransition(from, to) {
+			continue
+		}
+
+		q = "UPDATE shop_orders SET status=$3, updated_at=$4 WHERE order_id=$1 AND shop_id=$2"
+		if _, err := tx.ExecContext(ctx, q, orderID, shopID, to, time.Now()); err != nil {
+			return errors.Wrap(err, "couldn't update the shop order status")
+		}
+	}
+
+	return nil
+}
+
+// propagateStatus moves the shop orders of an order to the status of the parent, the ones
+// whose status doesn't allow the change are left as they are.
+//
+// Fulfillment statuses aren't propagated, each shop order follows its own shipments.
+func propagateStatus(ctx context.Context, tx *sqlx.Tx, orderID string, to status) error {
+	if _, ok := fulfillmentStatuses[to]; ok {
+		return nil
+	}
+	// Disputes concern the payment of the whole order, the shops keep their progress
+	if to == Disputed {
+		return nil
+	}
+
+	var from []int64
+	for s := range transitions {
+		if canTransition(s, to) {
+			from = append(from, int64(s))
+		}
+	}
+	if len(from) == 0 {
+		return nil
+	}
+
+	q := "UPDATE shop_orders SET status=$2, updated_at=$3 WHERE order_id=$1 AND status=ANY($4)"
+	if _, err := tx.ExecContext(ctx, q, orderID, to, time.Now(), pq.Array(from)); err != nil {
+		return errors.Wrap(err, "couldn't update the shop orders status")
+	}
+
+	return nil
+}
+
+// saveShopOrders saves the shop orders of an order.
+func saveShopOrders(ctx context.Context, tx *sqlx.Tx, shopOrders []ShopOrder) error {
+	q := `INSERT INTO shop_orders
+	(id, order_id, shop_id, status, counter, subtotal, discount, taxes, shipping_cost, total, created_at)
+	VALUES
+	(:id, :order_id, :shop_id, :status, :counter, :subtotal, :discount, :taxes, :shipping_cost, :total, :created_at)`
+	for _, so := range shopOrders {
+		if _, err := tx.NamedExecContext(ctx, q, so); err != nil {
+			return errors.Wrap(err, "couldn't save the shop order")
+		}
+	}
+
+	return nil
+}
+
+// splitShops groups the products of an order by the shop that sells them, the shipping cost
+// and promotions discount are split among the shops in proportion to the price of their products.
+func splitShops(orderID string, products []OrderProduct, shippingCost, discount int64) []ShopOrder {
+	byShop := make(map[string][]OrderProduct)
+	for _, p := range products {
+		byShop[p.ShopID.String] = append(byShop[p.ShopID.String], p)
+	}
+
+	shopIDs := make([]string, 0, len(byShop))
+	for shopID := range byShop {
+		shopIDs = append(shopIDs, shopID)
+	}
+	sort.Strings(shopIDs)
+
+	weights := make([]int64, len(shopIDs))
+	for i, shopID := range shopIDs {
+		for _, p := range byShop[shopID] {
+			weights[i] += p.Total.Int64 * p.Quantity.Int64
+		}
+	}
+	shipping := Allocate(shippingCost, weights)
+	discounts := Allocate(discount, weights)
+
+	now := time.Now()
+	shopOrders := make([]ShopOrder, len(shopIDs))
+	for i, shopID := range shopIDs {
+		var counter, taxes, exclusive int64
+		for _, p := range byShop[shopID] {
+			counter += p.Quantity.Int64
+			taxes += p.Taxes.Int64
+			exclusive += p.TaxLines.Exclusive()
+		}
+
+		shopOrders[i] = ShopOrder{
+			ID:           zero.StringFrom(uuid.NewString()),
+			OrderID:      zero.StringFrom(orderID),
+			ShopID:       zero.StringFrom(shopID),
+			Status:       int64(Pending),
+			Counter:      counter,
+			Subtotal:     weights[i],
+			Discount:     discounts[i],
+			Taxes:        taxes,
+			ShippingCost: shipping[i],
+			Total:        weights[i] + exclusive + shipping[i] - discounts[i],
+			CreatedAt:    zero.TimeFrom(now),
+			Products:     byShop[shopID],
+		}
+	}
+
+	return shopOrders
+}
+
+// fulfillmentStatus returns the status that corresponds to a fulfillment.
+func fulfillmentStatus(f shipment.Fulfillment) (status, bool) {
+	switch f {
+	case shipment.PartiallyShipped:
+		return PartiallyShipped, true
+	case shipment.Shipped:
+		return Shipped, true
+	case shipment.Fulfilled:
+		return Delivered, true
+	default:
+		return 0, false
+	}
+}
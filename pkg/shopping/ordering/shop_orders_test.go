@@ -0,0 +1,98 @@
+package ordering
+
+import (
+	"testing"
+
+	"github.com/GGP1/adak/pkg/shopping/shipment"
+	"github.com/GGP1/adak/pkg/shopping/tax"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestAllocate(t *testing.T) {
+	cases := []struct {
+		desc     string
+		amount   int64
+		weights  []int64
+		expected []int64
+	}{
+		{desc: "Proportional", amount: 1000, weights: []int64{3000, 1000}, expected: []int64{750, 250}},
+		{desc: "Remainder to the last", amount: 100, weights: []int64{1, 1, 1}, expected: []int64{33, 33, 34}},
+		{desc: "Zero weights", amount: 500, weights: []int64{0, 0}, expected: []int64{0, 500}},
+		{desc: "Empty", amount: 500, weights: nil, expected: []int64{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Allocate(tc.amount, tc.weights))
+		})
+	}
+}
+
+func TestTaxLines(t *testing.T) {
+	products := []OrderProduct{
+		{Category: zero.StringFrom("food"), Quantity: zero.IntFrom(3), Total: zero.IntFrom(1000)},
+		{Category: zero.StringFrom("books"), Quantity: zero.IntFrom(1), Total: zero.IntFrom(1000)},
+	}
+
+	// The discount is split 3 to 1 like the products totals
+	expected := []tax.Line{{Category: "food", Amount: 2250}, {Category: "books", Amount: 750}}
+	assert.Equal(t, expected, taxLines(products, 1000))
+
+	expected = []tax.Line{{Category: "food", Amount: 3000}, {Category: "books", Amount: 1000}}
+	assert.Equal(t, expected, taxLines(products, 0))
+}
+
+func TestSplitShops(t *testing.T) {
+	vat := tax.Tax{Name: "VAT", Rate: 2100, Amount: 420}
+	products := []OrderProduct{
+		{ProductID: zero.StringFrom("a"), ShopID: zero.StringFrom("shop2"), Quantity: zero.IntFrom(1),
+			Total: zero.IntFrom(1000)},
+		{ProductID: zero.StringFrom("b"), ShopID: zero.StringFrom("shop1"), Quantity: zero.IntFrom(2),
+			Total: zero.IntFrom(1000), Taxes: zero.IntFrom(420), TaxLines: tax.Breakdown{vat}},
+		{ProductID: zero.StringFrom("c"), ShopID: zero.StringFrom("shop1"), Quantity: zero.IntFrom(1),
+			Total: zero.IntFrom(1000), Taxes: zero.IntFrom(420), TaxLines: tax.Breakdown{vat}},
+	}
+	// 4000 + 840 of taxes + 500 of shipping - 300 of promotions
+	var orderTotal int64 = 5040
+
+	shopOrders := splitShops("order", products, 500, 300)
+
+	assert.Equal(t, 2, len(shopOrders))
+	assert.Equal(t, "shop1", shopOrders[0].ShopID.String)
+	assert.Equal(t, int64(3), shopOrders[0].Counter)
+	assert.Equal(t, int64(3000), shopOrders[0].Subtotal)
+	assert.Equal(t, int64(840), shopOrders[0].Taxes)
+	assert.Equal(t, int64(375), shopOrders[0].ShippingCost)
+	assert.Equal(t, int64(225), shopOrders[0].Discount)
+	assert.Equal(t, int64(3990), shopOrders[0].Total)
+	assert.Equal(t, 2, len(shopOrders[0].Products))
+
+	assert.Equal(t, "shop2", shopOrders[1].ShopID.String)
+	assert.Equal(t, int64(1050), shopOrders[1].Total)
+
+	var total int64
+	for _, so := range shopOrders {
+		assert.Equal(t, "order", so.OrderID.String)
+		assert.Equal(t, int64(Pending), so.Status)
+		total += so.Total
+	}
+	// The shop orders add up to the parent order
+	assert.Equal(t, orderTotal, total)
+}
+
+func TestAttachShipments(t *testing.T) {
+	so := ShopOrder{Products: []OrderProduct{{ProductID: zero.StringFrom("a")}}}
+	shipments := []shipment.Shipment{
+		{ID: zero.StringFrom("1"), Lines: []shipment.Line{{ProductID: "a"}, {ProductID: "b"}}},
+		{ID: zero.StringFrom("2"), Lines: []shipment.Line{{ProductID: "b"}}},
+	}
+
+	so.AttachShipments(shipments)
+
+	assert.Equal(t, 1, len(so.Shipments))
+	assert.Equal(t, []shipment.Line{{ProductID: "a"}}, so.Shipments[0].Lines)
+	// The shipments passed aren't modified
+	assert.Equal(t, 2, len(shipments[0].Lines))
+}
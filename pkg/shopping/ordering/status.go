@@ -0,0 +1,84 @@
+package ordering
+
+import (
+	"github.com/pkg/errors"
+)
+
+type status int64
+
+// Order statuses
+const (
+	Pending status = iota
+	Paid
+	Shipping
+	Shipped
+	Failed
+	PartiallyShipped
+	Cancelled
+	Refunded
+	Delivered
+	Disputed
+)
+
+var (
+	// ErrDateRequired is returned when ordering without a delivery date nor slots.
+	ErrDateRequired = errors.New("a delivery date or slot is required")
+	// ErrInvalidTransition is returned when the order can't move from its current status to the one requested.
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrNotFound is returned when the order doesn't exist.
+	ErrNotFound = errors.New("order not found")
+)
+
+var statusNames = map[status]string{
+	Pending:          "pending",
+	Paid:             "paid",
+	Shipping:         "shipping",
+	Shipped:          "shipped",
+	Failed:           "failed",
+	PartiallyShipped: "partially_shipped",
+	Cancelled:        "cancelled",
+	Refunded:         "refunded",
+	Delivered:        "delivered",
+	Disputed:         "disputed",
+}
+
+// transitions contains the statuses each status can move to, the ones missing are final.
+var transitions = map[status][]status{
+	Pending:          {Paid, Failed, Cancelled},
+	Paid:             {Shipping, PartiallyShipped, Shipped, Cancelled, Refunded, Disputed},
+	Shipping:         {PartiallyShipped, Shipped, Disputed},
+	PartiallyShipped: {Shipping, Shipped, Refunded, Disputed},
+	Shipped:          {Delivered, Refunded, Disputed},
+	Delivered:        {Refunded, Disputed},
+	// The payment can be retried
+	Failed: {Pending, Cancelled},
+	// Lost disputes withdraw the funds, won ones return the order to its previous status
+	Disputed: {Refunded},
+}
+
+func (s status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// canTransition returns whether an order with the status "from" can move to "to".
+func canTransition(from, to status) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStatus returns the status with the name provided.
+func parseStatus(name string) (status, error) {
+	for s, n := range statusNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, errors.Errorf("invalid status %q", name)
+}
@@ -0,0 +1,47 @@
+package ordering
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to status
+		valid    bool
+	}{
+		{from: Pending, to: Paid, valid: true},
+		{from: Pending, to: Shipped, valid: false},
+		{from: Paid, to: PartiallyShipped, valid: true},
+		{from: PartiallyShipped, to: Shipped, valid: true},
+		{from: Shipped, to: Refunded, valid: true},
+		{from: Shipped, to: Delivered, valid: true},
+		{from: PartiallyShipped, to: Delivered, valid: false},
+		{from: Shipped, to: Cancelled, valid: false},
+		{from: Failed, to: Pending, valid: true},
+		{from: Refunded, to: Paid, valid: false},
+		{from: Paid, to: Paid, valid: false},
+		{from: Delivered, to: Disputed, valid: true},
+		{from: Disputed, to: Refunded, valid: true},
+		{from: Disputed, to: Paid, valid: false},
+		{from: Pending, to: Disputed, valid: false},
+		{from: Pending, to: Cancelled, valid: true},
+		{from: Cancelled, to: Refunded, valid: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.from.String()+" to "+tc.to.String(), func(t *testing.T) {
+			assert.Equal(t, tc.valid, canTransition(tc.from, tc.to))
+		})
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	s, err := parseStatus("partially_shipped")
+	assert.NoError(t, err)
+	assert.Equal(t, PartiallyShipped, s)
+
+	_, err = parseStatus("lost")
+	assert.Error(t, err)
+}
@@ -0,0 +1,317 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GGP1/adak/internal/logger"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Cards with a special behavior in the fake provider, the rest are charged successfully.
+const (
+	// DeclinedCard is rejected when creating the intent
+	DeclinedCard = "4000000000000002"
+	// FailingCard is accepted but its payment fails once it's processed
+	FailingCard = "4000000000000341"
+	// ConfirmationCard intents wait until they are confirmed
+	ConfirmationCard = "4000002500003155"
+)
+
+// testMethods are the payment methods that can be attached to the customers of the fake
+// provider, they are named after the Stripe test ones.
+var testMethods = map[string]fakeCard{
+	"pm_card_visa":                  {Number: "4242424242424242", ExpMonth: "12", ExpYear: "2034"},
+	"pm_card_mastercard":            {Number: "5555555555554444", ExpMonth: "12", ExpYear: "2034"},
+	"pm_card_chargeDeclined":        {Number: DeclinedCard, ExpMonth: "12", ExpYear: "2034"},
+	"pm_card_chargeCustomerFail":    {Number: FailingCard, ExpMonth: "12", ExpYear: "2034"},
+	"pm_card_threeDSecure2Required": {Number: ConfirmationCard, ExpMonth: "12", ExpYear: "2034"},
+}
+
+// Fake processes the payments in memory, it's meant for development and tests.
+//
+// Intents are processed after a delay and their result is notified to the function
+// registered with Notify, as if it was received by the webhook.
+type Fake struct {
+	delay time.Duration
+
+	mu      sync.Mutex
+	intents map[string]*fakeIntent
+	cards   map[string]fakeCard
+	// refunds contains the ids of the refunds by their key
+	refunds map[string]string
+	// keys contains the ids of the intents by their idempotency key
+	keys   map[string]string
+	notify func(ctx context.Context, event Event) error
+}
+
+type fakeCard struct {
+	Number   string
+	ExpMonth string
+	ExpYear  string
+}
+
+type fakeIntent struct {
+	Intent
+	orderID  string
+	card     fakeCard
+	amount   int64
+	refunded int64
+}
+
+// NewFake returns a new fake provider.
+func NewFake(delay time.Duration) *Fake {
+	return &Fake{
+		delay:   delay,
+		intents: make(map[string]*fakeIntent),
+		cards:   make(map[string]fakeCard),
+		refunds: make(map[string]string),
+		keys:    make(map[string]string),
+	}
+}
+
+// Notify registers the function that receives the events.
+func (f *Fake) Notify(fn func(ctx context.Context, event Event) error) {
+	f.mu.Lock()
+	f.notify = fn
+	f.mu.Unlock()
+}
+
+// AttachMethod saves one of the test payment methods, or one attached before, to the customer.
+func (f *Fake) AttachMethod(ctx context.Context, customerID, methodID string) (MethodDetails, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	card, ok := f.cards[methodID]
+	if !ok {
+		card, ok = testMethods[methodID]
+		if !ok {
+			return MethodDetails{}, errors.Errorf("fake: payment method %q not found", methodID)
+		}
+		// Like Stripe, the test methods are copied when attached
+		methodID = "pm_fake_" + uuid.NewString()
+	}
+	f.cards[methodID] = card
+
+	details := MethodDetails{
+		ID:    methodID,
+		Brand: "unknown",
+		Last4: card.Number,
+	}
+	if n := len(card.Number); n > 4 {
+		details.Last4 = card.Number[n-4:]
+	}
+	switch {
+	case strings.HasPrefix(card.Number, "4"):
+		details.Brand = "visa"
+	case strings.HasPrefix(card.Number, "5"):
+		details.Brand = "mastercard"
+	}
+	details.ExpMonth, _ = strconv.ParseInt(card.ExpMonth, 10, 64)
+	details.ExpYear, _ = strconv.ParseInt(card.ExpYear, 10, 64)
+	return details, nil
+}
+
+// CancelIntent cancels a payment intent that wasn't charged.
+func (f *Fake) CancelIntent(ctx context.Context, intentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, err := f.intent(intentID)
+	if err != nil {
+		return err
+	}
+	if intent.Status == IntentSucceeded || intent.Status == IntentProcessing {
+		return errors.Errorf("fake: %s intents can't be canceled", intent.Status)
+	}
+	intent.Status = IntentCanceled
+	return nil
+}
+
+// CaptureIntent charges the funds held by an authorized payment intent.
+func (f *Fake) CaptureIntent(ctx context.Context, intentID string) (Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, err := f.intent(intentID)
+	if err != nil {
+		return Intent{}, err
+	}
+	if intent.Status != IntentRequiresCapture {
+		return Intent{}, errors.Errorf("fake: %s intents can't be captured", intent.Status)
+	}
+	intent.Status = IntentSucceeded
+	return intent.Intent, nil
+}
+
+// ConfirmIntent processes an intent that was waiting for confirmation.
+func (f *Fake) ConfirmIntent(ctx context.Context, intentID string) (Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, err := f.intent(intentID)
+	if err != nil {
+		return Intent{}, err
+	}
+	if intent.Status != IntentRequiresConfirmation {
+		return Intent{}, errors.Errorf("fake: %s intents can't be confirmed", intent.Status)
+	}
+	f.process(intent)
+	return intent.Intent, nil
+}
+
+// CreateCustomer creates a customer for the user.
+func (f *Fake) CreateCustomer(ctx context.Context, userID string) (string, error) {
+	return "cus_fake_" + uuid.NewString(), nil
+}
+
+// CreateIntent creates a payment intent and processes it unless the card requires confirmation.
+func (f *Fake) CreateIntent(ctx context.Context, params IntentParams) (Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if intentID, ok := f.keys[params.IdempotencyKey]; ok && params.IdempotencyKey != "" {
+		return f.intents[intentID].Intent, nil
+	}
+
+	card, ok := f.cards[params.MethodID]
+	if !ok {
+		return Intent{}, errors.Errorf("fake: payment method %q not found", params.MethodID)
+	}
+	if card.Number == DeclinedCard {
+		return Intent{}, ErrDeclined
+	}
+
+	intent := &fakeIntent{
+		Intent:  Intent{ID: "pi_fake_" + uuid.NewString(), Status: IntentRequiresConfirmation},
+		orderID: params.OrderID,
+		card:    card,
+		amount:  params.Amount,
+	}
+	f.intents[intent.ID] = intent
+	if params.IdempotencyKey != "" {
+		f.keys[params.IdempotencyKey] = intent.ID
+	}
+
+	// Cards charged without the user present can't be confirmed
+	if card.Number != ConfirmationCard || params.OffSession {
+		f.process(intent)
+	}
+	return intent.Intent, nil
+}
+
+// DetachMethod removes a saved payment method.
+func (f *Fake) DetachMethod(ctx context.Context, methodID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.cards[methodID]; !ok {
+		return errors.Errorf("fake: payment method %q not found", methodID)
+	}
+	delete(f.cards, methodID)
+	return nil
+}
+
+// ParseEvent parses the JSON encoding of an event.
+//
+// Fake events aren't signed, NewProvider refuses to use the provider outside development.
+func (f *Fake) ParseEvent(payload []byte, header http.Header) (Event, bool, error) {
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, false, errors.Wrap(err, "fake: invalid event")
+	}
+	if event.ID == "" || event.Type == "" {
+		return Event{}, false, errors.New("fake: the event id and type are required")
+	}
+	return event, true, nil
+}
+
+// Refund gives back the amount charged by the intent, zero refunds everything left.
+func (f *Fake) Refund(ctx context.Context, intentID, key string, amount int64) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if refundID, ok := f.refunds[key]; ok && key != "" {
+		return refundID, nil
+	}
+
+	intent, err := f.intent(intentID)
+	if err != nil {
+		return "", err
+	}
+	if intent.Status != IntentSucceeded {
+		return "", errors.Errorf("fake: %s intents can't be refunded", intent.Status)
+	}
+	if amount == 0 {
+		amount = intent.amount - intent.refunded
+	}
+	if amount <= 0 || intent.refunded+amount > intent.amount {
+		return "", errors.Errorf("fake: can't refund %d, %d is left", amount, intent.amount-intent.refunded)
+	}
+	intent.refunded += amount
+
+	refundID := "re_fake_" + uuid.NewString()
+	if key != "" {
+		f.refunds[key] = refundID
+	}
+	return refundID, nil
+}
+
+// SetDefaultMethod checks the payment method exists, the fake provider doesn't charge
+// customers by default.
+func (f *Fake) SetDefaultMethod(ctx context.Context, customerID, methodID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.cards[methodID]; !ok {
+		return errors.Errorf("fake: payment method %q not found", methodID)
+	}
+	return nil
+}
+
+// intent returns the intent with the id provided, the lock must be held.
+func (f *Fake) intent(id string) (*fakeIntent, error) {
+	intent, ok := f.intents[id]
+	if !ok {
+		return nil, errors.Errorf("fake: payment intent %q not found", id)
+	}
+	return intent, nil
+}
+
+// process settles the intent after the delay and notifies the result, the lock must be held.
+func (f *Fake) process(intent *fakeIntent) {
+	intent.Status = IntentProcessing
+
+	time.AfterFunc(f.delay, func() {
+		event := Event{
+			ID:       "evt_fake_" + uuid.NewString(),
+			Type:     PaymentSucceeded,
+			IntentID: intent.ID,
+			OrderID:  intent.orderID,
+		}
+
+		f.mu.Lock()
+		if intent.card.Number == FailingCard {
+			intent.Status = IntentRequiresPaymentMethod
+			event.Type = PaymentFailed
+		} else {
+			intent.Status = IntentSucceeded
+		}
+		notify := f.notify
+		f.mu.Unlock()
+
+		if notify == nil {
+			return
+		}
+		if err := notify(context.Background(), event); err != nil {
+			logger.Error(errors.Wrapf(err, "fake: couldn't notify the event %s", event.ID))
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		desc      string
+		method    string
+		eventType string
+	}{
+		{desc: "Succeeded", method: "pm_card_visa", eventType: PaymentSucceeded},
+		{desc: "Failed", method: "pm_card_chargeCustomerFail", eventType: PaymentFailed},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fake, events := newFake()
+			methodID := attach(t, fake, tc.method)
+
+			intent, err := fake.CreateIntent(ctx, IntentParams{OrderID: "order", Amount: 1000, MethodID: methodID})
+			require.NoError(t, err)
+			assert.Equal(t, IntentProcessing, intent.Status)
+
+			event := receive(t, events)
+			assert.Equal(t, tc.eventType, event.Type)
+			assert.Equal(t, intent.ID, event.IntentID)
+			assert.Equal(t, "order", event.OrderID)
+		})
+	}
+}
+
+func TestFakeDeclined(t *testing.T) {
+	ctx := context.Background()
+	fake, _ := newFake()
+	methodID := attach(t, fake, "pm_card_chargeDeclined")
+
+	_, err := fake.CreateIntent(ctx, IntentParams{OrderID: "order", Amount: 1000, MethodID: methodID})
+	assert.ErrorIs(t, err, ErrDeclined)
+}
+
+func TestFakeConfirmation(t *testing.T) {
+	ctx := context.Background()
+	fake, events := newFake()
+	methodID := attach(t, fake, "pm_card_threeDSecure2Required")
+
+	intent, err := fake.CreateIntent(ctx, IntentParams{OrderID: "order", Amount: 1000, MethodID: methodID})
+	require.NoError(t, err)
+	assert.Equal(t, IntentRequiresConfirmation, intent.Status)
+
+	intent, err = fake.ConfirmIntent(ctx, intent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, IntentProcessing, intent.Status)
+	assert.Equal(t, PaymentSucceeded, receive(t, events).Type)
+
+	_, err = fake.ConfirmIntent(ctx, intent.ID)
+	assert.Error(t, err)
+}
+
+func TestFakeIdempotentIntent(t *testing.T) {
+	ctx := context.Background()
+	fake, events := newFake()
+	methodID := attach(t, fake, "pm_card_visa")
+
+	params := IntentParams{OrderID: "order", Amount: 1000, MethodID: methodID, IdempotencyKey: "order"}
+	intent, err := fake.CreateIntent(ctx, params)
+	require.NoError(t, err)
+	receive(t, events)
+
+	// Retrying with the same key doesn't charge the order again
+	again, err := fake.CreateIntent(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, intent.ID, again.ID)
+
+	params.IdempotencyKey = "other"
+	other, err := fake.CreateIntent(ctx, params)
+	require.NoError(t, err)
+	assert.NotEqual(t, intent.ID, other.ID)
+}
+
+func TestFakeRefund(t *testing.T) {
+	ctx := context.Background()
+	fake, events := newFake()
+
+	methodID := attach(t, fake, "pm_card_visa")
+
+	intent, err := fake.CreateIntent(ctx, IntentParams{OrderID: "order", Amount: 1000, MethodID: methodID})
+	require.NoError(t, err)
+	receive(t, events)
+
+	refundID, err := fake.Refund(ctx, intent.ID, "first", 400)
+	require.NoError(t, err)
+	// Retrying with the same key doesn't refund the amount again
+	again, err := fake.Refund(ctx, intent.ID, "first", 400)
+	require.NoError(t, err)
+	assert.Equal(t, refundID, again)
+	_, err = fake.Refund(ctx, intent.ID, "second", 700)
+	assert.Error(t, err)
+	_, err = fake.Refund(ctx, intent.ID, "third", 0)
+	require.NoError(t, err)
+
+	assert.Error(t, fake.CancelIntent(ctx, intent.ID))
+}
+
+func TestFakeParseEvent(t *testing.T) {
+	fake, _ := newFake()
+
+	event, ok, err := fake.ParseEvent([]byte(`{"id":"evt_1","type":"dispute_created","intent_id":"pi_1"}`), nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Event{ID: "evt_1", Type: DisputeCreated, IntentID: "pi_1"}, event)
+
+	_, _, err = fake.ParseEvent([]byte(`{"type":"dispute_created"}`), nil)
+	assert.Error(t, err)
+}
+
+func newFake() (*Fake, <-chan Event) {
+	events := make(chan Event, 1)
+	fake := NewFake(0)
+	fake.Notify(func(ctx context.Context, event Event) error {
+		events <- event
+		return nil
+	})
+	return fake, events
+}
+
+func attach(t *testing.T, fake *Fake, testMethod string) string {
+	method, err := fake.AttachMethod(context.Background(), "cus_fake", testMethod)
+	require.NoError(t, err)
+	return method.ID
+}
+
+func receive(t *testing.T, events <-chan Event) Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("the event wasn't notified")
+		return Event{}
+	}
+}
+
+func TestFakeAttachMethod(t *testing.T) {
+	ctx := context.Background()
+	fake, events := newFake()
+
+	customerID, err := fake.CreateCustomer(ctx, "user")
+	require.NoError(t, err)
+
+	method, err := fake.AttachMethod(ctx, customerID, "pm_card_visa")
+	require.NoError(t, err)
+	assert.NotEqual(t, "pm_card_visa", method.ID)
+	assert.Equal(t, "visa", method.Brand)
+	assert.Equal(t, "4242", method.Last4)
+	assert.Equal(t, int64(12), method.ExpMonth)
+	assert.NoError(t, fake.SetDefaultMethod(ctx, customerID, method.ID))
+
+	_, err = fake.CreateIntent(ctx, IntentParams{OrderID: "order", Amount: 1000, CustomerID: customerID, MethodID: method.ID})
+	require.NoError(t, err)
+	assert.Equal(t, PaymentSucceeded, receive(t, events).Type)
+
+	require.NoError(t, fake.DetachMethod(ctx, method.ID))
+	_, err = fake.CreateIntent(ctx, IntentParams{OrderID: "order", Amount: 1000, CustomerID: customerID, MethodID: method.ID})
+	assert.Error(t, err)
+
+	declined, err := fake.AttachMethod(ctx, customerID, "pm_card_chargeDeclined")
+	require.NoError(t, err)
+	_, err = fake.CreateIntent(ctx, IntentParams{OrderID: "order", Amount: 1000, CustomerID: customerID, MethodID: declined.ID})
+	assert.ErrorIs(t, err, ErrDeclined)
+
+	_, err = fake.AttachMethod(ctx, customerID, "pm_unknown")
+	assert.Error(t, err)
+}
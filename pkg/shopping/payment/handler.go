@@ -0,0 +1,142 @@
+package payment
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/GGP1/adak/internal/cookie"
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/internal/validate"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+)
+
+// methodRequest holds the id of a payment method created by the client with the provider
+// library, the card details are sent to the provider directly.
+type methodRequest struct {
+	PaymentMethodID string `json:"payment_method_id" validate:"required,max=255"`
+}
+
+// Handler handles payment methods endpoints.
+type Handler struct {
+	service Service
+}
+
+// NewHandler returns a new payment methods handler.
+func NewHandler(service Service) Handler {
+	return Handler{service: service}
+}
+
+// AddMethod saves a payment method to the user logged in.
+func (h *Handler) AddMethod() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		var req methodRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		method, err := h.service.AddMethod(ctx, userID, req.PaymentMethodID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSON(w, http.StatusCreated, method)
+	}
+}
+
+// GetMethods lists the payment methods of the user logged in.
+func (h *Handler) GetMethods() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		methods, err := h.service.GetMethods(r.Context(), userID)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, methods)
+	}
+}
+
+// RemoveMethod detaches a payment method of the user logged in.
+func (h *Handler) RemoveMethod() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, methodID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		if err := h.service.RemoveMethod(r.Context(), userID, methodID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "payment method "+methodID+" removed")
+	}
+}
+
+// SetDefault sets the payment method charged by default to the user logged in.
+func (h *Handler) SetDefault() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, methodID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		if err := h.service.SetDefault(r.Context(), userID, methodID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "payment method "+methodID+" set as default")
+	}
+}
+
+// ids returns the id of the user logged in and the payment method id from the url.
+func ids(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	userID, err := cookie.GetValue(r, "UID")
+	if err != nil {
+		response.Error(w, http.StatusForbidden, err)
+		return "", "", false
+	}
+
+	methodID := chi.URLParam(r, "methodID")
+	if methodID == "" {
+		response.Error(w, http.StatusBadRequest, errors.New("invalid payment method id"))
+		return "", "", false
+	}
+
+	return userID, methodID, true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		response.Error(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrDeclined):
+		response.Error(w, http.StatusPaymentRequired, err)
+	default:
+		response.Error(w, http.StatusInternalServerError, err)
+	}
+}
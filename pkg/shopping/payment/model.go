@@ -0,0 +1,17 @@
+package payment
+
+import "gopkg.in/guregu/null.v4/zero"
+
+// Method is a payment method saved by a user.
+type Method struct {
+	ID     zero.String `json:"id" db:"id"`
+	UserID zero.String `json:"user_id" db:"user_id"`
+	// CustomerID is the provider customer the method is attached to
+	CustomerID zero.String `json:"-" db:"customer_id"`
+	Brand      zero.String `json:"brand" db:"brand"`
+	Last4      zero.String `json:"last4" db:"last4"`
+	ExpMonth   zero.Int    `json:"exp_month" db:"exp_month"`
+	ExpYear    zero.Int    `json:"exp_year" db:"exp_year"`
+	Default    bool        `json:"default" db:"is_default"`
+	CreatedAt  zero.Time   `json:"created_at" db:"created_at"`
+}
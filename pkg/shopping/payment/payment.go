@@ -0,0 +1,148 @@
+// Package payment charges the orders through the provider chosen in the configuration.
+package payment
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/GGP1/adak/internal/config"
+
+	"github.com/pkg/errors"
+)
+
+// Providers available.
+const (
+	StripeProvider = "stripe"
+	FakeProvider   = "fake"
+)
+
+// Payment intent statuses, they are named after the Stripe ones.
+const (
+	// IntentRequiresConfirmation intents wait for the customer to confirm the payment
+	IntentRequiresConfirmation = "requires_confirmation"
+	// IntentRequiresPaymentMethod intents were declined and need a new payment method
+	IntentRequiresPaymentMethod = "requires_payment_method"
+	// IntentRequiresCapture intents were authorized, the funds are held until they are captured
+	IntentRequiresCapture = "requires_capture"
+	// IntentProcessing intents were confirmed, their result is notified with an event
+	IntentProcessing = "processing"
+	IntentSucceeded  = "succeeded"
+	IntentCanceled   = "canceled"
+)
+
+// Payment events types.
+const (
+	// PaymentSucceeded is sent when the payment intent of an order is charged
+	PaymentSucceeded = "payment_succeeded"
+	// PaymentFailed is sent when charging the payment intent of an order failed
+	PaymentFailed = "payment_failed"
+	// PaymentRefunded is sent when the whole charge was refunded
+	PaymentRefunded = "payment_refunded"
+	// DisputeCreated is sent when the customer disputes the charge with their bank
+	DisputeCreated = "dispute_created"
+	// DisputeWon is sent when a dispute is closed in favor of the merchant
+	DisputeWon = "dispute_won"
+	// DisputeLost is sent when a dispute is closed in favor of the customer, the funds are withdrawn
+	DisputeLost = "dispute_lost"
+)
+
+// ErrDeclined is returned when the card can't be charged.
+var ErrDeclined = errors.New("the card was declined")
+
+// Provider processes the payments of the orders.
+//
+// The result of the payments is notified asynchronously with events.
+type Provider interface {
+	// AttachMethod saves a payment method created by the client to the customer, the id
+	// returned may differ from the one provided
+	AttachMethod(ctx context.Context, customerID, methodID string) (MethodDetails, error)
+	CancelIntent(ctx context.Context, intentID string) error
+	CaptureIntent(ctx context.Context, intentID string) (Intent, error)
+	ConfirmIntent(ctx context.Context, intentID string) (Intent, error)
+	CreateCustomer(ctx context.Context, userID string) (string, error)
+	// CreateIntent charges an order, the intent is returned along with the error if it was
+	// created anyway so it can be cancelled
+	CreateIntent(ctx context.Context, params IntentParams) (Intent, error)
+	DetachMethod(ctx context.Context, methodID string) error
+	// ParseEvent verifies and parses an event received by the webhook, false is returned
+	// if its type isn't handled
+	ParseEvent(payload []byte, header http.Header) (Event, bool, error)
+	// Refund gives back the amount charged by the intent, the refunds requested with a key
+	// that was already used aren't created again
+	Refund(ctx context.Context, intentID, key string, amount int64) (string, error)
+	SetDefaultMethod(ctx context.Context, customerID, methodID string) error
+}
+
+// MethodDetails describes a card attached to a customer.
+type MethodDetails struct {
+	ID       string
+	Brand    string
+	Last4    string
+	ExpMonth int64
+	ExpYear  int64
+}
+
+// IntentParams holds the parameters for charging an order.
+type IntentParams struct {
+	OrderID  string
+	CartID   string
+	Currency string
+	// Amount in the currency's smallest unit
+	Amount int64
+	// CustomerID and MethodID identify the saved payment method charged
+	CustomerID string
+	MethodID   string
+	// OffSession is true when the saved card is charged while the user isn't present
+	OffSession bool
+	// IdempotencyKey makes the saved card charges created with a key that was already used
+	// return the intent of the first one
+	IdempotencyKey string
+}
+
+// Intent is the attempt to charge an order.
+type Intent struct {
+	ID     string
+	Status string
+}
+
+// Event is a change in the payment of an order.
+type Event struct {
+	// ID is the same on each delivery of the event
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	IntentID string `json:"intent_id"`
+	// OrderID is only known by the events of payment intents, the rest are found by the intent id
+	OrderID string `json:"order_id"`
+	// RefundID is the id of the refund of PaymentRefunded events
+	RefundID string `json:"refund_id"`
+}
+
+// NewProvider returns the provider selected in the configuration. If none was, the
+// fake one is used in development and Stripe otherwise.
+//
+// Stripe requires the webhook secret, otherwise anyone could post events to the webhook. For
+// the same reason, the fake provider, whose events aren't signed, is only available in development.
+func NewProvider(config config.Payment, stripeConfig config.Stripe, development bool) (Provider, error) {
+	name := config.Provider
+	if name == "" {
+		name = StripeProvider
+		if development {
+			name = FakeProvider
+		}
+	}
+
+	switch name {
+	case StripeProvider:
+		if stripeConfig.WebhookSecret == "" {
+			return nil, errors.New("the stripe webhook secret is required")
+		}
+		return NewStripe(stripeConfig.WebhookSecret), nil
+	case FakeProvider:
+		if !development {
+			return nil, errors.New("the fake payment provider can only be used in development")
+		}
+		return NewFake(config.FakeDelay), nil
+	default:
+		return nil, errors.Errorf("unknown payment provider %q", name)
+	}
+}
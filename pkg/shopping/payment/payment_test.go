@@ -0,0 +1,30 @@
+package payment
+
+import (
+	"testing"
+
+	"github.com/GGP1/adak/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProvider(t *testing.T) {
+	stripeConfig := config.Stripe{WebhookSecret: "whsec_test"}
+
+	provider, err := NewProvider(config.Payment{}, stripeConfig, false)
+	assert.NoError(t, err)
+	assert.IsType(t, &Stripe{}, provider)
+
+	provider, err = NewProvider(config.Payment{}, stripeConfig, true)
+	assert.NoError(t, err)
+	assert.IsType(t, &Fake{}, provider)
+
+	_, err = NewProvider(config.Payment{Provider: StripeProvider}, config.Stripe{}, false)
+	assert.Error(t, err)
+
+	_, err = NewProvider(config.Payment{Provider: FakeProvider}, stripeConfig, false)
+	assert.Error(t, err)
+
+	_, err = NewProvider(config.Payment{Provider: "paypal"}, stripeConfig, false)
+	assert.Error(t, err)
+}
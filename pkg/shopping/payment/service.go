@@ -0,0 +1,222 @@
+package payment
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/GGP1/adak/internal/logger"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// ErrNotFound is returned when the payment method doesn't exist or belongs to other user.
+var ErrNotFound = errors.New("payment method not found")
+
+// Service contains the payment methods saved by the users.
+type Service interface {
+	AddMethod(ctx context.Context, userID, methodID string) (Method, error)
+	Customer(ctx context.Context, userID string) (string, error)
+	GetMethod(ctx context.Context, userID, methodID string) (Method, error)
+	GetMethods(ctx context.Context, userID string) ([]Method, error)
+	RemoveMethod(ctx context.Context, userID, methodID string) error
+	SetDefault(ctx context.Context, userID, methodID string) error
+}
+
+type service struct {
+	db       *sqlx.DB
+	provider Provider
+	metrics  metrics
+}
+
+// NewService returns a new payment methods service.
+func NewService(db *sqlx.DB, provider Provider) Service {
+	return &service{db, provider, initMetrics()}
+}
+
+// AddMethod attaches a payment method created by the client to the user customer, the first
+// one is set as the default.
+func (s *service) AddMethod(ctx context.Context, userID, methodID string) (Method, error) {
+	s.metrics.incMethodCalls("AddMethod")
+
+	customerID, err := s.Customer(ctx, userID)
+	if err != nil {
+		return Method{}, err
+	}
+
+	var exists bool
+	q := "SELECT EXISTS(SELECT 1 FROM payment_methods WHERE user_id=$1)"
+	if err := s.db.GetContext(ctx, &exists, q, userID); err != nil {
+		return Method{}, errors.Wrap(err, "couldn't find the payment methods")
+	}
+
+	details, err := s.provider.AttachMethod(ctx, customerID, methodID)
+	if err != nil {
+		return Method{}, err
+	}
+
+	m := Method{
+		ID:         zero.StringFrom(details.ID),
+		UserID:     zero.StringFrom(userID),
+		CustomerID: zero.StringFrom(customerID),
+		Brand:      zero.StringFrom(details.Brand),
+		Last4:      zero.StringFrom(details.Last4),
+		ExpMonth:   zero.IntFrom(details.ExpMonth),
+		ExpYear:    zero.IntFrom(details.ExpYear),
+		Default:    !exists,
+		CreatedAt:  zero.TimeFrom(time.Now()),
+	}
+
+	insertQ := `INSERT INTO payment_methods
+	(id, user_id, brand, last4, exp_month, exp_year, is_default, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = s.db.ExecContext(ctx, insertQ, m.ID, m.UserID, m.Brand, m.Last4, m.ExpMonth, m.ExpYear, m.Default, m.CreatedAt)
+	if err != nil {
+		if err := s.provider.DetachMethod(ctx, m.ID.String); err != nil {
+			logger.Error(err)
+		}
+		return Method{}, errors.Wrap(err, "couldn't save the payment method")
+	}
+
+	if m.Default {
+		if err := s.provider.SetDefaultMethod(ctx, customerID, m.ID.String); err != nil {
+			return Method{}, err
+		}
+	}
+
+	return m, nil
+}
+
+// Customer returns the id of the user customer in the provider, it's created the first time.
+func (s *service) Customer(ctx context.Context, userID string) (string, error) {
+	s.metrics.incMethodCalls("Customer")
+
+	var customerID string
+	q := "SELECT customer_id FROM payment_customers WHERE user_id=$1"
+	err := s.db.GetContext(ctx, &customerID, q, userID)
+	if err == nil {
+		return customerID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", errors.Wrap(err, "couldn't find the customer")
+	}
+
+	customerID, err = s.provider.CreateCustomer(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	insertQ := `INSERT INTO payment_customers (user_id, customer_id, created_at) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) DO NOTHING`
+	if _, err := s.db.ExecContext(ctx, insertQ, userID, customerID, time.Now()); err != nil {
+		return "", errors.Wrap(err, "couldn't save the customer")
+	}
+
+	// Another request may have created it first
+	if err := s.db.GetContext(ctx, &customerID, q, userID); err != nil {
+		return "", errors.Wrap(err, "couldn't find the customer")
+	}
+
+	return customerID, nil
+}
+
+// GetMethod returns a payment method of the user along with the customer it's attached to.
+func (s *service) GetMethod(ctx context.Context, userID, methodID string) (Method, error) {
+	s.metrics.incMethodCalls("GetMethod")
+
+	var m Method
+	q := `SELECT m.*, c.customer_id FROM payment_methods m
+	JOIN payment_customers c ON c.user_id=m.user_id
+	WHERE m.id=$1 AND m.user_id=$2`
+	if err := s.db.GetContext(ctx, &m, q, methodID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Method{}, ErrNotFound
+		}
+		return Method{}, errors.Wrap(err, "couldn't find the payment method")
+	}
+
+	return m, nil
+}
+
+// GetMethods lists the payment methods of the user, the default one first.
+func (s *service) GetMethods(ctx context.Context, userID string) ([]Method, error) {
+	s.metrics.incMethodCalls("GetMethods")
+
+	var methods []Method
+	q := "SELECT * FROM payment_methods WHERE user_id=$1 ORDER BY is_default DESC, created_at DESC"
+	if err := s.db.SelectContext(ctx, &methods, q, userID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the payment methods")
+	}
+
+	return methods, nil
+}
+
+// RemoveMethod detaches a payment method of the user, if it was the default one the
+// newest left takes its place.
+func (s *service) RemoveMethod(ctx context.Context, userID, methodID string) error {
+	s.metrics.incMethodCalls("RemoveMethod")
+
+	m, err := s.GetMethod(ctx, userID, methodID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM payment_methods WHERE id=$1", methodID); err != nil {
+		return errors.Wrap(err, "couldn't delete the payment method")
+	}
+
+	var newDefault string
+	if m.Default {
+		q := `UPDATE payment_methods SET is_default=true WHERE id=
+		(SELECT id FROM payment_methods WHERE user_id=$1 ORDER BY created_at DESC LIMIT 1)
+		RETURNING id`
+		if err := tx.GetContext(ctx, &newDefault, q, userID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrap(err, "couldn't update the default payment method")
+		}
+	}
+
+	if err := s.provider.DetachMethod(ctx, methodID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	if newDefault != "" {
+		if err := s.provider.SetDefaultMethod(ctx, m.CustomerID.String, newDefault); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetDefault sets the payment method charged by default to the user.
+func (s *service) SetDefault(ctx context.Context, userID, methodID string) error {
+	s.metrics.incMethodCalls("SetDefault")
+
+	m, err := s.GetMethod(ctx, userID, methodID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.provider.SetDefaultMethod(ctx, m.CustomerID.String, methodID); err != nil {
+		return err
+	}
+
+	q := "UPDATE payment_methods SET is_default=(id=$2) WHERE user_id=$1"
+	if _, err := s.db.ExecContext(ctx, q, userID, methodID); err != nil {
+		return errors.Wrap(err, "couldn't update the default payment method")
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package payment
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/GGP1/adak/pkg/shopping/payment/stripe"
+
+	"github.com/pkg/errors"
+)
+
+// Stripe charges the orders through Stripe, the events are received by its webhook.
+type Stripe struct {
+	webhookSecret string
+}
+
+// NewStripe returns a new Stripe provider.
+func NewStripe(webhookSecret string) *Stripe {
+	return &Stripe{webhookSecret: webhookSecret}
+}
+
+// AttachMethod attaches a payment method to the customer.
+func (s *Stripe) AttachMethod(ctx context.Context, customerID, methodID string) (MethodDetails, error) {
+	pm, err := stripe.AttachMethod(customerID, methodID)
+	if err != nil {
+		return MethodDetails{}, declined(err)
+	}
+
+	details := MethodDetails{ID: pm.ID}
+	if pm.Card != nil {
+		details.Brand = string(pm.Card.Brand)
+		details.Last4 = pm.Card.Last4
+		details.ExpMonth = int64(pm.Card.ExpMonth)
+		details.ExpYear = int64(pm.Card.ExpYear)
+	}
+	return details, nil
+}
+
+// CancelIntent cancels a payment intent that wasn't charged.
+func (s *Stripe) CancelIntent(ctx context.Context, intentID string) error {
+	return stripe.CancelIntent(intentID)
+}
+
+// CaptureIntent charges the funds held by an authorized payment intent.
+func (s *Stripe) CaptureIntent(ctx context.Context, intentID string) (Intent, error) {
+	if err := stripe.CaptureIntent(intentID); err != nil {
+		return Intent{}, err
+	}
+	return s.retrieveIntent(intentID)
+}
+
+// ConfirmIntent confirms a payment intent that requires it.
+func (s *Stripe) ConfirmIntent(ctx context.Context, intentID string) (Intent, error) {
+	if err := stripe.ConfirmIntent(intentID, nil); err != nil {
+		return Intent{}, declined(err)
+	}
+	return s.retrieveIntent(intentID)
+}
+
+// CreateCustomer creates a customer for the user.
+func (s *Stripe) CreateCustomer(ctx context.Context, userID string) (string, error) {
+	customer, err := stripe.CreateCustomer(userID)
+	if err != nil {
+		return "", err
+	}
+	return customer.ID, nil
+}
+
+// CreateIntent creates and confirms a payment intent that charges the saved payment method.
+func (s *Stripe) CreateIntent(ctx context.Context, params IntentParams) (Intent, error) {
+	pi, err := stripe.ChargeMethod(params.OrderID, params.CartID, strings.ToLower(params.Currency), params.Amount,
+		params.CustomerID, params.MethodID, params.OffSession, params.IdempotencyKey)
+	if err != nil {
+		var intent Intent
+		if pi != nil {
+			intent = Intent{ID: pi.ID, Status: string(pi.Status)}
+		}
+		return intent, declined(err)
+	}
+	return Intent{ID: pi.ID, Status: string(pi.Status)}, nil
+}
+
+// DetachMethod removes a payment method from its customer.
+func (s *Stripe) DetachMethod(ctx context.Context, methodID string) error {
+	_, err := stripe.DetachMethod(methodID)
+	return err
+}
+
+// ParseEvent verifies the signature of the event and parses it.
+func (s *Stripe) ParseEvent(payload []byte, header http.Header) (Event, bool, error) {
+	e, ok, err := stripe.ConstructPaymentEvent(payload, header.Get("Stripe-Signature"), s.webhookSecret)
+	if err != nil || !ok {
+		return Event{}, false, err
+	}
+
+	// The event types are named the same in both packages
+	event := Event{
+		ID:       e.ID,
+		Type:     e.Type,
+		IntentID: e.IntentID,
+		OrderID:  e.OrderID,
+		RefundID: e.RefundID,
+	}
+	return event, true, nil
+}
+
+// Refund gives back the amount to the card charged by the intent, zero refunds everything.
+func (s *Stripe) Refund(ctx context.Context, intentID, key string, amount int64) (string, error) {
+	refund, err := stripe.CreateRefund(intentID, key, amount)
+	if err != nil {
+		return "", err
+	}
+	return refund.ID, nil
+}
+
+// SetDefaultMethod sets the payment method charged by default to the customer.
+func (s *Stripe) SetDefaultMethod(ctx context.Context, customerID, methodID string) error {
+	return stripe.SetDefaultMethod(customerID, methodID)
+}
+
+func (s *Stripe) retrieveIntent(intentID string) (Intent, error) {
+	pi, err := stripe.RetrieveIntent(intentID)
+	if err != nil {
+		return Intent{}, err
+	}
+	return Intent{ID: pi.ID, Status: string(pi.Status)}, nil
+}
+
+// declined replaces the errors caused by the card with ErrDeclined.
+func declined(err error) error {
+	if stripe.IsCardError(err) {
+		return errors.Wrap(ErrDeclined, err.Error())
+	}
+	return err
+}
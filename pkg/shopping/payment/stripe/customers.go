@@ -0,0 +1,41 @@
+package stripe
+
+import (
+	"github.com/pkg/errors"
+	stripe "github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/customer"
+)
+
+// CreateCustomer creates a customer for the user, it's required to charge the payment
+// methods saved when the user isn't present.
+func CreateCustomer(userID string) (*stripe.Customer, error) {
+	params := &stripe.CustomerParams{
+		Params: stripe.Params{
+			Metadata: map[string]string{
+				"user_id": userID,
+			},
+		},
+	}
+
+	c, err := customer.New(params)
+	if err != nil {
+		return nil, errors.Wrap(err, "stripe: Customer")
+	}
+
+	return c, nil
+}
+
+// SetDefaultMethod sets the payment method charged by default to the customer.
+func SetDefaultMethod(customerID, methodID string) error {
+	params := &stripe.CustomerParams{
+		InvoiceSettings: &stripe.CustomerInvoiceSettingsParams{
+			DefaultPaymentMethod: stripe.String(methodID),
+		},
+	}
+
+	if _, err := customer.Update(customerID, params); err != nil {
+		return errors.Wrap(err, "stripe: Customer")
+	}
+
+	return nil
+}
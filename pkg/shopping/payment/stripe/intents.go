@@ -35,7 +35,7 @@ func ConfirmIntent(intentID string, source *stripe.Source) error {
 		return errors.Wrap(err, "stripe: PaymentIntent")
 	}
 
-	if pi.Status != "requires_payment_method" {
+	if pi.Status != "requires_payment_method" && pi.Status != "requires_confirmation" {
 		return errors.Errorf("stripe: paymentIntent already has a status of %s", pi.Status)
 	}
 
@@ -47,6 +47,52 @@ func ConfirmIntent(intentID string, source *stripe.Source) error {
 	return nil
 }
 
+// ChargeMethod charges a payment method saved by the customer, offSession is true when they
+// are not present. The intent is confirmed immediately.
+//
+// Requests with an idempotency key that was already used return the intent created by the first one.
+// The intent is returned along with the error if it was created anyway.
+func ChargeMethod(id, cartID, currency string, total int64, customerID, methodID string,
+	offSession bool, idempotencyKey string) (*stripe.PaymentIntent, error) {
+	if total < 50 {
+		return nil, errors.New("stripe: the order total should be higher than $0.50")
+	}
+
+	params := &stripe.PaymentIntentParams{
+		Customer:      stripe.String(customerID),
+		PaymentMethod: stripe.String(methodID),
+		Amount:        stripe.Int64(total),
+		Currency:      stripe.String(currency),
+		OffSession:    stripe.Bool(offSession),
+		Confirm:       stripe.Bool(true),
+		Params: stripe.Params{
+			Metadata: map[string]string{
+				"order_id": id,
+				"cart_id":  cartID,
+			},
+		},
+	}
+	if idempotencyKey != "" {
+		params.SetIdempotencyKey(idempotencyKey)
+	}
+
+	pi, err := paymentintent.New(params)
+	if err != nil {
+		// Declined charges create the intent too
+		var stripeErr *stripe.Error
+		if errors.As(err, &stripeErr) && stripeErr.PaymentIntent != nil {
+			return stripeErr.PaymentIntent, errors.Wrap(err, "stripe: PaymentIntent")
+		}
+		return nil, errors.Wrap(err, "stripe: PaymentIntent")
+	}
+
+	if pi.Status != stripe.PaymentIntentStatusSucceeded && pi.Status != stripe.PaymentIntentStatusProcessing {
+		return pi, errors.Errorf("stripe: invalid PaymentIntent status: %s", pi.Status)
+	}
+
+	return pi, nil
+}
+
 // CreateIntent creates a payment intent object.
 func CreateIntent(id, cartID, currency string, total int64, card Card) (*stripe.PaymentIntent, error) {
 	pMethodID, err := CreateMethod(card)
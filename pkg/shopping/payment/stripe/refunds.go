@@ -9,10 +9,19 @@ import (
 // CreateRefund will refund a charge that has previously been created but not yet
 // refunded.
 // Funds will be refunded to the credit or debit card that was originally charged.
-func CreateRefund(intentID string) (*stripe.Refund, error) {
+//
+// The amount is in the currency's smallest unit, zero refunds the whole charge. Requests
+// with the same idempotency key return the refund created by the first one.
+func CreateRefund(intentID, idempotencyKey string, amount int64) (*stripe.Refund, error) {
 	params := &stripe.RefundParams{
 		PaymentIntent: stripe.String(intentID),
 	}
+	if idempotencyKey != "" {
+		params.SetIdempotencyKey(idempotencyKey)
+	}
+	if amount > 0 {
+		params.Amount = stripe.Int64(amount)
+	}
 
 	r, err := refund.New(params)
 	if err != nil {
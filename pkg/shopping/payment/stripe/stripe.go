@@ -1,5 +1,10 @@
 package stripe
 
+import (
+	"github.com/pkg/errors"
+	stripe "github.com/stripe/stripe-go/v72"
+)
+
 // Card symbolizes a user card.
 type Card struct {
 	Number   string `json:"number"`
@@ -7,3 +12,9 @@ type Card struct {
 	ExpYear  string `json:"exp_year" validate:"len=4"`
 	CVC      string `json:"cvc" validate:"len=3"`
 }
+
+// IsCardError returns whether the error was caused by the card, like when it's declined.
+func IsCardError(err error) bool {
+	var stripeErr *stripe.Error
+	return errors.As(err, &stripeErr) && stripeErr.Type == stripe.ErrorTypeCard
+}
@@ -0,0 +1,110 @@
+package stripe
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	stripe "github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/webhook"
+)
+
+// Payment events types
+const (
+	// PaymentSucceeded is sent when the payment intent of an order is charged
+	PaymentSucceeded = "payment_succeeded"
+	// PaymentFailed is sent when charging the payment intent of an order failed
+	PaymentFailed = "payment_failed"
+	// PaymentRefunded is sent when the whole charge was refunded
+	PaymentRefunded = "payment_refunded"
+	// DisputeCreated is sent when the customer disputes the charge with their bank
+	DisputeCreated = "dispute_created"
+	// DisputeWon is sent when a dispute is closed in favor of the merchant
+	DisputeWon = "dispute_won"
+	// DisputeLost is sent when a dispute is closed in favor of the customer, the funds are withdrawn
+	DisputeLost = "dispute_lost"
+)
+
+// PaymentEvent is a change in the payment of an order notified through the webhook.
+type PaymentEvent struct {
+	// ID is the Stripe event id, it's the same on each delivery of the event
+	ID       string
+	Type     string
+	IntentID string
+	// OrderID is only known by the events of payment intents, the rest are found by the intent id
+	OrderID string
+	// RefundID is the id of the refund of PaymentRefunded events
+	RefundID string
+}
+
+// ConstructPaymentEvent verifies the signature of the payload received by the webhook and
+// returns the payment event it contains, false if its type isn't handled.
+func ConstructPaymentEvent(payload []byte, signature, secret string) (PaymentEvent, bool, error) {
+	// Anyone can sign the events with an empty secret
+	if secret == "" {
+		return PaymentEvent{}, false, errors.New("stripe: the webhook secret is empty")
+	}
+	e, err := webhook.ConstructEvent(payload, signature, secret)
+	if err != nil {
+		return PaymentEvent{}, false, errors.Wrap(err, "stripe: invalid webhook event")
+	}
+
+	return parsePaymentEvent(e)
+}
+
+// parsePaymentEvent returns the payment event corresponding to the Stripe event.
+func parsePaymentEvent(e stripe.Event) (PaymentEvent, bool, error) {
+	event := PaymentEvent{ID: e.ID}
+
+	switch e.Type {
+	case "payment_intent.succeeded", "payment_intent.payment_failed":
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(e.Data.Raw, &pi); err != nil {
+			return PaymentEvent{}, false, errors.Wrap(err, "stripe: invalid PaymentIntent")
+		}
+		event.Type = PaymentSucceeded
+		if e.Type == "payment_intent.payment_failed" {
+			event.Type = PaymentFailed
+		}
+		event.IntentID = pi.ID
+		event.OrderID = pi.Metadata["order_id"]
+
+	case "charge.refunded":
+		var ch stripe.Charge
+		if err := json.Unmarshal(e.Data.Raw, &ch); err != nil {
+			return PaymentEvent{}, false, errors.Wrap(err, "stripe: invalid Charge")
+		}
+		// Partial refunds are recorded when they are issued
+		if !ch.Refunded || ch.PaymentIntent == nil {
+			return PaymentEvent{}, false, nil
+		}
+		event.Type = PaymentRefunded
+		event.IntentID = ch.PaymentIntent.ID
+		if ch.Refunds != nil && len(ch.Refunds.Data) > 0 {
+			event.RefundID = ch.Refunds.Data[0].ID
+		}
+
+	case "charge.dispute.created", "charge.dispute.closed":
+		var d stripe.Dispute
+		if err := json.Unmarshal(e.Data.Raw, &d); err != nil {
+			return PaymentEvent{}, false, errors.Wrap(err, "stripe: invalid Dispute")
+		}
+		if d.PaymentIntent == nil {
+			return PaymentEvent{}, false, nil
+		}
+		event.IntentID = d.PaymentIntent.ID
+		switch {
+		case e.Type == "charge.dispute.created":
+			event.Type = DisputeCreated
+		case d.Status == stripe.DisputeStatusLost:
+			event.Type = DisputeLost
+		default:
+			// Won and warnings closed without turning into a dispute
+			event.Type = DisputeWon
+		}
+
+	default:
+		return PaymentEvent{}, false, nil
+	}
+
+	return event, true, nil
+}
@@ -0,0 +1,111 @@
+package stripe
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v72/webhook"
+)
+
+const testSecret = "whsec_test"
+
+func TestConstructPaymentEvent(t *testing.T) {
+	cases := []struct {
+		desc     string
+		payload  string
+		expected PaymentEvent
+		handled  bool
+	}{
+		{
+			desc: "Payment succeeded",
+			payload: `{"id":"evt_1","object":"event","type":"payment_intent.succeeded",
+			"data":{"object":{"id":"pi_1","object":"payment_intent","metadata":{"order_id":"order_1"}}}}`,
+			expected: PaymentEvent{ID: "evt_1", Type: PaymentSucceeded, IntentID: "pi_1", OrderID: "order_1"},
+			handled:  true,
+		},
+		{
+			desc: "Payment failed",
+			payload: `{"id":"evt_2","object":"event","type":"payment_intent.payment_failed",
+			"data":{"object":{"id":"pi_1","object":"payment_intent","metadata":{"order_id":"order_1"}}}}`,
+			expected: PaymentEvent{ID: "evt_2", Type: PaymentFailed, IntentID: "pi_1", OrderID: "order_1"},
+			handled:  true,
+		},
+		{
+			desc: "Charge refunded",
+			payload: `{"id":"evt_3","object":"event","type":"charge.refunded",
+			"data":{"object":{"id":"ch_1","object":"charge","refunded":true,"payment_intent":"pi_1",
+			"refunds":{"object":"list","data":[{"id":"re_1","object":"refund"}]}}}}`,
+			expected: PaymentEvent{ID: "evt_3", Type: PaymentRefunded, IntentID: "pi_1", RefundID: "re_1"},
+			handled:  true,
+		},
+		{
+			desc: "Charge partially refunded",
+			payload: `{"id":"evt_4","object":"event","type":"charge.refunded",
+			"data":{"object":{"id":"ch_1","object":"charge","refunded":false,"payment_intent":"pi_1"}}}`,
+			handled: false,
+		},
+		{
+			desc: "Dispute created",
+			payload: `{"id":"evt_5","object":"event","type":"charge.dispute.created",
+			"data":{"object":{"id":"dp_1","object":"dispute","status":"needs_response","payment_intent":"pi_1"}}}`,
+			expected: PaymentEvent{ID: "evt_5", Type: DisputeCreated, IntentID: "pi_1"},
+			handled:  true,
+		},
+		{
+			desc: "Dispute lost",
+			payload: `{"id":"evt_6","object":"event","type":"charge.dispute.closed",
+			"data":{"object":{"id":"dp_1","object":"dispute","status":"lost","payment_intent":"pi_1"}}}`,
+			expected: PaymentEvent{ID: "evt_6", Type: DisputeLost, IntentID: "pi_1"},
+			handled:  true,
+		},
+		{
+			desc: "Dispute won",
+			payload: `{"id":"evt_7","object":"event","type":"charge.dispute.closed",
+			"data":{"object":{"id":"dp_1","object":"dispute","status":"won","payment_intent":"pi_1"}}}`,
+			expected: PaymentEvent{ID: "evt_7", Type: DisputeWon, IntentID: "pi_1"},
+			handled:  true,
+		},
+		{
+			desc:    "Not handled",
+			payload: `{"id":"evt_8","object":"event","type":"customer.created","data":{"object":{"id":"cus_1"}}}`,
+			handled: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			payload := []byte(tc.payload)
+			event, handled, err := ConstructPaymentEvent(payload, sign(payload, testSecret), testSecret)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.handled, handled)
+			if tc.handled {
+				assert.Equal(t, tc.expected, event)
+			}
+		})
+	}
+}
+
+func TestConstructPaymentEventSignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","object":"event","type":"payment_intent.succeeded","data":{"object":{}}}`)
+
+	_, _, err := ConstructPaymentEvent(payload, sign(payload, "whsec_other"), testSecret)
+	assert.Error(t, err)
+
+	_, _, err = ConstructPaymentEvent(payload, "", testSecret)
+	assert.Error(t, err)
+
+	// Events signed with an empty secret are forged
+	_, _, err = ConstructPaymentEvent(payload, sign(payload, ""), "")
+	assert.Error(t, err)
+}
+
+func sign(payload []byte, secret string) string {
+	now := time.Now()
+	signature := webhook.ComputeSignature(now, payload, secret)
+	return fmt.Sprintf("t=%d,v1=%s", now.Unix(), hex.EncodeToString(signature))
+}
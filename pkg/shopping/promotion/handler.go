@@ -0,0 +1,213 @@
+package promotion
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GGP1/adak/internal/cookie"
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/internal/sanitize"
+	"github.com/GGP1/adak/internal/validate"
+	"github.com/google/uuid"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+type cursorResponse struct {
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Promotions []Promotion `json:"promotions,omitempty"`
+}
+
+type applyRequest struct {
+	Code string `json:"code" validate:"required,max=40"`
+}
+
+// Handler handles promotion endpoints.
+type Handler struct {
+	service Service
+}
+
+// NewHandler returns a new promotion handler.
+func NewHandler(service Service) Handler {
+	return Handler{
+		service: service,
+	}
+}
+
+// Apply adds a promotion code to the user cart.
+func (h *Handler) Apply() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		cartID, err := params.CartID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+		// Guests have no user id, their per-user limits are checked when ordering
+		userID, _ := cookie.GetValue(r, "UID")
+
+		var req applyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		code := normalizeCode(req.Code)
+		if err := h.service.Apply(ctx, cartID, userID, code); err != nil {
+			if errors.Is(err, ErrNotApplicable) {
+				response.Error(w, http.StatusUnprocessableEntity, err)
+				return
+			}
+			if errors.Is(err, ErrNotFound) {
+				response.Error(w, http.StatusNotFound, err)
+				return
+			}
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "promotion "+code+" applied")
+	}
+}
+
+// Create creates a new promotion and saves it.
+func (h *Handler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var p Promotion
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, p); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		p.ID = zero.StringFrom(uuid.NewString())
+		p.Code = zero.StringFrom(normalizeCode(p.Code.String))
+		p.CreatedAt = zero.TimeFrom(time.Now())
+		if err := h.service.Create(ctx, p); err != nil {
+			if errors.Is(err, ErrInvalid) {
+				response.Error(w, http.StatusBadRequest, err)
+				return
+			}
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusCreated, p)
+	}
+}
+
+// Delete removes a promotion.
+func (h *Handler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.service.Delete(ctx, id); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, id)
+	}
+}
+
+// Get lists all the promotions.
+func (h *Handler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		urlParams, err := params.ParseQuery(r.URL.RawQuery, params.Promotion)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		promotions, err := h.service.Get(ctx, urlParams)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		var nextCursor string
+		if len(promotions) > 0 {
+			nextCursor = params.EncodeCursor(
+				promotions[len(promotions)-1].CreatedAt.Time,
+				promotions[len(promotions)-1].ID.String,
+			)
+		}
+
+		response.JSON(w, http.StatusOK, cursorResponse{
+			NextCursor: nextCursor,
+			Promotions: promotions,
+		})
+	}
+}
+
+// GetByID lists the promotion with the id requested.
+func (h *Handler) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		promotion, err := h.service.GetByID(ctx, id)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, promotion)
+	}
+}
+
+// Remove takes out a promotion code from the user cart.
+func (h *Handler) Remove() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		cartID, err := params.CartID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		code := normalizeCode(chi.URLParam(r, "code"))
+		if err := h.service.Remove(ctx, cartID, code); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "promotion "+code+" removed")
+	}
+}
+
+// normalizeCode makes promotion codes case insensitive.
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(sanitize.Normalize(code)))
+}
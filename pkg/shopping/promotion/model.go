@@ -0,0 +1,73 @@
+package promotion
+
+import (
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Promotion types
+const (
+	// PercentageOff discounts a percentage (Value) of the cart total
+	PercentageOff = "percentage_off"
+	// FixedAmountOff discounts a fixed amount (Value) from the cart total
+	FixedAmountOff = "fixed_amount_off"
+	// FreeShipping removes the shipping cost of the order
+	FreeShipping = "free_shipping"
+	// BuyXGetY gives GetQuantity units of ProductID for free every BuyQuantity units bought
+	BuyXGetY = "buy_x_get_y"
+)
+
+// Promotion represents a code that can be applied to a cart to obtain a benefit.
+//
+// Amounts to be provided in a currency’s smallest unit.
+// 100 = 1 USD.
+type Promotion struct {
+	ID          zero.String `json:"id,omitempty"`
+	Code        zero.String `json:"code,omitempty" validate:"required,max=40"`
+	Type        zero.String `json:"type,omitempty" validate:"required,oneof=percentage_off fixed_amount_off free_shipping buy_x_get_y"`
+	Value       int64       `json:"value,omitempty" validate:"min=0"`
+	ProductID   zero.String `json:"product_id,omitempty" db:"product_id"`
+	BuyQuantity zero.Int    `json:"buy_quantity,omitempty" db:"buy_quantity" validate:"min=0"`
+	GetQuantity zero.Int    `json:"get_quantity,omitempty" db:"get_quantity" validate:"min=0"`
+	// MinTotal is the minimum cart total required to use the promotion
+	MinTotal int64 `json:"min_total,omitempty" db:"min_total" validate:"min=0"`
+	// UsageLimit is the maximum number of redemptions (0 means no limit)
+	UsageLimit int64 `json:"usage_limit,omitempty" db:"usage_limit" validate:"min=0"`
+	// UserUsageLimit is the maximum number of redemptions per user (0 means no limit)
+	UserUsageLimit int64     `json:"user_usage_limit,omitempty" db:"user_usage_limit" validate:"min=0"`
+	StartsAt       zero.Time `json:"starts_at,omitempty" db:"starts_at"`
+	EndsAt         zero.Time `json:"ends_at,omitempty" db:"ends_at"`
+	CreatedAt      zero.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+// Line is a cart product the promotions are evaluated against.
+type Line struct {
+	ProductID string `db:"id"`
+	Quantity  int64  `db:"quantity"`
+	UnitPrice int64  `db:"unit_price"`
+}
+
+// Result is the outcome of evaluating the promotions applied to a cart.
+type Result struct {
+	Codes        []string `json:"codes,omitempty"`
+	Discount     int64    `json:"discount"`
+	FreeShipping bool     `json:"free_shipping,omitempty"`
+}
+
+// Subtotal returns the cart total minus the promotions discount, it's the amount the
+// shipping methods are quoted and charged on.
+func (r Result) Subtotal(total int64) int64 {
+	if subtotal := total - r.Discount; subtotal > 0 {
+		return subtotal
+	}
+	return 0
+}
+
+// Redemption is the record of a promotion used in an order.
+type Redemption struct {
+	PromotionID zero.String `json:"promotion_id,omitempty" db:"promotion_id"`
+	OrderID     zero.String `json:"order_id,omitempty" db:"order_id"`
+	UserID      zero.String `json:"user_id,omitempty" db:"user_id"`
+	Code        zero.String `json:"code,omitempty"`
+	Discount    zero.Int    `json:"discount,omitempty"`
+	RedeemedAt  zero.Time   `json:"redeemed_at,omitempty" db:"redeemed_at"`
+}
@@ -0,0 +1,426 @@
+// Package promotion implements the promotion codes that can be applied to the carts.
+package promotion
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/pkg/postgres"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Promotion errors.
+var (
+	// ErrInvalid is returned when the promotion values don't match its type
+	ErrInvalid = errors.New("invalid promotion")
+	// ErrNotApplicable is returned when a promotion cannot be used on a cart
+	ErrNotApplicable = errors.New("promotion not applicable")
+	// ErrNotFound is returned when there is no promotion with the code provided
+	ErrNotFound = errors.New("promotion not found")
+)
+
+// Service contains promotion functionalities.
+type Service interface {
+	Apply(ctx context.Context, cartID, userID, code string) error
+	Create(ctx context.Context, p Promotion) error
+	Delete(ctx context.Context, id string) error
+	Evaluate(ctx context.Context, cartID string) (Result, error)
+	Get(ctx context.Context, params params.Query) ([]Promotion, error)
+	GetByID(ctx context.Context, id string) (Promotion, error)
+	Redeem(ctx context.Context, tx *sqlx.Tx, cartID, userID, orderID string) (Result, error)
+	Release(ctx context.Context, tx *sqlx.Tx, orderID string) error
+	Remove(ctx context.Context, cartID, code string) error
+}
+
+type service struct {
+	db      *sqlx.DB
+	metrics metrics
+}
+
+// NewService returns a new promotion service.
+func NewService(db *sqlx.DB) Service {
+	return &service{db, initMetrics()}
+}
+
+// Apply adds the promotion with the code provided to the cart.
+func (s *service) Apply(ctx context.Context, cartID, userID, code string) error {
+	s.metrics.incMethodCalls("Apply")
+
+	var p Promotion
+	if err := s.db.GetContext(ctx, &p, "SELECT * FROM promotions WHERE code=$1", code); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrapf(ErrNotFound, "%q", code)
+		}
+		return errors.Wrap(err, "couldn't find the promotion")
+	}
+
+	total, err := cartTotal(ctx, s.db, cartID)
+	if err != nil {
+		return err
+	}
+
+	if err := p.check(time.Now(), total); err != nil {
+		return err
+	}
+
+	if err := checkUsage(ctx, s.db, p, userID); err != nil {
+		return err
+	}
+
+	q := `INSERT INTO cart_promotions
+	(cart_id, promotion_id)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING`
+	if _, err := s.db.ExecContext(ctx, q, cartID, p.ID); err != nil {
+		return errors.Wrap(err, "couldn't apply the promotion")
+	}
+
+	return nil
+}
+
+// Create a promotion.
+func (s *service) Create(ctx context.Context, p Promotion) error {
+	s.metrics.incMethodCalls("Create")
+
+	if err := validatePromotion(p); err != nil {
+		return err
+	}
+
+	q := `INSERT INTO promotions
+	(id, code, type, value, product_id, buy_quantity, get_quantity, min_total,
+	usage_limit, user_usage_limit, starts_at, ends_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	_, err := s.db.ExecContext(ctx, q, p.ID, p.Code, p.Type, p.Value, p.ProductID,
+		p.BuyQuantity, p.GetQuantity, p.MinTotal, p.UsageLimit, p.UserUsageLimit,
+		p.StartsAt, p.EndsAt, p.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create the promotion")
+	}
+
+	return nil
+}
+
+// Delete permanently deletes a promotion from the database.
+//
+// The redemptions are kept for auditing purposes.
+func (s *service) Delete(ctx context.Context, id string) error {
+	s.metrics.incMethodCalls("Delete")
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM promotions WHERE id=$1", id); err != nil {
+		return errors.Wrap(err, "couldn't delete the promotion")
+	}
+
+	return nil
+}
+
+// Evaluate calculates the benefits of the promotions applied to the cart.
+//
+// Promotions that are no longer applicable are ignored. Per-user limits are
+// enforced when applying and redeeming the promotions.
+func (s *service) Evaluate(ctx context.Context, cartID string) (Result, error) {
+	s.metrics.incMethodCalls("Evaluate")
+
+	promotions, err := cartPromotions(ctx, s.db, cartID, false)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(promotions) == 0 {
+		return Result{}, nil
+	}
+
+	total, err := cartTotal(ctx, s.db, cartID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	lines, err := cartLines(ctx, s.db, cartID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	now := time.Now()
+	valid := make([]Promotion, 0, len(promotions))
+	for _, p := range promotions {
+		if err := p.check(now, total); err != nil {
+			continue
+		}
+		if err := checkUsage(ctx, s.db, p, ""); err != nil {
+			continue
+		}
+		valid = append(valid, p)
+	}
+
+	result, _ := evaluate(valid, total, lines)
+	return result, nil
+}
+
+// Get returns a list with all the promotions stored in the database.
+func (s *service) Get(ctx context.Context, params params.Query) ([]Promotion, error) {
+	s.metrics.incMethodCalls("Get")
+
+	var promotions []Promotion
+	q, args := postgres.AddPagination("SELECT * FROM promotions", params)
+	if err := s.db.SelectContext(ctx, &promotions, q, args...); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the promotions")
+	}
+
+	return promotions, nil
+}
+
+// GetByID retrieves the promotion requested from the database.
+func (s *service) GetByID(ctx context.Context, id string) (Promotion, error) {
+	s.metrics.incMethodCalls("GetByID")
+
+	var p Promotion
+	if err := s.db.GetContext(ctx, &p, "SELECT * FROM promotions WHERE id=$1", id); err != nil {
+		return Promotion{}, errors.Wrap(err, "couldn't find the promotion")
+	}
+
+	return p, nil
+}
+
+// Redeem evaluates the promotions applied to the cart and records their usage by the order.
+//
+// It must be executed inside the transaction that creates the order, an error is returned
+// if any of the promotions is no longer applicable.
+func (s *service) Redeem(ctx context.Context, tx *sqlx.Tx, cartID, userID, orderID string) (Result, error) {
+	s.metrics.incMethodCalls("Redeem")
+
+	// Lock the promotions rows to respect the usage limits under concurrent orders
+	promotions, err := cartPromotions(ctx, tx, cartID, true)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(promotions) == 0 {
+		return Result{}, nil
+	}
+
+	total, err := cartTotal(ctx, tx, cartID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	lines, err := cartLines(ctx, tx, cartID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	now := time.Now()
+	for _, p := range promotions {
+		if err := p.check(now, total); err != nil {
+			return Result{}, err
+		}
+		if err := checkUsage(ctx, tx, p, userID); err != nil {
+			return Result{}, err
+		}
+	}
+
+	result, discounts := evaluate(promotions, total, lines)
+
+	q := `INSERT INTO promotion_redemptions
+	(promotion_id, order_id, user_id, code, discount, redeemed_at)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+	for i, p := range promotions {
+		_, err := tx.ExecContext(ctx, q, p.ID, orderID, userID, p.Code, discounts[i], now)
+		if err != nil {
+			return Result{}, errors.Wrap(err, "couldn't save the redemption")
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM cart_promotions WHERE cart_id=$1", cartID); err != nil {
+		return Result{}, errors.Wrap(err, "couldn't remove the cart promotions")
+	}
+
+	s.metrics.redemptions.Add(float64(len(promotions)))
+	return result, nil
+}
+
+// Release deletes the redemptions of an order so they don't count towards the usage limits.
+func (s *service) Release(ctx context.Context, tx *sqlx.Tx, orderID string) error {
+	s.metrics.incMethodCalls("Release")
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM promotion_redemptions WHERE order_id=$1", orderID); err != nil {
+		return errors.Wrap(err, "couldn't release the promotions")
+	}
+
+	return nil
+}
+
+// Remove takes out the promotion with the code provided from the cart.
+func (s *service) Remove(ctx context.Context, cartID, code string) error {
+	s.metrics.incMethodCalls("Remove")
+
+	q := `DELETE FROM cart_promotions
+	WHERE cart_id=$1 AND promotion_id IN (SELECT id FROM promotions WHERE code=$2)`
+	if _, err := s.db.ExecContext(ctx, q, cartID, code); err != nil {
+		return errors.Wrap(err, "couldn't remove the promotion")
+	}
+
+	return nil
+}
+
+// check returns an error if the promotion cannot be used at the time and with the cart total provided.
+func (p Promotion) check(now time.Time, total int64) error {
+	if p.StartsAt.Valid && now.Before(p.StartsAt.Time) {
+		return errors.Wrapf(ErrNotApplicable, "%q is not available yet", p.Code.String)
+	}
+	if p.EndsAt.Valid && now.After(p.EndsAt.Time) {
+		return errors.Wrapf(ErrNotApplicable, "%q has expired", p.Code.String)
+	}
+	if total < p.MinTotal {
+		return errors.Wrapf(ErrNotApplicable, "%q requires a minimum cart total of %d", p.Code.String, p.MinTotal)
+	}
+	return nil
+}
+
+// discount returns the amount discounted by the promotion.
+func (p Promotion) discount(total int64, lines []Line) int64 {
+	switch p.Type.String {
+	case PercentageOff:
+		return total * p.Value / 100
+	case FixedAmountOff:
+		return p.Value
+	case BuyXGetY:
+		buy, get := p.BuyQuantity.Int64, p.GetQuantity.Int64
+		if buy <= 0 || get <= 0 {
+			return 0
+		}
+		// Units of all the product variants count, the cheapest ones are given for free
+		var quantity, unitPrice int64
+		for _, l := range lines {
+			if l.ProductID != p.ProductID.String {
+				continue
+			}
+			quantity += l.Quantity
+			if unitPrice == 0 || l.UnitPrice < unitPrice {
+				unitPrice = l.UnitPrice
+			}
+		}
+		set := buy + get
+		free := (quantity / set) * get
+		if rest := quantity%set - buy; rest > 0 {
+			free += rest
+		}
+		return free * unitPrice
+	}
+	return 0
+}
+
+// evaluate returns the result of applying the promotions and the discount of each one of them.
+//
+// The total discount never exceeds the cart total.
+func evaluate(promotions []Promotion, total int64, lines []Line) (Result, []int64) {
+	result := Result{Codes: make([]string, len(promotions))}
+	discounts := make([]int64, len(promotions))
+	for i, p := range promotions {
+		result.Codes[i] = p.Code.String
+		if p.Type.String == FreeShipping {
+			result.FreeShipping = true
+		}
+
+		d := p.discount(total, lines)
+		if left := total - result.Discount; d > left {
+			d = left
+		}
+		discounts[i] = d
+		result.Discount += d
+	}
+
+	return result, discounts
+}
+
+// checkUsage verifies that the promotion usage limits haven't been reached.
+// The per-user limit is skipped if the user id is empty.
+func checkUsage(ctx context.Context, db sqlx.QueryerContext, p Promotion, userID string) error {
+	if p.UsageLimit > 0 {
+		var count int64
+		q := "SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id=$1"
+		if err := sqlx.GetContext(ctx, db, &count, q, p.ID); err != nil {
+			return errors.Wrap(err, "couldn't count redemptions")
+		}
+		if count >= p.UsageLimit {
+			return errors.Wrapf(ErrNotApplicable, "%q has reached its usage limit", p.Code.String)
+		}
+	}
+
+	if userID != "" && p.UserUsageLimit > 0 {
+		var count int64
+		q := "SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id=$1 AND user_id=$2"
+		if err := sqlx.GetContext(ctx, db, &count, q, p.ID, userID); err != nil {
+			return errors.Wrap(err, "couldn't count redemptions")
+		}
+		if count >= p.UserUsageLimit {
+			return errors.Wrapf(ErrNotApplicable, "%q has already been used", p.Code.String)
+		}
+	}
+
+	return nil
+}
+
+func cartPromotions(ctx context.Context, db sqlx.QueryerContext, cartID string, lock bool) ([]Promotion, error) {
+	q := `SELECT p.* FROM promotions AS p
+	INNER JOIN cart_promotions AS cp ON p.id=cp.promotion_id
+	WHERE cp.cart_id=$1
+	ORDER BY p.code`
+	if lock {
+		q += " FOR UPDATE OF p"
+	}
+
+	var promotions []Promotion
+	if err := sqlx.SelectContext(ctx, db, &promotions, q, cartID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the cart promotions")
+	}
+
+	return promotions, nil
+}
+
+func cartLines(ctx context.Context, db sqlx.QueryerContext, cartID string) ([]Line, error) {
+	q := "SELECT id, quantity, total AS unit_price FROM cart_products WHERE cart_id=$1"
+
+	var lines []Line
+	if err := sqlx.SelectContext(ctx, db, &lines, q, cartID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the cart products")
+	}
+
+	return lines, nil
+}
+
+func cartTotal(ctx context.Context, db sqlx.QueryerContext, cartID string) (int64, error) {
+	var total int64
+	if err := sqlx.GetContext(ctx, db, &total, "SELECT total FROM carts WHERE id=$1", cartID); err != nil {
+		return 0, errors.Wrap(err, "couldn't find the cart")
+	}
+
+	return total, nil
+}
+
+// validatePromotion checks the fields that depend on the promotion type, percentages can't
+// exceed 100 as the discount would be larger than the cart total.
+func validatePromotion(p Promotion) error {
+	switch p.Type.String {
+	case PercentageOff:
+		if p.Value <= 0 || p.Value > 100 {
+			return errors.Wrap(ErrInvalid, "percentage value must be between 1 and 100")
+		}
+	case FixedAmountOff:
+		if p.Value <= 0 {
+			return errors.Wrap(ErrInvalid, "fixed amount value must be greater than 0")
+		}
+	case BuyXGetY:
+		if p.ProductID.String == "" || p.BuyQuantity.Int64 <= 0 || p.GetQuantity.Int64 <= 0 {
+			return errors.Wrap(ErrInvalid, "buy x get y promotions require product_id, buy_quantity and get_quantity")
+		}
+	}
+
+	if p.StartsAt.Valid && p.EndsAt.Valid && p.EndsAt.Time.Before(p.StartsAt.Time) {
+		return errors.Wrap(ErrInvalid, "ends_at must be after starts_at")
+	}
+
+	return nil
+}
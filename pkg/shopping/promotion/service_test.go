@@ -0,0 +1,182 @@
+package promotion_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/internal/logger"
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/internal/test"
+	"github.com/GGP1/adak/pkg/product"
+	"github.com/GGP1/adak/pkg/shop"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+const (
+	cartID    = "promotion_cart"
+	productID = "promotion_product"
+	userID    = "promotion_user"
+)
+
+var (
+	percentage = promotion.Promotion{
+		ID:        zero.StringFrom("1"),
+		Code:      zero.StringFrom("TEN"),
+		Type:      zero.StringFrom(promotion.PercentageOff),
+		Value:     10,
+		CreatedAt: zero.TimeFrom(time.Now()),
+	}
+	buyXGetY = promotion.Promotion{
+		ID:          zero.StringFrom("2"),
+		Code:        zero.StringFrom("3X2"),
+		Type:        zero.StringFrom(promotion.BuyXGetY),
+		ProductID:   zero.StringFrom(productID),
+		BuyQuantity: zero.IntFrom(2),
+		GetQuantity: zero.IntFrom(1),
+		CreatedAt:   zero.TimeFrom(time.Now()),
+	}
+	expired = promotion.Promotion{
+		ID:        zero.StringFrom("3"),
+		Code:      zero.StringFrom("OLD"),
+		Type:      zero.StringFrom(promotion.FixedAmountOff),
+		Value:     100,
+		EndsAt:    zero.TimeFrom(time.Now().Add(-time.Hour)),
+		CreatedAt: zero.TimeFrom(time.Now()),
+	}
+)
+
+func NewPromotionService(t *testing.T) (context.Context, promotion.Service) {
+	t.Helper()
+	logger.Disable()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	db := test.StartPostgres(t)
+	mc := test.StartMemcached(t)
+	service := promotion.NewService(db)
+	createRelationship(ctx, t, db, mc, service)
+
+	t.Cleanup(func() {
+		cancel()
+	})
+
+	return ctx, service
+}
+
+func TestPromotionService(t *testing.T) {
+	ctx, s := NewPromotionService(t)
+
+	t.Run("Create", create(ctx, s))
+	t.Run("Get", get(ctx, s))
+	t.Run("Apply", apply(ctx, s))
+	t.Run("Evaluate", evaluate(ctx, s))
+	t.Run("Remove", remove(ctx, s))
+	t.Run("Delete", delete(ctx, s))
+}
+
+func apply(ctx context.Context, s promotion.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		assert.NoError(t, s.Apply(ctx, cartID, userID, percentage.Code.String))
+		assert.NoError(t, s.Apply(ctx, cartID, userID, buyXGetY.Code.String))
+
+		err := s.Apply(ctx, cartID, userID, expired.Code.String)
+		assert.True(t, errors.Is(err, promotion.ErrNotApplicable))
+	}
+}
+
+func create(ctx context.Context, s promotion.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		for _, p := range []promotion.Promotion{percentage, buyXGetY, expired} {
+			assert.NoError(t, s.Create(ctx, p))
+		}
+
+		p, err := s.GetByID(ctx, percentage.ID.String)
+		assert.NoError(t, err)
+		assert.Equal(t, percentage.Code, p.Code)
+
+		tooHigh := percentage
+		tooHigh.ID = zero.StringFrom("4")
+		tooHigh.Code = zero.StringFrom("ALL")
+		tooHigh.Value = 150
+		err = s.Create(ctx, tooHigh)
+		assert.True(t, errors.Is(err, promotion.ErrInvalid))
+	}
+}
+
+func delete(ctx context.Context, s promotion.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		assert.NoError(t, s.Delete(ctx, percentage.ID.String))
+
+		_, err := s.GetByID(ctx, percentage.ID.String)
+		assert.Error(t, err)
+	}
+}
+
+func evaluate(ctx context.Context, s promotion.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		result, err := s.Evaluate(ctx, cartID)
+		assert.NoError(t, err)
+
+		// A free unit (1000) plus 10% of the cart total (300)
+		assert.Equal(t, int64(1300), result.Discount)
+		assert.ElementsMatch(t, []string{"3X2", "TEN"}, result.Codes)
+	}
+}
+
+func get(ctx context.Context, s promotion.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		promotions, err := s.Get(ctx, params.Query{Limit: "10"})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(promotions))
+	}
+}
+
+func remove(ctx context.Context, s promotion.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		assert.NoError(t, s.Remove(ctx, cartID, buyXGetY.Code.String))
+
+		result, err := s.Evaluate(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(300), result.Discount)
+	}
+}
+
+func createRelationship(ctx context.Context, t *testing.T, db *sqlx.DB, mc *memcache.Client, s promotion.Service) {
+	t.Helper()
+
+	shopService := shop.NewService(db, mc)
+	err := shopService.Create(ctx, shop.Shop{ID: "shop", Name: "test"})
+	assert.NoError(t, err)
+
+	productService := product.NewService(db, mc)
+	err = productService.Create(ctx, product.Product{
+		ID:       zero.StringFrom(productID),
+		ShopID:   zero.StringFrom("shop"),
+		Stock:    zero.IntFrom(10),
+		Brand:    zero.StringFrom("brand"),
+		Category: zero.StringFrom("category"),
+		Type:     zero.StringFrom("type"),
+		Weight:   zero.IntFrom(1),
+		Subtotal: zero.IntFrom(1000),
+		Total:    zero.IntFrom(1000),
+	})
+	assert.NoError(t, err)
+
+	cartService := cart.NewService(db, mc, config.Cart{}, inventory.NewService(config.Inventory{}), s)
+	assert.NoError(t, cartService.Create(ctx, cartID))
+	err = cartService.Add(ctx, cart.Product{
+		ID:       zero.StringFrom(productID),
+		CartID:   zero.StringFrom(cartID),
+		Quantity: zero.IntFrom(3),
+	})
+	assert.NoError(t, err)
+}
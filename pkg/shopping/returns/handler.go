@@ -0,0 +1,256 @@
+package returns
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GGP1/adak/internal/cookie"
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/internal/sanitize"
+	"github.com/GGP1/adak/internal/token"
+	"github.com/GGP1/adak/internal/validate"
+	"github.com/GGP1/adak/pkg/shopping/ordering"
+	"github.com/GGP1/adak/pkg/shopping/payment"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+type cursorResponse struct {
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Returns    []Return `json:"returns,omitempty"`
+}
+
+type refundRequest struct {
+	// Restock puts the products back in stock, they may be damaged
+	Restock bool `json:"restock"`
+}
+
+type statusRequest struct {
+	Status string `json:"status" validate:"required,oneof=approved rejected received"`
+	Note   string `json:"note" validate:"max=500"`
+}
+
+// Handler handles return endpoints.
+type Handler struct {
+	service  Service
+	provider payment.Provider
+}
+
+// NewHandler returns a new returns handler.
+func NewHandler(provider payment.Provider, service Service) Handler {
+	return Handler{
+		service:  service,
+		provider: provider,
+	}
+}
+
+// Get lists the returns of all the users.
+func (h *Handler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		urlParams, err := params.ParseQuery(r.URL.RawQuery, params.Return)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		returns, err := h.service.Get(ctx, urlParams)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		var nextCursor string
+		if len(returns) > 0 {
+			nextCursor = params.EncodeCursor(
+				returns[len(returns)-1].CreatedAt.Time,
+				returns[len(returns)-1].ID.String,
+			)
+		}
+
+		response.JSON(w, http.StatusOK, cursorResponse{
+			NextCursor: nextCursor,
+			Returns:    returns,
+		})
+	}
+}
+
+// GetByID retrieves the return with the id requested.
+func (h *Handler) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		ret, err := h.service.GetByID(ctx, id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, ret)
+	}
+}
+
+// GetByUserID retrieves the returns requested by the user.
+func (h *Handler) GetByUserID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := token.CheckPermits(r, id); err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		returns, err := h.service.GetByUserID(ctx, id)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, returns)
+	}
+}
+
+// Refund gives back the money paid for the products of a received return.
+func (h *Handler) Refund() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		adminID, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		var req refundRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		ret, err := h.service.Refund(ctx, id, adminID, req.Restock, ordering.ProviderRefund(ctx, h.provider))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, ret)
+	}
+}
+
+// Request creates a return for products of an order that were delivered to the user.
+func (h *Handler) Request() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		orderID, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		userID, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		var ret Return
+		if err := json.NewDecoder(r.Body).Decode(&ret); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, ret); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		ret.ID = zero.StringFrom(uuid.NewString())
+		ret.OrderID = zero.StringFrom(orderID)
+		ret.UserID = zero.StringFrom(userID)
+		ret.Reason = zero.StringFrom(sanitize.Normalize(ret.Reason.String))
+		ret.Status = zero.StringFrom(Requested)
+		ret.Note = zero.String{}
+		ret.RefundID = zero.String{}
+		ret.Restocked = false
+		ret.CreatedAt = zero.TimeFrom(time.Now())
+		if err := h.service.Request(ctx, ret); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSON(w, http.StatusCreated, ret)
+	}
+}
+
+// UpdateStatus approves, rejects or marks a return as received.
+func (h *Handler) UpdateStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var req statusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		req.Status = strings.ToLower(sanitize.Normalize(req.Status))
+		if err := validate.Struct(ctx, req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		ret, err := h.service.UpdateStatus(ctx, id, req.Status, sanitize.Normalize(req.Note))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, ret)
+	}
+}
+
+// writeError responds with the status code that corresponds to the return error provided.
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ordering.ErrNotFound):
+		response.Error(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrInvalidTransition), errors.Is(err, ordering.ErrInvalidTransition):
+		response.Error(w, http.StatusConflict, err)
+	case errors.Is(err, ErrNotReturnable), errors.Is(err, ordering.ErrInvalidRefund):
+		response.Error(w, http.StatusUnprocessableEntity, err)
+	default:
+		response.Error(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,59 @@
+package returns
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Return is a request to send back some of the products delivered to the user.
+type Return struct {
+	ID      zero.String `json:"id,omitempty"`
+	OrderID zero.String `json:"order_id,omitempty" db:"order_id"`
+	UserID  zero.String `json:"user_id,omitempty" db:"user_id"`
+	Reason  zero.String `json:"reason,omitempty" validate:"required,max=500"`
+	Lines   Lines       `json:"lines,omitempty" validate:"required,min=1,dive"`
+	Status  zero.String `json:"status,omitempty"`
+	// Note is the message left by the administrator on the last status change
+	Note zero.String `json:"note,omitempty"`
+	// RefundID is the id of the order refund created when the return is refunded
+	RefundID  zero.String `json:"refund_id,omitempty" db:"refund_id"`
+	Restocked bool        `json:"restocked,omitempty"`
+	CreatedAt zero.Time   `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt zero.Time   `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// Line is a quantity of a delivered product being returned.
+type Line struct {
+	ProductID string `json:"product_id" db:"product_id" validate:"required"`
+	// VariantID is empty for products without variants
+	VariantID string `json:"variant_id,omitempty" db:"variant_id"`
+	Quantity  int64  `json:"quantity" validate:"min=1"`
+}
+
+// Lines contains the products returned.
+type Lines []Line
+
+// Scan implements the sql.Scanner interface.
+func (l *Lines) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	}
+	return errors.Errorf("cannot scan %T into return lines", src)
+}
+
+// Value implements the driver.Valuer interface.
+func (l Lines) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return json.Marshal(l)
+}
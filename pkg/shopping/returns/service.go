@@ -0,0 +1,276 @@
+package returns
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/GGP1/adak/internal/email"
+	"github.com/GGP1/adak/internal/logger"
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/pkg/postgres"
+	"github.com/GGP1/adak/pkg/shopping/ordering"
+	"github.com/GGP1/adak/pkg/shopping/shipment"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Service provides return operations.
+type Service interface {
+	Get(ctx context.Context, params params.Query) ([]Return, error)
+	GetByID(ctx context.Context, id string) (Return, error)
+	GetByUserID(ctx context.Context, userID string) ([]Return, error)
+	Refund(ctx context.Context, id, adminID string, restock bool, refund ordering.RefundFunc) (Return, error)
+	Request(ctx context.Context, r Return) error
+	UpdateStatus(ctx context.Context, id, to, note string) (Return, error)
+}
+
+type service struct {
+	db      *sqlx.DB
+	orders  ordering.Service
+	emailer email.Emailer
+	metrics metrics
+}
+
+// NewService returns a new returns service.
+func NewService(db *sqlx.DB, orders ordering.Service, emailer email.Emailer) Service {
+	return &service{db, orders, emailer, initMetrics()}
+}
+
+// Get returns a list with the returns of all the users.
+func (s *service) Get(ctx context.Context, params params.Query) ([]Return, error) {
+	s.metrics.incMethodCalls("Get")
+
+	var returns []Return
+	q, args := postgres.AddPagination("SELECT * FROM order_returns", params)
+	if err := s.db.SelectContext(ctx, &returns, q, args...); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the returns")
+	}
+
+	return returns, nil
+}
+
+// GetByID returns the return with the id provided.
+func (s *service) GetByID(ctx context.Context, id string) (Return, error) {
+	s.metrics.incMethodCalls("GetByID")
+
+	var r Return
+	if err := s.db.GetContext(ctx, &r, "SELECT * FROM order_returns WHERE id=$1", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Return{}, ErrNotFound
+		}
+		return Return{}, errors.Wrap(err, "couldn't find the return")
+	}
+
+	return r, nil
+}
+
+// GetByUserID returns the returns requested by a user, from the newest to the oldest.
+func (s *service) GetByUserID(ctx context.Context, userID string) ([]Return, error) {
+	s.metrics.incMethodCalls("GetByUserID")
+
+	var returns []Return
+	q := "SELECT * FROM order_returns WHERE user_id=$1 ORDER BY created_at DESC"
+	if err := s.db.SelectContext(ctx, &returns, q, userID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the returns")
+	}
+
+	return returns, nil
+}
+
+// Refund gives back the money paid for the products of a received return and, if restock
+// is true, puts them back in stock.
+func (s *service) Refund(ctx context.Context, id, adminID string, restock bool, refund ordering.RefundFunc) (Return, error) {
+	s.metrics.incMethodCalls("Refund")
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Return{}, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	r, err := getForUpdate(ctx, tx, id)
+	if err != nil {
+		return Return{}, err
+	}
+	if !canTransition(r.Status.String, Refunded) {
+		return Return{}, errors.Wrapf(ErrInvalidTransition, "%s -> %s", r.Status.String, Refunded)
+	}
+
+	lines := make([]ordering.RefundLine, len(r.Lines))
+	for i, l := range r.Lines {
+		lines[i] = ordering.RefundLine{ProductID: l.ProductID, VariantID: l.VariantID, Quantity: l.Quantity}
+	}
+	orderRefund, err := s.orders.RefundReturn(ctx, tx, r.OrderID.String, adminID, lines, restock, refund)
+	if err != nil {
+		return Return{}, err
+	}
+
+	r.Status = zero.StringFrom(Refunded)
+	r.RefundID = orderRefund.ID
+	r.Restocked = restock
+	r.UpdatedAt = zero.TimeFrom(time.Now())
+	q := "UPDATE order_returns SET status=$2, refund_id=$3, restocked=$4, updated_at=$5 WHERE id=$1"
+	if _, err := tx.ExecContext(ctx, q, id, r.Status, r.RefundID, r.Restocked, r.UpdatedAt); err != nil {
+		return Return{}, errors.Wrap(err, "couldn't update the return")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Return{}, errors.Wrap(err, "committing transaction")
+	}
+
+	s.notify(ctx, r)
+	return r, nil
+}
+
+// Request saves a return for products of the order that were delivered.
+func (s *service) Request(ctx context.Context, r Return) error {
+	s.metrics.incMethodCalls("Request")
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	// Lock the order so concurrent requests can't return the same units
+	var userID string
+	q := "SELECT user_id FROM orders WHERE id=$1 FOR UPDATE"
+	if err := tx.GetContext(ctx, &userID, q, r.OrderID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return errors.Wrap(err, "couldn't find the order")
+	}
+	if userID != r.UserID.String {
+		return ErrNotFound
+	}
+
+	delivered, err := getDelivered(ctx, tx, r.OrderID.String)
+	if err != nil {
+		return err
+	}
+	returned, err := getReturned(ctx, tx, r.OrderID.String)
+	if err != nil {
+		return err
+	}
+	if err := checkLines(delivered, returned, r.Lines); err != nil {
+		return err
+	}
+
+	insertQ := `INSERT INTO order_returns (id, order_id, user_id, reason, lines, status, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = tx.ExecContext(ctx, insertQ, r.ID, r.OrderID, r.UserID, r.Reason, r.Lines, r.Status, r.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create the return")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	s.notify(ctx, r)
+	return nil
+}
+
+// UpdateStatus approves, rejects or marks a return as received, the note is sent to the user.
+func (s *service) UpdateStatus(ctx context.Context, id, to, note string) (Return, error) {
+	s.metrics.incMethodCalls("UpdateStatus")
+
+	if to == Refunded {
+		return Return{}, errors.Wrap(ErrInvalidTransition, "returns are refunded through Refund")
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Return{}, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	r, err := getForUpdate(ctx, tx, id)
+	if err != nil {
+		return Return{}, err
+	}
+	if !canTransition(r.Status.String, to) {
+		return Return{}, errors.Wrapf(ErrInvalidTransition, "%s -> %s", r.Status.String, to)
+	}
+
+	r.Status = zero.StringFrom(to)
+	r.Note = zero.StringFrom(note)
+	r.UpdatedAt = zero.TimeFrom(time.Now())
+	q := "UPDATE order_returns SET status=$2, note=$3, updated_at=$4 WHERE id=$1"
+	if _, err := tx.ExecContext(ctx, q, id, r.Status, r.Note, r.UpdatedAt); err != nil {
+		return Return{}, errors.Wrap(err, "couldn't update the return")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Return{}, errors.Wrap(err, "committing transaction")
+	}
+
+	s.notify(ctx, r)
+	return r, nil
+}
+
+// notify emails the user the current status of the return, failures are only logged
+// as the change was already saved.
+func (s *service) notify(ctx context.Context, r Return) {
+	s.metrics.returns.With(prometheus.Labels{"status": r.Status.String}).Inc()
+
+	var user struct {
+		Username string `db:"username"`
+		Email    string `db:"email"`
+	}
+	q := "SELECT username, email FROM users WHERE id=$1"
+	if err := s.db.GetContext(ctx, &user, q, r.UserID); err != nil {
+		logger.Error(errors.Wrap(err, "couldn't find the user to notify"))
+		return
+	}
+
+	err := s.emailer.SendReturnStatus(user.Username, user.Email, r.ID.String, r.Status.String, r.Note.String)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+func getForUpdate(ctx context.Context, tx *sqlx.Tx, id string) (Return, error) {
+	var r Return
+	if err := tx.GetContext(ctx, &r, "SELECT * FROM order_returns WHERE id=$1 FOR UPDATE", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Return{}, ErrNotFound
+		}
+		return Return{}, errors.Wrap(err, "couldn't find the return")
+	}
+	return r, nil
+}
+
+// getDelivered returns the units of each product of the order that reached the user.
+func getDelivered(ctx context.Context, tx *sqlx.Tx, orderID string) ([]Line, error) {
+	q := `SELECT l.product_id, l.variant_id, SUM(l.quantity) AS quantity
+	FROM shipment_lines AS l
+	JOIN shipments AS s ON s.id=l.shipment_id
+	WHERE s.order_id=$1 AND s.status=$2
+	GROUP BY l.product_id, l.variant_id`
+	var delivered []Line
+	if err := tx.SelectContext(ctx, &delivered, q, orderID, shipment.Delivered); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the products delivered")
+	}
+	return delivered, nil
+}
+
+// getReturned returns the lines of the order returns that weren't rejected.
+func getReturned(ctx context.Context, tx *sqlx.Tx, orderID string) ([]Line, error) {
+	var lines []Lines
+	q := "SELECT lines FROM order_returns WHERE order_id=$1 AND status<>$2"
+	if err := tx.SelectContext(ctx, &lines, q, orderID, Rejected); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the order returns")
+	}
+
+	var returned []Line
+	for _, l := range lines {
+		returned = append(returned, l...)
+	}
+	return returned, nil
+}
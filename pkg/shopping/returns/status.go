@@ -0,0 +1,68 @@
+package returns
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Return statuses
+const (
+	Requested = "requested"
+	Approved  = "approved"
+	Rejected  = "rejected"
+	Received  = "received"
+	Refunded  = "refunded"
+)
+
+var (
+	// ErrInvalidTransition is returned when the return can't move from its current status to the one requested.
+	ErrInvalidTransition = errors.New("invalid return status transition")
+	// ErrNotFound is returned when the return or the order don't exist.
+	ErrNotFound = errors.New("return not found")
+	// ErrNotReturnable is returned when the lines requested weren't delivered or were already returned.
+	ErrNotReturnable = errors.New("the products can't be returned")
+)
+
+// transitions contains the statuses each status can move to, the ones missing are final.
+var transitions = map[string][]string{
+	Requested: {Approved, Rejected},
+	Approved:  {Received},
+	Received:  {Refunded},
+}
+
+// canTransition returns whether a return with the status "from" can move to "to".
+func canTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLines makes sure the lines requested were delivered and aren't part of other returns.
+//
+// delivered contains the units delivered of each product and returned the lines of the
+// returns that weren't rejected.
+func checkLines(delivered, returned, requested []Line) error {
+	left := make(map[string]int64, len(delivered))
+	for _, l := range delivered {
+		left[l.ProductID+"/"+l.VariantID] += l.Quantity
+	}
+	for _, l := range returned {
+		left[l.ProductID+"/"+l.VariantID] -= l.Quantity
+	}
+
+	for _, l := range requested {
+		key := l.ProductID + "/" + l.VariantID
+		units, ok := left[key]
+		if !ok {
+			return errors.Wrapf(ErrNotReturnable, "product %q wasn't delivered", l.ProductID)
+		}
+		if l.Quantity > units {
+			return errors.Wrapf(ErrNotReturnable, "product %q has %d units left to return", l.ProductID, units)
+		}
+		left[key] -= l.Quantity
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package returns
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		valid    bool
+	}{
+		{from: Requested, to: Approved, valid: true},
+		{from: Requested, to: Rejected, valid: true},
+		{from: Requested, to: Received, valid: false},
+		{from: Approved, to: Received, valid: true},
+		{from: Received, to: Refunded, valid: true},
+		{from: Rejected, to: Approved, valid: false},
+		{from: Refunded, to: Received, valid: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.from+" to "+tc.to, func(t *testing.T) {
+			assert.Equal(t, tc.valid, canTransition(tc.from, tc.to))
+		})
+	}
+}
+
+func TestCheckLines(t *testing.T) {
+	delivered := []Line{
+		{ProductID: "a", Quantity: 3},
+		{ProductID: "b", VariantID: "red", Quantity: 1},
+	}
+	returned := []Line{{ProductID: "a", Quantity: 1}}
+
+	cases := []struct {
+		desc      string
+		requested []Line
+		valid     bool
+	}{
+		{
+			desc:      "Units left",
+			requested: []Line{{ProductID: "a", Quantity: 2}, {ProductID: "b", VariantID: "red", Quantity: 1}},
+			valid:     true,
+		},
+		{
+			desc:      "Already returned",
+			requested: []Line{{ProductID: "a", Quantity: 3}},
+		},
+		{
+			desc:      "Repeated lines",
+			requested: []Line{{ProductID: "a", Quantity: 1}, {ProductID: "a", Quantity: 2}},
+		},
+		{
+			desc:      "Not delivered",
+			requested: []Line{{ProductID: "b", Quantity: 1}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkLines(delivered, returned, tc.requested)
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, ErrNotReturnable))
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package shipment
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Fulfillment is how far the shipments of an order got.
+type Fulfillment int
+
+// Order fulfillments
+const (
+	// Unfulfilled orders have no shipments
+	Unfulfilled Fulfillment = iota
+	// PartiallyShipped orders have units that weren't shipped yet
+	PartiallyShipped
+	// Shipped orders have all their units shipped, but not delivered
+	Shipped
+	// Fulfilled orders have all their units delivered
+	Fulfilled
+)
+
+// progress contains the units of an order product that must be shipped, were shipped
+// and were delivered.
+type progress struct {
+	ProductID string `db:"product_id"`
+	VariantID string `db:"variant_id"`
+	ShopID    string `db:"shop_id"`
+	// Due excludes the refunded units
+	Due       int64 `db:"due"`
+	Shipped   int64 `db:"shipped"`
+	Delivered int64 `db:"delivered"`
+}
+
+// fulfillment returns the fulfillment of an order given the progress of its products.
+func fulfillment(products []progress) Fulfillment {
+	var shipped, pending, undelivered bool
+	for _, p := range products {
+		if p.Shipped > 0 {
+			shipped = true
+		}
+		if p.Shipped < p.Due {
+			pending = true
+		}
+		if p.Delivered < p.Due {
+			undelivered = true
+		}
+	}
+
+	switch {
+	case !shipped:
+		return Unfulfilled
+	case pending:
+		return PartiallyShipped
+	case undelivered:
+		return Shipped
+	default:
+		return Fulfilled
+	}
+}
+
+// shopFulfillments returns the fulfillment of the part of an order sold by each shop.
+func shopFulfillments(products []progress) map[string]Fulfillment {
+	byShop := make(map[string][]progress)
+	for _, p := range products {
+		if p.ShopID != "" {
+			byShop[p.ShopID] = append(byShop[p.ShopID], p)
+		}
+	}
+
+	shops := make(map[string]Fulfillment, len(byShop))
+	for shopID, products := range byShop {
+		shops[shopID] = fulfillment(products)
+	}
+	return shops
+}
+
+// checkLines makes sure the lines belong to the order and don't exceed the units left to ship.
+func checkLines(products []progress, lines []Line) error {
+	shipping := make(map[string]int64, len(lines))
+	for _, l := range lines {
+		key := l.ProductID + "/" + l.VariantID
+		shipping[key] += l.Quantity
+
+		var found bool
+		for _, p := range products {
+			if p.ProductID != l.ProductID || p.VariantID != l.VariantID {
+				continue
+			}
+			found = true
+			if left := p.Due - p.Shipped; shipping[key] > left {
+				return errors.Wrapf(ErrInvalidLines, "product %q has %d units left to ship", l.ProductID, left)
+			}
+		}
+		if !found {
+			return errors.Wrapf(ErrInvalidLines, "product %q is not part of the order", l.ProductID)
+		}
+	}
+
+	return nil
+}
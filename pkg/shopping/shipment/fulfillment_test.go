@@ -0,0 +1,104 @@
+package shipment
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFulfillment(t *testing.T) {
+	cases := []struct {
+		desc     string
+		products []progress
+		expected Fulfillment
+	}{
+		{
+			desc:     "Nothing shipped",
+			products: []progress{{Due: 2}, {Due: 1}},
+			expected: Unfulfilled,
+		},
+		{
+			desc:     "Some units shipped",
+			products: []progress{{Due: 2, Shipped: 1}, {Due: 1}},
+			expected: PartiallyShipped,
+		},
+		{
+			desc:     "All units shipped",
+			products: []progress{{Due: 2, Shipped: 2, Delivered: 2}, {Due: 1, Shipped: 1}},
+			expected: Shipped,
+		},
+		{
+			desc:     "All units delivered",
+			products: []progress{{Due: 2, Shipped: 2, Delivered: 2}, {Due: 1, Shipped: 1, Delivered: 1}},
+			expected: Fulfilled,
+		},
+		{
+			desc:     "Refunded units left",
+			products: []progress{{Due: 1, Shipped: 1}, {Due: 0}},
+			expected: Shipped,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, fulfillment(tc.products))
+		})
+	}
+}
+
+func TestShopFulfillments(t *testing.T) {
+	products := []progress{
+		{ShopID: "a", Due: 2, Shipped: 2},
+		{ShopID: "a", Due: 1, Shipped: 1, Delivered: 1},
+		{ShopID: "b", Due: 3, Shipped: 1},
+		{ShopID: "c", Due: 1},
+		// Placed before splitting the orders by shop
+		{Due: 1, Shipped: 1},
+	}
+
+	expected := map[string]Fulfillment{"a": Shipped, "b": PartiallyShipped, "c": Unfulfilled}
+	assert.Equal(t, expected, shopFulfillments(products))
+}
+
+func TestCheckLines(t *testing.T) {
+	products := []progress{
+		{ProductID: "a", Due: 3, Shipped: 1},
+		{ProductID: "b", VariantID: "red", Due: 1},
+	}
+
+	cases := []struct {
+		desc  string
+		lines []Line
+		valid bool
+	}{
+		{
+			desc:  "Units left",
+			lines: []Line{{ProductID: "a", Quantity: 2}, {ProductID: "b", VariantID: "red", Quantity: 1}},
+			valid: true,
+		},
+		{
+			desc:  "Too many units",
+			lines: []Line{{ProductID: "a", Quantity: 1}, {ProductID: "a", Quantity: 2}},
+		},
+		{
+			desc:  "Wrong variant",
+			lines: []Line{{ProductID: "b", VariantID: "blue", Quantity: 1}},
+		},
+		{
+			desc:  "Not ordered",
+			lines: []Line{{ProductID: "c", Quantity: 1}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkLines(products, tc.lines)
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, ErrInvalidLines))
+		})
+	}
+}
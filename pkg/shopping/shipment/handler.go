@@ -0,0 +1,262 @@
+package shipment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/GGP1/adak/internal/cookie"
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/internal/sanitize"
+	"github.com/GGP1/adak/internal/validate"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+var errNotAllowed = errors.New("not found")
+
+// Admins tells whether a user is an administrator, it's satisfied by the user service.
+type Admins interface {
+	IsAdmin(ctx context.Context, id string) (bool, error)
+}
+
+// Handler handles shipment endpoints.
+type Handler struct {
+	service Service
+	admins  Admins
+	db      *sqlx.DB
+	cache   *memcache.Client
+}
+
+// NewHandler returns a new shipment handler.
+func NewHandler(service Service, admins Admins, db *sqlx.DB, cache *memcache.Client) Handler {
+	return Handler{
+		service: service,
+		admins:  admins,
+		db:      db,
+		cache:   cache,
+	}
+}
+
+// Create ships some or all the units of an order.
+//
+// Only administrators and the owners of the shops of all the products shipped are allowed.
+func (h *Handler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		orderID, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var shipment Shipment
+		if err := json.NewDecoder(r.Body).Decode(&shipment); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, shipment); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		userID, err := h.authorize(ctx, r, shipment.Lines)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		shipment.ID = zero.StringFrom(uuid.NewString())
+		shipment.OrderID = zero.StringFrom(orderID)
+		shipment.Carrier = zero.StringFrom(sanitize.Normalize(shipment.Carrier.String))
+		shipment.TrackingNumber = zero.StringFrom(sanitize.Normalize(shipment.TrackingNumber.String))
+		shipment.Status = zero.StringFrom(InTransit)
+		shipment.CreatedBy = zero.StringFrom(userID)
+		shipment.CreatedAt = zero.TimeFrom(time.Now())
+		if err := h.service.Create(ctx, shipment); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if err := h.invalidateOrders(ctx, orderID); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusCreated, shipment)
+	}
+}
+
+// Deliver marks a shipment as delivered.
+func (h *Handler) Deliver() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		shipment, err := h.service.GetByID(ctx, id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		userID, err := h.authorize(ctx, r, shipment.Lines)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if err := h.service.Deliver(ctx, id, userID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if err := h.invalidateOrders(ctx, shipment.OrderID.String); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "shipment "+id+" delivered")
+	}
+}
+
+// GetByOrderID lists the shipments of an order.
+func (h *Handler) GetByOrderID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		orderID, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		shipments, err := h.service.GetByOrderID(ctx, orderID)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, shipments)
+	}
+}
+
+// Update changes the carrier and tracking number of a shipment.
+func (h *Handler) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var update UpdateShipment
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, update); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		update.Carrier = zero.StringFrom(sanitize.Normalize(update.Carrier.String))
+		update.TrackingNumber = zero.StringFrom(sanitize.Normalize(update.TrackingNumber.String))
+
+		shipment, err := h.service.GetByID(ctx, id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if _, err := h.authorize(ctx, r, shipment.Lines); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if err := h.service.Update(ctx, id, update); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if err := h.invalidateOrders(ctx, shipment.OrderID.String); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, id)
+	}
+}
+
+// authorize returns the id of the user if it's an administrator or owns the shops
+// of the products in the lines.
+func (h *Handler) authorize(ctx context.Context, r *http.Request, lines []Line) (string, error) {
+	userID, err := cookie.GetValue(r, "UID")
+	if err != nil {
+		return "", errNotAllowed
+	}
+
+	isAdmin, err := h.admins.IsAdmin(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if isAdmin {
+		return userID, nil
+	}
+
+	owner, err := h.service.CanManage(ctx, userID, lines)
+	if err != nil {
+		return "", err
+	}
+	if !owner {
+		return "", errNotAllowed
+	}
+
+	return userID, nil
+}
+
+// invalidateOrders removes the orders of the user that placed the order from the cache,
+// they are cached by user id.
+func (h *Handler) invalidateOrders(ctx context.Context, orderID string) error {
+	var userID string
+	if err := h.db.GetContext(ctx, &userID, "SELECT user_id FROM orders WHERE id=$1", orderID); err != nil {
+		return errors.Wrap(err, "couldn't find the order")
+	}
+
+	if err := h.cache.Delete(userID); err != nil && err != memcache.ErrCacheMiss {
+		return errors.Wrap(err, "couldn't delete the orders from cache")
+	}
+
+	return nil
+}
+
+// writeError responds with the status code that corresponds to the shipment error provided.
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, errNotAllowed):
+		// Return 404 instead of 401 to not give additional information
+		response.Error(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrNotShippable):
+		response.Error(w, http.StatusConflict, err)
+	case errors.Is(err, ErrInvalidLines):
+		response.Error(w, http.StatusUnprocessableEntity, err)
+	default:
+		response.Error(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,41 @@
+package shipment
+
+import (
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Shipment statuses
+const (
+	// InTransit shipments were handed to the carrier
+	InTransit = "in_transit"
+	// Delivered shipments reached the user
+	Delivered = "delivered"
+)
+
+// Shipment is a package containing some or all the products of an order.
+type Shipment struct {
+	ID             zero.String `json:"id,omitempty"`
+	OrderID        zero.String `json:"order_id,omitempty" db:"order_id"`
+	Carrier        zero.String `json:"carrier,omitempty" validate:"required,max=60"`
+	TrackingNumber zero.String `json:"tracking_number,omitempty" db:"tracking_number" validate:"required,max=100"`
+	Status         zero.String `json:"status,omitempty"`
+	Lines          []Line      `json:"lines,omitempty" validate:"required,min=1,dive"`
+	CreatedBy      zero.String `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt      zero.Time   `json:"created_at,omitempty" db:"created_at"`
+	DeliveredAt    zero.Time   `json:"delivered_at,omitempty" db:"delivered_at"`
+}
+
+// Line is the number of units of an order product included in a shipment.
+type Line struct {
+	ShipmentID string `json:"-" db:"shipment_id"`
+	ProductID  string `json:"product_id" db:"product_id" validate:"required"`
+	// VariantID is empty for products without variants
+	VariantID string `json:"variant_id,omitempty" db:"variant_id"`
+	Quantity  int64  `json:"quantity" validate:"min=1"`
+}
+
+// UpdateShipment is the structure used to update shipments.
+type UpdateShipment struct {
+	Carrier        zero.String `json:"carrier,omitempty" validate:"required,max=60"`
+	TrackingNumber zero.String `json:"tracking_number,omitempty" validate:"required,max=100"`
+}
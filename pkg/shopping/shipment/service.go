@@ -0,0 +1,304 @@
+package shipment
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidLines is returned when the lines of a shipment don't match the units left to ship.
+	ErrInvalidLines = errors.New("invalid shipment lines")
+	// ErrNotFound is returned when the shipment or its order don't exist.
+	ErrNotFound = errors.New("shipment not found")
+	// ErrNotShippable is returned when the order status doesn't allow shipping it.
+	ErrNotShippable = errors.New("the order can't be shipped")
+)
+
+// Orders updates the status of the orders as their shipments progress.
+type Orders interface {
+	// Fulfill is called inside the transaction that modified the shipments of the order,
+	// it must return ErrNotShippable if the order status doesn't allow the change.
+	//
+	// shops contains the fulfillment of the part of the order sold by each shop.
+	Fulfill(ctx context.Context, tx *sqlx.Tx, orderID string, f Fulfillment, shops map[string]Fulfillment, changedBy string) error
+}
+
+// Service provides shipment operations.
+type Service interface {
+	CanManage(ctx context.Context, userID string, lines []Line) (bool, error)
+	Create(ctx context.Context, shipment Shipment) error
+	Deliver(ctx context.Context, id, deliveredBy string) error
+	GetByID(ctx context.Context, id string) (Shipment, error)
+	GetByOrderID(ctx context.Context, orderID string) ([]Shipment, error)
+	GetByOrderIDs(ctx context.Context, orderIDs []string) (map[string][]Shipment, error)
+	Update(ctx context.Context, id string, shipment UpdateShipment) error
+}
+
+type service struct {
+	db      *sqlx.DB
+	orders  Orders
+	metrics metrics
+}
+
+// NewService returns a new shipment service.
+func NewService(db *sqlx.DB, orders Orders) Service {
+	return &service{db, orders, initMetrics()}
+}
+
+// CanManage returns whether the user owns the shops of all the products in the lines.
+func (s *service) CanManage(ctx context.Context, userID string, lines []Line) (bool, error) {
+	s.metrics.incMethodCalls("CanManage")
+
+	ids := make(map[string]struct{}, len(lines))
+	productIDs := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if _, ok := ids[l.ProductID]; !ok {
+			ids[l.ProductID] = struct{}{}
+			productIDs = append(productIDs, l.ProductID)
+		}
+	}
+
+	var owned int
+	q := `SELECT COUNT(DISTINCT p.id) FROM products AS p
+	JOIN shop_owners AS o ON o.shop_id=p.shop_id
+	WHERE o.user_id=$1 AND p.id=ANY($2)`
+	if err := s.db.GetContext(ctx, &owned, q, userID, pq.Array(productIDs)); err != nil {
+		return false, errors.Wrap(err, "couldn't check the shops owned")
+	}
+
+	return len(productIDs) > 0 && owned == len(productIDs), nil
+}
+
+// Create saves a shipment with some or all the units of an order and updates its status.
+func (s *service) Create(ctx context.Context, shipment Shipment) error {
+	s.metrics.incMethodCalls("Create")
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	orderID := shipment.OrderID.String
+	products, err := lockProgress(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkLines(products, shipment.Lines); err != nil {
+		return err
+	}
+
+	shipmentQ := `INSERT INTO shipments
+	(id, order_id, carrier, tracking_number, status, created_by, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = tx.ExecContext(ctx, shipmentQ, shipment.ID, shipment.OrderID, shipment.Carrier,
+		shipment.TrackingNumber, InTransit, shipment.CreatedBy, shipment.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create the shipment")
+	}
+
+	lineQ := `INSERT INTO shipment_lines (shipment_id, product_id, variant_id, quantity)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (shipment_id, product_id, variant_id)
+	DO UPDATE SET quantity=shipment_lines.quantity+EXCLUDED.quantity`
+	for _, l := range shipment.Lines {
+		if _, err := tx.ExecContext(ctx, lineQ, shipment.ID, l.ProductID, l.VariantID, l.Quantity); err != nil {
+			return errors.Wrap(err, "couldn't save the shipment lines")
+		}
+	}
+
+	if err := s.fulfill(ctx, tx, orderID, shipment.CreatedBy.String); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	s.metrics.shipments.Inc()
+	return nil
+}
+
+// Deliver marks a shipment as delivered and updates the status of its order.
+func (s *service) Deliver(ctx context.Context, id, deliveredBy string) error {
+	s.metrics.incMethodCalls("Deliver")
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	var orderID string
+	if err := tx.GetContext(ctx, &orderID, "SELECT order_id FROM shipments WHERE id=$1", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return errors.Wrap(err, "couldn't find the shipment")
+	}
+
+	if _, err := lockProgress(ctx, tx, orderID); err != nil {
+		return err
+	}
+
+	q := "UPDATE shipments SET status=$2, delivered_at=$3 WHERE id=$1 AND status=$4"
+	res, err := tx.ExecContext(ctx, q, id, Delivered, time.Now(), InTransit)
+	if err != nil {
+		return errors.Wrap(err, "couldn't update the shipment")
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		// Already delivered
+		return err
+	}
+
+	if err := s.fulfill(ctx, tx, orderID, deliveredBy); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	s.metrics.deliveries.Inc()
+	return nil
+}
+
+// GetByID returns the shipment with the id provided.
+func (s *service) GetByID(ctx context.Context, id string) (Shipment, error) {
+	s.metrics.incMethodCalls("GetByID")
+
+	var shipment Shipment
+	if err := s.db.GetContext(ctx, &shipment, "SELECT * FROM shipments WHERE id=$1", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Shipment{}, ErrNotFound
+		}
+		return Shipment{}, errors.Wrap(err, "couldn't find the shipment")
+	}
+
+	if err := s.db.SelectContext(ctx, &shipment.Lines, "SELECT * FROM shipment_lines WHERE shipment_id=$1", id); err != nil {
+		return Shipment{}, errors.Wrap(err, "couldn't find the shipment lines")
+	}
+
+	return shipment, nil
+}
+
+// GetByOrderID returns the shipments of an order, from the oldest to the newest.
+func (s *service) GetByOrderID(ctx context.Context, orderID string) ([]Shipment, error) {
+	s.metrics.incMethodCalls("GetByOrderID")
+
+	shipments, err := s.getByOrderIDs(ctx, []string{orderID})
+	if err != nil {
+		return nil, err
+	}
+
+	return shipments[orderID], nil
+}
+
+// GetByOrderIDs returns the shipments of each of the orders provided.
+func (s *service) GetByOrderIDs(ctx context.Context, orderIDs []string) (map[string][]Shipment, error) {
+	s.metrics.incMethodCalls("GetByOrderIDs")
+	return s.getByOrderIDs(ctx, orderIDs)
+}
+
+// Update changes the carrier and tracking number of a shipment.
+func (s *service) Update(ctx context.Context, id string, shipment UpdateShipment) error {
+	s.metrics.incMethodCalls("Update")
+
+	q := "UPDATE shipments SET carrier=$2, tracking_number=$3 WHERE id=$1"
+	res, err := s.db.ExecContext(ctx, q, id, shipment.Carrier, shipment.TrackingNumber)
+	if err != nil {
+		return errors.Wrap(err, "couldn't update the shipment")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// fulfill updates the order status depending on the progress of its shipments.
+func (s *service) fulfill(ctx context.Context, tx *sqlx.Tx, orderID, changedBy string) error {
+	products, err := getProgress(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	return s.orders.Fulfill(ctx, tx, orderID, fulfillment(products), shopFulfillments(products), changedBy)
+}
+
+func (s *service) getByOrderIDs(ctx context.Context, orderIDs []string) (map[string][]Shipment, error) {
+	var shipments []Shipment
+	q := "SELECT * FROM shipments WHERE order_id=ANY($1) ORDER BY created_at"
+	if err := s.db.SelectContext(ctx, &shipments, q, pq.Array(orderIDs)); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the shipments")
+	}
+	if len(shipments) == 0 {
+		return map[string][]Shipment{}, nil
+	}
+
+	ids := make([]string, len(shipments))
+	for i, sh := range shipments {
+		ids[i] = sh.ID.String
+	}
+	var lines []Line
+	linesQ := "SELECT * FROM shipment_lines WHERE shipment_id=ANY($1)"
+	if err := s.db.SelectContext(ctx, &lines, linesQ, pq.Array(ids)); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the shipment lines")
+	}
+
+	byShipment := make(map[string][]Line, len(shipments))
+	for _, l := range lines {
+		byShipment[l.ShipmentID] = append(byShipment[l.ShipmentID], l)
+	}
+
+	byOrder := make(map[string][]Shipment, len(orderIDs))
+	for _, sh := range shipments {
+		sh.Lines = byShipment[sh.ID.String]
+		byOrder[sh.OrderID.String] = append(byOrder[sh.OrderID.String], sh)
+	}
+
+	return byOrder, nil
+}
+
+// lockProgress locks the order so its shipments can't be modified concurrently and
+// returns the progress of its products.
+func lockProgress(ctx context.Context, tx *sqlx.Tx, orderID string) ([]progress, error) {
+	var id string
+	if err := tx.GetContext(ctx, &id, "SELECT id FROM orders WHERE id=$1 FOR UPDATE", orderID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Wrap(err, "couldn't find the order")
+	}
+
+	return getProgress(ctx, tx, orderID)
+}
+
+func getProgress(ctx context.Context, tx *sqlx.Tx, orderID string) ([]progress, error) {
+	q := `SELECT op.product_id, COALESCE(op.variant_id, '') AS variant_id,
+	COALESCE(op.shop_id, '') AS shop_id, op.quantity-op.refunded AS due,
+	COALESCE(SUM(l.quantity), 0) AS shipped,
+	COALESCE(SUM(l.quantity) FILTER (WHERE s.status=$2), 0) AS delivered
+	FROM order_products AS op
+	LEFT JOIN shipments AS s ON s.order_id=op.order_id
+	LEFT JOIN shipment_lines AS l ON l.shipment_id=s.id
+	AND l.product_id=op.product_id AND l.variant_id=COALESCE(op.variant_id, '')
+	WHERE op.order_id=$1
+	GROUP BY op.product_id, op.variant_id, op.shop_id, op.quantity, op.refunded`
+	var products []progress
+	if err := tx.SelectContext(ctx, &products, q, orderID, Delivered); err != nil {
+		return nil, errors.Wrap(err, "couldn't calculate the order progress")
+	}
+
+	return products, nil
+}
@@ -0,0 +1,67 @@
+package shipping
+
+import (
+	"sort"
+	"strings"
+)
+
+// cost returns how much it costs to ship a cart with the method and false if the method
+// can't be used.
+func (m Method) cost(country string, weight, total int64) (int64, bool) {
+	if !m.covers(country) {
+		return 0, false
+	}
+
+	var cost int64
+	switch m.Type.String {
+	case Flat:
+		cost = m.Rate
+	case Weight:
+		i := sort.Search(len(m.Brackets), func(i int) bool {
+			return m.Brackets[i].MaxWeight >= weight
+		})
+		if i == len(m.Brackets) {
+			// The cart is heavier than the method allows
+			return 0, false
+		}
+		cost = m.Brackets[i].Rate
+	default:
+		return 0, false
+	}
+
+	if m.FreeAbove > 0 && total >= m.FreeAbove {
+		return 0, true
+	}
+
+	return cost, true
+}
+
+// covers returns whether the country is part of the method zone.
+func (m Method) covers(country string) bool {
+	if len(m.Countries) == 0 {
+		return true
+	}
+	for _, c := range m.Countries {
+		if strings.EqualFold(c, strings.TrimSpace(country)) {
+			return true
+		}
+	}
+	return false
+}
+
+// quote returns the methods that can ship the cart, from the cheapest to the most expensive.
+func quote(methods []Method, country string, weight, total int64) []Quote {
+	quotes := make([]Quote, 0, len(methods))
+	for _, m := range methods {
+		cost, ok := m.cost(country, weight, total)
+		if !ok {
+			continue
+		}
+		quotes = append(quotes, Quote{MethodID: m.ID.String, Name: m.Name.String, Cost: cost})
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return quotes[i].Cost < quotes[j].Cost
+	})
+	return quotes
+}
@@ -0,0 +1,59 @@
+package shipping
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestCost(t *testing.T) {
+	flat := Method{Type: zero.StringFrom(Flat), Rate: 500}
+	brackets := Method{
+		Type:     zero.StringFrom(Weight),
+		Brackets: Brackets{{MaxWeight: 1000, Rate: 300}, {MaxWeight: 5000, Rate: 800}},
+	}
+	free := Method{Type: zero.StringFrom(Flat), Rate: 500, FreeAbove: 10000}
+	zone := Method{Type: zero.StringFrom(Flat), Rate: 500, Countries: pq.StringArray{"AR", "UY"}}
+
+	cases := []struct {
+		desc    string
+		method  Method
+		country string
+		weight  int64
+		total   int64
+		cost    int64
+		ok      bool
+	}{
+		{desc: "flat", method: flat, country: "AR", weight: 9000, total: 100, cost: 500, ok: true},
+		{desc: "first bracket", method: brackets, country: "AR", weight: 1000, cost: 300, ok: true},
+		{desc: "second bracket", method: brackets, country: "AR", weight: 1001, cost: 800, ok: true},
+		{desc: "too heavy", method: brackets, country: "AR", weight: 5001},
+		{desc: "below threshold", method: free, country: "AR", total: 9999, cost: 500, ok: true},
+		{desc: "above threshold", method: free, country: "AR", total: 10000, cost: 0, ok: true},
+		{desc: "inside zone", method: zone, country: "uy", cost: 500, ok: true},
+		{desc: "outside zone", method: zone, country: "BR"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cost, ok := tc.method.cost(tc.country, tc.weight, tc.total)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.cost, cost)
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	methods := []Method{
+		{ID: zero.StringFrom("express"), Type: zero.StringFrom(Flat), Rate: 900},
+		{ID: zero.StringFrom("local"), Type: zero.StringFrom(Flat), Countries: pq.StringArray{"AR"}},
+		{ID: zero.StringFrom("standard"), Type: zero.StringFrom(Flat), Rate: 400},
+	}
+
+	quotes := quote(methods, "US", 1000, 1000)
+	assert.Equal(t, 2, len(quotes))
+	assert.Equal(t, "standard", quotes[0].MethodID)
+	assert.Equal(t, "express", quotes[1].MethodID)
+}
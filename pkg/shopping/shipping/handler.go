@@ -0,0 +1,183 @@
+package shipping
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/internal/validate"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+type cursorResponse struct {
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Methods    []Method `json:"methods,omitempty"`
+}
+
+// Handler handles shipping endpoints.
+type Handler struct {
+	service Service
+}
+
+// NewHandler returns a new shipping handler.
+func NewHandler(service Service) Handler {
+	return Handler{
+		service: service,
+	}
+}
+
+// Create creates a new shipping method and saves it.
+func (h *Handler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var method Method
+		if err := json.NewDecoder(r.Body).Decode(&method); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, method); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := validateMethod(method); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		method.ID = zero.StringFrom(uuid.NewString())
+		method.CreatedAt = zero.TimeFrom(time.Now())
+		if err := h.service.Create(ctx, method); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusCreated, method)
+	}
+}
+
+// Delete removes a shipping method.
+func (h *Handler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.service.Delete(ctx, id); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, id)
+	}
+}
+
+// Get lists all the shipping methods.
+func (h *Handler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		urlParams, err := params.ParseQuery(r.URL.RawQuery, params.Shipping)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		methods, err := h.service.Get(ctx, urlParams)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		var nextCursor string
+		if len(methods) > 0 {
+			nextCursor = params.EncodeCursor(
+				methods[len(methods)-1].CreatedAt.Time,
+				methods[len(methods)-1].ID.String,
+			)
+		}
+
+		response.JSON(w, http.StatusOK, cursorResponse{
+			NextCursor: nextCursor,
+			Methods:    methods,
+		})
+	}
+}
+
+// GetByID lists the shipping method with the id requested.
+func (h *Handler) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		method, err := h.service.GetByID(ctx, id)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, method)
+	}
+}
+
+// Update updates the shipping method with the given id.
+func (h *Handler) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var method Method
+		if err := json.NewDecoder(r.Body).Decode(&method); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, method); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := validateMethod(method); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.service.Update(ctx, id, method); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, id)
+	}
+}
+
+// validateMethod checks the fields that depend on the method type.
+func validateMethod(m Method) error {
+	if m.Type.String == Weight && len(m.Brackets) == 0 {
+		return errors.New("weight methods require at least one bracket")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package shipping
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Method types
+const (
+	// Flat methods cost Rate no matter the weight of the cart
+	Flat = "flat"
+	// Weight methods cost the rate of the first bracket that fits the cart weight
+	Weight = "weight"
+)
+
+// Method is a way of shipping the products to the user.
+//
+// Amounts to be provided in a currency’s smallest unit.
+// 100 = 1 USD.
+type Method struct {
+	ID   zero.String `json:"id,omitempty"`
+	Name zero.String `json:"name,omitempty" validate:"required,max=60"`
+	Type zero.String `json:"type,omitempty" validate:"required,oneof=flat weight"`
+	// Countries is the zone covered by the method, an empty list covers every country
+	Countries pq.StringArray `json:"countries,omitempty"`
+	Rate      int64          `json:"rate,omitempty" validate:"min=0"`
+	Brackets  Brackets       `json:"brackets,omitempty" validate:"dive"`
+	// FreeAbove makes the shipping free for carts whose total is equal or greater, zero disables it
+	FreeAbove int64     `json:"free_above,omitempty" db:"free_above" validate:"min=0"`
+	CreatedAt zero.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+// Bracket is the rate charged to the carts weighing up to MaxWeight.
+type Bracket struct {
+	MaxWeight int64 `json:"max_weight" validate:"min=1"`
+	Rate      int64 `json:"rate" validate:"min=0"`
+}
+
+// Brackets is a list of weight brackets sorted by their maximum weight.
+type Brackets []Bracket
+
+// Scan implements the sql.Scanner interface.
+func (b *Brackets) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*b = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, b)
+	case string:
+		return json.Unmarshal([]byte(v), b)
+	}
+	return errors.Errorf("cannot scan %T into weight brackets", src)
+}
+
+// Value implements the driver.Valuer interface.
+func (b Brackets) Value() (driver.Value, error) {
+	if b == nil {
+		return nil, nil
+	}
+	return json.Marshal(b)
+}
+
+// Quote is the cost of shipping a cart with a method.
+type Quote struct {
+	MethodID string `json:"method_id"`
+	Name     string `json:"name"`
+	Cost     int64  `json:"cost"`
+}
@@ -0,0 +1,166 @@
+// Package shipping calculates the cost of delivering the carts depending on their weight and destination.
+package shipping
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/pkg/postgres"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrMethodRequired is returned when ordering without a method and there are some available.
+	ErrMethodRequired = errors.New("a shipping method is required")
+	// ErrUnavailable is returned when the method chosen can't ship the cart to the destination.
+	ErrUnavailable = errors.New("the shipping method is not available for this cart and destination")
+)
+
+// Service contains shipping functionalities.
+type Service interface {
+	Choose(ctx context.Context, methodID, country string, weight, total int64) (Quote, error)
+	Create(ctx context.Context, m Method) error
+	Delete(ctx context.Context, id string) error
+	Get(ctx context.Context, params params.Query) ([]Method, error)
+	GetByID(ctx context.Context, id string) (Method, error)
+	Quote(ctx context.Context, country string, weight, total int64) ([]Quote, error)
+	Update(ctx context.Context, id string, m Method) error
+}
+
+type service struct {
+	db      *sqlx.DB
+	metrics metrics
+}
+
+// NewService returns a new shipping service.
+func NewService(db *sqlx.DB) Service {
+	return &service{db, initMetrics()}
+}
+
+// Choose returns the cost of shipping the cart with the method provided.
+//
+// An empty method id is only accepted when none of the methods can ship the cart,
+// the quote returned is then empty.
+func (s *service) Choose(ctx context.Context, methodID, country string, weight, total int64) (Quote, error) {
+	s.metrics.incMethodCalls("Choose")
+
+	if methodID == "" {
+		quotes, err := s.Quote(ctx, country, weight, total)
+		if err != nil {
+			return Quote{}, err
+		}
+		if len(quotes) > 0 {
+			return Quote{}, ErrMethodRequired
+		}
+		return Quote{}, nil
+	}
+
+	var m Method
+	if err := s.db.GetContext(ctx, &m, "SELECT * FROM shipping_methods WHERE id=$1", methodID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Quote{}, ErrUnavailable
+		}
+		return Quote{}, errors.Wrap(err, "couldn't find the shipping method")
+	}
+
+	cost, ok := m.cost(country, weight, total)
+	if !ok {
+		return Quote{}, ErrUnavailable
+	}
+
+	return Quote{MethodID: m.ID.String, Name: m.Name.String, Cost: cost}, nil
+}
+
+// Create a shipping method.
+func (s *service) Create(ctx context.Context, m Method) error {
+	s.metrics.incMethodCalls("Create")
+
+	sortBrackets(m.Brackets)
+	q := `INSERT INTO shipping_methods
+	(id, name, type, countries, rate, brackets, free_above, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := s.db.ExecContext(ctx, q, m.ID, m.Name, m.Type, m.Countries,
+		m.Rate, m.Brackets, m.FreeAbove, m.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create the shipping method")
+	}
+
+	return nil
+}
+
+// Delete permanently deletes a shipping method from the database.
+//
+// The orders keep the name and cost of the method they were shipped with.
+func (s *service) Delete(ctx context.Context, id string) error {
+	s.metrics.incMethodCalls("Delete")
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM shipping_methods WHERE id=$1", id); err != nil {
+		return errors.Wrap(err, "couldn't delete the shipping method")
+	}
+
+	return nil
+}
+
+// Get returns a list with all the shipping methods stored in the database.
+func (s *service) Get(ctx context.Context, params params.Query) ([]Method, error) {
+	s.metrics.incMethodCalls("Get")
+
+	var methods []Method
+	q, args := postgres.AddPagination("SELECT * FROM shipping_methods", params)
+	if err := s.db.SelectContext(ctx, &methods, q, args...); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the shipping methods")
+	}
+
+	return methods, nil
+}
+
+// GetByID returns the shipping method with the id provided.
+func (s *service) GetByID(ctx context.Context, id string) (Method, error) {
+	s.metrics.incMethodCalls("GetByID")
+
+	var m Method
+	if err := s.db.GetContext(ctx, &m, "SELECT * FROM shipping_methods WHERE id=$1", id); err != nil {
+		return Method{}, errors.Wrap(err, "couldn't find the shipping method")
+	}
+
+	return m, nil
+}
+
+// Quote returns the methods that can ship a cart with the weight and total provided
+// to the country, sorted by cost.
+func (s *service) Quote(ctx context.Context, country string, weight, total int64) ([]Quote, error) {
+	s.metrics.incMethodCalls("Quote")
+
+	var methods []Method
+	if err := s.db.SelectContext(ctx, &methods, "SELECT * FROM shipping_methods ORDER BY name"); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the shipping methods")
+	}
+
+	return quote(methods, country, weight, total), nil
+}
+
+// Update updates a shipping method.
+func (s *service) Update(ctx context.Context, id string, m Method) error {
+	s.metrics.incMethodCalls("Update")
+
+	sortBrackets(m.Brackets)
+	q := `UPDATE shipping_methods SET
+	name=$2, type=$3, countries=$4, rate=$5, brackets=$6, free_above=$7
+	WHERE id=$1`
+	_, err := s.db.ExecContext(ctx, q, id, m.Name, m.Type, m.Countries, m.Rate, m.Brackets, m.FreeAbove)
+	if err != nil {
+		return errors.Wrap(err, "couldn't update the shipping method")
+	}
+
+	return nil
+}
+
+func sortBrackets(brackets Brackets) {
+	sort.Slice(brackets, func(i, j int) bool {
+		return brackets[i].MaxWeight < brackets[j].MaxWeight
+	})
+}
@@ -0,0 +1,307 @@
+package subscription
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/GGP1/adak/internal/cookie"
+	"github.com/GGP1/adak/internal/logger"
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/internal/sanitize"
+	"github.com/GGP1/adak/internal/validate"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/payment"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+const dateLayout = "2006-01-02"
+
+// subscribeRequest holds the parameters for subscribing to the user cart.
+type subscribeRequest struct {
+	Frequency string `json:"frequency" validate:"required,oneof=weekly monthly custom"`
+	// IntervalDays is required by the custom frequency
+	IntervalDays   int64  `json:"interval_days" validate:"min=0,max=365"`
+	Currency       string `json:"currency" validate:"required,len=3"`
+	Address        string `json:"address" validate:"required"`
+	City           string `json:"city" validate:"required"`
+	Country        string `json:"country" validate:"required"`
+	State          string `json:"state" validate:"required"`
+	ZipCode        string `json:"zip_code" validate:"required"`
+	ShippingMethod string `json:"shipping_method"`
+	// PaymentMethodID is the id of the method saved by the user that is charged
+	PaymentMethodID string `json:"payment_method_id" validate:"required"`
+	// StartAt is the day of the first order in the 2006-01-02 format, it's placed right
+	// away if omitted
+	StartAt string `json:"start_at"`
+}
+
+// Handler handles subscriptions endpoints.
+type Handler struct {
+	service        Service
+	cartService    cart.Service
+	paymentService payment.Service
+}
+
+// NewHandler returns a new subscriptions handler.
+func NewHandler(service Service, cartS cart.Service, paymentS payment.Service) Handler {
+	return Handler{
+		service:        service,
+		cartService:    cartS,
+		paymentService: paymentS,
+	}
+}
+
+// Cancel stops ordering a subscription of the user permanently.
+func (h *Handler) Cancel() http.HandlerFunc {
+	return h.update("cancelled", h.service.Cancel)
+}
+
+// Create subscribes the user to the products in the cart, they are ordered with the address
+// and payment method provided at the frequency chosen.
+func (h *Handler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		cartID, err := cookie.GetValue(r, "CID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+		userID, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		var req subscribeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		startAt, err := validateRequest(ctx, &req)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		cartProducts, err := h.cartService.CartProducts(ctx, cartID)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if len(cartProducts) == 0 {
+			response.Error(w, http.StatusBadRequest, errors.New("subscribing to an empty cart is not permitted"))
+			return
+		}
+
+		sub := Subscription{
+			ID:             zero.StringFrom(uuid.NewString()),
+			UserID:         zero.StringFrom(userID),
+			CartID:         zero.StringFrom(uuid.NewString()),
+			Status:         zero.StringFrom(Active),
+			Frequency:      zero.StringFrom(req.Frequency),
+			IntervalDays:   req.IntervalDays,
+			AnchorDay:      int64(startAt.Day()),
+			Currency:       zero.StringFrom(req.Currency),
+			Address:        zero.StringFrom(req.Address),
+			City:           zero.StringFrom(req.City),
+			Country:        zero.StringFrom(req.Country),
+			State:          zero.StringFrom(req.State),
+			ZipCode:        zero.StringFrom(req.ZipCode),
+			ShippingMethod: zero.StringFrom(req.ShippingMethod),
+			NextOrderAt:    zero.TimeFrom(startAt),
+			CreatedAt:      zero.TimeFrom(time.Now()),
+		}
+		for _, p := range cartProducts {
+			sub.Products = append(sub.Products, Product{
+				SubscriptionID: sub.ID,
+				ProductID:      p.ID,
+				VariantID:      p.VariantID,
+				Quantity:       p.Quantity,
+			})
+		}
+
+		method, err := h.paymentService.GetMethod(ctx, userID, req.PaymentMethodID)
+		if err != nil {
+			if errors.Is(err, payment.ErrNotFound) {
+				response.Error(w, http.StatusBadRequest, err)
+				return
+			}
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		sub.CustomerID = method.CustomerID
+		sub.PaymentMethodID = method.ID
+
+		// Each subscription has its own cart so ordering it doesn't touch the user one
+		if err := h.cartService.Create(ctx, sub.CartID.String); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if err := h.service.Create(ctx, sub); err != nil {
+			if err := h.cartService.Delete(ctx, sub.CartID.String); err != nil {
+				logger.Error(err)
+			}
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusCreated, sub)
+	}
+}
+
+// Get lists the subscriptions of the user logged in.
+func (h *Handler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID, err := cookie.GetValue(r, "UID")
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+
+		subs, err := h.service.GetByUserID(ctx, userID)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, subs)
+	}
+}
+
+// GetByID returns a subscription of the user logged in.
+func (h *Handler) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, userID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		sub, err := h.service.GetByID(ctx, id, userID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, sub)
+	}
+}
+
+// Pause stops ordering a subscription of the user until it's resumed.
+func (h *Handler) Pause() http.HandlerFunc {
+	return h.update("paused", h.service.Pause)
+}
+
+// Resume orders a paused subscription of the user again.
+func (h *Handler) Resume() http.HandlerFunc {
+	return h.update("resumed", h.service.Resume)
+}
+
+// Skip postpones the next order of a subscription of the user.
+func (h *Handler) Skip() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, userID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		next, err := h.service.Skip(ctx, id, userID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "next order on "+next.Format(dateLayout))
+	}
+}
+
+// update applies a status change to a subscription of the user.
+func (h *Handler) update(action string, fn func(ctx context.Context, id, userID string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, userID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		if err := fn(ctx, id, userID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "subscription "+id+" "+action)
+	}
+}
+
+// ids returns the subscription id from the url and the id of the user logged in.
+func ids(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	id, err := params.URLID(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err)
+		return "", "", false
+	}
+	userID, err := cookie.GetValue(r, "UID")
+	if err != nil {
+		response.Error(w, http.StatusForbidden, err)
+		return "", "", false
+	}
+	return id, userID, true
+}
+
+// validateRequest validates and normalizes the request and returns the date of the first order.
+func validateRequest(ctx context.Context, req *subscribeRequest) (time.Time, error) {
+	if err := validate.Struct(ctx, req); err != nil {
+		return time.Time{}, err
+	}
+	if req.Frequency == Custom && req.IntervalDays < 1 {
+		return time.Time{}, errors.New("the custom frequency requires an interval of at least one day")
+	}
+	if req.Frequency != Custom {
+		req.IntervalDays = 0
+	}
+	req.Address = sanitize.Normalize(req.Address)
+	req.City = sanitize.Normalize(req.City)
+	req.Country = sanitize.Normalize(req.Country)
+	req.Currency = sanitize.Normalize(req.Currency)
+	req.ShippingMethod = sanitize.Normalize(req.ShippingMethod)
+	req.State = sanitize.Normalize(req.State)
+	req.ZipCode = sanitize.Normalize(req.ZipCode)
+
+	now := time.Now()
+	if req.StartAt == "" {
+		return now, nil
+	}
+	startAt, err := time.ParseInLocation(dateLayout, req.StartAt, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("invalid start date")
+	}
+	if startAt.Before(now) {
+		if startAt.Format(dateLayout) != now.Format(dateLayout) {
+			return time.Time{}, errors.New("past dates are not valid")
+		}
+		return now, nil
+	}
+	return startAt, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		response.Error(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrInvalidStatus):
+		response.Error(w, http.StatusConflict, err)
+	default:
+		response.Error(w, http.StatusInternalServerError, err)
+	}
+}
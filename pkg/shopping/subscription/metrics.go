@@ -0,0 +1,56 @@
+package subscription
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type metrics struct {
+	methodCalls *prometheus.CounterVec
+}
+
+func initMetrics() metrics {
+	const ns, sub = "adak", "subscriptions"
+	return metrics{
+		methodCalls: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: sub,
+			Name:      "method_calls_total",
+			Help:      "Total number of calls per method",
+		}, []string{"method"}),
+	}
+}
+
+func (m metrics) incMethodCalls(method string) {
+	m.methodCalls.With(prometheus.Labels{"method": method}).Inc()
+}
+
+type schedulerMetrics struct {
+	ordered prometheus.Counter
+	failed  prometheus.Counter
+	paused  prometheus.Counter
+}
+
+func initSchedulerMetrics() schedulerMetrics {
+	const ns, sub = "adak", "subscription_scheduler"
+	return schedulerMetrics{
+		ordered: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: sub,
+			Name:      "ordered_total",
+			Help:      "Total number of subscription orders placed",
+		}),
+		failed: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: sub,
+			Name:      "failed_total",
+			Help:      "Total number of attempts to order a subscription that failed",
+		}),
+		paused: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: sub,
+			Name:      "paused_total",
+			Help:      "Total number of subscriptions paused after running out of retries",
+		}),
+	}
+}
@@ -0,0 +1,69 @@
+package subscription
+
+import (
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Frequencies at which the orders are placed
+const (
+	Weekly  = "weekly"
+	Monthly = "monthly"
+	// Custom places an order every IntervalDays
+	Custom = "custom"
+)
+
+// Subscription statuses
+const (
+	// Active subscriptions are ordered when their next order date comes
+	Active = "active"
+	// Paused subscriptions aren't ordered until they are resumed, it's also set after
+	// running out of retries
+	Paused = "paused"
+	// Cancelled subscriptions are never ordered again
+	Cancelled = "cancelled"
+)
+
+// Subscription is a cart that is ordered periodically with the address and payment
+// method saved when subscribing.
+type Subscription struct {
+	ID     zero.String `json:"id,omitempty"`
+	UserID zero.String `json:"user_id,omitempty" db:"user_id"`
+	// CartID is the cart filled with the subscription products on each order
+	CartID    zero.String `json:"cart_id,omitempty" db:"cart_id"`
+	Status    zero.String `json:"status,omitempty"`
+	Frequency zero.String `json:"frequency,omitempty"`
+	// IntervalDays is the number of days between orders of the custom frequency
+	IntervalDays int64 `json:"interval_days,omitempty" db:"interval_days"`
+	// AnchorDay is the day of the month of the monthly orders, the last day is used in the
+	// shorter months
+	AnchorDay       int64       `json:"anchor_day,omitempty" db:"anchor_day"`
+	Currency        zero.String `json:"currency,omitempty"`
+	Address         zero.String `json:"address,omitempty"`
+	City            zero.String `json:"city,omitempty"`
+	Country         zero.String `json:"country,omitempty"`
+	State           zero.String `json:"state,omitempty"`
+	ZipCode         zero.String `json:"zip_code,omitempty" db:"zip_code"`
+	ShippingMethod  zero.String `json:"shipping_method,omitempty" db:"shipping_method"`
+	CustomerID      zero.String `json:"-" db:"customer_id"`
+	PaymentMethodID zero.String `json:"-" db:"payment_method_id"`
+	NextOrderAt     zero.Time   `json:"next_order_at,omitempty" db:"next_order_at"`
+	// Failures is the number of consecutive attempts to order that failed
+	Failures    zero.Int    `json:"failures,omitempty"`
+	LastOrderID zero.String `json:"last_order_id,omitempty" db:"last_order_id"`
+	// PendingOrderID is the order placed by the attempt in progress, it's recorded before
+	// charging it so an interrupted attempt is resumed instead of charging another order
+	PendingOrderID zero.String `json:"-" db:"pending_order_id"`
+	LastError      zero.String `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      zero.Time   `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt      zero.Time   `json:"updated_at,omitempty" db:"updated_at"`
+	Products       []Product   `json:"products,omitempty"`
+}
+
+// Product is a product ordered on every delivery of a subscription.
+type Product struct {
+	SubscriptionID zero.String `json:"subscription_id,omitempty" db:"subscription_id"`
+	ProductID      zero.String `json:"product_id,omitempty" db:"product_id"`
+	// VariantID is empty for products without variants
+	VariantID zero.String `json:"variant_id,omitempty" db:"variant_id"`
+	Quantity  zero.Int    `json:"quantity,omitempty"`
+}
@@ -0,0 +1,93 @@
+package subscription
+
+import (
+	"time"
+
+	"github.com/GGP1/adak/pkg/shopping/delivery"
+)
+
+// next returns the date of the order that follows the one placed at t.
+func (s Subscription) next(t time.Time) time.Time {
+	switch s.Frequency.String {
+	case Weekly:
+		return t.AddDate(0, 0, 7)
+	case Monthly:
+		return nextMonthDay(t, int(s.AnchorDay))
+	default:
+		days := int(s.IntervalDays)
+		if days < 1 {
+			days = 1
+		}
+		return t.AddDate(0, 0, days)
+	}
+}
+
+// nextAfter returns the first order date after now, the ones missed while the subscription
+// couldn't be ordered are skipped.
+func (s Subscription) nextAfter(t, now time.Time) time.Time {
+	t = s.next(t)
+	for !t.After(now) {
+		t = s.next(t)
+	}
+	return t
+}
+
+// nextMonthDay returns the first date after t on the day of the month provided, or on the
+// last day of the months that are shorter. The time of t is kept.
+func nextMonthDay(t time.Time, day int) time.Time {
+	if day < 1 {
+		day = t.Day()
+	}
+	year, month, _ := t.Date()
+	// Retries may have moved t before the day in its own month
+	if d := monthDay(year, month, day); d > t.Day() {
+		return time.Date(year, month, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	}
+	month++
+	return time.Date(year, month, monthDay(year, month, day), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// monthDay returns the day provided or the last day of the month if it has less days.
+func monthDay(year int, month time.Month, day int) int {
+	// The day zero of a month is the last day of the previous one
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if day > last {
+		return last
+	}
+	return day
+}
+
+// retryDelay returns the time waited before ordering again after a number of failures.
+func retryDelay(base time.Duration, failures int64) time.Duration {
+	if failures < 1 {
+		failures = 1
+	}
+	return base * time.Duration(failures)
+}
+
+// earliestSlots chooses the first slot available of each shop, delivery slots are
+// preferred over pickup ones as the orders are placed without the user present.
+//
+// The slots must be sorted by their start.
+func earliestSlots(available []delivery.Availability) []delivery.Booking {
+	chosen := make(map[string]delivery.Availability)
+	var shops []string
+	for _, a := range available {
+		c, ok := chosen[a.ShopID]
+		if !ok {
+			shops = append(shops, a.ShopID)
+			chosen[a.ShopID] = a
+			continue
+		}
+		if c.Type != delivery.Delivery && a.Type == delivery.Delivery {
+			chosen[a.ShopID] = a
+		}
+	}
+
+	bookings := make([]delivery.Booking, 0, len(shops))
+	for _, shopID := range shops {
+		c := chosen[shopID]
+		bookings = append(bookings, delivery.Booking{SlotID: c.SlotID, Date: c.Date})
+	}
+	return bookings
+}
@@ -0,0 +1,108 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GGP1/adak/pkg/shopping/delivery"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestNext(t *testing.T) {
+	t0 := time.Date(2022, time.January, 31, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		desc     string
+		sub      Subscription
+		expected time.Time
+	}{
+		{
+			desc:     "Weekly",
+			sub:      Subscription{Frequency: zero.StringFrom(Weekly)},
+			expected: time.Date(2022, time.February, 7, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			desc: "Monthly",
+			sub:  Subscription{Frequency: zero.StringFrom(Monthly), AnchorDay: 31},
+			// February doesn't have 31 days
+			expected: time.Date(2022, time.February, 28, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:     "Monthly without anchor",
+			sub:      Subscription{Frequency: zero.StringFrom(Monthly)},
+			expected: time.Date(2022, time.February, 28, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:     "Custom",
+			sub:      Subscription{Frequency: zero.StringFrom(Custom), IntervalDays: 10},
+			expected: time.Date(2022, time.February, 10, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			desc:     "Custom without interval",
+			sub:      Subscription{Frequency: zero.StringFrom(Custom)},
+			expected: time.Date(2022, time.February, 1, 10, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.sub.next(t0))
+		})
+	}
+}
+
+func TestNextMonthly(t *testing.T) {
+	sub := Subscription{Frequency: zero.StringFrom(Monthly), AnchorDay: 31}
+
+	// The day is kept after the shorter months
+	t0 := time.Date(2022, time.February, 28, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2022, time.March, 31, 10, 0, 0, 0, time.UTC), sub.next(t0))
+	t0 = time.Date(2022, time.December, 31, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2023, time.January, 31, 10, 0, 0, 0, time.UTC), sub.next(t0))
+
+	// An order retried on the next day of the month isn't skipped
+	t0 = time.Date(2022, time.April, 1, 16, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2022, time.April, 30, 16, 0, 0, 0, time.UTC), sub.next(t0))
+
+	sub.AnchorDay = 15
+	t0 = time.Date(2022, time.January, 15, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2022, time.February, 15, 10, 0, 0, 0, time.UTC), sub.next(t0))
+}
+
+func TestNextAfter(t *testing.T) {
+	sub := Subscription{Frequency: zero.StringFrom(Weekly)}
+	scheduled := time.Date(2022, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+	// Ordered on time
+	now := time.Date(2022, time.March, 1, 9, 5, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2022, time.March, 8, 9, 0, 0, 0, time.UTC), sub.nextAfter(scheduled, now))
+
+	// Ordered late, the dates missed are skipped
+	now = time.Date(2022, time.March, 17, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2022, time.March, 22, 9, 0, 0, 0, time.UTC), sub.nextAfter(scheduled, now))
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Hour, retryDelay(time.Hour, 0))
+	assert.Equal(t, time.Hour, retryDelay(time.Hour, 1))
+	assert.Equal(t, 3*time.Hour, retryDelay(time.Hour, 3))
+}
+
+func TestEarliestSlots(t *testing.T) {
+	available := []delivery.Availability{
+		{SlotID: "pickup-a", ShopID: "a", Type: delivery.Pickup, Date: "2022-03-08"},
+		{SlotID: "delivery-b", ShopID: "b", Type: delivery.Delivery, Date: "2022-03-08"},
+		{SlotID: "delivery-a", ShopID: "a", Type: delivery.Delivery, Date: "2022-03-09"},
+		{SlotID: "later-a", ShopID: "a", Type: delivery.Delivery, Date: "2022-03-10"},
+		{SlotID: "pickup-c", ShopID: "c", Type: delivery.Pickup, Date: "2022-03-10"},
+	}
+
+	expected := []delivery.Booking{
+		{SlotID: "delivery-a", Date: "2022-03-09"},
+		{SlotID: "delivery-b", Date: "2022-03-08"},
+		{SlotID: "pickup-c", Date: "2022-03-10"},
+	}
+	assert.Equal(t, expected, earliestSlots(available))
+	assert.Empty(t, earliestSlots(nil))
+}
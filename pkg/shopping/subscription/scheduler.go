@@ -0,0 +1,431 @@
+package subscription
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/internal/email"
+	"github.com/GGP1/adak/internal/logger"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/delivery"
+	"github.com/GGP1/adak/pkg/shopping/ordering"
+	"github.com/GGP1/adak/pkg/shopping/payment"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+const (
+	defaultInterval   = 10 * time.Minute
+	defaultMaxRetries = 3
+	defaultRetryAfter = 6 * time.Hour
+	// claimTTL is the time a subscription is held by the instance ordering it, it's
+	// ordered again after it if the instance stopped before finishing
+	claimTTL = time.Hour
+	// deliveryLead is the time between ordering and the delivery date of the products
+	// of shops that don't define delivery slots
+	deliveryLead = 48 * time.Hour
+	// schedulerBatch is the maximum number of subscriptions ordered on each run
+	schedulerBatch = 50
+)
+
+// errNotified is returned when the failed payment of an order was handled through
+// PaymentFailed before the scheduler did it.
+var errNotified = errors.New("the failed payment was notified already")
+
+// Scheduler places the orders of the subscriptions when their date comes.
+type Scheduler struct {
+	db         *sqlx.DB
+	orders     ordering.Service
+	carts      cart.Service
+	delivery   delivery.Service
+	emailer    email.Emailer
+	provider   payment.Provider
+	interval   time.Duration
+	maxRetries int64
+	retryAfter time.Duration
+	metrics    schedulerMetrics
+}
+
+// pendingOrder is an order placed by the scheduler that may not have been charged yet.
+type pendingOrder struct {
+	ID              string `db:"id"`
+	Status          int64  `db:"status"`
+	PaymentIntentID string `db:"payment_intent_id"`
+	Currency        string `db:"currency"`
+	Amount          int64  `db:"amount"`
+}
+
+type subscriber struct {
+	Username string `db:"username"`
+	Email    string `db:"email"`
+}
+
+// NewScheduler returns a new subscriptions scheduler.
+func NewScheduler(db *sqlx.DB, orders ordering.Service, carts cart.Service, delivery delivery.Service,
+	emailer email.Emailer, provider payment.Provider, config config.Subscription) *Scheduler {
+	interval := config.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	maxRetries := int64(config.MaxRetries)
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	retryAfter := config.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = defaultRetryAfter
+	}
+	return &Scheduler{
+		db:         db,
+		orders:     orders,
+		carts:      carts,
+		delivery:   delivery,
+		emailer:    emailer,
+		provider:   provider,
+		interval:   interval,
+		maxRetries: maxRetries,
+		retryAfter: retryAfter,
+		metrics:    initSchedulerMetrics(),
+	}
+}
+
+// Run places the orders due periodically until the context is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.Process(ctx)
+			if err != nil {
+				logger.Error(err)
+				continue
+			}
+			if n > 0 {
+				logger.Debugf("Placed %d subscription orders", n)
+			}
+		}
+	}
+}
+
+// Process orders the active subscriptions whose date has come and returns how many
+// orders were placed.
+//
+// Failed attempts are retried after a delay that grows with each failure, the subscription
+// is paused once it runs out of retries. The user is emailed in both cases. Payments that
+// fail after the order was placed are retried the same way through PaymentFailed.
+func (s *Scheduler) Process(ctx context.Context) (int64, error) {
+	var subs []Subscription
+	q := "SELECT * FROM subscriptions WHERE status=$1 AND next_order_at <= $2 ORDER BY next_order_at LIMIT $3"
+	if err := s.db.SelectContext(ctx, &subs, q, Active, time.Now(), schedulerBatch); err != nil {
+		return 0, errors.Wrap(err, "couldn't find the subscriptions due")
+	}
+
+	var ordered int64
+	for _, sub := range subs {
+		ok, err := s.claim(ctx, sub)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		orderID, err := s.order(ctx, sub)
+		if err != nil {
+			if errors.Is(err, errNotified) {
+				continue
+			}
+			if err := s.fail(ctx, sub, err); err != nil {
+				logger.Error(err)
+			}
+			continue
+		}
+
+		if err := s.succeed(ctx, sub, orderID); err != nil {
+			logger.Error(err)
+		}
+		ordered++
+	}
+
+	s.metrics.ordered.Add(float64(ordered))
+	return ordered, nil
+}
+
+// claim postpones the subscription while it's ordered, it returns false if another
+// instance claimed it first or the user changed it in the meantime.
+func (s *Scheduler) claim(ctx context.Context, sub Subscription) (bool, error) {
+	q := "UPDATE subscriptions SET next_order_at=$3 WHERE id=$1 AND next_order_at=$2 AND status=$4"
+	res, err := s.db.ExecContext(ctx, q, sub.ID, sub.NextOrderAt, time.Now().Add(claimTTL), Active)
+	if err != nil {
+		return false, errors.Wrap(err, "couldn't claim the subscription")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// order places the order of the subscription and charges it.
+//
+// The order is recorded in the subscription before charging it, if the attempt is interrupted
+// the next one charges the same order with the same idempotency key instead of placing another.
+func (s *Scheduler) order(ctx context.Context, sub Subscription) (string, error) {
+	if sub.PendingOrderID.Valid {
+		orderID, ok, err := s.resume(ctx, sub)
+		if err != nil || ok {
+			return orderID, err
+		}
+	}
+
+	order, err := s.place(ctx, sub)
+	if err != nil {
+		return "", err
+	}
+
+	return s.charge(ctx, sub, order)
+}
+
+// resume finishes the attempt that placed the pending order of the subscription, it returns
+// false if the order can't be charged anymore and a new one must be placed.
+func (s *Scheduler) resume(ctx context.Context, sub Subscription) (string, bool, error) {
+	var order pendingOrder
+	q := `SELECT o.id, COALESCE(o.status, 0) AS status, COALESCE(o.payment_intent_id, '') AS payment_intent_id,
+	COALESCE(o.currency, '') AS currency, COALESCE(c.converted_total, 0) AS amount
+	FROM orders AS o
+	JOIN order_carts AS c ON c.order_id=o.id
+	WHERE o.id=$1`
+	if err := s.db.GetContext(ctx, &order, q, sub.PendingOrderID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, errors.Wrap(err, "couldn't find the pending order")
+	}
+
+	if order.Status != int64(ordering.Pending) {
+		// The payment was notified already, or the order was cancelled
+		return order.ID, order.PaymentIntentID != "", nil
+	}
+
+	orderID, err := s.charge(ctx, sub, order)
+	return orderID, true, err
+}
+
+// place fills the subscription cart with its products, orders it and records the order in
+// the subscription.
+func (s *Scheduler) place(ctx context.Context, sub Subscription) (pendingOrder, error) {
+	products, err := getProducts(ctx, s.db, sub.ID.String)
+	if err != nil {
+		return pendingOrder{}, err
+	}
+
+	cartID := sub.CartID.String
+	// Remove what's left of a previous attempt
+	if err := s.carts.Reset(ctx, cartID); err != nil {
+		return pendingOrder{}, err
+	}
+
+	productIDs := make([]string, len(products))
+	for i, p := range products {
+		productIDs[i] = p.ProductID.String
+		product := cart.Product{
+			ID:        p.ProductID,
+			VariantID: p.VariantID,
+			CartID:    zero.StringFrom(cartID),
+			Quantity:  p.Quantity,
+		}
+		if err := s.carts.Add(ctx, product); err != nil {
+			return pendingOrder{}, err
+		}
+	}
+
+	available, err := s.delivery.Available(ctx, productIDs, sub.Country.String, 0)
+	if err != nil {
+		return pendingOrder{}, err
+	}
+
+	date := time.Now().Add(deliveryLead)
+	params := ordering.OrderParams{
+		Currency: sub.Currency.String,
+		Address:  sub.Address.String,
+		City:     sub.City.String,
+		Country:  sub.Country.String,
+		State:    sub.State.String,
+		ZipCode:  sub.ZipCode.String,
+		Date: &ordering.Date{
+			Year:    date.Year(),
+			Month:   int(date.Month()),
+			Day:     date.Day(),
+			Hour:    date.Hour(),
+			Minutes: date.Minute(),
+		},
+		Slots:          earliestSlots(available),
+		ShippingMethod: sub.ShippingMethod.String,
+	}
+
+	order, err := s.orders.New(ctx, uuid.NewString(), sub.UserID.String, cartID, params, s.carts)
+	if err != nil {
+		return pendingOrder{}, err
+	}
+	orderID := order.ID.String
+
+	q := "UPDATE subscriptions SET pending_order_id=$2 WHERE id=$1"
+	if _, err := s.db.ExecContext(ctx, q, sub.ID, orderID); err != nil {
+		s.cancel(ctx, orderID, sub.UserID.String)
+		return pendingOrder{}, errors.Wrap(err, "couldn't record the subscription order")
+	}
+
+	return pendingOrder{
+		ID:       orderID,
+		Currency: order.Currency.String,
+		Amount:   order.Cart.ConvertedTotal.Int64,
+	}, nil
+}
+
+// charge pays the order with the subscription payment method.
+func (s *Scheduler) charge(ctx context.Context, sub Subscription, order pendingOrder) (string, error) {
+	intent, err := s.provider.CreateIntent(ctx, payment.IntentParams{
+		OrderID:    order.ID,
+		CartID:     sub.CartID.String,
+		Currency:   order.Currency,
+		Amount:     order.Amount,
+		CustomerID: sub.CustomerID.String,
+		MethodID:   sub.PaymentMethodID.String,
+		OffSession: true,
+		// The key is the same on each attempt to charge the order so it's paid only once
+		IdempotencyKey: "subscription-order-" + order.ID,
+	})
+	if err != nil {
+		// Other errors don't tell whether it was charged, the order is retried with the same key
+		if errors.Is(err, payment.ErrDeclined) {
+			if err := s.cancel(ctx, order.ID, sub.UserID.String); errors.Is(err, ordering.ErrInvalidTransition) {
+				return "", errNotified
+			}
+		}
+		return "", err
+	}
+
+	if err := s.orders.SetPaymentIntent(ctx, order.ID, intent.ID); err != nil {
+		return "", err
+	}
+
+	if err := s.carts.Reset(ctx, sub.CartID.String); err != nil {
+		logger.Error(err)
+	}
+
+	// The order is marked as paid and confirmed once the provider notifies the payment succeeded
+	return order.ID, nil
+}
+
+// cancel gives back the stock and slots reserved by an order that won't be charged.
+//
+// ordering.ErrInvalidTransition is returned if the order was cancelled already.
+func (s *Scheduler) cancel(ctx context.Context, orderID, userID string) error {
+	if err := s.orders.UpdateStatus(ctx, orderID, ordering.Failed, userID); err != nil &&
+		!errors.Is(err, ordering.ErrInvalidTransition) {
+		logger.Error(err)
+	}
+	err := s.orders.Cancel(ctx, orderID, userID, nil)
+	if err != nil && !errors.Is(err, ordering.ErrInvalidTransition) {
+		logger.Error(err)
+	}
+	return err
+}
+
+// PaymentFailed schedules a retry of the subscription whose order couldn't be charged by
+// the provider, as if placing the order had failed. Orders that don't belong to a
+// subscription are ignored.
+func (s *Scheduler) PaymentFailed(ctx context.Context, order ordering.Order) error {
+	var sub Subscription
+	q := "SELECT * FROM subscriptions WHERE last_order_id=$1 OR pending_order_id=$1"
+	if err := s.db.GetContext(ctx, &sub, q, order.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return errors.Wrap(err, "couldn't find the subscription of the order")
+	}
+
+	// Cancelling the order happens once, a failure already handled by the scheduler or
+	// notified twice is skipped
+	if err := s.cancel(ctx, order.ID.String, sub.UserID.String); err != nil {
+		if errors.Is(err, ordering.ErrInvalidTransition) {
+			return nil
+		}
+		return err
+	}
+	// Clearing the pending order stops the attempt in progress from scheduling the next one
+	q = "UPDATE subscriptions SET pending_order_id=NULL WHERE id=$1"
+	if _, err := s.db.ExecContext(ctx, q, sub.ID); err != nil {
+		return errors.Wrapf(err, "couldn't update the subscription %s", sub.ID.String)
+	}
+
+	if sub.Status.String != Active {
+		return nil
+	}
+	return s.fail(ctx, sub, errors.Errorf("the payment of the order %s failed", order.ID.String))
+}
+
+// succeed schedules the following order of the subscription, unless the payment of the
+// order failed in the meantime.
+func (s *Scheduler) succeed(ctx context.Context, sub Subscription, orderID string) error {
+	now := time.Now()
+	q := `UPDATE subscriptions SET next_order_at=$2, failures=0, last_order_id=$3, pending_order_id=NULL,
+	last_error=NULL, updated_at=$4
+	WHERE id=$1 AND pending_order_id=$3`
+	next := sub.nextAfter(sub.NextOrderAt.Time, now)
+	if _, err := s.db.ExecContext(ctx, q, sub.ID, next, orderID, now); err != nil {
+		return errors.Wrapf(err, "couldn't schedule the subscription %s", sub.ID.String)
+	}
+	return nil
+}
+
+// fail records the error and schedules a retry, or pauses the subscription if it ran out of them.
+func (s *Scheduler) fail(ctx context.Context, sub Subscription, orderErr error) error {
+	s.metrics.failed.Inc()
+	logger.Error(errors.Wrapf(orderErr, "couldn't order the subscription %s", sub.ID.String))
+
+	// Release the stock held by the products added to the cart
+	if err := s.carts.Reset(ctx, sub.CartID.String); err != nil {
+		logger.Error(err)
+	}
+
+	now := time.Now()
+	failures := sub.Failures.Int64 + 1
+	status, note := Active, ""
+	next := now.Add(retryDelay(s.retryAfter, failures))
+	if failures >= s.maxRetries {
+		// Resuming it orders right away
+		status, next = Paused, now
+		note = fmt.Sprintf("We couldn't place your order after %d attempts, resume the subscription once the issue is solved.", failures)
+		s.metrics.paused.Inc()
+	} else {
+		note = "We couldn't place your order, we will try again on " + next.Format("January 2 at 15:04") + "."
+	}
+
+	q := `UPDATE subscriptions SET status=$2, next_order_at=$3, failures=$4, last_error=$5, updated_at=$6
+	WHERE id=$1`
+	if _, err := s.db.ExecContext(ctx, q, sub.ID, status, next, failures, orderErr.Error(), now); err != nil {
+		return errors.Wrapf(err, "couldn't record the subscription %s failure", sub.ID.String)
+	}
+
+	var user subscriber
+	if err := s.db.GetContext(ctx, &user, "SELECT username, email FROM users WHERE id=$1", sub.UserID); err != nil {
+		return errors.Wrap(err, "couldn't find the subscriber")
+	}
+	emailStatus := "delayed"
+	if status == Paused {
+		emailStatus = Paused
+	}
+	return s.emailer.SendSubscriptionStatus(user.Username, user.Email, sub.ID.String, emailStatus, note)
+}
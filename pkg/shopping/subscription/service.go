@@ -0,0 +1,223 @@
+// Package subscription places the orders of the carts the users subscribed to periodically.
+package subscription
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidStatus is returned when the operation can't be applied to the subscription in its current status.
+	ErrInvalidStatus = errors.New("the subscription status doesn't allow this operation")
+	// ErrNotFound is returned when the subscription doesn't exist or belongs to another user.
+	ErrNotFound = errors.New("subscription not found")
+)
+
+// Service contains subscriptions functionalities.
+type Service interface {
+	Cancel(ctx context.Context, id, userID string) error
+	Create(ctx context.Context, sub Subscription) error
+	GetByID(ctx context.Context, id, userID string) (Subscription, error)
+	GetByUserID(ctx context.Context, userID string) ([]Subscription, error)
+	Pause(ctx context.Context, id, userID string) error
+	Resume(ctx context.Context, id, userID string) error
+	Skip(ctx context.Context, id, userID string) (time.Time, error)
+}
+
+type service struct {
+	db      *sqlx.DB
+	metrics metrics
+}
+
+// NewService returns a new subscriptions service.
+func NewService(db *sqlx.DB) Service {
+	return &service{db, initMetrics()}
+}
+
+// Cancel stops ordering a subscription permanently.
+func (s *service) Cancel(ctx context.Context, id, userID string) error {
+	s.metrics.incMethodCalls("Cancel")
+
+	q := `UPDATE subscriptions SET status=$3, updated_at=$4
+	WHERE id=$1 AND user_id=$2 AND status IN ($5, $6)`
+	res, err := s.db.ExecContext(ctx, q, id, userID, Cancelled, time.Now(), Active, Paused)
+	if err != nil {
+		return errors.Wrap(err, "couldn't cancel the subscription")
+	}
+
+	return s.checkAffected(ctx, res, id, userID)
+}
+
+// Create saves a subscription along with its products.
+func (s *service) Create(ctx context.Context, sub Subscription) error {
+	s.metrics.incMethodCalls("Create")
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	q := `INSERT INTO subscriptions
+	(id, user_id, cart_id, status, frequency, interval_days, anchor_day, currency, address, city, country,
+	state, zip_code, shipping_method, customer_id, payment_method_id, next_order_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`
+	_, err = tx.ExecContext(ctx, q, sub.ID, sub.UserID, sub.CartID, sub.Status, sub.Frequency,
+		sub.IntervalDays, sub.AnchorDay, sub.Currency, sub.Address, sub.City, sub.Country, sub.State, sub.ZipCode,
+		sub.ShippingMethod, sub.CustomerID, sub.PaymentMethodID, sub.NextOrderAt, sub.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create the subscription")
+	}
+
+	productsQ := `INSERT INTO subscription_products
+	(subscription_id, product_id, variant_id, quantity)
+	VALUES (:subscription_id, :product_id, :variant_id, :quantity)`
+	if _, err := tx.NamedExecContext(ctx, productsQ, sub.Products); err != nil {
+		return errors.Wrap(err, "couldn't save the subscription products")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	return nil
+}
+
+// GetByID returns a subscription of the user with its products.
+func (s *service) GetByID(ctx context.Context, id, userID string) (Subscription, error) {
+	s.metrics.incMethodCalls("GetByID")
+
+	var sub Subscription
+	q := "SELECT * FROM subscriptions WHERE id=$1 AND user_id=$2"
+	if err := s.db.GetContext(ctx, &sub, q, id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Subscription{}, ErrNotFound
+		}
+		return Subscription{}, errors.Wrap(err, "couldn't find the subscription")
+	}
+
+	products, err := getProducts(ctx, s.db, id)
+	if err != nil {
+		return Subscription{}, err
+	}
+	sub.Products = products
+
+	return sub, nil
+}
+
+// GetByUserID returns the subscriptions of the user, the cancelled ones included.
+func (s *service) GetByUserID(ctx context.Context, userID string) ([]Subscription, error) {
+	s.metrics.incMethodCalls("GetByUserID")
+
+	var subs []Subscription
+	q := "SELECT * FROM subscriptions WHERE user_id=$1 ORDER BY created_at DESC"
+	if err := s.db.SelectContext(ctx, &subs, q, userID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the subscriptions")
+	}
+
+	for i, sub := range subs {
+		products, err := getProducts(ctx, s.db, sub.ID.String)
+		if err != nil {
+			return nil, err
+		}
+		subs[i].Products = products
+	}
+
+	return subs, nil
+}
+
+// Pause stops ordering a subscription until it's resumed.
+func (s *service) Pause(ctx context.Context, id, userID string) error {
+	s.metrics.incMethodCalls("Pause")
+
+	q := "UPDATE subscriptions SET status=$3, updated_at=$4 WHERE id=$1 AND user_id=$2 AND status=$5"
+	res, err := s.db.ExecContext(ctx, q, id, userID, Paused, time.Now(), Active)
+	if err != nil {
+		return errors.Wrap(err, "couldn't pause the subscription")
+	}
+
+	return s.checkAffected(ctx, res, id, userID)
+}
+
+// Resume orders a paused subscription again, if the next order date already passed it's
+// placed on the next run of the scheduler.
+func (s *service) Resume(ctx context.Context, id, userID string) error {
+	s.metrics.incMethodCalls("Resume")
+
+	now := time.Now()
+	q := `UPDATE subscriptions SET status=$3, failures=0, next_order_at=GREATEST(next_order_at, $4), updated_at=$4
+	WHERE id=$1 AND user_id=$2 AND status=$5`
+	res, err := s.db.ExecContext(ctx, q, id, userID, Active, now, Paused)
+	if err != nil {
+		return errors.Wrap(err, "couldn't resume the subscription")
+	}
+
+	return s.checkAffected(ctx, res, id, userID)
+}
+
+// Skip postpones the next order of a subscription to the following date and returns it.
+func (s *service) Skip(ctx context.Context, id, userID string) (time.Time, error) {
+	s.metrics.incMethodCalls("Skip")
+
+	var sub Subscription
+	q := "SELECT * FROM subscriptions WHERE id=$1 AND user_id=$2"
+	if err := s.db.GetContext(ctx, &sub, q, id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, ErrNotFound
+		}
+		return time.Time{}, errors.Wrap(err, "couldn't find the subscription")
+	}
+	if sub.Status.String == Cancelled {
+		return time.Time{}, ErrInvalidStatus
+	}
+
+	next := sub.next(sub.NextOrderAt.Time)
+	// Fails if the scheduler claimed the order in the meantime
+	q = `UPDATE subscriptions SET next_order_at=$3, updated_at=$4
+	WHERE id=$1 AND next_order_at=$2 AND status IN ($5, $6)`
+	res, err := s.db.ExecContext(ctx, q, id, sub.NextOrderAt, next, time.Now(), Active, Paused)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "couldn't skip the subscription order")
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return time.Time{}, ErrInvalidStatus
+	}
+
+	return next, nil
+}
+
+// checkAffected returns ErrNotFound if the subscription doesn't exist and ErrInvalidStatus
+// if it wasn't updated because of its status.
+func (s *service) checkAffected(ctx context.Context, res sql.Result, id, userID string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "couldn't check the rows affected")
+	}
+	if n > 0 {
+		return nil
+	}
+
+	var exists bool
+	q := "SELECT EXISTS(SELECT 1 FROM subscriptions WHERE id=$1 AND user_id=$2)"
+	if err := s.db.GetContext(ctx, &exists, q, id, userID); err != nil {
+		return errors.Wrap(err, "couldn't find the subscription")
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrInvalidStatus
+}
+
+// getProducts returns the products of a subscription.
+func getProducts(ctx context.Context, db sqlx.QueryerContext, subscriptionID string) ([]Product, error) {
+	var products []Product
+	q := "SELECT * FROM subscription_products WHERE subscription_id=$1"
+	if err := sqlx.SelectContext(ctx, db, &products, q, subscriptionID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the subscription products")
+	}
+	return products, nil
+}
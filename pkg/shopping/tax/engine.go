@@ -0,0 +1,86 @@
+package tax
+
+import (
+	"math/big"
+	"strings"
+)
+
+// basisPoints is the number of basis points in one unit.
+var basisPoints = big.NewRat(10000, 1)
+
+// calculate applies the rules, sorted by priority, that match the line category.
+//
+// Every rate is expressed as a fraction of the net price (the line price without the
+// inclusive taxes), compound rates include the taxes of the rules applied before them.
+// The net price is obtained by dividing the line amount by one plus the inclusive fractions.
+func calculate(rules []Rule, line Line) Breakdown {
+	matching := make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		if r.Category.String != "" && !strings.EqualFold(r.Category.String, line.Category) {
+			continue
+		}
+		if r.Exempt {
+			return nil
+		}
+		matching = append(matching, r)
+	}
+
+	if len(matching) == 0 || line.Amount == 0 {
+		return nil
+	}
+
+	gross := big.NewRat(1, 1)
+	inclusive := big.NewRat(1, 1)
+	fractions := make([]*big.Rat, len(matching))
+	for i, r := range matching {
+		fraction := new(big.Rat).Quo(big.NewRat(r.Rate, 1), basisPoints)
+		if r.Compound {
+			fraction.Mul(fraction, gross)
+		}
+		gross.Add(gross, fraction)
+		if r.Inclusive {
+			inclusive.Add(inclusive, fraction)
+		}
+		fractions[i] = fraction
+	}
+
+	net := new(big.Rat).Quo(big.NewRat(line.Amount, 1), inclusive)
+	breakdown := make(Breakdown, len(matching))
+	for i, r := range matching {
+		breakdown[i] = Tax{
+			RuleID:    r.ID.String,
+			Name:      r.Name.String,
+			Rate:      r.Rate,
+			Inclusive: r.Inclusive,
+			Amount:    round(new(big.Rat).Mul(net, fractions[i])),
+		}
+	}
+
+	return breakdown
+}
+
+// matches returns whether the rule applies to the address.
+func (r Rule) matches(address Address) bool {
+	if !strings.EqualFold(r.Country.String, address.Country) {
+		return false
+	}
+	if r.State.String != "" && !strings.EqualFold(r.State.String, address.State) {
+		return false
+	}
+	return strings.HasPrefix(normalizeZipCode(address.ZipCode), normalizeZipCode(r.ZipCode.String))
+}
+
+// normalizeZipCode removes the spaces and dashes from the zip code and upper cases it.
+func normalizeZipCode(zipCode string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(zipCode))
+}
+
+// round rounds the number half away from zero.
+func round(v *big.Rat) int64 {
+	num, denom := v.Num(), v.Denom()
+	q, m := new(big.Int).QuoRem(num, denom, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(denom) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	return q.Int64()
+}
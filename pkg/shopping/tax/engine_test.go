@@ -0,0 +1,98 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestCalculate(t *testing.T) {
+	vat := Rule{ID: zero.StringFrom("vat"), Rate: 2100}
+	food := Rule{ID: zero.StringFrom("food"), Category: zero.StringFrom("food"), Rate: 1000}
+	inclusive := Rule{ID: zero.StringFrom("inc"), Rate: 2000, Inclusive: true}
+	compound := Rule{ID: zero.StringFrom("cmp"), Rate: 1000, Compound: true}
+	exempt := Rule{ID: zero.StringFrom("exempt"), Category: zero.StringFrom("books"), Exempt: true}
+
+	cases := []struct {
+		desc      string
+		rules     []Rule
+		line      Line
+		amounts   []int64
+		exclusive int64
+	}{
+		{
+			desc:      "exclusive",
+			rules:     []Rule{vat},
+			line:      Line{Category: "toys", Amount: 1000},
+			amounts:   []int64{210},
+			exclusive: 210,
+		},
+		{
+			desc:      "category",
+			rules:     []Rule{vat, food},
+			line:      Line{Category: "Food", Amount: 1000},
+			amounts:   []int64{210, 100},
+			exclusive: 310,
+		},
+		{
+			desc:      "inclusive",
+			rules:     []Rule{inclusive},
+			line:      Line{Amount: 1200},
+			amounts:   []int64{200},
+			exclusive: 0,
+		},
+		{
+			desc:      "compound",
+			rules:     []Rule{vat, compound},
+			line:      Line{Amount: 1000},
+			amounts:   []int64{210, 121},
+			exclusive: 331,
+		},
+		{
+			desc:      "inclusive and compound",
+			rules:     []Rule{inclusive, compound},
+			line:      Line{Amount: 1200},
+			amounts:   []int64{200, 120},
+			exclusive: 120,
+		},
+		{
+			desc:      "rounding",
+			rules:     []Rule{vat},
+			line:      Line{Amount: 5},
+			amounts:   []int64{1},
+			exclusive: 1,
+		},
+		{
+			desc:  "exempt",
+			rules: []Rule{vat, exempt},
+			line:  Line{Category: "books", Amount: 1000},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			breakdown := calculate(tc.rules, tc.line)
+
+			var amounts []int64
+			for _, tax := range breakdown {
+				amounts = append(amounts, tax.Amount)
+			}
+			assert.Equal(t, tc.amounts, amounts)
+			assert.Equal(t, tc.exclusive, breakdown.Exclusive())
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	rule := Rule{
+		Country: zero.StringFrom("US"),
+		State:   zero.StringFrom("NY"),
+		ZipCode: zero.StringFrom("100"),
+	}
+
+	assert.True(t, rule.matches(Address{Country: "us", State: "ny", ZipCode: "10001"}))
+	assert.False(t, rule.matches(Address{Country: "US", State: "NJ", ZipCode: "10001"}))
+	assert.False(t, rule.matches(Address{Country: "US", State: "NY", ZipCode: "11201"}))
+	assert.False(t, rule.matches(Address{Country: "CA", State: "NY", ZipCode: "10001"}))
+}
@@ -0,0 +1,164 @@
+package tax
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/internal/validate"
+
+	"github.com/google/uuid"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+type cursorResponse struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	Rules      []Rule `json:"rules,omitempty"`
+}
+
+// Handler handles tax endpoints.
+type Handler struct {
+	service Service
+}
+
+// NewHandler returns a new tax handler.
+func NewHandler(service Service) Handler {
+	return Handler{
+		service: service,
+	}
+}
+
+// Create creates a new tax rule and saves it.
+func (h *Handler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var rule Rule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, rule); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		rule.ID = zero.StringFrom(uuid.NewString())
+		rule.CreatedAt = zero.TimeFrom(time.Now())
+		if err := h.service.Create(ctx, rule); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusCreated, rule)
+	}
+}
+
+// Delete removes a tax rule.
+func (h *Handler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.service.Delete(ctx, id); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, id)
+	}
+}
+
+// Get lists all the tax rules.
+func (h *Handler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		urlParams, err := params.ParseQuery(r.URL.RawQuery, params.Tax)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		rules, err := h.service.Get(ctx, urlParams)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		var nextCursor string
+		if len(rules) > 0 {
+			nextCursor = params.EncodeCursor(
+				rules[len(rules)-1].CreatedAt.Time,
+				rules[len(rules)-1].ID.String,
+			)
+		}
+
+		response.JSON(w, http.StatusOK, cursorResponse{
+			NextCursor: nextCursor,
+			Rules:      rules,
+		})
+	}
+}
+
+// GetByID lists the tax rule with the id requested.
+func (h *Handler) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		rule, err := h.service.GetByID(ctx, id)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, rule)
+	}
+}
+
+// Update updates the tax rule with the given id.
+func (h *Handler) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := params.URLID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var rule Rule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, rule); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.service.Update(ctx, id, rule); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, id)
+	}
+}
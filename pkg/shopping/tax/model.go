@@ -0,0 +1,104 @@
+package tax
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Rule is a tax applied to the products shipped to a jurisdiction.
+//
+// Empty State, ZipCode and Category match any value, ZipCode matches all the zip codes
+// starting with it.
+type Rule struct {
+	ID       zero.String `json:"id,omitempty"`
+	Name     zero.String `json:"name,omitempty" validate:"required,max=60"`
+	Country  zero.String `json:"country,omitempty" validate:"required,max=60"`
+	State    zero.String `json:"state,omitempty" validate:"max=60"`
+	ZipCode  zero.String `json:"zip_code,omitempty" db:"zip_code" validate:"max=20"`
+	Category zero.String `json:"category,omitempty" validate:"max=60"`
+	// Rate in basis points, 2100 = 21%
+	Rate int64 `json:"rate,omitempty" validate:"min=0,max=100000"`
+	// Inclusive taxes are already contained in the product price, the rest are added to it
+	Inclusive bool `json:"inclusive,omitempty"`
+	// Compound taxes are calculated over the price plus the taxes of the rules applied before
+	Compound bool `json:"compound,omitempty"`
+	// Exempt rules make the products matching them tax free
+	Exempt bool `json:"exempt,omitempty"`
+	// Priority defines the order in which the rules are applied, lower goes first
+	Priority  int64     `json:"priority,omitempty" validate:"min=0"`
+	CreatedAt zero.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+// Address is the destination of the products, used to find the rules that apply.
+type Address struct {
+	Country string
+	State   string
+	ZipCode string
+}
+
+// Line is a product, and the quantity of it, being taxed.
+type Line struct {
+	Category string
+	// Amount is the price of all the units in the line
+	Amount int64
+}
+
+// Tax is the amount charged by a rule on a line.
+//
+// Amounts to be provided in a currency’s smallest unit.
+// 100 = 1 USD.
+type Tax struct {
+	RuleID    string `json:"rule_id"`
+	Name      string `json:"name"`
+	Rate      int64  `json:"rate"`
+	Inclusive bool   `json:"inclusive"`
+	Amount    int64  `json:"amount"`
+}
+
+// Breakdown contains the taxes charged on a line.
+type Breakdown []Tax
+
+// Exclusive returns the sum of the taxes that are not included in the line price.
+func (b Breakdown) Exclusive() int64 {
+	var total int64
+	for _, t := range b {
+		if !t.Inclusive {
+			total += t.Amount
+		}
+	}
+	return total
+}
+
+// Total returns the sum of all the taxes.
+func (b Breakdown) Total() int64 {
+	var total int64
+	for _, t := range b {
+		total += t.Amount
+	}
+	return total
+}
+
+// Scan implements the sql.Scanner interface.
+func (b *Breakdown) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*b = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, b)
+	case string:
+		return json.Unmarshal([]byte(v), b)
+	}
+	return errors.Errorf("cannot scan %T into a tax breakdown", src)
+}
+
+// Value implements the driver.Valuer interface.
+func (b Breakdown) Value() (driver.Value, error) {
+	if b == nil {
+		return nil, nil
+	}
+	return json.Marshal(b)
+}
@@ -0,0 +1,130 @@
+// Package tax calculates the taxes of the products depending on the jurisdiction they are shipped to.
+package tax
+
+import (
+	"context"
+
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/pkg/postgres"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Service contains tax functionalities.
+type Service interface {
+	Calculate(ctx context.Context, address Address, lines []Line) ([]Breakdown, error)
+	Create(ctx context.Context, r Rule) error
+	Delete(ctx context.Context, id string) error
+	Get(ctx context.Context, params params.Query) ([]Rule, error)
+	GetByID(ctx context.Context, id string) (Rule, error)
+	Update(ctx context.Context, id string, r Rule) error
+}
+
+type service struct {
+	db      *sqlx.DB
+	metrics metrics
+}
+
+// NewService returns a new tax service.
+func NewService(db *sqlx.DB) Service {
+	return &service{db, initMetrics()}
+}
+
+// Calculate returns the taxes of each line when shipping them to the address provided.
+//
+// The breakdowns are returned in the same order as the lines.
+func (s *service) Calculate(ctx context.Context, address Address, lines []Line) ([]Breakdown, error) {
+	s.metrics.incMethodCalls("Calculate")
+
+	var rules []Rule
+	q := "SELECT * FROM tax_rules WHERE LOWER(country)=LOWER($1) ORDER BY priority, created_at"
+	if err := s.db.SelectContext(ctx, &rules, q, address.Country); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the tax rules")
+	}
+
+	jurisdiction := make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		if r.matches(address) {
+			jurisdiction = append(jurisdiction, r)
+		}
+	}
+
+	breakdowns := make([]Breakdown, len(lines))
+	for i, line := range lines {
+		breakdowns[i] = calculate(jurisdiction, line)
+	}
+
+	return breakdowns, nil
+}
+
+// Create a tax rule.
+func (s *service) Create(ctx context.Context, r Rule) error {
+	s.metrics.incMethodCalls("Create")
+
+	q := `INSERT INTO tax_rules
+	(id, name, country, state, zip_code, category, rate, inclusive, compound, exempt, priority, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	_, err := s.db.ExecContext(ctx, q, r.ID, r.Name, r.Country, r.State, r.ZipCode, r.Category,
+		r.Rate, r.Inclusive, r.Compound, r.Exempt, r.Priority, r.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create the tax rule")
+	}
+
+	return nil
+}
+
+// Delete permanently deletes a tax rule from the database.
+//
+// The taxes already charged on orders are kept in their breakdown.
+func (s *service) Delete(ctx context.Context, id string) error {
+	s.metrics.incMethodCalls("Delete")
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM tax_rules WHERE id=$1", id); err != nil {
+		return errors.Wrap(err, "couldn't delete the tax rule")
+	}
+
+	return nil
+}
+
+// Get returns a list with all the tax rules stored in the database.
+func (s *service) Get(ctx context.Context, params params.Query) ([]Rule, error) {
+	s.metrics.incMethodCalls("Get")
+
+	var rules []Rule
+	q, args := postgres.AddPagination("SELECT * FROM tax_rules", params)
+	if err := s.db.SelectContext(ctx, &rules, q, args...); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the tax rules")
+	}
+
+	return rules, nil
+}
+
+// GetByID returns the tax rule with the id provided.
+func (s *service) GetByID(ctx context.Context, id string) (Rule, error) {
+	s.metrics.incMethodCalls("GetByID")
+
+	var r Rule
+	if err := s.db.GetContext(ctx, &r, "SELECT * FROM tax_rules WHERE id=$1", id); err != nil {
+		return Rule{}, errors.Wrap(err, "couldn't find the tax rule")
+	}
+
+	return r, nil
+}
+
+// Update updates a tax rule.
+func (s *service) Update(ctx context.Context, id string, r Rule) error {
+	s.metrics.incMethodCalls("Update")
+
+	q := `UPDATE tax_rules SET
+	name=$2, country=$3, state=$4, zip_code=$5, category=$6, rate=$7,
+	inclusive=$8, compound=$9, exempt=$10, priority=$11
+	WHERE id=$1`
+	_, err := s.db.ExecContext(ctx, q, id, r.Name, r.Country, r.State, r.ZipCode, r.Category,
+		r.Rate, r.Inclusive, r.Compound, r.Exempt, r.Priority)
+	if err != nil {
+		return errors.Wrap(err, "couldn't update the tax rule")
+	}
+
+	return nil
+}
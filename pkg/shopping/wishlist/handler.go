@@ -0,0 +1,375 @@
+package wishlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/GGP1/adak/internal/params"
+	"github.com/GGP1/adak/internal/response"
+	"github.com/GGP1/adak/internal/sanitize"
+	"github.com/GGP1/adak/internal/token"
+	"github.com/GGP1/adak/internal/validate"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+type nameRequest struct {
+	Name string `json:"name" validate:"required,max=60"`
+}
+
+type shareResponse struct {
+	ShareToken string `json:"share_token"`
+	URL        string `json:"url"`
+}
+
+// Handler handles wishlist endpoints.
+type Handler struct {
+	service Service
+	cache   *memcache.Client
+}
+
+// NewHandler returns a new wishlist handler.
+func NewHandler(service Service, cache *memcache.Client) Handler {
+	return Handler{
+		service: service,
+		cache:   cache,
+	}
+}
+
+// Add saves a product in the wishlist.
+func (h *Handler) Add() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID, wishlistID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		var product Product
+		if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, product); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.service.Add(ctx, userID, wishlistID, product); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, product)
+	}
+}
+
+// Create creates a new wishlist for the user.
+func (h *Handler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID, ok := user(w, r)
+		if !ok {
+			return
+		}
+
+		var req nameRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		wishlist := Wishlist{
+			ID:        zero.StringFrom(uuid.NewString()),
+			UserID:    zero.StringFrom(userID),
+			Name:      zero.StringFrom(sanitize.Normalize(req.Name)),
+			CreatedAt: zero.TimeFrom(time.Now()),
+		}
+		if err := h.service.Create(ctx, wishlist); err != nil {
+			response.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		response.JSON(w, http.StatusCreated, wishlist)
+	}
+}
+
+// Delete removes a wishlist.
+func (h *Handler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, wishlistID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		if err := h.service.Delete(r.Context(), userID, wishlistID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, wishlistID)
+	}
+}
+
+// Get lists the wishlists of the user.
+func (h *Handler) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := user(w, r)
+		if !ok {
+			return
+		}
+
+		wishlists, err := h.service.Get(r.Context(), userID)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, wishlists)
+	}
+}
+
+// GetByID returns the wishlist and its products.
+func (h *Handler) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, wishlistID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		key := cacheKey(userID, wishlistID)
+		item, err := h.cache.Get(key)
+		if err == nil {
+			response.EncodedJSON(w, item.Value)
+			return
+		}
+
+		wishlist, err := h.service.GetByID(r.Context(), userID, wishlistID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONAndCache(h.cache, w, key, wishlist)
+	}
+}
+
+// GetShared returns the wishlist that belongs to the share token, it doesn't require the user to be logged in.
+func (h *Handler) GetShared() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		shareToken := chi.URLParam(r, "token")
+
+		wishlist, err := h.service.GetShared(r.Context(), shareToken)
+		if err != nil {
+			response.Error(w, http.StatusNotFound, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, wishlist)
+	}
+}
+
+// MoveFromCart takes the product out of the cart and saves it in the wishlist.
+func (h *Handler) MoveFromCart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		cartID, err := params.CartID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+		userID, wishlistID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		productID := chi.URLParam(r, "productID")
+		variantID := r.URL.Query().Get("variant_id")
+		if err := h.service.MoveFromCart(ctx, userID, wishlistID, cartID, productID, variantID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "product "+productID+" moved to the wishlist")
+	}
+}
+
+// MoveToCart adds the product to the cart and takes it out of the wishlist.
+func (h *Handler) MoveToCart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		cartID, err := params.CartID(ctx)
+		if err != nil {
+			response.Error(w, http.StatusForbidden, err)
+			return
+		}
+		userID, wishlistID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		productID := chi.URLParam(r, "productID")
+		variantID := r.URL.Query().Get("variant_id")
+		if err := h.service.MoveToCart(ctx, userID, wishlistID, cartID, productID, variantID); err != nil {
+			if errors.Is(err, inventory.ErrOutOfStock) {
+				response.Error(w, http.StatusConflict, err)
+				return
+			}
+			if errors.Is(err, cart.ErrVariantRequired) {
+				response.Error(w, http.StatusBadRequest, err)
+				return
+			}
+			if errors.Is(err, cart.ErrProductNotFound) {
+				response.Error(w, http.StatusNotFound, err)
+				return
+			}
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "product "+productID+" moved to the cart")
+	}
+}
+
+// Remove takes out a product from the wishlist.
+func (h *Handler) Remove() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, wishlistID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		productID := chi.URLParam(r, "productID")
+		variantID := r.URL.Query().Get("variant_id")
+		if err := h.service.Remove(r.Context(), userID, wishlistID, productID, variantID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, "product "+productID+" removed from the wishlist")
+	}
+}
+
+// Rename changes the name of the wishlist.
+func (h *Handler) Rename() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID, wishlistID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		var req nameRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := validate.Struct(ctx, req); err != nil {
+			response.Error(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.service.Rename(ctx, userID, wishlistID, sanitize.Normalize(req.Name)); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, wishlistID)
+	}
+}
+
+// Share returns the read-only link of the wishlist.
+func (h *Handler) Share() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, wishlistID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		shareToken, err := h.service.Share(r.Context(), userID, wishlistID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, shareResponse{
+			ShareToken: shareToken,
+			URL:        "/wishlists/shared/" + shareToken,
+		})
+	}
+}
+
+// Unshare disables the read-only link of the wishlist.
+func (h *Handler) Unshare() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, wishlistID, ok := ids(w, r)
+		if !ok {
+			return
+		}
+
+		if err := h.service.Unshare(r.Context(), userID, wishlistID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		response.JSONText(w, http.StatusOK, wishlistID)
+	}
+}
+
+// ids returns the ids of the user and the wishlist, writing the error if any of them is invalid.
+func ids(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	userID, ok := user(w, r)
+	if !ok {
+		return "", "", false
+	}
+
+	wishlistID := chi.URLParam(r, "wishlistID")
+	if err := validate.UUID(wishlistID); err != nil {
+		response.Error(w, http.StatusBadRequest, err)
+		return "", "", false
+	}
+
+	return userID, wishlistID, true
+}
+
+// user returns the id of the user, writing the error if it's invalid or it's not the one
+// performing the request.
+func user(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, err := params.URLID(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err)
+		return "", false
+	}
+
+	if err := token.CheckPermits(r, userID); err != nil {
+		response.Error(w, http.StatusForbidden, err)
+		return "", false
+	}
+
+	return userID, true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		response.Error(w, http.StatusNotFound, err)
+		return
+	}
+	response.Error(w, http.StatusInternalServerError, err)
+}
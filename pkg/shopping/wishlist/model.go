@@ -0,0 +1,33 @@
+package wishlist
+
+import (
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+// Wishlist is a named list of products that the user keeps outside the cart.
+type Wishlist struct {
+	ID     zero.String `json:"id,omitempty"`
+	UserID zero.String `json:"user_id,omitempty" db:"user_id"`
+	Name   zero.String `json:"name,omitempty" validate:"required,max=60"`
+	// ShareToken identifies the list in its read-only link, it's empty if the list isn't shared
+	ShareToken zero.String `json:"share_token,omitempty" db:"share_token"`
+	Products   []Product   `json:"products,omitempty"`
+	CreatedAt  zero.Time   `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt  zero.Time   `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// Product represents a product saved in a wishlist.
+type Product struct {
+	WishlistID zero.String `json:"wishlist_id,omitempty" db:"wishlist_id"`
+	ID         zero.String `json:"id,omitempty" validate:"uuid4_rfc4122"`
+	VariantID  zero.String `json:"variant_id,omitempty" db:"variant_id"`
+	Quantity   zero.Int    `json:"quantity,omitempty" validate:"required,min=1"`
+	AddedAt    zero.Time   `json:"added_at,omitempty" db:"added_at"`
+}
+
+// shared returns the list without the information of its owner.
+func (w Wishlist) shared() Wishlist {
+	w.UserID = zero.String{}
+	w.ShareToken = zero.String{}
+	return w
+}
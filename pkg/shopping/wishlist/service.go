@@ -0,0 +1,377 @@
+// Package wishlist implements the lists where users save the products they are interested in.
+package wishlist
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/GGP1/adak/internal/token"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+const shareTokenLength = 40
+
+// ErrNotFound is returned when the wishlist doesn't exist or belongs to other user.
+var ErrNotFound = errors.New("wishlist not found")
+
+// Service contains wishlist functionalities.
+type Service interface {
+	Add(ctx context.Context, userID, wishlistID string, p Product) error
+	Create(ctx context.Context, w Wishlist) error
+	Delete(ctx context.Context, userID, wishlistID string) error
+	Get(ctx context.Context, userID string) ([]Wishlist, error)
+	GetByID(ctx context.Context, userID, wishlistID string) (Wishlist, error)
+	GetShared(ctx context.Context, shareToken string) (Wishlist, error)
+	MoveFromCart(ctx context.Context, userID, wishlistID, cartID, productID, variantID string) error
+	MoveToCart(ctx context.Context, userID, wishlistID, cartID, productID, variantID string) error
+	Remove(ctx context.Context, userID, wishlistID, productID, variantID string) error
+	Rename(ctx context.Context, userID, wishlistID, name string) error
+	Share(ctx context.Context, userID, wishlistID string) (string, error)
+	Unshare(ctx context.Context, userID, wishlistID string) error
+}
+
+type service struct {
+	db      *sqlx.DB
+	mc      *memcache.Client
+	carts   cart.Service
+	metrics metrics
+}
+
+// NewService returns a new wishlist service.
+func NewService(db *sqlx.DB, mc *memcache.Client, carts cart.Service) Service {
+	return &service{db, mc, carts, initMetrics()}
+}
+
+// Add saves a product in the wishlist, if it was already there the quantities are added.
+func (s *service) Add(ctx context.Context, userID, wishlistID string, p Product) error {
+	s.metrics.incMethodCalls("Add")
+
+	if err := checkOwner(ctx, s.db, userID, wishlistID); err != nil {
+		return err
+	}
+
+	if err := addProduct(ctx, s.db, wishlistID, p); err != nil {
+		return err
+	}
+
+	return s.touch(ctx, userID, wishlistID)
+}
+
+// Create a wishlist.
+func (s *service) Create(ctx context.Context, w Wishlist) error {
+	s.metrics.incMethodCalls("Create")
+
+	q := `INSERT INTO wishlists
+	(id, user_id, name, created_at)
+	VALUES ($1, $2, $3, $4)`
+	if _, err := s.db.ExecContext(ctx, q, w.ID, w.UserID, w.Name, w.CreatedAt); err != nil {
+		return errors.Wrap(err, "couldn't create the wishlist")
+	}
+
+	return nil
+}
+
+// Delete permanently deletes a wishlist and its products.
+func (s *service) Delete(ctx context.Context, userID, wishlistID string) error {
+	s.metrics.incMethodCalls("Delete")
+
+	q := "DELETE FROM wishlists WHERE id=$1 AND user_id=$2"
+	res, err := s.db.ExecContext(ctx, q, wishlistID, userID)
+	if err != nil {
+		return errors.Wrap(err, "couldn't delete the wishlist")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	if err := s.mc.Delete(cacheKey(userID, wishlistID)); err != nil && err != memcache.ErrCacheMiss {
+		return errors.Wrap(err, "deleting wishlist from cache")
+	}
+
+	return nil
+}
+
+// Get returns the wishlists of the user, without their products.
+func (s *service) Get(ctx context.Context, userID string) ([]Wishlist, error) {
+	s.metrics.incMethodCalls("Get")
+
+	var wishlists []Wishlist
+	q := "SELECT * FROM wishlists WHERE user_id=$1 ORDER BY created_at"
+	if err := s.db.SelectContext(ctx, &wishlists, q, userID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the wishlists")
+	}
+
+	return wishlists, nil
+}
+
+// GetByID returns the wishlist with the id provided and its products.
+func (s *service) GetByID(ctx context.Context, userID, wishlistID string) (Wishlist, error) {
+	s.metrics.incMethodCalls("GetByID")
+
+	var w Wishlist
+	q := "SELECT * FROM wishlists WHERE id=$1 AND user_id=$2"
+	if err := s.db.GetContext(ctx, &w, q, wishlistID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Wishlist{}, ErrNotFound
+		}
+		return Wishlist{}, errors.Wrap(err, "couldn't find the wishlist")
+	}
+
+	products, err := getProducts(ctx, s.db, wishlistID)
+	if err != nil {
+		return Wishlist{}, err
+	}
+	w.Products = products
+
+	return w, nil
+}
+
+// GetShared returns the wishlist with the share token provided.
+//
+// The information of the owner is omitted.
+func (s *service) GetShared(ctx context.Context, shareToken string) (Wishlist, error) {
+	s.metrics.incMethodCalls("GetShared")
+
+	var w Wishlist
+	if err := s.db.GetContext(ctx, &w, "SELECT * FROM wishlists WHERE share_token=$1", shareToken); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Wishlist{}, ErrNotFound
+		}
+		return Wishlist{}, errors.Wrap(err, "couldn't find the wishlist")
+	}
+
+	products, err := getProducts(ctx, s.db, w.ID.String)
+	if err != nil {
+		return Wishlist{}, err
+	}
+	w.Products = products
+
+	return w.shared(), nil
+}
+
+// MoveFromCart takes the product out of the cart and saves it in the wishlist.
+//
+// The product is saved before removing it from the cart so it's never lost if the latter fails.
+func (s *service) MoveFromCart(ctx context.Context, userID, wishlistID, cartID, productID, variantID string) error {
+	s.metrics.incMethodCalls("MoveFromCart")
+
+	if err := checkOwner(ctx, s.db, userID, wishlistID); err != nil {
+		return err
+	}
+
+	cp, err := s.carts.CartProduct(ctx, cartID, productID, variantID)
+	if err != nil {
+		return err
+	}
+
+	p := Product{
+		ID:        cp.ID,
+		VariantID: cp.VariantID,
+		Quantity:  cp.Quantity,
+	}
+	if err := addProduct(ctx, s.db, wishlistID, p); err != nil {
+		return err
+	}
+
+	if err := s.carts.Remove(ctx, cartID, productID, variantID, cp.Quantity.Int64); err != nil {
+		return err
+	}
+
+	return s.touch(ctx, userID, wishlistID)
+}
+
+// MoveToCart adds the product to the cart and takes it out of the wishlist.
+//
+// The stock of the product is reserved as with any other product added to the cart, both
+// operations are performed in a transaction so the product is never in both lists.
+func (s *service) MoveToCart(ctx context.Context, userID, wishlistID, cartID, productID, variantID string) error {
+	s.metrics.incMethodCalls("MoveToCart")
+
+	if err := checkOwner(ctx, s.db, userID, wishlistID); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	var p Product
+	q := `DELETE FROM wishlist_products WHERE wishlist_id=$1 AND id=$2 AND variant_id=$3
+	RETURNING *`
+	if err := tx.GetContext(ctx, &p, q, wishlistID, productID, variantID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrapf(ErrNotFound, "product %q", productID)
+		}
+		return errors.Wrap(err, "couldn't remove the product from the wishlist")
+	}
+
+	cp := cart.Product{
+		ID:        p.ID,
+		VariantID: p.VariantID,
+		CartID:    zero.StringFrom(cartID),
+		Quantity:  p.Quantity,
+	}
+	if err := s.carts.AddTx(ctx, tx, cp); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	return s.touch(ctx, userID, wishlistID)
+}
+
+// Remove takes out a product from the wishlist.
+func (s *service) Remove(ctx context.Context, userID, wishlistID, productID, variantID string) error {
+	s.metrics.incMethodCalls("Remove")
+
+	if err := checkOwner(ctx, s.db, userID, wishlistID); err != nil {
+		return err
+	}
+
+	if err := removeProduct(ctx, s.db, wishlistID, productID, variantID); err != nil {
+		return err
+	}
+
+	return s.touch(ctx, userID, wishlistID)
+}
+
+// Rename changes the name of the wishlist.
+func (s *service) Rename(ctx context.Context, userID, wishlistID, name string) error {
+	s.metrics.incMethodCalls("Rename")
+
+	q := "UPDATE wishlists SET name=$3, updated_at=$4 WHERE id=$1 AND user_id=$2"
+	res, err := s.db.ExecContext(ctx, q, wishlistID, userID, name, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "couldn't rename the wishlist")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	if err := s.mc.Delete(cacheKey(userID, wishlistID)); err != nil && err != memcache.ErrCacheMiss {
+		return errors.Wrap(err, "deleting wishlist from cache")
+	}
+
+	return nil
+}
+
+// Share generates the token used to access the wishlist in read-only mode and returns it.
+//
+// If the list was already shared, the same token is returned.
+func (s *service) Share(ctx context.Context, userID, wishlistID string) (string, error) {
+	s.metrics.incMethodCalls("Share")
+
+	var shareToken string
+	q := `UPDATE wishlists SET share_token=COALESCE(share_token, $3)
+	WHERE id=$1 AND user_id=$2 RETURNING share_token`
+	err := s.db.GetContext(ctx, &shareToken, q, wishlistID, userID, token.RandString(shareTokenLength))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", errors.Wrap(err, "couldn't share the wishlist")
+	}
+
+	if err := s.mc.Delete(cacheKey(userID, wishlistID)); err != nil && err != memcache.ErrCacheMiss {
+		return "", errors.Wrap(err, "deleting wishlist from cache")
+	}
+
+	return shareToken, nil
+}
+
+// Unshare invalidates the read-only link of the wishlist.
+func (s *service) Unshare(ctx context.Context, userID, wishlistID string) error {
+	s.metrics.incMethodCalls("Unshare")
+
+	q := "UPDATE wishlists SET share_token=NULL WHERE id=$1 AND user_id=$2"
+	res, err := s.db.ExecContext(ctx, q, wishlistID, userID)
+	if err != nil {
+		return errors.Wrap(err, "couldn't unshare the wishlist")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	if err := s.mc.Delete(cacheKey(userID, wishlistID)); err != nil && err != memcache.ErrCacheMiss {
+		return errors.Wrap(err, "deleting wishlist from cache")
+	}
+
+	return nil
+}
+
+// touch updates the modification date of the wishlist and removes it from the cache.
+func (s *service) touch(ctx context.Context, userID, wishlistID string) error {
+	q := "UPDATE wishlists SET updated_at=$2 WHERE id=$1"
+	if _, err := s.db.ExecContext(ctx, q, wishlistID, time.Now()); err != nil {
+		return errors.Wrap(err, "updating wishlist")
+	}
+
+	if err := s.mc.Delete(cacheKey(userID, wishlistID)); err != nil && err != memcache.ErrCacheMiss {
+		return errors.Wrap(err, "deleting wishlist from cache")
+	}
+
+	return nil
+}
+
+// cacheKey returns the key of the wishlist in the cache, it includes the owner so only
+// they can get the list from it.
+func cacheKey(userID, wishlistID string) string {
+	return userID + ":" + wishlistID
+}
+
+func addProduct(ctx context.Context, db sqlx.ExecerContext, wishlistID string, p Product) error {
+	q := `INSERT INTO wishlist_products
+	(wishlist_id, id, variant_id, quantity, added_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (wishlist_id, id, variant_id)
+	DO UPDATE SET quantity=wishlist_products.quantity+EXCLUDED.quantity`
+	_, err := db.ExecContext(ctx, q, wishlistID, p.ID, p.VariantID.String, p.Quantity, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "couldn't add the product to the wishlist")
+	}
+
+	return nil
+}
+
+// checkOwner returns ErrNotFound if the wishlist doesn't belong to the user.
+func checkOwner(ctx context.Context, db sqlx.QueryerContext, userID, wishlistID string) error {
+	var exists bool
+	q := "SELECT EXISTS(SELECT 1 FROM wishlists WHERE id=$1 AND user_id=$2)"
+	if err := sqlx.GetContext(ctx, db, &exists, q, wishlistID, userID); err != nil {
+		return errors.Wrap(err, "couldn't find the wishlist")
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func getProducts(ctx context.Context, db sqlx.QueryerContext, wishlistID string) ([]Product, error) {
+	var products []Product
+	q := "SELECT * FROM wishlist_products WHERE wishlist_id=$1 ORDER BY added_at"
+	if err := sqlx.SelectContext(ctx, db, &products, q, wishlistID); err != nil {
+		return nil, errors.Wrap(err, "couldn't find the wishlist products")
+	}
+
+	return products, nil
+}
+
+func removeProduct(ctx context.Context, db sqlx.ExecerContext, wishlistID, productID, variantID string) error {
+	q := "DELETE FROM wishlist_products WHERE wishlist_id=$1 AND id=$2 AND variant_id=$3"
+	if _, err := db.ExecContext(ctx, q, wishlistID, productID, variantID); err != nil {
+		return errors.Wrap(err, "couldn't remove the product from the wishlist")
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package wishlist_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GGP1/adak/internal/config"
+	"github.com/GGP1/adak/internal/logger"
+	"github.com/GGP1/adak/internal/test"
+	"github.com/GGP1/adak/pkg/product"
+	"github.com/GGP1/adak/pkg/shop"
+	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
+	"github.com/GGP1/adak/pkg/shopping/wishlist"
+	"github.com/GGP1/adak/pkg/user"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+const (
+	cartID     = "wishlist_cart"
+	productID  = "wishlist_product"
+	userID     = "wishlist_user"
+	wishlistID = "wishlist"
+)
+
+func NewWishlistService(t *testing.T) (context.Context, wishlist.Service, cart.Service) {
+	t.Helper()
+	logger.Disable()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	db := test.StartPostgres(t)
+	mc := test.StartMemcached(t)
+	cartService := cart.NewService(db, mc, config.Cart{},
+		inventory.NewService(config.Inventory{}), promotion.NewService(db))
+	service := wishlist.NewService(db, mc, cartService)
+	createRelationship(ctx, t, db, mc, cartService)
+
+	t.Cleanup(func() {
+		cancel()
+	})
+
+	return ctx, service, cartService
+}
+
+func TestWishlistService(t *testing.T) {
+	ctx, s, cartService := NewWishlistService(t)
+
+	t.Run("Create", create(ctx, s))
+	t.Run("Add", add(ctx, s))
+	t.Run("Move to cart", moveToCart(ctx, s, cartService))
+	t.Run("Move from cart", moveFromCart(ctx, s, cartService))
+	t.Run("Share", share(ctx, s))
+	t.Run("Remove", remove(ctx, s))
+	t.Run("Delete", delete(ctx, s))
+}
+
+func add(ctx context.Context, s wishlist.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		p := wishlist.Product{ID: zero.StringFrom(productID), Quantity: zero.IntFrom(2)}
+		assert.NoError(t, s.Add(ctx, userID, wishlistID, p))
+
+		w, err := s.GetByID(ctx, userID, wishlistID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(w.Products))
+		assert.Equal(t, int64(2), w.Products[0].Quantity.Int64)
+
+		// Other users can't modify the list
+		err = s.Add(ctx, "other_user", wishlistID, p)
+		assert.True(t, errors.Is(err, wishlist.ErrNotFound))
+	}
+}
+
+func create(ctx context.Context, s wishlist.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		w := wishlist.Wishlist{
+			ID:        zero.StringFrom(wishlistID),
+			UserID:    zero.StringFrom(userID),
+			Name:      zero.StringFrom("Saved for later"),
+			CreatedAt: zero.TimeFrom(time.Now()),
+		}
+		assert.NoError(t, s.Create(ctx, w))
+
+		wishlists, err := s.Get(ctx, userID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(wishlists))
+	}
+}
+
+func delete(ctx context.Context, s wishlist.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		assert.NoError(t, s.Delete(ctx, userID, wishlistID))
+
+		_, err := s.GetByID(ctx, userID, wishlistID)
+		assert.True(t, errors.Is(err, wishlist.ErrNotFound))
+
+		err = s.Delete(ctx, userID, wishlistID)
+		assert.True(t, errors.Is(err, wishlist.ErrNotFound))
+	}
+}
+
+func moveFromCart(ctx context.Context, s wishlist.Service, cartService cart.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		assert.NoError(t, s.MoveFromCart(ctx, userID, wishlistID, cartID, productID, ""))
+
+		_, err := cartService.CartProduct(ctx, cartID, productID, "")
+		assert.Error(t, err)
+
+		w, err := s.GetByID(ctx, userID, wishlistID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(w.Products))
+		assert.Equal(t, int64(2), w.Products[0].Quantity.Int64)
+	}
+}
+
+func moveToCart(ctx context.Context, s wishlist.Service, cartService cart.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		assert.NoError(t, s.MoveToCart(ctx, userID, wishlistID, cartID, productID, ""))
+
+		p, err := cartService.CartProduct(ctx, cartID, productID, "")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), p.Quantity.Int64)
+
+		w, err := s.GetByID(ctx, userID, wishlistID)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(w.Products))
+
+		// The product is no longer in the wishlist
+		err = s.MoveToCart(ctx, userID, wishlistID, cartID, productID, "")
+		assert.True(t, errors.Is(err, wishlist.ErrNotFound))
+	}
+}
+
+func remove(ctx context.Context, s wishlist.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		assert.NoError(t, s.Remove(ctx, userID, wishlistID, productID, ""))
+
+		w, err := s.GetByID(ctx, userID, wishlistID)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(w.Products))
+	}
+}
+
+func share(ctx context.Context, s wishlist.Service) func(*testing.T) {
+	return func(t *testing.T) {
+		shareToken, err := s.Share(ctx, userID, wishlistID)
+		assert.NoError(t, err)
+
+		// Sharing twice keeps the same link
+		again, err := s.Share(ctx, userID, wishlistID)
+		assert.NoError(t, err)
+		assert.Equal(t, shareToken, again)
+
+		w, err := s.GetShared(ctx, shareToken)
+		assert.NoError(t, err)
+		assert.Equal(t, wishlistID, w.ID.String)
+		assert.Equal(t, "", w.UserID.String)
+
+		assert.NoError(t, s.Unshare(ctx, userID, wishlistID))
+		_, err = s.GetShared(ctx, shareToken)
+		assert.True(t, errors.Is(err, wishlist.ErrNotFound))
+
+		err = s.Unshare(ctx, "other_user", wishlistID)
+		assert.True(t, errors.Is(err, wishlist.ErrNotFound))
+	}
+}
+
+func createRelationship(ctx context.Context, t *testing.T, db *sqlx.DB, mc *memcache.Client, cartService cart.Service) {
+	t.Helper()
+
+	userService := user.NewService(db, mc)
+	err := userService.Create(ctx, user.AddUser{ID: userID})
+	assert.NoError(t, err)
+
+	shopService := shop.NewService(db, mc)
+	err = shopService.Create(ctx, shop.Shop{ID: "shop", Name: "test"})
+	assert.NoError(t, err)
+
+	productService := product.NewService(db, mc)
+	err = productService.Create(ctx, product.Product{
+		ID:       zero.StringFrom(productID),
+		ShopID:   zero.StringFrom("shop"),
+		Stock:    zero.IntFrom(10),
+		Brand:    zero.StringFrom("brand"),
+		Category: zero.StringFrom("category"),
+		Type:     zero.StringFrom("type"),
+		Weight:   zero.IntFrom(1),
+		Subtotal: zero.IntFrom(1000),
+		Total:    zero.IntFrom(1000),
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, cartService.Create(ctx, cartID))
+}
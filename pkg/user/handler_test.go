@@ -16,6 +16,8 @@ import (
 	"github.com/GGP1/adak/internal/test"
 	"github.com/GGP1/adak/pkg/auth"
 	"github.com/GGP1/adak/pkg/shopping/cart"
+	"github.com/GGP1/adak/pkg/shopping/inventory"
+	"github.com/GGP1/adak/pkg/shopping/promotion"
 	"github.com/GGP1/adak/pkg/user"
 	"github.com/google/uuid"
 
@@ -49,7 +51,7 @@ func TestMain(m *testing.M) {
 	}
 
 	userService = user.NewService(db, mc)
-	cartService = cart.NewService(db, mc)
+	cartService = cart.NewService(db, mc, config.Cart{}, inventory.NewService(config.Inventory{}), promotion.NewService(db))
 	handler = user.NewHandler(true, userService, cartService, email.Emailer{}, mc)
 
 	code := m.Run()
@@ -108,7 +110,7 @@ func TestDeleteHandler(t *testing.T) {
 
 	rdb := test.StartRedis(t)
 
-	session := auth.NewSession(nil, rdb, config.Session{}, true)
+	session := auth.NewSession(nil, rdb, cartService, config.Session{}, true)
 	mux := chi.NewRouter()
 	mux.Delete("/{id}", handler.Delete(session))
 